@@ -0,0 +1,123 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// ErrInvalidWeights is returned by ABPhase when the weights of its variants
+// are not valid.
+var ErrInvalidWeights = errors.New("invalid variant weights")
+
+// weightTolerance is how far the weights of an ABPhase may sum from 1.
+const weightTolerance = 1e-6
+
+// ABOption configures a phase returned by ABPhase.
+type ABOption func(a *abPhase)
+
+// WithABRand sets the function returning the random numbers in [0, 1)
+// selecting the variants, which defaults to rand.Float64. Calls are
+// serialized, so that it may use a seeded *rand.Rand.
+func WithABRand(random func() float64) ABOption {
+	return func(a *abPhase) {
+		a.random = random
+	}
+}
+
+// ABPhase returns a phase named name running one of variants on each input,
+// for experimenting with several implementations of a phase. Each run selects
+// a variant at random with probability its weight, and runs it with its hooks,
+// error handlers and other settings. The selected variant is passed to
+// recorder, when not nil, and recorded as the Case of the phase's result.
+//
+// Every variant must have a non-negative weight, and the weights must sum to
+// 1, otherwise ABPhase returns ErrInvalidWeights.
+func ABPhase(name string, variants map[string]*Phase, weights map[string]float64, recorder func(variant string), opts ...ABOption) (*Phase, error) {
+	a := &abPhase{variants: variants, recorder: recorder, random: rand.Float64}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("%w: phase %s has no variants", ErrInvalidWeights, name)
+	}
+	for key := range weights {
+		if _, ok := variants[key]; !ok {
+			return nil, fmt.Errorf("%w: phase %s has a weight for unknown variant %q", ErrInvalidWeights, name, key)
+		}
+	}
+	sum := 0.0
+	for _, key := range phaseKeys(variants) {
+		weight, ok := weights[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: variant %q of phase %s has no weight", ErrInvalidWeights, key, name)
+		}
+		if weight < 0 || math.IsNaN(weight) {
+			return nil, fmt.Errorf("%w: variant %q of phase %s has weight %v", ErrInvalidWeights, key, name, weight)
+		}
+		sum += weight
+		a.keys = append(a.keys, key)
+		a.cumulative = append(a.cumulative, sum)
+	}
+	if math.Abs(sum-1) > weightTolerance {
+		return nil, fmt.Errorf("%w: weights of phase %s sum to %v", ErrInvalidWeights, name, sum)
+	}
+
+	p := NewPhaseContext(name, func(ctx context.Context, value interface{}) (interface{}, error) {
+		key := a.pick()
+		state := runStateFrom(ctx)
+		state.trace.printf(name, "variant %q selected", key)
+		state.decided(name, key)
+		phaseResultFrom(ctx).Case = key
+		if a.recorder != nil {
+			a.recorder(key)
+		}
+		return a.variants[key].runStages(ctx, value)
+	})
+	// The variants acquire the concurrency limits themselves
+	p.nested = true
+	return p, nil
+}
+
+// abPhase selects the variants of a phase returned by ABPhase.
+type abPhase struct {
+	variants map[string]*Phase
+	recorder func(variant string)
+	// keys are the sorted keys of the variants, and cumulative the sums of
+	// their weights up to and including each of them
+	keys       []string
+	cumulative []float64
+
+	mu     sync.Mutex
+	random func() float64
+}
+
+// pick returns the key of the variant of a run.
+func (a *abPhase) pick() string {
+	a.mu.Lock()
+	r := a.random()
+	a.mu.Unlock()
+
+	// The weights may sum to slightly less than 1
+	r *= a.cumulative[len(a.cumulative)-1]
+	i := sort.SearchFloat64s(a.cumulative, r)
+	// SearchFloat64s finds the first sum >= r, while variants cover [start, end)
+	for i < len(a.cumulative)-1 && a.cumulative[i] <= r {
+		i++
+	}
+	return a.keys[i]
+}
+
+// phaseKeys returns the sorted keys of phases.
+func phaseKeys(phases map[string]*Phase) []string {
+	keys := make([]string, 0, len(phases))
+	for key := range phases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,76 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestABPhaseDistribution(t *testing.T) {
+	var recorded []string
+	p, err := ABPhase("score", map[string]*Phase{
+		"control":   NewPhase("control", addOne),
+		"candidate": NewPhase("candidate", func(value interface{}) (interface{}, error) { return value.(int) + 2, nil }),
+	}, map[string]float64{"control": 0.8, "candidate": 0.2}, func(variant string) {
+		recorded = append(recorded, variant)
+	}, WithABRand(rand.New(rand.NewSource(1)).Float64))
+	require.NoError(t, err)
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	const runs = 10000
+	counts := map[string]int{}
+	for i := 0; i < runs; i++ {
+		var report RunReport
+		value, err := m.RunContext(context.Background(), 0, WithReport(&report))
+		require.NoError(t, err)
+		variant := report.Phases[0].Case
+		counts[variant]++
+		require.Equal(t, variant, recorded[i])
+		if variant == "control" {
+			assert.Equal(t, 1, value)
+		} else {
+			assert.Equal(t, 2, value)
+		}
+	}
+	assert.Len(t, recorded, runs)
+	assert.InDelta(t, 0.8, float64(counts["control"])/runs, 0.02)
+	assert.InDelta(t, 0.2, float64(counts["candidate"])/runs, 0.02)
+}
+
+func TestABPhaseZeroWeight(t *testing.T) {
+	p, err := ABPhase("score", map[string]*Phase{
+		"a": NewPhase("a", addOne),
+		"b": NewPhase("b", failWith(assert.AnError)),
+	}, map[string]float64{"a": 1, "b": 0}, nil, WithABRand(func() float64 { return 0 }))
+	require.NoError(t, err)
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
+
+func TestABPhaseInvalidWeights(t *testing.T) {
+	variants := map[string]*Phase{"a": NewPhase("a", addOne), "b": NewPhase("b", addOne)}
+	for name, weights := range map[string]map[string]float64{
+		"sum":      {"a": 0.5, "b": 0.4},
+		"missing":  {"a": 1},
+		"unknown":  {"a": 0.5, "b": 0.25, "c": 0.25},
+		"negative": {"a": 1.5, "b": -0.5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ABPhase("score", variants, weights, nil)
+			assert.True(t, errors.Is(err, ErrInvalidWeights), err)
+		})
+	}
+
+	_, err := ABPhase("score", variants, map[string]float64{"a": 0.7, "b": 0.3000000001}, nil)
+	assert.NoError(t, err)
+}
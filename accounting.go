@@ -0,0 +1,125 @@
+package phaser
+
+import "runtime"
+
+// DefaultLeakThreshold is the goroutine delta above which phases are
+// suspected of leaking goroutines, unless set by WithLeakThreshold.
+const DefaultLeakThreshold = 10
+
+// ResourceUsage is the change in the process' resources measured around a
+// phase by WithResourceAccounting. The measures are process wide, so they
+// include the activity of everything running concurrently with the phase,
+// such as other runs and the garbage collector, and should be read as hints.
+type ResourceUsage struct {
+	// Goroutines is the change in the number of goroutines. Large positive
+	// deltas suggest the phase leaks goroutines
+	Goroutines int
+	// HeapAlloc is the change in the bytes of allocated heap objects, which
+	// collections during the phase may make negative
+	HeapAlloc int64
+	// TotalAlloc is the number of bytes allocated for heap objects
+	TotalAlloc uint64
+	// Mallocs is the number of heap objects allocated
+	Mallocs uint64
+}
+
+// LeakObserver is implemented by observers notified of the phases suspected
+// of leaking goroutines by runs using WithResourceAccounting.
+type LeakObserver interface {
+	// OnSuspectedLeak is called after the phase named phase ended with more
+	// goroutines than it started with, by more than the leak threshold
+	OnSuspectedLeak(phase string, usage ResourceUsage)
+}
+
+// WithResourceAccounting measures the goroutines and heap allocations of each
+// phase of the run, recording them as the Resources of their results in the
+// run's report. Observers implementing LeakObserver are notified of the
+// phases whose goroutine delta exceeds the leak threshold.
+//
+// Reading the memory statistics briefly stops the world before and after
+// every phase, so it is meant for investigating a pipeline rather than for
+// every run. Runs not using it do not measure anything.
+func WithResourceAccounting() RunOption {
+	return func(c *runConfig) {
+		c.ensureAccounting()
+	}
+}
+
+// WithPreciseAccounting enables WithResourceAccounting, running a garbage
+// collection before each measure so that HeapAlloc only counts the live heap.
+// It is slow, making each phase wait for two collections.
+func WithPreciseAccounting() RunOption {
+	return func(c *runConfig) {
+		c.ensureAccounting().precise = true
+	}
+}
+
+// WithLeakThreshold enables WithResourceAccounting, setting the goroutine
+// delta above which phases are suspected of leaking goroutines, which
+// defaults to DefaultLeakThreshold.
+func WithLeakThreshold(n int) RunOption {
+	return func(c *runConfig) {
+		c.ensureAccounting().threshold = n
+	}
+}
+
+// ensureAccounting returns the resource accounting of the run, enabling it.
+func (c *runConfig) ensureAccounting() *resourceAccounting {
+	if c.accounting == nil {
+		c.accounting = &resourceAccounting{threshold: DefaultLeakThreshold}
+	}
+	return c.accounting
+}
+
+// resourceAccounting measures the resources used by the phases of a run. A
+// nil *resourceAccounting measures nothing.
+type resourceAccounting struct {
+	precise   bool
+	threshold int
+}
+
+// resourceSample is a measure of the process' resources.
+type resourceSample struct {
+	goroutines int
+	memory     runtime.MemStats
+}
+
+// sample measures the process' resources, returning nil when a is nil.
+func (a *resourceAccounting) sample() *resourceSample {
+	if a == nil {
+		return nil
+	}
+	if a.precise {
+		runtime.GC()
+	}
+	s := &resourceSample{}
+	runtime.ReadMemStats(&s.memory)
+	s.goroutines = runtime.NumGoroutine()
+	return s
+}
+
+// account records the resources used by the phase of result since before
+// was sampled, notifying the run's observers when the phase is suspected of
+// leaking goroutines.
+func (s *runState) account(result *PhaseResult, before *resourceSample) {
+	if before == nil {
+		return
+	}
+	after := s.accounting.sample()
+	usage := &ResourceUsage{
+		Goroutines: after.goroutines - before.goroutines,
+		HeapAlloc:  int64(after.memory.HeapAlloc) - int64(before.memory.HeapAlloc),
+		TotalAlloc: after.memory.TotalAlloc - before.memory.TotalAlloc,
+		Mallocs:    after.memory.Mallocs - before.memory.Mallocs,
+	}
+	result.Resources = usage
+	if usage.Goroutines <= s.accounting.threshold {
+		return
+	}
+	s.trace.printf(result.Phase, "suspected goroutine leak: %d goroutines started", usage.Goroutines)
+	for _, o := range s.observers {
+		if lo, ok := o.(LeakObserver); ok {
+			lo.OnSuspectedLeak(result.Phase, *usage)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package phaser
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leakObserver records the usage of the phases it is notified as leaking.
+type leakObserver map[string]ResourceUsage
+
+func (o leakObserver) OnPhaseStart(phase string, value interface{}) {}
+func (o leakObserver) OnPhaseEnd(result PhaseResult)                {}
+func (o leakObserver) OnSuspectedLeak(phase string, usage ResourceUsage) {
+	o[phase] = usage
+}
+
+func TestResourceAccountingFlagsLeaks(t *testing.T) {
+	const leaked = 20
+	release := make(chan struct{})
+	defer close(release)
+
+	observer := leakObserver{}
+	m := NewPhaseManager(WithObserver(observer))
+	require.NoError(t, m.AddPhases(
+		NewPhase("clean", addOne),
+		NewPhase("leaky", func(value interface{}) (interface{}, error) {
+			for i := 0; i < leaked; i++ {
+				go func() { <-release }()
+			}
+			return value, nil
+		}),
+	))
+
+	var report RunReport
+	_, err := m.RunContext(context.Background(), 0, WithReport(&report), WithResourceAccounting())
+	require.NoError(t, err)
+	require.Len(t, report.Phases, 2)
+	require.NotNil(t, report.Phases[1].Resources)
+	assert.GreaterOrEqual(t, report.Phases[1].Resources.Goroutines, leaked)
+	assert.Contains(t, observer, "leaky")
+	assert.NotContains(t, observer, "clean")
+}
+
+func TestPreciseResourceAccounting(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("clean", addOne)))
+
+	var report RunReport
+	_, err := m.RunContext(context.Background(), 0, WithReport(&report), WithPreciseAccounting())
+	require.NoError(t, err)
+	usage := report.Phases[0].Resources
+	require.NotNil(t, usage)
+	assert.InDelta(t, 0, usage.Goroutines, 2)
+	assert.InDelta(t, 0, usage.HeapAlloc, 64<<10)
+}
+
+func TestResourceAccountingThreshold(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	observer := leakObserver{}
+	m := NewPhaseManager(WithObserver(observer))
+	require.NoError(t, m.AddPhase(NewPhase("leaky", func(value interface{}) (interface{}, error) {
+		go func() { <-release }()
+		return value, nil
+	})))
+
+	_, err := m.RunContext(context.Background(), 0, WithLeakThreshold(5))
+	require.NoError(t, err)
+	assert.Empty(t, observer)
+}
+
+func TestResourceAccountingDisabled(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("clean", addOne)))
+
+	var report RunReport
+	_, err := m.RunContext(context.Background(), 0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Nil(t, report.Phases[0].Resources)
+}
@@ -0,0 +1,123 @@
+package phaser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ArtifactsTruncated is the key of the artifact recording the number of
+// artifacts dropped by a phase exceeding its artifact limit.
+const ArtifactsTruncated = "phaser.truncated"
+
+// Artifacts contains the diagnostic artifacts attached to a phase's result
+// through AddArtifact, keyed by name.
+type Artifacts map[string]interface{}
+
+// MarshalJSON marshals the artifacts, replacing the values that cannot be
+// marshaled by a string describing them.
+func (a Artifacts) MarshalJSON() ([]byte, error) {
+	values := make(map[string]json.RawMessage, len(a))
+	for name, value := range a {
+		data, err := json.Marshal(value)
+		if err != nil {
+			data, _ = json.Marshal(fmt.Sprintf("<unmarshalable %T: %v>", value, err))
+		}
+		values[name] = data
+	}
+	return json.Marshal(values)
+}
+
+// WithArtifactLimit limits the artifacts attached by each phase to count
+// artifacts of size bytes in total, measured as their JSON encoding.
+// Artifacts over the limit are dropped, and counted by the ArtifactsTruncated
+// artifact. Non-positive values disable the corresponding limit.
+func WithArtifactLimit(count, size int) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.artifactCount, m.artifactSize = count, size
+	}
+}
+
+// AddArtifact attaches value to the result of the phase running with ctx
+// under name, replacing any artifact previously attached under the same name.
+// It is meant to be called from context hooks and execute functions using
+// the context they receive, and may be called concurrently. Artifacts added
+// outside of a run, or after the phase ended, are dropped.
+func AddArtifact(ctx context.Context, name string, value interface{}) {
+	runStateFrom(ctx).artifacts.add(phaseResultFrom(ctx), name, value)
+}
+
+// artifactStore collects the artifacts of the phases of a run. A nil
+// *artifactStore drops every artifact.
+type artifactStore struct {
+	// count and size are the limits of the artifacts of each phase
+	count, size int
+
+	mu     sync.Mutex
+	phases map[*PhaseResult]*phaseArtifacts
+}
+
+// phaseArtifacts contains the artifacts of a phase.
+type phaseArtifacts struct {
+	artifacts Artifacts
+	// sizes contains the size of each artifact, and size their total
+	sizes map[string]int
+	size  int
+	// dropped counts the artifacts dropped for exceeding the limits
+	dropped int
+}
+
+// newArtifactStore returns a store limiting the artifacts of each phase to
+// count artifacts of size bytes.
+func newArtifactStore(count, size int) *artifactStore {
+	return &artifactStore{count: count, size: size, phases: make(map[*PhaseResult]*phaseArtifacts)}
+}
+
+// add attaches the artifact named name to the phase whose result is result.
+func (s *artifactStore) add(result *PhaseResult, name string, value interface{}) {
+	if s == nil {
+		return
+	}
+	size := 0
+	if s.size > 0 {
+		data, _ := json.Marshal(Artifacts{name: value})
+		size = len(data)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	phase, ok := s.phases[result]
+	if !ok {
+		phase = &phaseArtifacts{artifacts: Artifacts{}, sizes: map[string]int{}}
+		s.phases[result] = phase
+	}
+	_, replaced := phase.artifacts[name]
+	total := phase.size - phase.sizes[name] + size
+	if (s.count > 0 && !replaced && len(phase.artifacts) >= s.count) || (s.size > 0 && total > s.size) {
+		phase.dropped++
+		return
+	}
+	phase.artifacts[name] = value
+	phase.sizes[name], phase.size = size, total
+}
+
+// take returns the artifacts of the phase whose result is result, which
+// stops collecting them.
+func (s *artifactStore) take(result *PhaseResult) Artifacts {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	phase, ok := s.phases[result]
+	if !ok {
+		return nil
+	}
+	// Later artifacts are dropped, as the phase is no longer tracked
+	delete(s.phases, result)
+	if phase.dropped > 0 {
+		phase.artifacts[ArtifactsTruncated] = phase.dropped
+	}
+	return phase.artifacts
+}
@@ -0,0 +1,101 @@
+package phaser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddArtifact(t *testing.T) {
+	p := NewPhaseContext("query", func(ctx context.Context, value interface{}) (interface{}, error) {
+		AddArtifact(ctx, "sql", "SELECT 1")
+		return value, nil
+	})
+	p.AppendPostHookContext(func(ctx context.Context, value interface{}) (interface{}, error) {
+		AddArtifact(ctx, "url", "https://example.com/file")
+		return value, assert.AnError
+	})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(NewPhase("one", addOne), p))
+
+	var report RunReport
+	_, err := m.Run(0, WithReport(&report))
+	require.Error(t, err)
+	assert.Nil(t, report.Phases[0].Artifacts)
+	assert.Equal(t, Artifacts{"sql": "SELECT 1", "url": "https://example.com/file"}, report.Phases[1].Artifacts)
+
+	// Artifacts added outside of runs are dropped
+	_, err = p.run(0)
+	assert.Error(t, err)
+}
+
+func TestArtifactLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		count, size int
+		want        Artifacts
+	}{
+		{name: "count", count: 2, want: Artifacts{"a": "1", "b": "22", ArtifactsTruncated: 1}},
+		// The JSON encoding of each artifact is 9 or 10 bytes long
+		{name: "size", size: 20, want: Artifacts{"a": "1", "b": "22", ArtifactsTruncated: 1}},
+		{name: "unlimited", want: Artifacts{"a": "1", "b": "22", "c": "333"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewPhaseManager(WithArtifactLimit(tt.count, tt.size))
+			require.NoError(t, m.AddPhase(NewPhaseContext("one", func(ctx context.Context, value interface{}) (interface{}, error) {
+				AddArtifact(ctx, "a", "1")
+				AddArtifact(ctx, "b", "2")
+				// Replacing an artifact does not count as a new one
+				AddArtifact(ctx, "b", "22")
+				AddArtifact(ctx, "c", "333")
+				return value, nil
+			})))
+
+			var report RunReport
+			_, err := m.Run(0, WithReport(&report))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, report.Phases[0].Artifacts)
+		})
+	}
+}
+
+func TestArtifactsMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Artifacts{"diff": "+1", "callback": func() {}})
+	require.NoError(t, err)
+
+	var artifacts map[string]string
+	require.NoError(t, json.Unmarshal(data, &artifacts))
+	assert.Equal(t, "+1", artifacts["diff"])
+	assert.Contains(t, artifacts["callback"], "<unmarshalable func()")
+}
+
+func TestAddArtifactConcurrently(t *testing.T) {
+	const workers = 20
+	m := NewPhaseManager(WithArtifactLimit(workers/2, 0))
+	require.NoError(t, m.AddPhase(NewPhaseContext("map", func(ctx context.Context, value interface{}) (interface{}, error) {
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				AddArtifact(ctx, fmt.Sprintf("worker-%d", i), i)
+			}(i)
+		}
+		wg.Wait()
+		return value, nil
+	})))
+
+	var report RunReport
+	_, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	artifacts := report.Phases[0].Artifacts
+	assert.Len(t, artifacts, workers/2+1)
+	assert.Equal(t, workers/2, artifacts[ArtifactsTruncated])
+}
@@ -0,0 +1,106 @@
+package phaser
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditRecord describes what a phase received and produced in a run.
+type AuditRecord struct {
+	// Phase is the name of the phase
+	Phase string `json:"phase"`
+	// Timestamp is the time the phase started running
+	Timestamp time.Time `json:"timestamp"`
+	// Input is the JSON encoding of the phase's input. Values serialized by
+	// a Codec other than JSONCodec are held as base64 JSON strings
+	Input json.RawMessage `json:"inputJSON,omitempty"`
+	// Output is the encoding of the phase's output, as for Input. It is empty
+	// when the phase failed
+	Output json.RawMessage `json:"outputJSON,omitempty"`
+	// Error is the error returned by the phase, if any
+	Error string `json:"error,omitempty"`
+	// MarshalError describes why the input or output could not be encoded,
+	// in which case they are left empty
+	MarshalError string `json:"marshalError,omitempty"`
+}
+
+// AuditSink receives the audit records of the phases of every run. It is
+// called synchronously, and concurrently by concurrent runs.
+type AuditSink interface {
+	// Audit stores record. Runs fail when it returns an error
+	Audit(record AuditRecord) error
+}
+
+// AuditOption configures the audit trail of a manager.
+type AuditOption func(a *auditLog)
+
+// WithAuditRedactor sets a function redacting the values of the phase named
+// phase before they are encoded, for example by replacing sensitive fields.
+// It must not modify value in place, as value is used by the run.
+func WithAuditRedactor(redact func(phase string, value interface{}) interface{}) AuditOption {
+	return func(a *auditLog) {
+		a.redact = redact
+	}
+}
+
+// WithAuditSink sends an audit record to sink for every phase that runs,
+// holding the encoding of its input and output by the phase's codec, JSON by
+// default.
+func WithAuditSink(sink AuditSink, opts ...AuditOption) ManagerOption {
+	a := &auditLog{sink: sink}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return func(m *DefaultPhaseManager) {
+		m.audit = a
+	}
+}
+
+// auditLog writes the audit records of a manager. A nil *auditLog writes
+// nothing.
+type auditLog struct {
+	sink   AuditSink
+	redact func(phase string, value interface{}) interface{}
+}
+
+// write sends the record of the phase named phase, which started at start and
+// turned input into output or failed with err, encoding the values using
+// codec, or JSON when nil.
+func (a *auditLog) write(phase string, codec Codec, start time.Time, input, output interface{}, err error) error {
+	if a == nil {
+		return nil
+	}
+	record := AuditRecord{Phase: phase, Timestamp: start}
+	var marshalErr error
+	if record.Input, marshalErr = a.marshal(phase, codec, input); marshalErr != nil {
+		record.MarshalError = fmt.Sprintf("encoding input: %v", marshalErr)
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else if record.Output, marshalErr = a.marshal(phase, codec, output); marshalErr != nil && record.MarshalError == "" {
+		record.MarshalError = fmt.Sprintf("encoding output: %v", marshalErr)
+	}
+
+	if err := a.sink.Audit(record); err != nil {
+		return fmt.Errorf("writing audit record for phase %s: %w", phase, err)
+	}
+	return nil
+}
+
+// marshal returns the encoding of value using codec after redacting it.
+// Encodings other than JSON are returned as JSON strings holding their base64
+// encoding.
+func (a *auditLog) marshal(phase string, codec Codec, value interface{}) (json.RawMessage, error) {
+	if a.redact != nil {
+		value = a.redact(phase, value)
+	}
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	data, err := codec.Marshal(value)
+	if _, ok := codec.(JSONCodec); ok || err != nil {
+		return data, err
+	}
+	return json.Marshal(data)
+}
@@ -0,0 +1,103 @@
+package phaser
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingSink is an AuditSink keeping the records it receives.
+type capturingSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+	err     error
+}
+
+func (s *capturingSink) Audit(record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func TestAuditSink(t *testing.T) {
+	sink := &capturingSink{}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := NewPhaseManager(WithAuditSink(sink))
+	m.clock = &testClock{now: start}
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("disabled", addOne),
+		NewPhase("two", addOne, WithNonCritical()),
+		NewPhase("three", failWith(assert.AnError), WithNonCritical()),
+	))
+	m.phases[1].Disabled = true
+
+	_, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, []AuditRecord{
+		{Phase: "one", Timestamp: start, Input: json.RawMessage("0"), Output: json.RawMessage("1")},
+		{Phase: "two", Timestamp: start, Input: json.RawMessage("1"), Output: json.RawMessage("2")},
+		{Phase: "three", Timestamp: start, Input: json.RawMessage("2"), Error: assert.AnError.Error()},
+	}, sink.records)
+
+	encoded, err := json.Marshal(sink.records[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"phase":"one","timestamp":"2024-01-02T03:04:05Z","inputJSON":0,"outputJSON":1}`, string(encoded))
+}
+
+func TestAuditSinkMarshalError(t *testing.T) {
+	sink := &capturingSink{}
+	m := NewPhaseManager(WithAuditSink(sink))
+	require.NoError(t, m.AddPhase(NewPhase("channel", func(value interface{}) (interface{}, error) {
+		return make(chan int), nil
+	})))
+
+	_, err := m.Run(1)
+	require.NoError(t, err)
+	require.Len(t, sink.records, 1)
+	assert.Equal(t, json.RawMessage("1"), sink.records[0].Input)
+	assert.Empty(t, sink.records[0].Output)
+	assert.Contains(t, sink.records[0].MarshalError, "encoding output")
+}
+
+func TestAuditSinkRedactor(t *testing.T) {
+	type user struct {
+		Name     string
+		Password string
+	}
+	sink := &capturingSink{}
+	m := NewPhaseManager(WithAuditSink(sink, WithAuditRedactor(func(phase string, value interface{}) interface{} {
+		u := value.(user)
+		u.Password = "REDACTED"
+		return u
+	})))
+	require.NoError(t, m.AddPhase(NewPhase("login", func(value interface{}) (interface{}, error) {
+		return value, nil
+	})))
+
+	output, err := m.Run(user{Name: "ada", Password: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, user{Name: "ada", Password: "secret"}, output)
+	require.Len(t, sink.records, 1)
+	assert.JSONEq(t, `{"Name":"ada","Password":"REDACTED"}`, string(sink.records[0].Input))
+	assert.JSONEq(t, `{"Name":"ada","Password":"REDACTED"}`, string(sink.records[0].Output))
+}
+
+func TestAuditSinkError(t *testing.T) {
+	sink := &capturingSink{err: assert.AnError}
+	calls := 0
+	m := NewPhaseManager(WithAuditSink(sink))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		flakyPhase("two", 0, &calls),
+	))
+
+	_, err := m.Run(0)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 0, calls)
+}
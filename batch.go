@@ -0,0 +1,64 @@
+package phaser
+
+import "sync"
+
+// BatchOption configures a RunEach call.
+type BatchOption func(c *batchConfig)
+
+// batchConfig contains the settings of a RunEach call.
+type batchConfig struct {
+	workers int
+	// summary is filled with the summary of the runs when set
+	summary *BatchSummary
+	// classify returns the class of the errors of the summary
+	classify func(error) string
+	// samples is the number of sample errors kept per class
+	samples int
+}
+
+// WithWorkerPoolSize runs the values of a RunEach call on a pool of n
+// goroutines, bounding the number of concurrent runs. Non-positive values run
+// every value concurrently.
+func WithWorkerPoolSize(n int) BatchOption {
+	return func(c *batchConfig) {
+		c.workers = n
+	}
+}
+
+// RunEach runs the pipeline concurrently on each of values. The output and
+// error of each run are returned at the index of its value, so that failed
+// runs do not stop the others.
+func (m *DefaultPhaseManager) RunEach(values []interface{}, opts ...BatchOption) ([]interface{}, []error) {
+	c := &batchConfig{classify: ClassifyError, samples: defaultErrorSamples}
+	for _, opt := range opts {
+		opt(c)
+	}
+	outputs := make([]interface{}, len(values))
+	errs := make([]error, len(values))
+
+	workers := c.workers
+	if workers <= 0 || workers > len(values) {
+		workers = len(values)
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				outputs[i], errs[i] = m.Run(values[i])
+			}
+		}()
+	}
+	for i := range values {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if c.summary != nil {
+		*c.summary = summarize(errs, c.classify, c.samples)
+	}
+	return outputs, errs
+}
@@ -0,0 +1,53 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunEach(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("half", func(value interface{}) (interface{}, error) {
+		if value.(int)%2 != 0 {
+			return nil, assert.AnError
+		}
+		return value.(int) / 2, nil
+	})))
+
+	outputs, errs := m.RunEach([]interface{}{2, 3, 4})
+	assert.Equal(t, []interface{}{1, 3, 2}, outputs)
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], assert.AnError)
+	assert.NoError(t, errs[2])
+}
+
+func TestRunEachWorkerPool(t *testing.T) {
+	var h highWaterMark
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("limited", h.execute),
+		NewPhase("double", func(value interface{}) (interface{}, error) {
+			return value.(int) * 2, nil
+		}),
+	))
+
+	values := make([]interface{}, 100)
+	for i := range values {
+		values[i] = i
+	}
+	outputs, errs := m.RunEach(values, WithWorkerPoolSize(4))
+	for i, output := range outputs {
+		assert.Equal(t, i*2, output)
+		assert.NoError(t, errs[i])
+	}
+	assert.LessOrEqual(t, h.max, int64(4))
+}
+
+func TestRunEachEmpty(t *testing.T) {
+	outputs, errs := NewPhaseManager().RunEach(nil, WithWorkerPoolSize(4))
+	assert.Empty(t, outputs)
+	assert.Empty(t, errs)
+}
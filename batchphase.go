@@ -0,0 +1,160 @@
+package phaser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchPhase is a phase accumulating the inputs of concurrent runs to process
+// them together in a single call, such as a bulk write. Add its Phase to a
+// manager to run it.
+type BatchPhase struct {
+	// Phase is the phase to add to managers. Its execute function waits for
+	// the batch holding its input to be processed, and returns the result at
+	// the input's position
+	*Phase
+	size     int
+	interval time.Duration
+	process  func(inputs []interface{}) ([]interface{}, error)
+
+	mu sync.Mutex
+	// pending contains the inputs waiting for the next batch, in order
+	pending []*batchItem
+	// cancelFlush is closed to cancel the flush of the pending inputs once
+	// the interval elapses
+	cancelFlush chan struct{}
+	// stopped is set once a manager running the phase shuts down, after
+	// which inputs are processed one at a time
+	stopped bool
+}
+
+// batchItem is an input waiting in a batch.
+type batchItem struct {
+	value interface{}
+	done  chan batchOutcome
+}
+
+// batchOutcome is the result of an input of a batch.
+type batchOutcome struct {
+	value interface{}
+	err   error
+}
+
+// NewBatchPhase returns a phase named name processing the inputs of
+// concurrent runs in batches using process, configured with opts. A batch is
+// processed once size inputs are waiting, or interval after its first input,
+// whichever comes first, according to the clock of the run adding the first
+// input. Non-positive intervals wait for full batches, or a call to Flush.
+//
+// process returns a result per input, in the order of the inputs. Its errors,
+// and results of the wrong length, fail every run of the batch. Runs whose
+// context is done stop waiting, but their input is still processed. Once a
+// manager running the phase shuts down, the waiting inputs are flushed and
+// the next ones are processed without waiting, for every manager sharing
+// the phase.
+func NewBatchPhase(name string, size int, interval time.Duration, process func(inputs []interface{}) ([]interface{}, error), opts ...PhaseOption) *BatchPhase {
+	if size < 1 {
+		size = 1
+	}
+	b := &BatchPhase{size: size, interval: interval, process: process}
+	b.Phase = NewPhaseContext(name, b.execute, opts...)
+	b.Phase.onShutdown = b.stop
+	return b
+}
+
+// Flush processes the waiting inputs without waiting for the batch to fill,
+// such as on shutdown. It returns once they are processed.
+func (b *BatchPhase) Flush() {
+	b.mu.Lock()
+	items := b.take()
+	b.mu.Unlock()
+	b.run(items)
+}
+
+// stop flushes the waiting inputs, and stops batching the next ones, as a
+// manager running the phase shuts down.
+func (b *BatchPhase) stop() {
+	b.mu.Lock()
+	b.stopped = true
+	items := b.take()
+	b.mu.Unlock()
+	b.run(items)
+}
+
+// execute adds value to the pending batch and waits for its result.
+func (b *BatchPhase) execute(ctx context.Context, value interface{}) (interface{}, error) {
+	item := &batchItem{value: value, done: make(chan batchOutcome, 1)}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, item)
+	var full []*batchItem
+	if len(b.pending) >= b.size || b.stopped {
+		full = b.take()
+	} else if len(b.pending) == 1 && b.interval > 0 {
+		b.cancelFlush = make(chan struct{})
+		go b.flushAfter(ClockFrom(ctx).After(b.interval), b.cancelFlush)
+	}
+	b.mu.Unlock()
+	// The run completing the batch processes it
+	b.run(full)
+
+	select {
+	case outcome := <-item.done:
+		return outcome.value, outcome.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flushAfter processes the pending inputs once after fires, unless cancel is
+// closed first.
+func (b *BatchPhase) flushAfter(after <-chan time.Time, cancel chan struct{}) {
+	select {
+	case <-after:
+	case <-cancel:
+		return
+	}
+	b.mu.Lock()
+	var items []*batchItem
+	if b.cancelFlush == cancel {
+		items = b.take()
+	}
+	b.mu.Unlock()
+	b.run(items)
+}
+
+// take removes the pending inputs, cancelling their flush. b.mu must be held.
+func (b *BatchPhase) take() []*batchItem {
+	items := b.pending
+	b.pending = nil
+	if b.cancelFlush != nil {
+		close(b.cancelFlush)
+		b.cancelFlush = nil
+	}
+	return items
+}
+
+// run processes the inputs of items, sending each its result.
+func (b *BatchPhase) run(items []*batchItem) {
+	if len(items) == 0 {
+		return
+	}
+	inputs := make([]interface{}, len(items))
+	for i, item := range items {
+		inputs[i] = item.value
+	}
+
+	outputs, err := b.process(inputs)
+	if err == nil && len(outputs) != len(inputs) {
+		err = fmt.Errorf("batch of %d inputs returned %d results", len(inputs), len(outputs))
+	}
+	for i, item := range items {
+		if err != nil {
+			item.done <- batchOutcome{err: err}
+		} else {
+			item.done <- batchOutcome{value: outputs[i]}
+		}
+	}
+}
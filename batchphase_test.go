@@ -0,0 +1,209 @@
+package phaser
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doubleBatch doubles every input of a batch, recording the batch sizes.
+type doubleBatch struct {
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (d *doubleBatch) process(inputs []interface{}) ([]interface{}, error) {
+	d.mu.Lock()
+	d.sizes = append(d.sizes, len(inputs))
+	d.mu.Unlock()
+	outputs := make([]interface{}, len(inputs))
+	for i, input := range inputs {
+		outputs[i] = input.(int) * 2
+	}
+	return outputs, nil
+}
+
+// manualClock is a Clock whose time only moves when advanced, firing the
+// channels returned by After once their time comes.
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+// manualWaiter is a channel returned by After, firing at at.
+type manualWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, manualWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// pending returns the number of channels waiting to fire.
+func (c *manualClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// advance moves the clock by d, firing the channels whose time came.
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = waiters
+}
+
+func TestBatchPhaseDemultiplexes(t *testing.T) {
+	d := &doubleBatch{}
+	b := NewBatchPhase("write", 4, 0, d.process)
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(b.Phase))
+
+	outputs, errs := m.RunEach(batchValues(8))
+	for i, err := range errs {
+		require.NoError(t, err)
+		assert.Equal(t, (i+1)*2, outputs[i])
+	}
+	assert.Equal(t, []int{4, 4}, d.sizes)
+}
+
+func TestBatchPhaseFlush(t *testing.T) {
+	t.Run("interval", func(t *testing.T) {
+		d := &doubleBatch{}
+		b := NewBatchPhase("write", 10, 10*time.Millisecond, d.process)
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(b.Phase))
+
+		outputs, errs := m.RunEach(batchValues(3))
+		assert.Equal(t, []error{nil, nil, nil}, errs)
+		assert.Equal(t, []interface{}{2, 4, 6}, outputs)
+		assert.Equal(t, []int{3}, d.sizes)
+	})
+
+	t.Run("explicit", func(t *testing.T) {
+		d := &doubleBatch{}
+		b := NewBatchPhase("write", 10, 0, d.process)
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(b.Phase))
+
+		done := make(chan interface{})
+		go func() {
+			output, _ := m.Run(5)
+			done <- output
+		}()
+		// The run waits for a full batch until flushed
+		require.Eventually(t, func() bool {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return len(b.pending) == 1
+		}, time.Second, time.Millisecond)
+		b.Flush()
+		assert.Equal(t, 10, <-done)
+		assert.Equal(t, []int{1}, d.sizes)
+
+		// Flushing nothing does not call process
+		b.Flush()
+		assert.Equal(t, []int{1}, d.sizes)
+	})
+}
+
+func TestBatchPhaseErrors(t *testing.T) {
+	t.Run("process error", func(t *testing.T) {
+		b := NewBatchPhase("write", 2, 0, func(inputs []interface{}) ([]interface{}, error) {
+			return nil, assert.AnError
+		})
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(b.Phase))
+
+		_, errs := m.RunEach(batchValues(2))
+		for _, err := range errs {
+			assert.ErrorIs(t, err, assert.AnError)
+		}
+	})
+
+	t.Run("result count", func(t *testing.T) {
+		b := NewBatchPhase("write", 2, 0, func(inputs []interface{}) ([]interface{}, error) {
+			return inputs[:1], nil
+		})
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(b.Phase))
+
+		_, errs := m.RunEach(batchValues(2))
+		for _, err := range errs {
+			assert.EqualError(t, err, "phase write: batch of 2 inputs returned 1 results")
+		}
+	})
+
+	t.Run("cancelled run", func(t *testing.T) {
+		d := &doubleBatch{}
+		b := NewBatchPhase("write", 2, 0, d.process)
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(b.Phase))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := m.RunContext(ctx, 1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// The input of the cancelled run is still processed
+		b.Flush()
+		assert.Equal(t, []int{1}, d.sizes)
+	})
+}
+
+func TestBatchPhaseIntervalClock(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	d := &doubleBatch{}
+	b := NewBatchPhase("write", 10, time.Second, d.process)
+	m := NewPhaseManager(WithClock(clock))
+	require.NoError(t, m.AddPhase(b.Phase))
+
+	done := make(chan []interface{})
+	go func() {
+		outputs, _ := m.RunEach(batchValues(3))
+		done <- outputs
+	}()
+	require.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.pending) == 3 && clock.pending() == 1
+	}, time.Second, time.Millisecond)
+
+	// The batch is flushed once the interval elapsed on the run's clock
+	clock.advance(999 * time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	b.mu.Lock()
+	assert.Len(t, b.pending, 3)
+	b.mu.Unlock()
+	clock.advance(time.Millisecond)
+	assert.Equal(t, []interface{}{2, 4, 6}, <-done)
+	assert.Equal(t, []int{3}, d.sizes)
+}
@@ -0,0 +1,56 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultBranch is the key of the branch taken when a branch selector returns
+// a key without a branch.
+const DefaultBranch = "default"
+
+// ErrUnknownBranch is returned when a branch selector returns a key without a
+// branch and there is no DefaultBranch.
+var ErrUnknownBranch = errors.New("unknown branch")
+
+// BranchSelector returns the key of the branch that should process value.
+type BranchSelector func(value interface{}) (string, error)
+
+// AddBranch adds a branch point named name. When the run reaches it, selector
+// picks which of the branches runs with the current value, and the output of
+// the branch is passed on to the phase following the branch point. Branches
+// run as part of the current run, so they may contain branches themselves.
+func (m *DefaultPhaseManager) AddBranch(name string, selector BranchSelector, branches map[string]*DefaultPhaseManager) error {
+	p := &Phase{Name: name, branches: branches, nested: true}
+	p.executeContext = func(ctx context.Context, value interface{}) (interface{}, error) {
+		key, err := selector(value)
+		if err != nil {
+			return nil, err
+		}
+		branch, ok := branches[key]
+		if !ok {
+			if branch, ok = branches[DefaultBranch]; !ok {
+				return nil, fmt.Errorf("%w %q, available branches are %v", ErrUnknownBranch, key, branchKeys(branches))
+			}
+			key = DefaultBranch
+		}
+		state := runStateFrom(ctx)
+		state.trace.printf(name, "branch %q selected", key)
+		state.decided(name, key)
+		phaseResultFrom(ctx).Case = key
+		return branch.runFrom(ctx, 0, value)
+	}
+	return m.AddPhase(p)
+}
+
+// branchKeys returns the sorted keys of branches.
+func branchKeys(branches map[string]*DefaultPhaseManager) []string {
+	keys := make([]string, 0, len(branches))
+	for key := range branches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,149 @@
+package phaser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// appendPhase returns a phase named name appending suffix to a string value.
+func appendPhase(name, suffix string) *Phase {
+	return NewPhase(name, func(value interface{}) (interface{}, error) {
+		return value.(string) + suffix, nil
+	})
+}
+
+// branchManager returns a manager whose phases append each of the suffixes.
+func branchManager(t *testing.T, suffixes ...string) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	for _, suffix := range suffixes {
+		require.NoError(t, m.AddPhase(appendPhase(suffix, suffix)))
+	}
+	return m
+}
+
+// classify selects the branch named as the first character of a string value.
+func classify(value interface{}) (string, error) {
+	return value.(string)[:1], nil
+}
+
+func TestBranchSelectsPath(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(appendPhase("start", "-start")))
+	require.NoError(t, m.AddBranch("classify", classify, map[string]*DefaultPhaseManager{
+		"i": branchManager(t, "-resize", "-thumbnail"),
+		"p": branchManager(t, "-ocr"),
+	}))
+	require.NoError(t, m.AddPhase(appendPhase("end", "-end")))
+
+	value, err := m.Run("image")
+	require.NoError(t, err)
+	assert.Equal(t, "image-start-resize-thumbnail-end", value)
+
+	value, err = m.Run("pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "pdf-start-ocr-end", value)
+}
+
+func TestBranchUnknownKey(t *testing.T) {
+	branches := map[string]*DefaultPhaseManager{
+		"i": branchManager(t, "-resize"),
+		"p": branchManager(t, "-ocr"),
+	}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddBranch("classify", classify, branches))
+
+	_, err := m.Run("text")
+	assert.ErrorIs(t, err, ErrUnknownBranch)
+	assert.EqualError(t, err, `phase classify: unknown branch "t", available branches are [i p]`)
+
+	// Unknown keys take the default branch when there is one
+	branches[DefaultBranch] = branchManager(t, "-raw")
+	value, err := m.Run("text")
+	require.NoError(t, err)
+	assert.Equal(t, "text-raw", value)
+}
+
+func TestBranchNested(t *testing.T) {
+	inner := NewPhaseManager()
+	require.NoError(t, inner.AddBranch("second", func(value interface{}) (string, error) {
+		return value.(string)[1:2], nil
+	}, map[string]*DefaultPhaseManager{
+		"a": branchManager(t, "-ia"),
+		"b": branchManager(t, "-ib"),
+	}))
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddBranch("first", classify, map[string]*DefaultPhaseManager{
+		"x": inner,
+		"y": branchManager(t, "-y"),
+	}))
+
+	value, err := m.Run("xb")
+	require.NoError(t, err)
+	assert.Equal(t, "xb-ib", value)
+
+	value, err = m.Run("ya")
+	require.NoError(t, err)
+	assert.Equal(t, "ya-y", value)
+}
+
+func TestBranchErrors(t *testing.T) {
+	failing := NewPhaseManager()
+	require.NoError(t, failing.AddPhase(NewPhase("fail", func(value interface{}) (interface{}, error) {
+		return nil, assert.AnError
+	})))
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddBranch("classify", classify, map[string]*DefaultPhaseManager{"f": failing}))
+
+	_, err := m.Run("f")
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.EqualError(t, err, "phase classify: phase fail: "+assert.AnError.Error())
+
+	m = NewPhaseManager()
+	require.NoError(t, m.AddBranch("classify", func(value interface{}) (string, error) {
+		return "", errors.New("cannot classify")
+	}, map[string]*DefaultPhaseManager{"f": failing}))
+	_, err = m.Run("f")
+	assert.EqualError(t, err, "phase classify: cannot classify")
+}
+
+func TestBranchReportPath(t *testing.T) {
+	inner := NewPhaseManager()
+	require.NoError(t, inner.AddPhase(SwitchPhase("format", func(value interface{}) (string, error) {
+		return value.(string)[1:2], nil
+	}, map[string]func(interface{}) (interface{}, error){
+		"m": appendPhase("", "-png").execute,
+	}, appendPhase("", "-raw").execute)))
+	require.NoError(t, inner.AddPhase(appendPhase("-thumbnail", "-thumbnail")))
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(appendPhase("start", "-start")))
+	require.NoError(t, m.AddBranch("classify", classify, map[string]*DefaultPhaseManager{
+		"i": inner,
+		"p": branchManager(t, "-ocr"),
+	}))
+	skipped := appendPhase("skipped", "-skipped")
+	skipped.Disabled = true
+	require.NoError(t, m.AddPhases(skipped, appendPhase("end", "-end")))
+
+	for input, expected := range map[string][]string{
+		"image": {"start", "classify:i", "format:m", "-thumbnail", "end"},
+		"icon":  {"start", "classify:i", "format:default", "-thumbnail", "end"},
+		"pdf":   {"start", "classify:p", "-ocr", "end"},
+	} {
+		var report RunReport
+		_, err := m.Run(input, WithReport(&report))
+		require.NoError(t, err)
+		assert.Equal(t, expected, report.Path, input)
+	}
+
+	// Failed phases end the path
+	var report RunReport
+	_, err := m.Run("text", WithReport(&report))
+	assert.ErrorIs(t, err, ErrUnknownBranch)
+	assert.Equal(t, []string{"start", "classify"}, report.Path)
+}
@@ -0,0 +1,125 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// The causes of the cancellation of runs and phases. Errors of cancelled runs
+// and phases match their cause using errors.Is, as well as the error of the
+// cancelled context, such as context.Canceled.
+var (
+	// ErrUserCancelled is the cause of runs cancelled by RunHandle.Cancel
+	ErrUserCancelled = errors.New("cancelled by user")
+	// ErrDeadlineBudgetExceeded is the cause of runs and phases exceeding the
+	// deadline of their context or their phase's Timeout
+	ErrDeadlineBudgetExceeded = errors.New("deadline budget exceeded")
+	// ErrInterrupted is the cause of runs stopped by a graceful shutdown
+	// running out of time, such as a Scheduler's Drain
+	ErrInterrupted = errors.New("interrupted by shutdown")
+	// ErrParentContextCancelled is the cause of runs whose context was
+	// cancelled by their caller
+	ErrParentContextCancelled = errors.New("parent context cancelled")
+	// ErrFailFastSibling is the cause of work cut short by the failure of a
+	// sibling running concurrently, such as parallel hooks. The cause is a
+	// *SiblingFailureError naming the sibling
+	ErrFailFastSibling = errors.New("sibling failed")
+)
+
+// SiblingFailureError is the cause of work cancelled because a sibling
+// running concurrently failed. It matches ErrFailFastSibling.
+type SiblingFailureError struct {
+	// Sibling identifies the sibling that failed, such as "pre-hook 1
+	// (auth)" for parallel hooks
+	Sibling string
+	// Err is the error of the sibling
+	Err error
+}
+
+func (e *SiblingFailureError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", ErrFailFastSibling, e.Sibling, e.Err)
+}
+
+func (e *SiblingFailureError) Is(target error) bool {
+	return target == ErrFailFastSibling
+}
+
+func (e *SiblingFailureError) Unwrap() error {
+	return e.Err
+}
+
+// CancelError wraps the error of a cancelled context with the cause of the
+// cancellation.
+type CancelError struct {
+	// Cause is the cause of the cancellation, matching one of the
+	// cancellation sentinels such as ErrUserCancelled
+	Cause error
+	// Err is the error caused by the cancellation
+	Err error
+}
+
+func (e *CancelError) Error() string {
+	return fmt.Sprintf("%v (%v)", e.Err, e.Cause)
+}
+
+// Unwrap returns the error caused by the cancellation and its cause, so that
+// the error matches both.
+func (e *CancelError) Unwrap() []error {
+	return []error{e.Err, e.Cause}
+}
+
+// Cause returns the cause of the cancellation of the run, or nil if it was not
+// cancelled.
+func (r *RunReport) Cause() error {
+	return cancelCauseOf(r.Err)
+}
+
+// cancelCauseOf returns the cancellation cause carried by err, if any.
+func cancelCauseOf(err error) error {
+	var cancelErr *CancelError
+	if errors.As(err, &cancelErr) {
+		return cancelErr.Cause
+	}
+	return nil
+}
+
+// withCancelCause wraps err, an error of the run using ctx, with the cause of
+// the cancellation that caused it. Other errors, and errors that already
+// carry a cause, are returned as is.
+func withCancelCause(ctx context.Context, err error) error {
+	if !isInterruption(err) || cancelCauseOf(err) != nil {
+		return err
+	}
+	return &CancelError{Cause: cancelCause(ctx, err), Err: err}
+}
+
+// cancelCause returns the cause of the cancellation causing err. Contexts
+// that are not done were not cancelled, so err was caused by a context
+// derived within the run, such as for a phase's Timeout.
+func cancelCause(ctx context.Context, err error) error {
+	cause := err
+	if ctx.Err() != nil {
+		cause = context.Cause(ctx)
+	}
+	switch {
+	case isCancelCause(cause):
+		return cause
+	case errors.Is(cause, context.DeadlineExceeded):
+		return ErrDeadlineBudgetExceeded
+	case errors.Is(cause, context.Canceled):
+		return ErrParentContextCancelled
+	}
+	// Causes set by callers cancelling the run's context
+	return fmt.Errorf("%w: %w", ErrParentContextCancelled, cause)
+}
+
+// isCancelCause reports whether err is one of the cancellation causes.
+func isCancelCause(err error) bool {
+	for _, cause := range []error{ErrUserCancelled, ErrDeadlineBudgetExceeded, ErrInterrupted, ErrParentContextCancelled, ErrFailFastSibling} {
+		if errors.Is(err, cause) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cancelCauses are the cancellation sentinels.
+var cancelCauses = []error{ErrUserCancelled, ErrDeadlineBudgetExceeded, ErrInterrupted, ErrParentContextCancelled, ErrFailFastSibling}
+
+// assertCause checks that err matches the cancellation sentinel want, and no
+// other one.
+func assertCause(t *testing.T, err error, want error) {
+	t.Helper()
+	for _, cause := range cancelCauses {
+		assert.Equal(t, cause == want, errors.Is(err, cause), "errors.Is(%v, %v)", err, cause)
+	}
+}
+
+// blockingPhase returns a phase waiting for its context to be done.
+func blockingPhase(name string, opts ...PhaseOption) *Phase {
+	return NewPhaseContext(name, func(ctx context.Context, value interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, opts...)
+}
+
+func TestCancelCauseParentContext(t *testing.T) {
+	o := &recordingObserver{}
+	m := NewPhaseManager(WithObserver(o))
+	require.NoError(t, m.AddPhase(blockingPhase("wait")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	var report RunReport
+	_, err := m.RunContext(ctx, 1, WithReport(&report))
+
+	assertCause(t, err, ErrParentContextCancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, ErrParentContextCancelled, report.Cause())
+	require.Len(t, o.results, 1)
+	assert.Equal(t, ErrParentContextCancelled, o.results[0].Cause)
+}
+
+func TestCancelCauseCustomParentCause(t *testing.T) {
+	errShutdown := errors.New("shutting down")
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(blockingPhase("wait")))
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(errShutdown) })
+	_, err := m.RunContext(ctx, 1)
+
+	assertCause(t, err, ErrParentContextCancelled)
+	assert.ErrorIs(t, err, errShutdown)
+}
+
+func TestCancelCauseDeadline(t *testing.T) {
+	t.Run("run deadline", func(t *testing.T) {
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(blockingPhase("wait")))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		var report RunReport
+		_, err := m.RunContext(ctx, 1, WithReport(&report))
+		assertCause(t, err, ErrDeadlineBudgetExceeded)
+		assert.Equal(t, ErrDeadlineBudgetExceeded, report.Cause())
+	})
+
+	t.Run("phase timeout", func(t *testing.T) {
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(blockingPhase("wait", WithTimeout(10*time.Millisecond))))
+
+		_, err := m.Run(1)
+		var phaseErr *PhaseError
+		require.ErrorAs(t, err, &phaseErr)
+		assertCause(t, phaseErr, ErrDeadlineBudgetExceeded)
+	})
+}
+
+func TestCancelCauseUserCancelled(t *testing.T) {
+	started := make(chan struct{}, 1)
+	s := NewScheduler()
+	h := s.Submit(gateManager(t, started, nil), 1)
+	queued := s.Submit(gateManager(t, started, nil), 2)
+	<-started
+	queued.Cancel()
+	h.Cancel()
+
+	_, err := h.Wait()
+	assertCause(t, err, ErrUserCancelled)
+	report := h.Report()
+	assert.Equal(t, ErrUserCancelled, report.Cause())
+
+	_, err = queued.Wait()
+	assertCause(t, err, ErrUserCancelled)
+	require.NoError(t, s.Drain(context.Background()))
+}
+
+func TestCancelCauseInterrupted(t *testing.T) {
+	started := make(chan struct{}, 1)
+	s := NewScheduler()
+	running := s.Submit(gateManager(t, started, nil), 1)
+	<-started
+	queued := s.Submit(gateManager(t, started, nil), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Drain(ctx), context.DeadlineExceeded)
+
+	_, err := running.Wait()
+	assertCause(t, err, ErrInterrupted)
+	_, err = queued.Wait()
+	assertCause(t, err, ErrInterrupted)
+}
+
+func TestCancelCauseFailFastSibling(t *testing.T) {
+	p := NewPhase("validate", addOne)
+	p.ParallelHooks = true
+	p.AppendNamedPreHook("auth", failWith(assert.AnError))
+	p.AppendPreHookContext(func(ctx context.Context, value interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return value, ctx.Err()
+	})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	_, err := m.Run(1)
+	assert.ErrorIs(t, err, assert.AnError)
+	assertCause(t, err, ErrFailFastSibling)
+	var sibling *SiblingFailureError
+	require.ErrorAs(t, err, &sibling)
+	assert.Equal(t, "pre-hook 0 (auth)", sibling.Sibling)
+	assert.ErrorIs(t, sibling.Err, assert.AnError)
+}
@@ -0,0 +1,191 @@
+package phaser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// DefaultMaxCapturedBytes is the default bound of the encoded size of the
+// values captured by RunCaptured.
+const DefaultMaxCapturedBytes = 64 << 20
+
+// CapturePoint is the point of a phase at which RunCaptured captured a value.
+type CapturePoint string
+
+const (
+	// CaptureInput is the value entering the phase, before its pre-hooks
+	CaptureInput CapturePoint = "input"
+	// CaptureHook is the value returned by a hook
+	CaptureHook CapturePoint = "hook"
+	// CaptureExecute is the value returned by the execute function
+	CaptureExecute CapturePoint = "execute"
+	// CaptureOutput is the value leaving the phase, after its post-hooks
+	CaptureOutput CapturePoint = "output"
+)
+
+// Snapshot is a copy of a value captured by RunCaptured.
+type Snapshot struct {
+	// Phase is the name of the phase
+	Phase string
+	// Point is the point of the phase at which the value was captured
+	Point CapturePoint
+	// Stage and Hook are the stage and the name, or index for unnamed hooks,
+	// of the hook that returned the value, for the CaptureHook point
+	Stage Stage
+	Hook  string
+	// Value is a deep copy of the value, made using the phase's codec. It is
+	// nil when the value is truncated or could not be copied
+	Value interface{}
+	// Size is the size of the value encoded by the phase's codec
+	Size int
+	// Truncated is set when the value was not kept because it did not fit
+	// within the bound set by WithMaxCapturedBytes
+	Truncated bool
+	// Err is the error copying the value, if any
+	Err error
+}
+
+// WithMaxCapturedBytes bounds the total encoded size of the values kept by
+// RunCaptured, which defaults to DefaultMaxCapturedBytes. Values captured
+// past the bound are recorded as truncated snapshots without their value. It
+// has no effect on other runs.
+func WithMaxCapturedBytes(n int) RunOption {
+	return func(c *runConfig) {
+		c.maxCapturedBytes = n
+	}
+}
+
+// CapturedRun is the history of the values of a run made by RunCaptured.
+type CapturedRun struct {
+	// Output is the value returned by the run
+	Output interface{}
+
+	manager   *DefaultPhaseManager
+	remaining int
+
+	mu        sync.Mutex
+	snapshots []Snapshot
+}
+
+// RunCaptured runs the pipeline on value like Run, capturing a copy of the
+// value at every point of every phase: its input, the output of each of its
+// hooks and of its execute function, and its output. The values are copied
+// using the phase's codec, or JSONCodec, when they are captured, so that
+// later changes to them do not change the history. Hooks run using
+// ParallelHooks are not captured individually.
+//
+// It is meant for investigating a failing input, as capturing copies every
+// value. The returned run is never nil, and holds the values captured up to
+// the failure when the run fails.
+func (m *DefaultPhaseManager) RunCaptured(value interface{}, opts ...RunOption) (*CapturedRun, error) {
+	c := newRunConfig(opts)
+	captured := &CapturedRun{manager: m, remaining: c.maxCapturedBytes}
+	if captured.remaining <= 0 {
+		captured.remaining = DefaultMaxCapturedBytes
+	}
+	c.captured = captured
+	output, err := m.run(context.Background(), c, 0, value)
+	captured.Output = output
+	return captured, err
+}
+
+// record captures value, the value of p at point.
+func (r *CapturedRun) record(p *Phase, point CapturePoint, stage Stage, hook string, value interface{}) {
+	if r == nil {
+		return
+	}
+	snapshot := Snapshot{Phase: p.Name, Point: point, Stage: stage, Hook: hook}
+	codec := r.manager.codecFor(p)
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+
+	var data []byte
+	if value != nil {
+		data, snapshot.Err = codec.Marshal(value)
+		snapshot.Size = len(data)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case snapshot.Err != nil:
+	case snapshot.Size > r.remaining:
+		snapshot.Truncated = true
+	case value != nil:
+		r.remaining -= snapshot.Size
+		copied := reflect.New(reflect.TypeOf(value))
+		target := copied.Interface()
+		if snapshot.Err = codec.Unmarshal(data, &target); snapshot.Err == nil {
+			snapshot.Value = copied.Elem().Interface()
+		}
+	}
+	r.snapshots = append(r.snapshots, snapshot)
+}
+
+// Timeline returns the snapshots of the run in the order they were captured.
+func (r *CapturedRun) Timeline() []Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Snapshot(nil), r.snapshots...)
+}
+
+// Before returns the value that entered the phase named phase. It returns
+// false when the phase did not run, or when its input was truncated or could
+// not be copied.
+func (r *CapturedRun) Before(phase string) (interface{}, bool) {
+	return r.find(phase, CaptureInput)
+}
+
+// After returns the value that left the phase named phase. It returns false
+// when the phase did not complete, or when its output was truncated or could
+// not be copied.
+func (r *CapturedRun) After(phase string) (interface{}, bool) {
+	return r.find(phase, CaptureOutput)
+}
+
+// find returns the value of the last snapshot of phase at point.
+func (r *CapturedRun) find(phase string, point CapturePoint) (interface{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.snapshots) - 1; i >= 0; i-- {
+		s := r.snapshots[i]
+		if s.Phase == phase && s.Point == point {
+			return s.Value, !s.Truncated && s.Err == nil
+		}
+	}
+	return nil, false
+}
+
+// Dump writes the timeline of the run to w, one snapshot per entry with its
+// value encoded as indented JSON.
+func (r *CapturedRun) Dump(w io.Writer) error {
+	for i, s := range r.Timeline() {
+		label := string(s.Point)
+		if s.Point == CaptureHook {
+			label = fmt.Sprintf("%s %s", s.Stage, s.Hook)
+		}
+		var value string
+		switch {
+		case s.Truncated:
+			value = fmt.Sprintf("<truncated, %d bytes>", s.Size)
+		case s.Err != nil:
+			value = fmt.Sprintf("<error: %v>", s.Err)
+		default:
+			data, err := json.MarshalIndent(s.Value, "  ", "  ")
+			if err != nil {
+				value = fmt.Sprintf("%v", s.Value)
+			} else {
+				value = string(data)
+			}
+		}
+		if _, err := fmt.Fprintf(w, "#%d %s %s:\n  %s\n", i, s.Phase, label, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
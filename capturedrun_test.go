@@ -0,0 +1,133 @@
+package phaser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingPipeline returns a manager whose phases set keys of a map in
+// place, and whose "persist" phase fails when fail is set. received collects
+// the values the hooks received, copied.
+func capturingPipeline(t *testing.T, fail bool, received *[]map[string]int) *DefaultPhaseManager {
+	set := func(key string, n int) PhaseHook {
+		return func(value interface{}) (interface{}, error) {
+			values := value.(map[string]int)
+			copied := make(map[string]int, len(values))
+			for k, v := range values {
+				copied[k] = v
+			}
+			*received = append(*received, copied)
+			values[key] = n
+			return values, nil
+		}
+	}
+
+	transform := NewPhase("transform", set("execute", 1))
+	transform.AppendNamedPreHook("normalize", set("normalize", 1))
+	transform.appendPostHook(set("post", 1))
+	persist := NewPhase("persist", func(value interface{}) (interface{}, error) {
+		if fail {
+			return nil, errNotFound
+		}
+		value.(map[string]int)["persisted"] = 1
+		return value, nil
+	})
+	persist.appendPreHook(set("normalize", 2))
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(transform, persist, NewPhase("report", func(value interface{}) (interface{}, error) {
+		return value, nil
+	})))
+	return m
+}
+
+func TestRunCaptured(t *testing.T) {
+	var received []map[string]int
+	captured, err := capturingPipeline(t, false, &received).RunCaptured(map[string]int{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"normalize": 2, "execute": 1, "post": 1, "persisted": 1}, captured.Output)
+
+	// The values were copied, so changing them in place later did not change
+	// the history
+	before, ok := captured.Before("transform")
+	require.True(t, ok)
+	assert.Equal(t, map[string]int{}, before)
+	after, ok := captured.After("transform")
+	require.True(t, ok)
+	assert.Equal(t, map[string]int{"normalize": 1, "execute": 1, "post": 1}, after)
+	before, ok = captured.Before("persist")
+	require.True(t, ok)
+	assert.Equal(t, after, before)
+
+	timeline := captured.Timeline()
+	var points []string
+	for _, s := range timeline {
+		points = append(points, s.Phase+" "+string(s.Point)+" "+s.Hook)
+	}
+	assert.Equal(t, []string{
+		"transform input ",
+		"transform hook normalize",
+		"transform execute ",
+		"transform hook 0",
+		"transform output ",
+		"persist input ",
+		"persist hook 0",
+		"persist execute ",
+		"persist output ",
+		"report input ",
+		"report execute ",
+		"report output ",
+	}, points)
+
+	// Each hook received the value captured before it
+	assert.Equal(t, []map[string]int{
+		timeline[0].Value.(map[string]int),
+		timeline[1].Value.(map[string]int),
+		timeline[2].Value.(map[string]int),
+		timeline[5].Value.(map[string]int),
+	}, received)
+}
+
+func TestRunCapturedFailure(t *testing.T) {
+	var received []map[string]int
+	captured, err := capturingPipeline(t, true, &received).RunCaptured(map[string]int{})
+	assert.ErrorIs(t, err, errNotFound)
+
+	_, ok := captured.After("transform")
+	assert.True(t, ok)
+	_, ok = captured.Before("persist")
+	assert.True(t, ok)
+	_, ok = captured.After("persist")
+	assert.False(t, ok)
+	_, ok = captured.Before("report")
+	assert.False(t, ok)
+
+	timeline := captured.Timeline()
+	last := timeline[len(timeline)-1]
+	assert.Equal(t, "persist", last.Phase)
+	assert.Equal(t, CaptureHook, last.Point)
+}
+
+func TestRunCapturedMaxBytes(t *testing.T) {
+	var received []map[string]int
+	captured, err := capturingPipeline(t, false, &received).RunCaptured(map[string]int{}, WithMaxCapturedBytes(40))
+	require.NoError(t, err)
+
+	timeline := captured.Timeline()
+	assert.False(t, timeline[0].Truncated)
+	last := timeline[len(timeline)-1]
+	assert.True(t, last.Truncated)
+	assert.Nil(t, last.Value)
+	assert.Positive(t, last.Size)
+	_, ok := captured.After("report")
+	assert.False(t, ok)
+
+	var buf bytes.Buffer
+	require.NoError(t, captured.Dump(&buf))
+	assert.Contains(t, buf.String(), "#0 transform input:\n  {}\n")
+	assert.Contains(t, buf.String(), "#1 transform pre-hook normalize:\n")
+	assert.Contains(t, buf.String(), "<truncated, ")
+}
@@ -0,0 +1,162 @@
+package phaser
+
+import (
+	"encoding/gob"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	gob.Register(&CheckpointRecord{})
+}
+
+// Checkpointer persists the value produced by each phase so that an
+// interrupted run can be resumed with ResumeRun. The manager saves and loads
+// *CheckpointRecord values.
+type Checkpointer interface {
+	// Save stores value as the output of the phase named phaseName
+	Save(phaseName string, value interface{}) error
+	// Load returns the value saved for the phase named phaseName. The boolean
+	// reports whether a checkpoint exists for the phase
+	Load(phaseName string) (interface{}, bool, error)
+}
+
+// CheckpointRecord is the value saved by the manager after each phase. Along
+// with the phase's output it stores the pipeline's phase versions, which are
+// checked for compatibility before resuming.
+type CheckpointRecord struct {
+	// Fingerprint is the fingerprint of the pipeline that saved the record
+	Fingerprint string
+	// Phases contains the names and versions of the pipeline's phases
+	Phases []PhaseVersion
+	// Value is the output of the checkpointed phase, unless the phase's
+	// values are serialized by a Codec
+	Value interface{}
+	// Codec is the name of the codec that encoded the output of the
+	// checkpointed phase into Data, empty when Value holds it
+	Codec string
+	// Data is the output of the checkpointed phase encoded by Codec
+	Data []byte
+}
+
+// checkpointValue returns the output of p saved in record, decoding it using
+// the phase's codec when it was encoded.
+func (m *DefaultPhaseManager) checkpointValue(p *Phase, record *CheckpointRecord) (interface{}, error) {
+	if record.Codec == "" {
+		return record.Value, nil
+	}
+	codec := m.codecFor(p)
+	if codec == nil || codec.Name() != record.Codec {
+		return nil, fmt.Errorf("checkpoint for phase %s was encoded using codec %s: %w", p.Name, record.Codec, ErrIncompatibleState)
+	}
+	value, err := p.decode(codec, record.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding checkpoint for phase %s: %w", p.Name, err)
+	}
+	return value, nil
+}
+
+// AllowAddedPhases lets ResumeRun resume from checkpoints saved by a pipeline
+// that lacked some of the current phases, as long as every added phase comes
+// after the last checkpointed phase.
+func AllowAddedPhases() RunOption {
+	return func(c *runConfig) {
+		c.allowAddedPhases = true
+	}
+}
+
+// WithCheckpointer makes the manager save a checkpoint through c after each
+// phase completes.
+func WithCheckpointer(c Checkpointer) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.checkpointer = c
+	}
+}
+
+// checkpointExt is the extension used for FileCheckpointer files.
+const checkpointExt = ".gob"
+
+// FileCheckpointer is a Checkpointer storing one gob encoded file per phase in
+// Dir. As with any gob encoded interface value, concrete types other than the
+// basic types must be registered using gob.Register.
+type FileCheckpointer struct {
+	// Dir is the directory the checkpoint files are written to
+	Dir string
+}
+
+// fileCheckpoint is the gob envelope of a checkpoint file. Wrapping the value
+// lets gob encode its concrete type.
+type fileCheckpoint struct {
+	Value interface{}
+}
+
+// NewFileCheckpointer returns a FileCheckpointer writing to dir, creating the
+// directory if necessary.
+func NewFileCheckpointer(dir string) (*FileCheckpointer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointer{Dir: dir}, nil
+}
+
+// Save writes the checkpoint to a temporary file and renames it into place, so
+// a crash never leaves a partially written checkpoint behind.
+func (c *FileCheckpointer) Save(phaseName string, value interface{}) error {
+	tmp, err := os.CreateTemp(c.Dir, "checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = gob.NewEncoder(tmp).Encode(&fileCheckpoint{Value: value}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path(phaseName))
+}
+
+func (c *FileCheckpointer) Load(phaseName string) (interface{}, bool, error) {
+	f, err := os.Open(c.path(phaseName))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	var checkpoint fileCheckpoint
+	if err = gob.NewDecoder(f).Decode(&checkpoint); err != nil {
+		return nil, false, err
+	}
+
+	return checkpoint.Value, true, nil
+}
+
+// Clear removes every checkpoint in Dir. It should be called once a run
+// completes so that the next ResumeRun starts from the first phase.
+func (c *FileCheckpointer) Clear() error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), checkpointExt) {
+			if err = os.Remove(filepath.Join(c.Dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// path returns the checkpoint file path of the phase named phaseName.
+func (c *FileCheckpointer) path(phaseName string) string {
+	return filepath.Join(c.Dir, url.PathEscape(phaseName)+checkpointExt)
+}
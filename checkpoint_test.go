@@ -0,0 +1,123 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingPhase returns a phase adding one to its input and counting how many
+// times it was executed. When fail is set, the phase errors instead.
+func countingPhase(name string, calls *int, fail *bool) *Phase {
+	return NewPhase(name, func(value interface{}) (interface{}, error) {
+		*calls++
+		if fail != nil && *fail {
+			return nil, assert.AnError
+		}
+		return value.(int) + 1, nil
+	})
+}
+
+func TestFileCheckpointerSaveLoad(t *testing.T) {
+	c, err := NewFileCheckpointer(t.TempDir())
+	require.NoError(t, err)
+
+	_, ok, err := c.Load("missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Save("a/phase", 42))
+	value, ok, err := c.Load("a/phase")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 42, value)
+
+	require.NoError(t, c.Clear())
+	_, ok, err = c.Load("a/phase")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestResumeRunFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	var calls [3]int
+	fail := true
+
+	// First run crashes on the second phase
+	c, err := NewFileCheckpointer(dir)
+	require.NoError(t, err)
+	m := NewPhaseManager(WithCheckpointer(c))
+	require.NoError(t, m.AddPhase(countingPhase("one", &calls[0], nil)))
+	require.NoError(t, m.AddPhase(countingPhase("two", &calls[1], &fail)))
+	require.NoError(t, m.AddPhase(countingPhase("three", &calls[2], nil)))
+
+	_, err = m.Run(0)
+	require.Error(t, err)
+	assert.Equal(t, [3]int{1, 1, 0}, calls)
+
+	// Simulate a restart with a fresh manager and checkpointer
+	fail = false
+	c, err = NewFileCheckpointer(dir)
+	require.NoError(t, err)
+	m = NewPhaseManager(WithCheckpointer(c))
+	require.NoError(t, m.AddPhase(countingPhase("one", &calls[0], nil)))
+	require.NoError(t, m.AddPhase(countingPhase("two", &calls[1], &fail)))
+	require.NoError(t, m.AddPhase(countingPhase("three", &calls[2], nil)))
+
+	value, err := m.ResumeRun(0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+	// The first phase was checkpointed so it must not run again
+	assert.Equal(t, [3]int{1, 2, 1}, calls)
+}
+
+func TestResumeRunWithoutCheckpoints(t *testing.T) {
+	c, err := NewFileCheckpointer(t.TempDir())
+	require.NoError(t, err)
+	var calls [2]int
+
+	m := NewPhaseManager(WithCheckpointer(c))
+	require.NoError(t, m.AddPhase(countingPhase("one", &calls[0], nil)))
+	require.NoError(t, m.AddPhase(countingPhase("two", &calls[1], nil)))
+
+	value, err := m.ResumeRun(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, [2]int{1, 1}, calls)
+
+	// Every phase is checkpointed, so resuming again runs nothing
+	value, err = m.ResumeRun(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, [2]int{1, 1}, calls)
+}
+
+func TestResumeRunPastSkippedPhase(t *testing.T) {
+	var calls [4]int
+	fail := true
+	flags := &fakeFlags{enabled: map[string]bool{}}
+	c, err := NewFileCheckpointer(t.TempDir())
+	require.NoError(t, err)
+	m := NewPhaseManager(WithCheckpointer(c), WithFlagProvider(flags))
+	gated := countingPhase("gated", &calls[1], nil)
+	gated.FeatureFlag = "beta"
+	require.NoError(t, m.AddPhases(
+		countingPhase("one", &calls[0], nil),
+		gated,
+		countingPhase("two", &calls[2], nil),
+		countingPhase("three", &calls[3], &fail),
+	))
+
+	_, err = m.Run(0)
+	require.Error(t, err)
+	assert.Equal(t, [4]int{1, 0, 1, 1}, calls)
+
+	// The phase skipped by its flag has no checkpoint, while the phases
+	// around it do
+	fail = false
+	value, err := m.ResumeRun(0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+	assert.Equal(t, [4]int{1, 0, 1, 2}, calls)
+}
@@ -0,0 +1,53 @@
+package phaser
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of time of a manager's runs: the times and durations
+// in their reports, retry backoffs, rate limits, heartbeats and debug
+// traces. Tests can replace it by a fake clock using WithClock, so that time
+// dependent behavior runs without sleeping. Phase timeouts and stall
+// detection keep using the real clock, as they rely on context deadlines and
+// tickers.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// Sleep waits for d
+	Sleep(d time.Duration)
+	// After returns a channel receiving the current time once d elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock reading the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock sets the manager's clock, which defaults to the system time.
+func WithClock(clock Clock) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.clock = clock
+	}
+}
+
+// ClockFrom returns the clock of the run using ctx, such as to let execute
+// functions wait using the manager's clock. Contexts of other runs get a
+// clock reading the system time.
+func ClockFrom(ctx context.Context) Clock {
+	if clock := runStateFrom(ctx).clock; clock != nil {
+		return clock
+	}
+	return realClock{}
+}
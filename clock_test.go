@@ -0,0 +1,44 @@
+package phaser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockRateLimit(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewPhaseManager(WithClock(clock))
+	limited := NewPhase("limited", addOne)
+	limited.RateLimit = &RateLimit{PerSecond: 0.5, Burst: 1}
+	require.NoError(t, m.AddPhase(limited))
+
+	var durations []time.Duration
+	for i := 0; i < 3; i++ {
+		var report RunReport
+		_, err := m.Run(i, WithReport(&report))
+		require.NoError(t, err)
+		durations = append(durations, report.Duration)
+	}
+	// The burst allows the first run, and the others wait for a token
+	assert.Equal(t, []time.Duration{0, 2 * time.Second, 2 * time.Second}, durations)
+}
+
+func TestClockFrom(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewPhaseManager(WithClock(clock))
+	require.NoError(t, m.AddPhase(NewPhaseContext("wait", func(ctx context.Context, value interface{}) (interface{}, error) {
+		ClockFrom(ctx).Sleep(time.Minute)
+		return value, nil
+	})))
+
+	var report RunReport
+	_, err := m.Run(1, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, report.Phases[0].Duration)
+
+	assert.IsType(t, realClock{}, ClockFrom(context.Background()))
+}
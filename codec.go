@@ -0,0 +1,133 @@
+package phaser
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Codec serializes the values flowing between phases, for the features
+// persisting them: checkpoints and audit records.
+type Codec interface {
+	// Marshal encodes value
+	Marshal(value interface{}) ([]byte, error)
+	// Unmarshal decodes data into v. When v holds a non-nil pointer, the
+	// value is decoded into the value it points to
+	Unmarshal(data []byte, v *interface{}) error
+	// Name identifies the codec in persisted state, such as checkpoints
+	Name() string
+}
+
+// JSONCodec is the Codec encoding values to JSON. Values decoded into an
+// empty interface lose their types: structs and maps become
+// map[string]interface{}, numbers float64, and byte slices base64 strings.
+// Phases whose values are decoded, such as when resuming checkpoints, should
+// use WithCheckpointType to decode them into their concrete type.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v *interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+// GobCodec is the Codec encoding values using encoding/gob, preserving their
+// concrete types. As with any gob encoded interface value, concrete types
+// other than the basic types must be registered using gob.Register.
+type GobCodec struct{}
+
+// gobEnvelope wraps the values encoded by GobCodec, so that gob encodes
+// their concrete type.
+type gobEnvelope struct {
+	Value interface{}
+}
+
+func (GobCodec) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&gobEnvelope{Value: value}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v *interface{}) error {
+	var envelope gobEnvelope
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&envelope); err != nil {
+		return err
+	}
+	target := reflect.ValueOf(*v)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		*v = envelope.Value
+		return nil
+	}
+	decoded := reflect.ValueOf(envelope.Value)
+	if !decoded.IsValid() || !decoded.Type().AssignableTo(target.Type().Elem()) {
+		return fmt.Errorf("cannot decode %T into %v", envelope.Value, target.Type().Elem())
+	}
+	target.Elem().Set(decoded)
+	return nil
+}
+
+func (GobCodec) Name() string {
+	return "gob"
+}
+
+// WithCodec sets the codec serializing the values of the manager's phases,
+// which defaults to JSONCodec for audit records. Checkpoints hold the values
+// themselves, leaving their serialization to the Checkpointer, unless a codec
+// is set.
+func WithCodec(c Codec) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.codec = c
+	}
+}
+
+// WithPhaseCodec sets the codec serializing the values of the phase,
+// overriding the manager's codec set by WithCodec.
+func WithPhaseCodec(c Codec) PhaseOption {
+	return func(p *Phase) {
+		p.codec = c
+	}
+}
+
+// WithCheckpointType makes the values of the phase decoded by codecs, such
+// as its output when resuming from its checkpoint, decode into a T rather
+// than an empty interface, so that the next phase receives the concrete type
+// with codecs losing types like JSONCodec.
+func WithCheckpointType[T any]() PhaseOption {
+	return func(p *Phase) {
+		p.valueType = reflect.TypeOf((*T)(nil)).Elem()
+	}
+}
+
+// codecFor returns the codec serializing the values of p, or nil when
+// neither p nor the manager set one.
+func (m *DefaultPhaseManager) codecFor(p *Phase) Codec {
+	if p.codec != nil {
+		return p.codec
+	}
+	return m.codec
+}
+
+// decode decodes data, a value of the phase encoded using codec.
+func (p *Phase) decode(codec Codec, data []byte) (interface{}, error) {
+	if p.valueType == nil {
+		var value interface{}
+		err := codec.Unmarshal(data, &value)
+		return value, err
+	}
+	target := reflect.New(p.valueType)
+	value := target.Interface()
+	if err := codec.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return target.Elem().Interface(), nil
+}
@@ -0,0 +1,143 @@
+package phaser
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type codecInner struct {
+	Tags []string
+}
+
+type codecValue struct {
+	When  time.Time
+	Data  []byte
+	Count int
+	Inner codecInner
+}
+
+func init() {
+	gob.Register(codecValue{})
+}
+
+func newCodecValue() codecValue {
+	return codecValue{
+		When:  time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		Data:  []byte{0, 1, 2},
+		Count: 3,
+		Inner: codecInner{Tags: []string{"a", "b"}},
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			data, err := codec.Marshal(newCodecValue())
+			require.NoError(t, err)
+
+			var decoded codecValue
+			var target interface{} = &decoded
+			require.NoError(t, codec.Unmarshal(data, &target))
+			assert.Equal(t, newCodecValue(), decoded)
+		})
+	}
+}
+
+func TestCodecDecodeIntoInterface(t *testing.T) {
+	data, err := JSONCodec{}.Marshal(newCodecValue())
+	require.NoError(t, err)
+	var value interface{}
+	require.NoError(t, JSONCodec{}.Unmarshal(data, &value))
+	assert.IsType(t, map[string]interface{}{}, value)
+
+	data, err = GobCodec{}.Marshal(newCodecValue())
+	require.NoError(t, err)
+	value = nil
+	require.NoError(t, GobCodec{}.Unmarshal(data, &value))
+	assert.Equal(t, newCodecValue(), value)
+}
+
+// codecPipeline returns a manager checkpointing to dir whose second phase
+// fails when fail is set, and which records the input of its last phase.
+func codecPipeline(t *testing.T, dir string, fail *bool, last *interface{}, opts ...ManagerOption) *DefaultPhaseManager {
+	c, err := NewFileCheckpointer(dir)
+	require.NoError(t, err)
+	m := NewPhaseManager(append([]ManagerOption{WithCheckpointer(c)}, opts...)...)
+	require.NoError(t, m.AddPhase(NewPhase("build", func(interface{}) (interface{}, error) {
+		return newCodecValue(), nil
+	}, WithCheckpointType[codecValue]())))
+	require.NoError(t, m.AddPhase(NewPhase("check", func(value interface{}) (interface{}, error) {
+		if *fail {
+			return nil, assert.AnError
+		}
+		return value, nil
+	})))
+	require.NoError(t, m.AddPhase(NewPhase("use", func(value interface{}) (interface{}, error) {
+		*last = value
+		return value, nil
+	})))
+	return m
+}
+
+func TestResumeRunWithCodec(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			dir := t.TempDir()
+			fail := true
+			var last interface{}
+			_, err := codecPipeline(t, dir, &fail, &last, WithCodec(codec)).Run(nil)
+			require.Error(t, err)
+
+			fail = false
+			value, err := codecPipeline(t, dir, &fail, &last, WithCodec(codec)).ResumeRun(nil)
+			require.NoError(t, err)
+			assert.Equal(t, newCodecValue(), value)
+			assert.Equal(t, newCodecValue(), last)
+		})
+	}
+}
+
+func TestResumeRunCodecMismatch(t *testing.T) {
+	dir := t.TempDir()
+	fail := true
+	var last interface{}
+	_, err := codecPipeline(t, dir, &fail, &last, WithCodec(GobCodec{})).Run(nil)
+	require.Error(t, err)
+
+	fail = false
+	_, err = codecPipeline(t, dir, &fail, &last, WithCodec(JSONCodec{})).ResumeRun(nil)
+	assert.True(t, errors.Is(err, ErrIncompatibleState))
+	assert.Nil(t, last)
+}
+
+func TestAuditWithCodec(t *testing.T) {
+	sink := &capturingSink{}
+	m := NewPhaseManager(WithAuditSink(sink), WithCodec(GobCodec{}))
+	require.NoError(t, m.AddPhase(NewPhase("build", func(interface{}) (interface{}, error) {
+		return newCodecValue(), nil
+	})))
+	require.NoError(t, m.AddPhase(NewPhase("plain", func(value interface{}) (interface{}, error) {
+		return value, nil
+	}, WithPhaseCodec(JSONCodec{}))))
+
+	_, err := m.Run(nil)
+	require.NoError(t, err)
+	records := sink.records
+	require.Len(t, records, 2)
+
+	var data []byte
+	require.NoError(t, json.Unmarshal(records[0].Output, &data))
+	var value interface{}
+	require.NoError(t, GobCodec{}.Unmarshal(data, &value))
+	assert.Equal(t, newCodecValue(), value)
+
+	var decoded codecValue
+	require.NoError(t, json.Unmarshal(records[1].Output, &decoded))
+	assert.Equal(t, newCodecValue(), decoded)
+}
@@ -0,0 +1,123 @@
+package phaser
+
+import (
+	"context"
+	"time"
+)
+
+// ConcurrencyOption configures a concurrency limit.
+type ConcurrencyOption func(l *concurrencyLimit)
+
+// LimitHooks makes the concurrency limit cover the phases' hooks in addition
+// to their execute functions.
+func LimitHooks() ConcurrencyOption {
+	return func(l *concurrencyLimit) {
+		l.hooks = true
+	}
+}
+
+// WithConcurrencyLimit limits the number of execute functions running at the
+// same time to n, across every run of the manager and the phases they run
+// concurrently. Runs waiting for the limit stop waiting when their context is
+// done, and the time spent waiting is reported as the Queued time of the
+// phase.
+func WithConcurrencyLimit(n int, opts ...ConcurrencyOption) ManagerOption {
+	l := &concurrencyLimit{sem: newSemaphore(n)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return func(m *DefaultPhaseManager) {
+		m.limit = l
+	}
+}
+
+// WithMaxConcurrent limits the number of executions of the phase's execute
+// function running at the same time to n, in addition to the manager's
+// concurrency limit.
+func WithMaxConcurrent(n int) PhaseOption {
+	return func(p *Phase) {
+		p.sem = newSemaphore(n)
+	}
+}
+
+// concurrencyLimit is the concurrency limit of a manager.
+type concurrencyLimit struct {
+	sem semaphore
+	// hooks makes the limit cover hooks
+	hooks bool
+}
+
+// semaphore limits the number of concurrent operations. A nil semaphore does
+// not limit anything.
+type semaphore chan struct{}
+
+// newSemaphore returns a semaphore allowing n concurrent operations.
+func newSemaphore(n int) semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+// acquire waits until an operation is allowed or ctx is done, returning the
+// time spent waiting according to the run's clock.
+func (s semaphore) acquire(ctx context.Context) (time.Duration, error) {
+	if s == nil {
+		return 0, nil
+	}
+	select {
+	case s <- struct{}{}:
+		return 0, nil
+	default:
+	}
+
+	clock := ClockFrom(ctx)
+	started := clock.Now()
+	select {
+	case s <- struct{}{}:
+		return clock.Now().Sub(started), nil
+	case <-ctx.Done():
+		return clock.Now().Sub(started), ctx.Err()
+	}
+}
+
+// release ends an operation.
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// acquireLimits acquires the semaphores limiting the phase's run, recording
+// the time spent waiting in the phase's result. The hooks flag tells whether
+// the whole run of the phase, including its hooks, is being limited rather
+// than its execute function alone. The returned function releases the
+// acquired semaphores.
+func (p *Phase) acquireLimits(ctx context.Context, hooks bool) (func(), error) {
+	var sems []semaphore
+	if !hooks {
+		sems = append(sems, p.sem)
+	}
+	// Phases running nested phases leave the limit to the nested phases,
+	// which would otherwise wait for the slot held by their parent
+	if l := runStateFrom(ctx).limit; l != nil && l.hooks == hooks && !p.nested {
+		sems = append(sems, l.sem)
+	}
+
+	result := phaseResultFrom(ctx)
+	release := func() {
+		for _, s := range sems {
+			s.release()
+		}
+	}
+	for i, s := range sems {
+		queued, err := s.acquire(ctx)
+		result.Queued += queued
+		if err != nil {
+			sems = sems[:i]
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
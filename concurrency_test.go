@@ -0,0 +1,147 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// highWaterMark tracks the maximum number of concurrent calls to its execute
+// function.
+type highWaterMark struct {
+	running int64
+	max     int64
+}
+
+func (h *highWaterMark) execute(value interface{}) (interface{}, error) {
+	running := atomic.AddInt64(&h.running, 1)
+	defer atomic.AddInt64(&h.running, -1)
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if running <= max || atomic.CompareAndSwapInt64(&h.max, max, running) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return value, nil
+}
+
+// runBatch runs m concurrently on n values.
+func runBatch(t *testing.T, m *DefaultPhaseManager, n int) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := m.Run(i)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	var h highWaterMark
+	m := NewPhaseManager(WithConcurrencyLimit(2))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", h.execute),
+		NewPhase("two", h.execute),
+	))
+
+	runBatch(t, m, 50)
+	assert.LessOrEqual(t, h.max, int64(2))
+	assert.Equal(t, int64(2), h.max)
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	var one, two highWaterMark
+	m := NewPhaseManager(WithConcurrencyLimit(4))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", one.execute, WithMaxConcurrent(1)),
+		NewPhase("two", two.execute),
+	))
+
+	runBatch(t, m, 50)
+	assert.Equal(t, int64(1), one.max)
+	assert.LessOrEqual(t, two.max, int64(4))
+}
+
+func TestConcurrencyLimitQueued(t *testing.T) {
+	release := make(chan struct{})
+	m := NewPhaseManager(WithConcurrencyLimit(1, LimitHooks()))
+	require.NoError(t, m.AddPhase(NewPhase("one", func(value interface{}) (interface{}, error) {
+		if value == "block" {
+			<-release
+		}
+		return value, nil
+	})))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = m.Run("block")
+	}()
+	require.Eventually(t, func() bool { return len(m.limit.sem) == 1 }, time.Second, time.Millisecond)
+
+	// A cancelled run stops waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := m.RunContext(ctx, "cancelled")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var partial *PartialResultError
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, "cancelled", partial.LastValue)
+
+	// Queued runs report the time waited
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+	var report RunReport
+	_, err = m.Run("queued", WithReport(&report))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, report.Phases[0].Queued, 10*time.Millisecond)
+	<-done
+}
+
+func TestConcurrencyLimitWithBranches(t *testing.T) {
+	for _, opts := range [][]ConcurrencyOption{nil, {LimitHooks()}} {
+		m := NewPhaseManager(WithConcurrencyLimit(1, opts...))
+		require.NoError(t, m.AddBranch("classify", classify, map[string]*DefaultPhaseManager{
+			"x": branchManager(t, "-a", "-b"),
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		value, err := m.RunContext(ctx, "x")
+		cancel()
+		require.NoError(t, err)
+		assert.Equal(t, "x-a-b", value)
+	}
+}
+
+func TestConcurrencyLimitHeldByTimedOutExecutions(t *testing.T) {
+	var h highWaterMark
+	slow := func(value interface{}) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return h.execute(value)
+	}
+	m := NewPhaseManager(WithConcurrencyLimit(1))
+	require.NoError(t, m.AddPhase(NewPhase("slow", slow, WithTimeout(time.Millisecond))))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Run(0)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		}()
+	}
+	wg.Wait()
+	// Wait for the abandoned executions to return
+	m.limit.sem <- struct{}{}
+	assert.Equal(t, int64(1), h.max)
+}
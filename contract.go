@@ -0,0 +1,220 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrAssertionFailed is the error of the contract violations of hooks
+// returned by Assert.
+var ErrAssertionFailed = errors.New("assertion failed")
+
+// DefaultExcerptLen is the default length of the value excerpts of contract
+// violations.
+const DefaultExcerptLen = 120
+
+// ContractMode is how a manager handles the contract hooks returned by Assert
+// and AssertErr.
+type ContractMode int
+
+const (
+	// Enforce fails the phase with a *ContractViolationError when a contract
+	// is violated. It is the default
+	Enforce ContractMode = iota
+	// WarnOnly reports violations as warnings of the phase, in the run's
+	// report and for RunWithWarnings, and lets the value flow on
+	WarnOnly
+	// Disabled skips the contract hooks without checking their predicates
+	Disabled
+)
+
+// ContractOption configures the contracts of a manager.
+type ContractOption func(c *contractConfig)
+
+// WithExcerptLen truncates the value excerpts of contract violations to n
+// bytes, which defaults to DefaultExcerptLen. Non-positive values leave the
+// excerpts out.
+func WithExcerptLen(n int) ContractOption {
+	return func(c *contractConfig) {
+		c.excerptLen = n
+	}
+}
+
+// WithExcerptFormatter sets the function formatting the values of contract
+// violations into their excerpts, which defaults to the %#v verb, such as to
+// redact secrets.
+func WithExcerptFormatter(format func(value interface{}) string) ContractOption {
+	return func(c *contractConfig) {
+		c.format = format
+	}
+}
+
+// WithContracts sets how the manager handles contract hooks, so that
+// contracts can be enforced in staging while only being reported in
+// production. The violations are counted by ContractViolations, apart from
+// the other failures.
+func WithContracts(mode ContractMode, opts ...ContractOption) ManagerOption {
+	c := newContractConfig(mode)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return func(m *DefaultPhaseManager) {
+		m.contracts = c
+	}
+}
+
+// ContractViolations returns the number of violations of each contract, by
+// assertion name, since the manager was created. It returns nil unless the
+// manager was created using WithContracts.
+func (m *DefaultPhaseManager) ContractViolations() map[string]int {
+	if m.contracts == nil {
+		return nil
+	}
+
+	m.contracts.mu.Lock()
+	defer m.contracts.mu.Unlock()
+	counts := make(map[string]int, len(m.contracts.counts))
+	for name, count := range m.contracts.counts {
+		counts[name] = count
+	}
+	return counts
+}
+
+// ContractViolationError is returned, or reported as a warning, when the
+// value given to a contract hook violates its contract.
+type ContractViolationError struct {
+	// Assertion is the name of the contract
+	Assertion string
+	// Phase is the name of the phase the contract hook is attached to
+	Phase string
+	// Stage is the stage running the contract hook
+	Stage Stage
+	// Hook is the name of the contract hook, or its index when unnamed
+	Hook string
+	// Excerpt is the formatted value, truncated as set by WithContracts
+	Excerpt string
+	// Err is the error returned by the predicate, ErrAssertionFailed for
+	// hooks returned by Assert
+	Err error
+}
+
+func (e *ContractViolationError) Error() string {
+	msg := fmt.Sprintf("contract %s violated in %s %s of phase %s: %v", e.Assertion, e.Stage, e.Hook, displayName(e.Phase), e.Err)
+	if e.Excerpt != "" {
+		msg += ", value " + e.Excerpt
+	}
+	return msg
+}
+
+func (e *ContractViolationError) Unwrap() error {
+	return e.Err
+}
+
+// Assert returns a contract hook named name checking that pred holds for the
+// value, such as the assumptions a phase makes about the output of the phase
+// before it. The value is passed through unchanged.
+func Assert(name string, pred func(value interface{}) bool) PhaseHook {
+	return AssertErr(name, func(value interface{}) error {
+		if !pred(value) {
+			return ErrAssertionFailed
+		}
+		return nil
+	})
+}
+
+// AssertErr is like Assert for predicates describing the violation through
+// the error they return, nil when the contract holds.
+func AssertErr(name string, pred func(value interface{}) error) PhaseHook {
+	check := &contractCheck{name: name, pred: pred}
+	return func(value interface{}) (interface{}, error) {
+		// The check runs in callHook, which knows the run's contract mode
+		return value, check
+	}
+}
+
+// contractCheck is returned by contract hooks for callHook to check their
+// contract.
+type contractCheck struct {
+	name string
+	pred func(value interface{}) error
+}
+
+func (c *contractCheck) Error() string {
+	return fmt.Sprintf("contract %s not checked outside of a run", c.name)
+}
+
+// contractConfig contains the contract settings of a manager. A nil
+// *contractConfig enforces contracts without counting violations.
+type contractConfig struct {
+	mode       ContractMode
+	excerptLen int
+	format     func(value interface{}) string
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newContractConfig(mode ContractMode) *contractConfig {
+	return &contractConfig{
+		mode:       mode,
+		excerptLen: DefaultExcerptLen,
+		format: func(value interface{}) string {
+			return fmt.Sprintf("%#v", value)
+		},
+		counts: map[string]int{},
+	}
+}
+
+// defaultContracts is the configuration of managers without WithContracts.
+var defaultContracts = newContractConfig(Enforce)
+
+// excerpt returns the excerpt of value.
+func (c *contractConfig) excerpt(value interface{}) string {
+	if c.excerptLen <= 0 {
+		return ""
+	}
+	s := c.format(value)
+	if len(s) > c.excerptLen {
+		s = s[:c.excerptLen] + "..."
+	}
+	return s
+}
+
+// checkContract checks the contract of the hook at index i of hooks when err,
+// returned by the hook along with value, is a contract check. Other errors
+// are returned as is.
+func (p *Phase) checkContract(state *runState, hooks *[]PhaseHook, i int, value interface{}, err error) error {
+	check, ok := err.(*contractCheck)
+	if !ok {
+		return err
+	}
+	c := state.contracts
+	if c == nil {
+		c = defaultContracts
+	}
+	if c.mode == Disabled {
+		return nil
+	}
+	if err = check.pred(value); err == nil {
+		return nil
+	}
+
+	violation := &ContractViolationError{
+		Assertion: check.name,
+		Phase:     p.Name,
+		Stage:     p.hookStage(hooks),
+		Hook:      p.hookKey(hooks, i),
+		Excerpt:   c.excerpt(value),
+		Err:       err,
+	}
+	if c != defaultContracts {
+		c.mu.Lock()
+		c.counts[check.name]++
+		c.mu.Unlock()
+	}
+	if c.mode == WarnOnly {
+		return AsWarning(violation)
+	}
+	return violation
+}
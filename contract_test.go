@@ -0,0 +1,123 @@
+package phaser
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contractPipeline returns a manager configured with opts whose "consume"
+// phase asserts that its input is sorted and has an ID. checks counts the
+// calls to the sorted predicate.
+func contractPipeline(t *testing.T, checks *int, opts ...ManagerOption) *DefaultPhaseManager {
+	consume := NewPhase("consume", passValue)
+	consume.AppendNamedPreHook("sorted", Assert("sorted", func(value interface{}) bool {
+		*checks++
+		return sort.IntsAreSorted(value.(map[string][]int)["items"])
+	}))
+	consume.appendPreHook(AssertErr("has-id", func(value interface{}) error {
+		if _, ok := value.(map[string][]int)["id"]; !ok {
+			return errNotFound
+		}
+		return nil
+	}))
+	m := NewPhaseManager(opts...)
+	require.NoError(t, m.AddPhase(consume))
+	return m
+}
+
+func TestContractsEnforce(t *testing.T) {
+	checks := 0
+	m := contractPipeline(t, &checks, WithContracts(Enforce))
+
+	_, err := m.Run(map[string][]int{"id": {1}, "items": {1, 2}})
+	require.NoError(t, err)
+
+	_, err = m.Run(map[string][]int{"id": {1}, "items": {2, 1}})
+	var violation *ContractViolationError
+	require.True(t, errors.As(err, &violation), err)
+	assert.Equal(t, "sorted", violation.Assertion)
+	assert.Equal(t, "consume", violation.Phase)
+	assert.Equal(t, StagePreHook, violation.Stage)
+	assert.Equal(t, "sorted", violation.Hook)
+	assert.ErrorIs(t, err, ErrAssertionFailed)
+	assert.Contains(t, violation.Excerpt, "2, 1")
+
+	_, err = m.Run(map[string][]int{"items": {1}})
+	require.True(t, errors.As(err, &violation), err)
+	assert.Equal(t, "has-id", violation.Assertion)
+	assert.Equal(t, "1", violation.Hook)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.Contains(t, err.Error(), "contract has-id violated in pre-hook 1 of phase consume: not found, value ")
+
+	assert.Equal(t, map[string]int{"sorted": 1, "has-id": 1}, m.ContractViolations())
+	assert.Equal(t, 3, checks)
+}
+
+func TestContractsEnforcedByDefault(t *testing.T) {
+	checks := 0
+	m := contractPipeline(t, &checks)
+	_, err := m.Run(map[string][]int{"id": {1}, "items": {2, 1}})
+	assert.ErrorIs(t, err, ErrAssertionFailed)
+	assert.Nil(t, m.ContractViolations())
+}
+
+func TestContractsWarnOnly(t *testing.T) {
+	checks := 0
+	m := contractPipeline(t, &checks, WithContracts(WarnOnly))
+
+	input := map[string][]int{"items": {2, 1}}
+	var report RunReport
+	value, warnings, err := m.RunWithWarnings(input, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, input, value)
+	require.Len(t, warnings, 2)
+	require.Len(t, report.Phases[0].Warnings, 2)
+	var violation *ContractViolationError
+	require.True(t, errors.As(report.Phases[0].Warnings[0], &violation))
+	assert.Equal(t, "sorted", violation.Assertion)
+	require.True(t, errors.As(report.Phases[0].Warnings[1], &violation))
+	assert.Equal(t, "has-id", violation.Assertion)
+
+	assert.Equal(t, map[string]int{"sorted": 1, "has-id": 1}, m.ContractViolations())
+}
+
+func TestContractsDisabled(t *testing.T) {
+	checks := 0
+	m := contractPipeline(t, &checks, WithContracts(Disabled))
+
+	_, err := m.Run(map[string][]int{"items": {2, 1}})
+	require.NoError(t, err)
+	// The predicates were never called
+	assert.Zero(t, checks)
+	assert.Empty(t, m.ContractViolations())
+}
+
+func TestContractsExcerpt(t *testing.T) {
+	never := Assert("never", func(interface{}) bool { return false })
+	run := func(opts ...ContractOption) *ContractViolationError {
+		p := NewPhase("consume", passValue)
+		p.appendPostHook(never)
+		m := NewPhaseManager(WithContracts(Enforce, opts...))
+		require.NoError(t, m.AddPhase(p))
+		_, err := m.Run(strings.Repeat("x", 500))
+		var violation *ContractViolationError
+		require.True(t, errors.As(err, &violation), err)
+		assert.Equal(t, StagePostHook, violation.Stage)
+		return violation
+	}
+
+	// The excerpt is truncated to DefaultExcerptLen by default
+	excerpt := run().Excerpt
+	assert.Equal(t, `"`+strings.Repeat("x", DefaultExcerptLen-1)+"...", excerpt)
+
+	assert.Equal(t, `"xxx...`, run(WithExcerptLen(4)).Excerpt)
+	assert.Empty(t, run(WithExcerptLen(0)).Excerpt)
+	redacted := run(WithExcerptFormatter(func(interface{}) string { return "<redacted>" }))
+	assert.Equal(t, "<redacted>", redacted.Excerpt)
+	assert.NotContains(t, redacted.Error(), "xxx")
+}
@@ -0,0 +1,74 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrNoConverter is returned when the declared output type of a phase differs
+// from the declared input type of the next phase, and no converter between
+// them is registered.
+var ErrNoConverter = errors.New("no converter")
+
+// converters contains the converters registered using RegisterConverter.
+var converters = struct {
+	sync.RWMutex
+	byTypes map[[2]reflect.Type]func(interface{}) (interface{}, error)
+}{byTypes: make(map[[2]reflect.Type]func(interface{}) (interface{}, error))}
+
+// RegisterConverter registers fn as the converter of values of type from into
+// values of type to, replacing the converter previously registered for the
+// two types. Runs apply it between a phase whose OutputType is from and the
+// next phase whose InputType is to, so that adapters between phase types are
+// written once. It is meant to be called during initialization, such as from
+// init functions.
+func RegisterConverter(from, to reflect.Type, fn func(interface{}) (interface{}, error)) {
+	converters.Lock()
+	defer converters.Unlock()
+	converters.byTypes[[2]reflect.Type{from, to}] = fn
+}
+
+// converter returns the converter registered for values of type from into
+// values of type to.
+func converter(from, to reflect.Type) (func(interface{}) (interface{}, error), bool) {
+	converters.RLock()
+	defer converters.RUnlock()
+	fn, ok := converters.byTypes[[2]reflect.Type{from, to}]
+	return fn, ok
+}
+
+// convertInput converts value, an output of type from, into the declared
+// input type of p. Values are passed on unchanged when either type is not
+// declared or the types match.
+func (p *Phase) convertInput(from reflect.Type, value interface{}) (interface{}, error) {
+	if from == nil || p.InputType == nil || from == p.InputType {
+		return value, nil
+	}
+	fn, ok := converter(from, p.InputType)
+	if !ok {
+		return nil, fmt.Errorf("%w from %v to %v for the input of phase %s", ErrNoConverter, from, p.InputType, p.Name)
+	}
+	converted, err := fn(value)
+	if err != nil {
+		return nil, fmt.Errorf("converting %v to %v for the input of phase %s: %w", from, p.InputType, p.Name, err)
+	}
+	return converted, nil
+}
+
+// checkConverters returns an error wrapping ErrNoConverter for each pair of
+// consecutive phases whose declared types differ without a converter.
+func (m *DefaultPhaseManager) checkConverters() []error {
+	var errs []error
+	for i := 1; i < len(m.phases); i++ {
+		from, to := m.phases[i-1].OutputType, m.phases[i].InputType
+		if from == nil || to == nil || from == to {
+			continue
+		}
+		if _, ok := converter(from, to); !ok {
+			errs = append(errs, fmt.Errorf("%w from %v to %v between phases %s and %s", ErrNoConverter, from, to, m.phases[i-1].Name, m.phases[i].Name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,67 @@
+package phaser
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// celsius and label are types converted between typed phases.
+type celsius float64
+type label string
+
+func init() {
+	RegisterConverter(reflect.TypeOf(0), reflect.TypeOf(""), func(value interface{}) (interface{}, error) {
+		return strconv.Itoa(value.(int)), nil
+	})
+	RegisterConverter(reflect.TypeOf(celsius(0)), reflect.TypeOf(label("")), func(value interface{}) (interface{}, error) {
+		if value.(celsius) < -273.15 {
+			return nil, errors.New("below absolute zero")
+		}
+		return label(strconv.FormatFloat(float64(value.(celsius)), 'f', 1, 64) + "C"), nil
+	})
+}
+
+func TestConverterBetweenTypedPhases(t *testing.T) {
+	count := NewTypedPhase("count", func(value []string) (int, error) { return len(value), nil })
+	shout := NewTypedPhase("shout", func(value string) (string, error) { return value + "!", nil })
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(count.AsPhase(), shout.AsPhase()))
+	require.NoError(t, m.Validate())
+
+	value, err := m.Run([]string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, "3!", value)
+}
+
+func TestConverterErrors(t *testing.T) {
+	measure := NewTypedPhase("measure", func(value float64) (celsius, error) { return celsius(value), nil })
+	format := NewTypedPhase("format", func(value label) (label, error) { return value, nil })
+	count := NewTypedPhase("count", func(value label) (int, error) { return len(value), nil })
+	double := NewTypedPhase("double", func(value float64) (float64, error) { return value * 2, nil })
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(measure.AsPhase(), format.AsPhase()))
+	value, err := m.Run(21.5)
+	require.NoError(t, err)
+	assert.Equal(t, label("21.5C"), value)
+
+	_, err = m.Run(-300.0)
+	var phaseErr *PhaseError
+	require.True(t, errors.As(err, &phaseErr))
+	assert.Equal(t, "format", phaseErr.Phase)
+	assert.EqualError(t, err, "phase format: converting phaser.celsius to phaser.label for the input of phase format: below absolute zero")
+
+	m = NewPhaseManager()
+	require.NoError(t, m.AddPhases(format.AsPhase(), count.AsPhase(), double.AsPhase()))
+	assert.ErrorIs(t, m.Validate(), ErrNoConverter)
+	_, err = m.Run(label("abc"))
+	assert.ErrorIs(t, err, ErrNoConverter)
+	require.True(t, errors.As(err, &phaseErr))
+	assert.Equal(t, "double", phaseErr.Phase)
+}
@@ -0,0 +1,339 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotExportable is returned by ExportDefinition when the pipeline uses
+	// settings that a PipelineDefinition cannot describe
+	ErrNotExportable = errors.New("pipeline not exportable")
+	// ErrMissingFunctions matches, through errors.Is, any
+	// MissingFunctionsError
+	ErrMissingFunctions = errors.New("missing functions")
+)
+
+// PipelineDefinition is a portable, JSON serializable description of the
+// structure of a pipeline. The functions of the pipeline are identified by
+// name, and provided by a Registry when the definition is imported.
+type PipelineDefinition struct {
+	StrictMode  bool              `json:"strictMode,omitempty"`
+	RetryBudget int               `json:"retryBudget,omitempty"`
+	Phases      []PhaseDefinition `json:"phases"`
+}
+
+// PhaseDefinition describes a phase of a PipelineDefinition. The execute
+// function of the phase is identified by the phase's name, and its hooks by
+// their names.
+type PhaseDefinition struct {
+	Name           string                        `json:"name"`
+	DependsOn      []string                      `json:"dependsOn,omitempty"`
+	Disabled       bool                          `json:"disabled,omitempty"`
+	FeatureFlag    string                        `json:"featureFlag,omitempty"`
+	NonCritical    bool                          `json:"nonCritical,omitempty"`
+	ParallelHooks  bool                          `json:"parallelHooks,omitempty"`
+	DedupeHooks    bool                          `json:"dedupeHooks,omitempty"`
+	AllowNilValues bool                          `json:"allowNilValues,omitempty"`
+	RateLimit      *RateLimitDefinition          `json:"rateLimit,omitempty"`
+	Timeout        time.Duration                 `json:"timeout,omitempty"`
+	Retry          *RetryDefinition              `json:"retry,omitempty"`
+	MaxConcurrent  int                           `json:"maxConcurrent,omitempty"`
+	Weight         float64                       `json:"weight,omitempty"`
+	Version        string                        `json:"version,omitempty"`
+	PreHooks       []string                      `json:"preHooks,omitempty"`
+	PostHooks      []string                      `json:"postHooks,omitempty"`
+	Branches       map[string]PipelineDefinition `json:"branches,omitempty"`
+}
+
+// RateLimitDefinition describes the RateLimit of a phase.
+type RateLimitDefinition struct {
+	PerSecond float64 `json:"perSecond"`
+	Burst     int     `json:"burst,omitempty"`
+}
+
+// RetryDefinition describes the RetryPolicy of a phase.
+type RetryDefinition struct {
+	MaxAttempts int           `json:"maxAttempts"`
+	Backoff     time.Duration `json:"backoff,omitempty"`
+	Scope       RetryScope    `json:"scope,omitempty"`
+}
+
+// Registry provides the functions of an imported PipelineDefinition. Phases
+// sharing a name in different branches share their execute function.
+type Registry struct {
+	// Executes maps phase names to their execute functions
+	Executes map[string]func(value interface{}) (interface{}, error)
+	// ContextExecutes maps phase names to execute functions receiving the
+	// run's context. It is used for phases missing from Executes
+	ContextExecutes map[string]func(ctx context.Context, value interface{}) (interface{}, error)
+	// Hooks maps hook names to hooks
+	Hooks map[string]PhaseHook
+	// Selectors maps the names of branch points to their selectors
+	Selectors map[string]BranchSelector
+}
+
+// MissingFunctionsError is returned by ImportDefinition when the registry
+// lacks some of the functions of the definition.
+type MissingFunctionsError struct {
+	// Executes contains the phases without an execute function
+	Executes []string
+	// Hooks contains the hook names without a hook
+	Hooks []string
+	// Selectors contains the branch points without a selector
+	Selectors []string
+}
+
+func (e *MissingFunctionsError) Error() string {
+	var details []string
+	if len(e.Executes) > 0 {
+		details = append(details, fmt.Sprintf("executes %v", e.Executes))
+	}
+	if len(e.Hooks) > 0 {
+		details = append(details, fmt.Sprintf("hooks %v", e.Hooks))
+	}
+	if len(e.Selectors) > 0 {
+		details = append(details, fmt.Sprintf("selectors %v", e.Selectors))
+	}
+	return fmt.Sprintf("%v: %s", ErrMissingFunctions, strings.Join(details, "; "))
+}
+
+func (e *MissingFunctionsError) Is(target error) bool {
+	return target == ErrMissingFunctions
+}
+
+// ExportDefinition returns the definition of the pipeline, which can be
+// serialized as JSON and turned back into an equivalent pipeline by
+// ImportDefinition. Hooks must be named to be exported, and phases may not
+// have error handlers, custom limiters, single flight keys, value isolation or
+// transforms. Manager options other than StrictMode and RetryBudget are not
+// part of the definition.
+func (m *DefaultPhaseManager) ExportDefinition() (PipelineDefinition, error) {
+	return m.definition(true)
+}
+
+// Describe returns the definition of the pipeline like ExportDefinition, but
+// never fails: settings that cannot be exported are left out, and unnamed
+// hooks have empty names. It is meant for describing a pipeline rather than
+// for importing it back.
+func (m *DefaultPhaseManager) Describe() PipelineDefinition {
+	// Definitions of unexportable pipelines do not fail
+	def, _ := m.definition(false)
+	return def
+}
+
+// definition returns the definition of the pipeline. Unless exportable is
+// set, settings that cannot be exported are left out of the definition
+// instead of failing, and unnamed hooks have empty names.
+func (m *DefaultPhaseManager) definition(exportable bool) (PipelineDefinition, error) {
+	def := PipelineDefinition{StrictMode: m.StrictMode, RetryBudget: m.RetryBudget, Phases: make([]PhaseDefinition, 0, len(m.phases))}
+	for _, p := range m.phases {
+		phaseDef, err := p.exportDefinition(exportable)
+		if err != nil {
+			return PipelineDefinition{}, err
+		}
+		def.Phases = append(def.Phases, phaseDef)
+	}
+	return def, nil
+}
+
+// exportDefinition returns the definition of the phase, which fails for
+// phases that cannot be exported when exportable is set.
+func (p *Phase) exportDefinition(exportable bool) (PhaseDefinition, error) {
+	switch {
+	case !exportable:
+	case len(p.errorHandlers) > 0:
+		return PhaseDefinition{}, fmt.Errorf("%w: phase %s has error handlers", ErrNotExportable, p.Name)
+	case p.RateLimit != nil && p.RateLimit.Limiter != nil:
+		return PhaseDefinition{}, fmt.Errorf("%w: phase %s has a custom limiter", ErrNotExportable, p.Name)
+	case p.flight != nil:
+		return PhaseDefinition{}, fmt.Errorf("%w: phase %s uses single flight", ErrNotExportable, p.Name)
+	case p.cloner != nil:
+		return PhaseDefinition{}, fmt.Errorf("%w: phase %s uses value isolation", ErrNotExportable, p.Name)
+	case p.Transform != nil:
+		return PhaseDefinition{}, fmt.Errorf("%w: phase %s has a transform", ErrNotExportable, p.Name)
+	case p.DefaultOnError != nil:
+		return PhaseDefinition{}, fmt.Errorf("%w: phase %s has a default on error", ErrNotExportable, p.Name)
+	case p.ErrorTransform != nil:
+		return PhaseDefinition{}, fmt.Errorf("%w: phase %s has an error transform", ErrNotExportable, p.Name)
+	}
+
+	def := PhaseDefinition{
+		Name:           p.Name,
+		DependsOn:      p.DependsOn,
+		Disabled:       p.Disabled,
+		FeatureFlag:    p.FeatureFlag,
+		NonCritical:    p.NonCritical,
+		ParallelHooks:  p.ParallelHooks,
+		DedupeHooks:    p.DedupeHooks,
+		AllowNilValues: p.AllowNilValues,
+		Timeout:        p.Timeout,
+		MaxConcurrent:  cap(p.sem),
+		Weight:         p.Weight,
+		Version:        p.Version,
+	}
+	if p.RateLimit != nil {
+		def.RateLimit = &RateLimitDefinition{PerSecond: p.RateLimit.PerSecond, Burst: p.RateLimit.Burst}
+	}
+	if p.Retry != nil {
+		def.Retry = &RetryDefinition{MaxAttempts: p.Retry.MaxAttempts, Backoff: p.Retry.Backoff, Scope: p.Retry.Scope}
+	}
+
+	var err error
+	if def.PreHooks, err = p.exportHooks(StagePreHook, &p.preHooks, exportable); err != nil {
+		return PhaseDefinition{}, err
+	}
+	if def.PostHooks, err = p.exportHooks(StagePostHook, &p.postHooks, exportable); err != nil {
+		return PhaseDefinition{}, err
+	}
+
+	if p.branches != nil {
+		def.Branches = make(map[string]PipelineDefinition, len(p.branches))
+		for key, branch := range p.branches {
+			if def.Branches[key], err = branch.definition(exportable); err != nil {
+				return PhaseDefinition{}, err
+			}
+		}
+	}
+	return def, nil
+}
+
+// exportHooks returns the names of hooks, the hooks of stage. Unnamed hooks
+// fail when exportable is set.
+func (p *Phase) exportHooks(stage Stage, hooks *[]PhaseHook, exportable bool) ([]string, error) {
+	var names []string
+	for i := range *hooks {
+		name := p.hookName(hooks, i)
+		if name == "" && exportable {
+			return nil, fmt.Errorf("%w: %s %d of phase %s is unnamed", ErrNotExportable, stage, i, p.Name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// ImportDefinition returns a manager configured with opts running the pipeline
+// described by def, using the functions of registry. It returns a
+// *MissingFunctionsError listing every function missing from registry.
+func ImportDefinition(def PipelineDefinition, registry Registry, opts ...ManagerOption) (*DefaultPhaseManager, error) {
+	missing := &MissingFunctionsError{}
+	m, err := importDefinition(def, registry, missing, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing.Executes)+len(missing.Hooks)+len(missing.Selectors) > 0 {
+		sort.Strings(missing.Executes)
+		sort.Strings(missing.Hooks)
+		sort.Strings(missing.Selectors)
+		return nil, missing
+	}
+	return m, nil
+}
+
+// importDefinition builds the manager described by def, adding the functions
+// missing from registry to missing.
+func importDefinition(def PipelineDefinition, registry Registry, missing *MissingFunctionsError, opts []ManagerOption) (*DefaultPhaseManager, error) {
+	m := NewPhaseManager(opts...)
+	m.StrictMode = def.StrictMode
+	m.RetryBudget = def.RetryBudget
+
+	for _, phaseDef := range def.Phases {
+		var err error
+		if phaseDef.Branches != nil {
+			err = importBranch(m, phaseDef, registry, missing)
+		} else {
+			err = m.AddPhase(importPhase(phaseDef, registry, missing))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// importBranch adds the branch point described by def to m.
+func importBranch(m *DefaultPhaseManager, def PhaseDefinition, registry Registry, missing *MissingFunctionsError) error {
+	branches := make(map[string]*DefaultPhaseManager, len(def.Branches))
+	for key, branchDef := range def.Branches {
+		branch, err := importDefinition(branchDef, registry, missing, nil)
+		if err != nil {
+			return err
+		}
+		branches[key] = branch
+	}
+
+	selector, ok := registry.Selectors[def.Name]
+	if !ok {
+		missing.Selectors = append(missing.Selectors, def.Name)
+	}
+	if err := m.AddBranch(def.Name, selector, branches); err != nil {
+		return err
+	}
+	p := m.phases[len(m.phases)-1]
+	configurePhase(p, def, registry, missing)
+	return nil
+}
+
+// importPhase returns the phase described by def.
+func importPhase(def PhaseDefinition, registry Registry, missing *MissingFunctionsError) *Phase {
+	p := &Phase{Name: def.Name}
+	if execute, ok := registry.Executes[def.Name]; ok {
+		p.execute = execute
+	} else if execute, ok := registry.ContextExecutes[def.Name]; ok {
+		p.executeContext = execute
+	} else {
+		missing.Executes = append(missing.Executes, def.Name)
+	}
+	configurePhase(p, def, registry, missing)
+	return p
+}
+
+// configurePhase applies the settings and hooks of def to p.
+func configurePhase(p *Phase, def PhaseDefinition, registry Registry, missing *MissingFunctionsError) {
+	p.DependsOn = def.DependsOn
+	p.Disabled = def.Disabled
+	p.FeatureFlag = def.FeatureFlag
+	p.NonCritical = def.NonCritical
+	p.ParallelHooks = def.ParallelHooks
+	p.DedupeHooks = def.DedupeHooks
+	p.AllowNilValues = def.AllowNilValues
+	p.Timeout = def.Timeout
+	p.Weight = def.Weight
+	p.Version = def.Version
+	p.sem = newSemaphore(def.MaxConcurrent)
+	if def.RateLimit != nil {
+		p.RateLimit = &RateLimit{PerSecond: def.RateLimit.PerSecond, Burst: def.RateLimit.Burst}
+	}
+	if def.Retry != nil {
+		p.Retry = &RetryPolicy{MaxAttempts: def.Retry.MaxAttempts, Backoff: def.Retry.Backoff, Scope: def.Retry.Scope}
+	}
+
+	for _, name := range def.PreHooks {
+		if hook, ok := registry.Hooks[name]; ok {
+			p.AppendNamedPreHook(name, hook)
+		} else {
+			missing.Hooks = appendMissing(missing.Hooks, name)
+		}
+	}
+	for _, name := range def.PostHooks {
+		if hook, ok := registry.Hooks[name]; ok {
+			p.AppendNamedPostHook(name, hook)
+		} else {
+			missing.Hooks = appendMissing(missing.Hooks, name)
+		}
+	}
+}
+
+// appendMissing appends name to names unless it is already listed.
+func appendMissing(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
@@ -0,0 +1,152 @@
+package phaser
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// definitionRegistry returns the registry of the pipeline built by
+// definitionManager.
+func definitionRegistry() Registry {
+	return Registry{
+		Executes: map[string]func(value interface{}) (interface{}, error){
+			"start":  appendPhase("start", "-start").execute,
+			"-ocr":   appendPhase("-ocr", "-ocr").execute,
+			"-thumb": appendPhase("-thumb", "-thumb").execute,
+			"broken": failWith(assert.AnError),
+			"end":    appendPhase("end", "-end").execute,
+		},
+		Hooks: map[string]PhaseHook{
+			"trim": func(value interface{}) (interface{}, error) {
+				return value.(string)[:len(value.(string))-1], nil
+			},
+			"check": func(value interface{}) (interface{}, error) {
+				return value, nil
+			},
+		},
+		Selectors: map[string]BranchSelector{"classify": classify},
+	}
+}
+
+// definitionManager returns a pipeline using every setting of definitions.
+func definitionManager(t *testing.T) *DefaultPhaseManager {
+	registry := definitionRegistry()
+	m := NewPhaseManager()
+	m.RetryBudget = 3
+
+	start := NewPhase("start", registry.Executes["start"], WithTimeout(time.Second), WithVersion("2"),
+		WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}), WithMaxConcurrent(4))
+	start.RateLimit = &RateLimit{PerSecond: 1000, Burst: 10}
+	start.AppendNamedPreHook("trim", registry.Hooks["trim"])
+	start.AppendNamedPostHook("check", registry.Hooks["check"])
+	require.NoError(t, m.AddPhase(start))
+
+	image := NewPhaseManager()
+	require.NoError(t, image.AddPhase(NewPhase("-thumb", registry.Executes["-thumb"])))
+	require.NoError(t, m.AddBranch("classify", registry.Selectors["classify"], map[string]*DefaultPhaseManager{
+		"i": image,
+		"p": branchManager(t, "-ocr"),
+	}))
+
+	broken := NewPhase("broken", registry.Executes["broken"], WithNonCritical(), WithWeight(2))
+	broken.DependsOn = []string{"classify"}
+	require.NoError(t, m.AddPhase(broken))
+	end := NewPhase("end", registry.Executes["end"])
+	end.Disabled = true
+	require.NoError(t, m.AddPhase(end))
+	return m
+}
+
+func TestDefinitionRoundTrip(t *testing.T) {
+	m := definitionManager(t)
+	def, err := m.ExportDefinition()
+	require.NoError(t, err)
+
+	encoded, err := json.Marshal(def)
+	require.NoError(t, err)
+	var decoded PipelineDefinition
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, def, decoded)
+
+	imported, err := ImportDefinition(decoded, definitionRegistry())
+	require.NoError(t, err)
+	reimported, err := imported.ExportDefinition()
+	require.NoError(t, err)
+	assert.Equal(t, def, reimported)
+
+	for _, input := range []string{"image.", "pdf."} {
+		var want, got RunReport
+		wantValue, wantErr := m.Run(input, WithReport(&want))
+		gotValue, gotErr := imported.Run(input, WithReport(&got))
+		assert.Equal(t, wantValue, gotValue)
+		assert.Equal(t, wantErr, gotErr)
+		assert.Equal(t, withoutTiming(want), withoutTiming(got))
+	}
+}
+
+// withoutTiming returns the results of report without their timings.
+func withoutTiming(report RunReport) []PhaseResult {
+	results := make([]PhaseResult, len(report.Phases))
+	for i, result := range report.Phases {
+		result.Start, result.Duration, result.Queued = time.Time{}, 0, 0
+		results[i] = result
+	}
+	return results
+}
+
+func TestDefinitionJSON(t *testing.T) {
+	m := NewPhaseManager()
+	p := NewPhase("parse", addOne, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	p.AppendNamedPreHook("trim", addOne)
+	require.NoError(t, m.AddPhase(p))
+
+	def, err := m.ExportDefinition()
+	require.NoError(t, err)
+	encoded, err := json.Marshal(def)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"phases":[{"name":"parse","retry":{"maxAttempts":3},"preHooks":["trim"]}]}`, string(encoded))
+}
+
+func TestImportDefinitionMissingFunctions(t *testing.T) {
+	def, err := definitionManager(t).ExportDefinition()
+	require.NoError(t, err)
+
+	registry := definitionRegistry()
+	delete(registry.Executes, "-ocr")
+	delete(registry.Executes, "end")
+	delete(registry.Hooks, "trim")
+	delete(registry.Selectors, "classify")
+
+	m, err := ImportDefinition(def, registry)
+	assert.Nil(t, m)
+	require.ErrorIs(t, err, ErrMissingFunctions)
+	var missing *MissingFunctionsError
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, &MissingFunctionsError{
+		Executes:  []string{"-ocr", "end"},
+		Hooks:     []string{"trim"},
+		Selectors: []string{"classify"},
+	}, missing)
+}
+
+func TestExportDefinitionNotExportable(t *testing.T) {
+	unnamed := NewPhase("unnamed", addOne)
+	unnamed.appendPostHook(addOne)
+	handled := NewPhase("handled", addOne)
+	handled.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		return false, nil, err
+	})
+	limited := NewPhase("limited", addOne)
+	limited.RateLimit = &RateLimit{Limiter: &fakeLimiter{}}
+
+	for _, p := range []*Phase{unnamed, handled, limited, NewPhase("flight", addOne, WithSingleFlight(currencyKey))} {
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(p))
+		_, err := m.ExportDefinition()
+		assert.ErrorIs(t, err, ErrNotExportable, p.Name)
+	}
+}
@@ -0,0 +1,310 @@
+package phaser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PipelineDiff describes the differences between two pipelines, as returned
+// by Diff and DiffDefinitions.
+//
+// Hooks are compared by name, as functions cannot be compared: hooks with the
+// same names in the same order are unchanged (by name), even if their
+// functions differ. Unnamed hooks only match other unnamed hooks.
+type PipelineDiff struct {
+	// Fields contains the changes to the settings of the pipeline
+	Fields []FieldChange `json:"fields,omitempty"`
+	// Added contains the phases only in the new pipeline, in its order
+	Added []string `json:"added,omitempty"`
+	// Removed contains the phases only in the old pipeline, in its order
+	Removed []string `json:"removed,omitempty"`
+	// Moved contains the phases of both pipelines that run in a different
+	// order relative to the other phases of both, in the new pipeline's
+	// order
+	Moved []PhaseMove `json:"moved,omitempty"`
+	// Changed contains the phases of both pipelines whose settings changed,
+	// in the new pipeline's order
+	Changed []PhaseChange `json:"changed,omitempty"`
+}
+
+// FieldChange is a change to a setting of a pipeline or phase.
+type FieldChange struct {
+	// Field is the name of the setting, as in its definition
+	Field string `json:"field"`
+	// Old and New are the formatted values of the setting in the old and new
+	// pipelines
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// PhaseMove is a phase that runs in a different order.
+type PhaseMove struct {
+	Phase string `json:"phase"`
+	// From and To are the positions of the phase in the old and new
+	// pipelines
+	From int `json:"from"`
+	To   int `json:"to"`
+	// After is the name of the phase preceding it in the new pipeline, empty
+	// when it is the first one
+	After string `json:"after,omitempty"`
+}
+
+// PhaseChange contains the changes to a phase of both pipelines.
+type PhaseChange struct {
+	Phase string `json:"phase"`
+	// Fields contains the changes to the settings of the phase
+	Fields []FieldChange `json:"fields,omitempty"`
+	// Branches contains the differences of the branches of both branch
+	// points, keyed by branch. Unchanged branches are left out
+	Branches map[string]PipelineDiff `json:"branches,omitempty"`
+}
+
+// Diff returns the differences between the pipeline of a and the pipeline of
+// b. Unlike ExportDefinition, it does not fail on pipelines that cannot be
+// exported, and compares unnamed hooks by position instead.
+func Diff(a, b *DefaultPhaseManager) PipelineDiff {
+	// Definitions of unexportable pipelines do not fail
+	defA, _ := a.definition(false)
+	defB, _ := b.definition(false)
+	return DiffDefinitions(defA, defB)
+}
+
+// DiffDefinitions returns the differences between the pipelines described by
+// a and b.
+func DiffDefinitions(a, b PipelineDefinition) PipelineDiff {
+	var diff PipelineDiff
+	diff.Fields = diffFields([]fieldPair{
+		{"strictMode", fmt.Sprint(a.StrictMode), fmt.Sprint(b.StrictMode)},
+		{"retryBudget", fmt.Sprint(a.RetryBudget), fmt.Sprint(b.RetryBudget)},
+	})
+
+	phasesA := make(map[string]int, len(a.Phases))
+	for i, p := range a.Phases {
+		phasesA[p.Name] = i
+	}
+	phasesB := make(map[string]int, len(b.Phases))
+	for i, p := range b.Phases {
+		phasesB[p.Name] = i
+	}
+	var commonA, commonB []string
+	for _, p := range a.Phases {
+		if _, ok := phasesB[p.Name]; ok {
+			commonA = append(commonA, p.Name)
+		} else {
+			diff.Removed = append(diff.Removed, p.Name)
+		}
+	}
+	for _, p := range b.Phases {
+		if _, ok := phasesA[p.Name]; ok {
+			commonB = append(commonB, p.Name)
+		} else {
+			diff.Added = append(diff.Added, p.Name)
+		}
+	}
+
+	// Phases out of the longest sequence keeping its order are moved
+	kept := longestCommonSequence(commonA, commonB)
+	for _, name := range commonB {
+		if !kept[name] {
+			move := PhaseMove{Phase: name, From: phasesA[name], To: phasesB[name]}
+			if move.To > 0 {
+				move.After = b.Phases[move.To-1].Name
+			}
+			diff.Moved = append(diff.Moved, move)
+		}
+	}
+	for _, name := range commonB {
+		change := diffPhases(a.Phases[phasesA[name]], b.Phases[phasesB[name]])
+		if len(change.Fields) > 0 || len(change.Branches) > 0 {
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+	return diff
+}
+
+// Empty reports whether the pipelines compared are the same.
+func (d PipelineDiff) Empty() bool {
+	return len(d.Fields)+len(d.Added)+len(d.Removed)+len(d.Moved)+len(d.Changed) == 0
+}
+
+// String returns the differences as human readable text, with a line per
+// difference.
+func (d PipelineDiff) String() string {
+	if d.Empty() {
+		return "no changes\n"
+	}
+	var b strings.Builder
+	d.write(&b, "")
+	return b.String()
+}
+
+// write writes the differences to b, indenting each line with indent.
+func (d PipelineDiff) write(b *strings.Builder, indent string) {
+	writeFields(b, indent, d.Fields)
+	for _, name := range d.Added {
+		fmt.Fprintf(b, "%s+ phase %s\n", indent, name)
+	}
+	for _, name := range d.Removed {
+		fmt.Fprintf(b, "%s- phase %s\n", indent, name)
+	}
+	for _, move := range d.Moved {
+		if move.After == "" {
+			fmt.Fprintf(b, "%s~ phase %s moved to the start\n", indent, move.Phase)
+		} else {
+			fmt.Fprintf(b, "%s~ phase %s moved after %s\n", indent, move.Phase, move.After)
+		}
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(b, "%s~ phase %s\n", indent, change.Phase)
+		writeFields(b, indent+"    ", change.Fields)
+		keys := make([]string, 0, len(change.Branches))
+		for key := range change.Branches {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(b, "%s    branch %s:\n", indent, key)
+			change.Branches[key].write(b, indent+"        ")
+		}
+	}
+}
+
+// writeFields writes a line per change in fields to b, indenting them with
+// indent.
+func writeFields(b *strings.Builder, indent string, fields []FieldChange) {
+	for _, field := range fields {
+		fmt.Fprintf(b, "%s%s: %s -> %s\n", indent, field.Field, field.Old, field.New)
+	}
+}
+
+// fieldPair is a setting formatted in two definitions.
+type fieldPair struct {
+	field    string
+	old, new string
+}
+
+// diffFields returns the changes of the pairs whose values differ.
+func diffFields(pairs []fieldPair) []FieldChange {
+	var changes []FieldChange
+	for _, pair := range pairs {
+		if pair.old != pair.new {
+			changes = append(changes, FieldChange{Field: pair.field, Old: pair.old, New: pair.new})
+		}
+	}
+	return changes
+}
+
+// diffPhases returns the changes from phase a to phase b.
+func diffPhases(a, b PhaseDefinition) PhaseChange {
+	retryA, retryB := a.Retry, b.Retry
+	if retryA == nil {
+		retryA = &RetryDefinition{}
+	}
+	if retryB == nil {
+		retryB = &RetryDefinition{}
+	}
+	limitA, limitB := a.RateLimit, b.RateLimit
+	if limitA == nil {
+		limitA = &RateLimitDefinition{}
+	}
+	if limitB == nil {
+		limitB = &RateLimitDefinition{}
+	}
+
+	change := PhaseChange{Phase: b.Name}
+	change.Fields = diffFields([]fieldPair{
+		{"dependsOn", formatNames(a.DependsOn), formatNames(b.DependsOn)},
+		{"disabled", fmt.Sprint(a.Disabled), fmt.Sprint(b.Disabled)},
+		{"featureFlag", fmt.Sprintf("%q", a.FeatureFlag), fmt.Sprintf("%q", b.FeatureFlag)},
+		{"nonCritical", fmt.Sprint(a.NonCritical), fmt.Sprint(b.NonCritical)},
+		{"parallelHooks", fmt.Sprint(a.ParallelHooks), fmt.Sprint(b.ParallelHooks)},
+		{"dedupeHooks", fmt.Sprint(a.DedupeHooks), fmt.Sprint(b.DedupeHooks)},
+		{"allowNilValues", fmt.Sprint(a.AllowNilValues), fmt.Sprint(b.AllowNilValues)},
+		{"rateLimit.perSecond", fmt.Sprint(limitA.PerSecond), fmt.Sprint(limitB.PerSecond)},
+		{"rateLimit.burst", fmt.Sprint(limitA.Burst), fmt.Sprint(limitB.Burst)},
+		{"timeout", a.Timeout.String(), b.Timeout.String()},
+		{"retry.maxAttempts", fmt.Sprint(retryA.MaxAttempts), fmt.Sprint(retryB.MaxAttempts)},
+		{"retry.backoff", retryA.Backoff.String(), retryB.Backoff.String()},
+		{"retry.scope", fmt.Sprint(retryA.Scope), fmt.Sprint(retryB.Scope)},
+		{"maxConcurrent", fmt.Sprint(a.MaxConcurrent), fmt.Sprint(b.MaxConcurrent)},
+		{"weight", fmt.Sprint(a.Weight), fmt.Sprint(b.Weight)},
+		{"version", fmt.Sprintf("%q", a.Version), fmt.Sprintf("%q", b.Version)},
+		{"preHooks", formatNames(a.PreHooks), formatNames(b.PreHooks)},
+		{"postHooks", formatNames(a.PostHooks), formatNames(b.PostHooks)},
+		{"branches", formatBranches(a.Branches), formatBranches(b.Branches)},
+	})
+
+	for key, branchA := range a.Branches {
+		branchB, ok := b.Branches[key]
+		if !ok {
+			continue
+		}
+		if diff := DiffDefinitions(branchA, branchB); !diff.Empty() {
+			if change.Branches == nil {
+				change.Branches = make(map[string]PipelineDiff)
+			}
+			change.Branches[key] = diff
+		}
+	}
+	return change
+}
+
+// formatNames formats names, such as hook names, naming empty names
+// <unnamed>.
+func formatNames(names []string) string {
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		if name == "" {
+			name = "<unnamed>"
+		}
+		formatted[i] = name
+	}
+	return "[" + strings.Join(formatted, " ") + "]"
+}
+
+// formatBranches formats the sorted keys of branches.
+func formatBranches(branches map[string]PipelineDefinition) string {
+	keys := make([]string, 0, len(branches))
+	for key := range branches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return formatNames(keys)
+}
+
+// longestCommonSequence returns the names of a longest sequence of names in
+// the same order in a and b, which contain the same distinct names.
+func longestCommonSequence(a, b []string) map[string]bool {
+	// lengths[i][j] is the length of the longest sequence of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	kept := make(map[string]bool, lengths[0][0])
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			kept[a[i]] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return kept
+}
@@ -0,0 +1,96 @@
+package phaser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diffManager returns the manager of a pipeline with the phases named names,
+// configured by configure when set.
+func diffManager(t *testing.T, names []string, configure func(m *DefaultPhaseManager)) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	for _, name := range names {
+		p := NewPhase(name, addOne)
+		p.AppendNamedPreHook("trim", addOne)
+		require.NoError(t, m.AddPhase(p))
+	}
+	if configure != nil {
+		configure(m)
+	}
+	return m
+}
+
+func TestDiffGolden(t *testing.T) {
+	base := []string{"extract", "transform", "validate", "load"}
+	tests := []struct {
+		name   string
+		names  []string
+		change func(m *DefaultPhaseManager)
+	}{
+		{name: "unchanged", names: base},
+		{name: "phases", names: []string{"validate", "transform", "index", "load"}},
+		{name: "settings", names: base, change: func(m *DefaultPhaseManager) {
+			m.RetryBudget = 10
+			p := m.phase("transform")
+			p.Retry = &RetryPolicy{MaxAttempts: 5, Scope: HooksAndExecute}
+			p.Timeout = 5 * time.Second
+			p.AppendNamedPreHook("normalize", addOne)
+			p.appendPostHook(addOne)
+			m.phase("load").DependsOn = []string{"validate"}
+		}},
+	}
+
+	old := diffManager(t, base, func(m *DefaultPhaseManager) {
+		m.phase("transform").Retry = &RetryPolicy{MaxAttempts: 3}
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			new := diffManager(t, tt.names, func(m *DefaultPhaseManager) {
+				m.phase("transform").Retry = &RetryPolicy{MaxAttempts: 3}
+				if tt.change != nil {
+					tt.change(m)
+				}
+			})
+			assertGolden(t, "diff_"+tt.name+".golden", []byte(Diff(old, new).String()))
+		})
+	}
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	m := diffManager(t, []string{"one", "two"}, nil)
+	// Hooks are compared by name, so hooks with other functions are
+	// unchanged
+	other := diffManager(t, []string{"one", "two"}, nil)
+	other.phase("one").preHooks[0] = returnNil
+
+	diff := Diff(m, other)
+	assert.True(t, diff.Empty())
+	assert.Equal(t, PipelineDiff{}, diff)
+	assert.Equal(t, "no changes\n", diff.String())
+}
+
+func TestDiffDefinitionsBranches(t *testing.T) {
+	old := PipelineDefinition{Phases: []PhaseDefinition{{
+		Name: "route",
+		Branches: map[string]PipelineDefinition{
+			"a": {Phases: []PhaseDefinition{{Name: "one"}}},
+			"b": {Phases: []PhaseDefinition{{Name: "two"}}},
+		},
+	}}}
+	new := PipelineDefinition{Phases: []PhaseDefinition{{
+		Name: "route",
+		Branches: map[string]PipelineDefinition{
+			"a": {Phases: []PhaseDefinition{{Name: "one", Version: "2"}}},
+			"c": {Phases: []PhaseDefinition{{Name: "three"}}},
+		},
+	}}}
+
+	assert.Equal(t, "~ phase route\n"+
+		"    branches: [a b] -> [a c]\n"+
+		"    branch a:\n"+
+		"        ~ phase one\n"+
+		"            version: \"\" -> \"2\"\n", DiffDefinitions(old, new).String())
+}
@@ -0,0 +1,122 @@
+package phaser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToDOT renders the pipeline as a Graphviz DOT digraph with a node per phase.
+// Edges follow the phases' DependsOn relationships, or the execution order
+// when no phase declares dependencies. Disabled phases are drawn dashed and
+// grayed out, and branch points are drawn as diamonds with an edge labeled
+// with the key of each of their branches.
+func (m *DefaultPhaseManager) ToDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	m.writeDOT(&b, "", "\t")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// writeDOT writes the nodes and edges of m, prefixing node IDs with prefix. It
+// returns the IDs of the nodes through which the pipeline is entered and
+// exited.
+func (m *DefaultPhaseManager) writeDOT(b *strings.Builder, prefix, indent string) (entries, exits []string) {
+	phaseEntries := make(map[string][]string, len(m.phases))
+	phaseExits := make(map[string][]string, len(m.phases))
+	hasDependencies := false
+
+	for _, p := range m.phases {
+		id := prefix + p.Name
+		var attrs []string
+		if prefix != "" {
+			attrs = append(attrs, fmt.Sprintf("label=%q", p.Name))
+		}
+		if p.branches != nil {
+			attrs = append(attrs, "shape=diamond")
+		}
+		if p.Disabled {
+			attrs = append(attrs, "style=dashed", "color=gray", "fontcolor=gray")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(b, "%s%q [%s];\n", indent, id, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(b, "%s%q;\n", indent, id)
+		}
+
+		phaseEntries[p.Name] = []string{id}
+		phaseExits[p.Name] = []string{id}
+		if p.branches != nil {
+			phaseExits[p.Name] = writeBranchesDOT(b, id, p.branches, indent)
+		}
+		hasDependencies = hasDependencies || len(p.DependsOn) > 0
+	}
+
+	if !hasDependencies {
+		for i, p := range m.phases {
+			if i > 0 {
+				writeEdgesDOT(b, phaseExits[m.phases[i-1].Name], phaseEntries[p.Name], "", indent)
+			}
+		}
+		if len(m.phases) > 0 {
+			entries = phaseEntries[m.phases[0].Name]
+			exits = phaseExits[m.phases[len(m.phases)-1].Name]
+		}
+		return entries, exits
+	}
+
+	dependedOn := make(map[string]bool)
+	for _, p := range m.phases {
+		for _, dependency := range p.DependsOn {
+			dependedOn[dependency] = true
+			from, ok := phaseExits[dependency]
+			if !ok {
+				from = []string{prefix + dependency}
+			}
+			writeEdgesDOT(b, from, phaseEntries[p.Name], p.outputLabel(dependency), indent)
+		}
+		if len(p.DependsOn) == 0 {
+			entries = append(entries, phaseEntries[p.Name]...)
+		}
+	}
+	for _, p := range m.phases {
+		if !dependedOn[p.Name] {
+			exits = append(exits, phaseExits[p.Name]...)
+		}
+	}
+	return entries, exits
+}
+
+// writeBranchesDOT writes each branch of the branch point id as a cluster,
+// returning the IDs through which the branches are exited.
+func writeBranchesDOT(b *strings.Builder, id string, branches map[string]*DefaultPhaseManager, indent string) []string {
+	var exits []string
+	for _, key := range branchKeys(branches) {
+		fmt.Fprintf(b, "%ssubgraph %q {\n", indent, "cluster_"+id+"/"+key)
+		fmt.Fprintf(b, "%s\tlabel=%q;\n", indent, key)
+		entries, branchExits := branches[key].writeDOT(b, id+"/"+key+"/", indent+"\t")
+		fmt.Fprintf(b, "%s}\n", indent)
+
+		if len(entries) == 0 {
+			// Empty branches pass the value straight through
+			exits = append(exits, id)
+			continue
+		}
+		writeEdgesDOT(b, []string{id}, entries, key, indent)
+		exits = append(exits, branchExits...)
+	}
+	return exits
+}
+
+// writeEdgesDOT writes an edge from each node in from to each node in to.
+func writeEdgesDOT(b *strings.Builder, from, to []string, label, indent string) {
+	for _, f := range from {
+		for _, t := range to {
+			if label != "" {
+				fmt.Fprintf(b, "%s%q -> %q [label=%q];\n", indent, f, t, label)
+			} else {
+				fmt.Fprintf(b, "%s%q -> %q;\n", indent, f, t)
+			}
+		}
+	}
+}
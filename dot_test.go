@@ -0,0 +1,86 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToDOTLinear(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("a", addOne),
+		NewPhase("b", addOne),
+		NewPhase("c", addOne),
+	))
+	m.phases[1].Disabled = true
+
+	assert.Equal(t, `digraph pipeline {
+	"a";
+	"b" [style=dashed, color=gray, fontcolor=gray];
+	"c";
+	"a" -> "b";
+	"b" -> "c";
+}
+`, m.ToDOT())
+}
+
+func TestToDOTDiamond(t *testing.T) {
+	m := NewPhaseManager()
+	left := NewPhase("left", addOne)
+	left.DependsOn = []string{"source"}
+	right := NewPhase("right", addOne)
+	right.DependsOn = []string{"source"}
+	right.Disabled = true
+	sink := NewPhase("sink", addOne)
+	sink.DependsOn = []string{"left", "right"}
+	require.NoError(t, m.AddPhases(NewPhase("source", addOne), left, right, sink))
+
+	dot := m.ToDOT()
+	for _, line := range []string{
+		`"source";`,
+		`"left";`,
+		`"right" [style=dashed, color=gray, fontcolor=gray];`,
+		`"sink";`,
+		`"source" -> "left";`,
+		`"source" -> "right";`,
+		`"left" -> "sink";`,
+		`"right" -> "sink";`,
+	} {
+		assert.Contains(t, dot, "\t"+line+"\n")
+	}
+	assert.NotContains(t, dot, `"source" -> "sink"`)
+}
+
+func TestToDOTBranches(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(appendPhase("start", "-start")))
+	require.NoError(t, m.AddBranch("classify", classify, map[string]*DefaultPhaseManager{
+		"i": branchManager(t, "resize", "thumbnail"),
+		"p": branchManager(t, "ocr"),
+	}))
+	require.NoError(t, m.AddPhase(appendPhase("end", "-end")))
+
+	assert.Equal(t, `digraph pipeline {
+	"start";
+	"classify" [shape=diamond];
+	subgraph "cluster_classify/i" {
+		label="i";
+		"classify/i/resize" [label="resize"];
+		"classify/i/thumbnail" [label="thumbnail"];
+		"classify/i/resize" -> "classify/i/thumbnail";
+	}
+	"classify" -> "classify/i/resize" [label="i"];
+	subgraph "cluster_classify/p" {
+		label="p";
+		"classify/p/ocr" [label="ocr"];
+	}
+	"classify" -> "classify/p/ocr" [label="p"];
+	"end";
+	"start" -> "classify";
+	"classify/i/thumbnail" -> "end";
+	"classify/p/ocr" -> "end";
+}
+`, m.ToDOT())
+}
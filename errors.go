@@ -0,0 +1,145 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStopPipeline can be returned by hooks and execute functions to end the
+// run successfully. The run returns the value returned along with it, with no
+// error, and skips the remaining phases. Error handlers do not see it.
+var ErrStopPipeline = errors.New("stop pipeline")
+
+// RejectionError is returned by Reject.
+type RejectionError struct {
+	// Reason explains why the input was rejected
+	Reason string
+	// Value is the value the rejecting phase outputs
+	Value interface{}
+}
+
+func (e *RejectionError) Error() string {
+	return "rejected: " + e.Reason
+}
+
+// Reject returns an error rejecting the phase's input for reason. Hooks and
+// execute functions, typically validation pre-hooks, return it to reject the
+// input as a regular outcome rather than a failure: the rest of the phase is
+// skipped, error handlers do not run, and the phase outputs value with the
+// StatusRejected status. The run goes on with value as the input of the next
+// phase.
+func Reject(reason string, value interface{}) error {
+	return &RejectionError{Reason: reason, Value: value}
+}
+
+// PhaseError wraps an error returned while running a phase, identifying the
+// phase that failed.
+type PhaseError struct {
+	// Phase is the name of the phase that failed
+	Phase string
+	// Step is the name of the step that failed in phases created using
+	// NewSteppedPhase
+	Step string
+	// Stage is the stage that failed when it is a validation stage,
+	// StageInputValidation or StageOutputValidation
+	Stage Stage
+	// Err is the error returned by the phase
+	Err error
+}
+
+func (e *PhaseError) Error() string {
+	if e.Step != "" {
+		return fmt.Sprintf("phase %s: step %s: %v", e.Phase, e.Step, e.Err)
+	}
+	return fmt.Sprintf("phase %s: %v", e.Phase, e.Err)
+}
+
+func (e *PhaseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorContext describes the failure passed to an ErrorHandler.
+type ErrorContext struct {
+	// Phase is the name of the failing phase
+	Phase string
+	// Stage is the stage of the phase that failed
+	Stage Stage
+	// Value is the input of the hook or execute function that failed
+	Value interface{}
+}
+
+// ErrorHandler reacts to a phase failure. A handler reporting handled ends the
+// phase with the returned value and error, so returning a nil error recovers
+// the phase. Otherwise the next handler runs, and any error returned is joined
+// with the original one.
+type ErrorHandler func(ec ErrorContext, err error) (handled bool, value interface{}, handlerErr error)
+
+// AppendErrorHandler appends handler to the phase's error handler chain.
+// Handlers run in the order they were appended, and handleError runs after
+// the last one.
+func (p *Phase) AppendErrorHandler(handler ErrorHandler) {
+	p.checkMutation()
+	p.errorHandlers = append(p.errorHandlers, handler)
+}
+
+// FlattenErrors returns the errors joined in err, recursively, in the order
+// they were joined. Errors joined by the package always follow the order in
+// which phases, hooks and handlers ran, so the result is deterministic. An
+// error wrapping joined errors is replaced by them, and other errors are
+// returned as is. It returns nil when err is nil.
+func FlattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, FlattenErrors(e)...)
+		}
+		return errs
+	}
+	if errs := FlattenErrors(errors.Unwrap(err)); len(errs) > 1 {
+		return errs
+	}
+	return []error{err}
+}
+
+// handleErrorChain passes err, returned by stage while processing value and
+// mapped by the phase's ErrorTransform, to the phase's error handlers until
+// one of them handles it. When none does, the phase's DefaultOnError may
+// replace it by a default output, or else the error is handed to
+// handleError. ErrStopPipeline is returned as is along with value, and
+// rejections along with their value.
+func (p *Phase) handleErrorChain(stage Stage, value interface{}, err error) (interface{}, error) {
+	if errors.Is(err, ErrStopPipeline) {
+		return value, err
+	}
+	var rejection *RejectionError
+	if errors.As(err, &rejection) {
+		return rejection.Value, err
+	}
+	if p.ErrorTransform != nil {
+		if transformed := p.ErrorTransform(err); transformed != nil {
+			err = transformed
+		}
+	}
+	ec := ErrorContext{Phase: p.Name, Stage: stage, Value: value}
+	result := err
+
+	for _, handler := range p.errorHandlers {
+		handled, handledValue, handlerErr := handler(ec, err)
+		if handled {
+			return handledValue, handlerErr
+		}
+		if handlerErr != nil {
+			result = errors.Join(result, handlerErr)
+		}
+	}
+	if p.DefaultOnError != nil {
+		if defaultValue, ok := p.DefaultOnError(err); ok {
+			return defaultValue, nil
+		}
+	}
+
+	return p.handleError(result)
+}
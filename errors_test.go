@@ -0,0 +1,323 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errNotFound is an error recovered by the tests' error handlers.
+var errNotFound = errors.New("not found")
+
+// failWith returns an execute function failing with err.
+func failWith(err error) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		return nil, err
+	}
+}
+
+func TestErrorHandlerSwallowsSpecificErrors(t *testing.T) {
+	var contexts []ErrorContext
+	handler := func(ec ErrorContext, err error) (bool, interface{}, error) {
+		contexts = append(contexts, ec)
+		if errors.Is(err, errNotFound) {
+			return true, "default", nil
+		}
+		return false, nil, nil
+	}
+
+	p := NewPhase("lookup", failWith(errNotFound))
+	p.AppendErrorHandler(handler)
+	value, err := p.run("input")
+	require.NoError(t, err)
+	assert.Equal(t, "default", value)
+	assert.Equal(t, []ErrorContext{{Phase: "lookup", Stage: StageExecute, Value: "input"}}, contexts)
+
+	// Other errors propagate untouched
+	p = NewPhase("lookup", failWith(assert.AnError))
+	p.AppendErrorHandler(handler)
+	value, err = p.run("input")
+	assert.Nil(t, value)
+	assert.Equal(t, assert.AnError, err)
+}
+
+func TestErrorHandlerOrder(t *testing.T) {
+	var calls []string
+	p := NewPhase("lookup", failWith(errNotFound))
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		return value, errNotFound
+	})
+	p.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		calls = append(calls, "metrics")
+		return false, nil, nil
+	})
+	p.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		calls = append(calls, "recover")
+		assert.Equal(t, StagePreHook, ec.Stage)
+		return true, "recovered", nil
+	})
+	p.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		calls = append(calls, "unreachable")
+		return true, nil, nil
+	})
+
+	value, err := p.run("input")
+	require.NoError(t, err)
+	assert.Equal(t, "recovered", value)
+	assert.Equal(t, []string{"metrics", "recover"}, calls)
+}
+
+func TestErrorHandlerErrorsAreJoined(t *testing.T) {
+	handlerErr := errors.New("compensation failed")
+	p := NewPhase("lookup", failWith(errNotFound))
+	p.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		return false, nil, handlerErr
+	})
+
+	_, err := p.run("input")
+	assert.ErrorIs(t, err, errNotFound)
+	assert.ErrorIs(t, err, handlerErr)
+}
+
+func TestErrorTransform(t *testing.T) {
+	errInternal := errors.New("connecting to 10.0.0.1: refused")
+	errUnavailable := errors.New("lookup unavailable")
+	sanitize := func(err error) error {
+		if errors.Is(err, errInternal) {
+			return errUnavailable
+		}
+		return nil
+	}
+
+	var seen []error
+	p := NewPhase("lookup", failWith(errInternal))
+	p.ErrorTransform = sanitize
+	p.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		seen = append(seen, err)
+		return false, nil, nil
+	})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	// Handlers and the caller see the transformed error
+	_, err := m.Run("input")
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.NotErrorIs(t, err, errInternal)
+	assert.NotContains(t, err.Error(), "10.0.0.1")
+	assert.Equal(t, []error{errUnavailable}, seen)
+
+	// Hook errors are transformed too, while transforms returning nil leave
+	// the error unchanged
+	p = NewPhase("lookup", addOne)
+	p.ErrorTransform = sanitize
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		return value, errInternal
+	})
+	_, err = p.run(0)
+	assert.Equal(t, errUnavailable, err)
+	p = NewPhase("lookup", failWith(errNotFound))
+	p.ErrorTransform = sanitize
+	_, err = p.run(0)
+	assert.Equal(t, errNotFound, err)
+}
+
+func TestErrorTransformSkipsStops(t *testing.T) {
+	p := NewPhase("stop", func(value interface{}) (interface{}, error) {
+		return value, ErrStopPipeline
+	})
+	p.ErrorTransform = func(err error) error {
+		return assert.AnError
+	}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(p, NewPhase("unreachable", addOne)))
+
+	value, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
+
+// stopPipeline is a hook stopping the run.
+func stopPipeline(value interface{}) (interface{}, error) {
+	return value, ErrStopPipeline
+}
+
+func TestDefaultOnError(t *testing.T) {
+	newManager := func(err error) *DefaultPhaseManager {
+		lookup := NewPhase("lookup", failWith(err))
+		lookup.DefaultOnError = func(err error) (interface{}, bool) {
+			if errors.Is(err, errNotFound) {
+				return 0, true
+			}
+			return nil, false
+		}
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhases(lookup, NewPhase("increment", addOne)))
+		return m
+	}
+
+	var report RunReport
+	value, err := newManager(errNotFound).Run("input", WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, StatusSucceeded, report.Phases[0].Status)
+
+	// Unexpected errors abort the run
+	_, err = newManager(assert.AnError).Run("input", WithReport(&report))
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Len(t, report.Phases, 1)
+}
+
+func TestStopPipelineFromPostHook(t *testing.T) {
+	var calls [3]int
+	two := countingPhase("two", &calls[1], nil)
+	two.appendPostHook(stopPipeline)
+	handled := false
+	two.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		handled = true
+		return false, nil, nil
+	})
+
+	var report RunReport
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		countingPhase("one", &calls[0], nil),
+		two,
+		countingPhase("three", &calls[2], nil),
+	))
+
+	value, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, [3]int{1, 1, 0}, calls)
+	assert.False(t, handled)
+	require.Len(t, report.Phases, 2)
+	assert.Equal(t, StatusSucceeded, report.Phases[1].Status)
+	assert.NoError(t, report.Err)
+}
+
+func TestStopPipelineFromExecute(t *testing.T) {
+	var calls int
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("stop", stopPipeline),
+		countingPhase("three", &calls, nil),
+	))
+
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Zero(t, calls)
+}
+
+func TestStopPipelineWithinBranch(t *testing.T) {
+	branch := branchManager(t, "-a")
+	branch.phases[0].appendPostHook(stopPipeline)
+	require.NoError(t, branch.AddPhase(appendPhase("b", "-b")))
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddBranch("classify", classify, map[string]*DefaultPhaseManager{"x": branch}))
+	require.NoError(t, m.AddPhase(appendPhase("end", "-end")))
+
+	value, err := m.Run("x")
+	require.NoError(t, err)
+	assert.Equal(t, "x-a", value)
+}
+
+func TestRejectContinuesRun(t *testing.T) {
+	executed, handled := false, false
+	two := NewPhase("two", func(value interface{}) (interface{}, error) {
+		executed = true
+		return value, nil
+	})
+	two.appendPreHook(func(value interface{}) (interface{}, error) {
+		if value.(int) > 0 {
+			return nil, Reject("positive value", -1)
+		}
+		return value, nil
+	})
+	two.appendPostHook(failWith(assert.AnError))
+	two.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		handled = true
+		return false, nil, nil
+	})
+
+	var report RunReport
+	o := &recordingObserver{}
+	m := NewPhaseManager(WithObserver(o))
+	require.NoError(t, m.AddPhases(NewPhase("one", addOne), two, NewPhase("three", addOne)))
+
+	value, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	// The rejection value is passed on to the third phase
+	assert.Equal(t, 0, value)
+	assert.False(t, executed)
+	assert.False(t, handled)
+
+	result, ok := report.Result("two")
+	require.True(t, ok)
+	assert.Equal(t, StatusRejected, result.Status)
+	var rejection *RejectionError
+	require.True(t, errors.As(result.Err, &rejection))
+	assert.Equal(t, "positive value", rejection.Reason)
+	assert.Equal(t, map[string]PhaseStatus{
+		"one":   StatusSucceeded,
+		"two":   StatusRejected,
+		"three": StatusSucceeded,
+	}, o.statuses())
+}
+
+func TestRejectIsNotRetried(t *testing.T) {
+	calls := 0
+	p := NewPhase("one", func(value interface{}) (interface{}, error) {
+		calls++
+		return nil, Reject("invalid", value)
+	}, WithRetry(RetryPolicy{MaxAttempts: 3}))
+
+	value, err := p.run(1)
+	assert.EqualError(t, err, "rejected: invalid")
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 1, calls)
+}
+
+func TestFlattenErrors(t *testing.T) {
+	errA, errB, errC := errors.New("a"), errors.New("b"), errors.New("c")
+	wrapped := fmt.Errorf("wrapped: %w", errA)
+
+	assert.Nil(t, FlattenErrors(nil))
+	assert.Equal(t, []error{wrapped}, FlattenErrors(wrapped))
+	assert.Equal(t, []error{errA, errB, errC}, FlattenErrors(errors.Join(errors.Join(errA, errB), nil, errC)))
+	assert.Equal(t, []error{errA, errB}, FlattenErrors(fmt.Errorf("wrapped: %w", errors.Join(errA, errB))))
+}
+
+func TestFlattenErrorsExecutionOrder(t *testing.T) {
+	errHook, errBroken := errors.New("hook"), errors.New("broken")
+	validate := NewPhase("validate", addOne, WithNonCritical())
+	validate.ParallelHooks = true
+	for i := 0; i < 3; i++ {
+		i := i
+		validate.appendPreHook(func(value interface{}) (interface{}, error) {
+			// Later hooks fail first
+			time.Sleep(time.Duration(3-i) * time.Millisecond)
+			return value, fmt.Errorf("%w %d", errHook, i)
+		})
+	}
+	m := NewPhaseManager(WithMaxFailures(1))
+	require.NoError(t, m.AddPhases(
+		validate,
+		NewPhase("broken", failWith(errBroken), WithNonCritical()),
+	))
+
+	_, err := m.Run(0)
+	errs := FlattenErrors(err)
+	require.Len(t, errs, 5)
+	assert.ErrorIs(t, errs[0], ErrFailureBudgetExceeded)
+	for i := 0; i < 3; i++ {
+		assert.EqualError(t, errs[1+i], fmt.Sprintf("hook %d", i))
+	}
+	assert.ErrorIs(t, errs[4], errBroken)
+}
@@ -0,0 +1,176 @@
+package phaser
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ExecutionTrace is a record of a whole run meant for post-mortem analysis,
+// such as to attach to logs or to store for later inspection. Unlike
+// RunReport, it leaves out the values of the run, and marshals to JSON with
+// errors as their messages and durations as strings.
+type ExecutionTrace struct {
+	// Start is the time the run started
+	Start time.Time
+	// Duration is the time the run took
+	Duration time.Duration
+	// Queued is the time the run waited in a Scheduler's queue
+	Queued time.Duration
+	// Err is the error returned by the run, if any
+	Err error
+	// Phases contains the trace of each phase in the order they ran,
+	// including the skipped phases
+	Phases []PhaseTrace
+}
+
+// PhaseTrace is the trace of a phase in an ExecutionTrace.
+type PhaseTrace struct {
+	Phase  string
+	Status PhaseStatus
+	// Start is the time the phase started running
+	Start time.Time
+	// Duration is the time the phase took to run, including Queued
+	Duration time.Duration
+	// Queued is the time the phase spent waiting for concurrency limits
+	Queued time.Duration
+	// Retries is the number of times the phase was retried
+	Retries int
+	// Attempts contains the result of each attempt of phases retried with
+	// the HooksAndExecute scope
+	Attempts []AttemptResult
+	// Steps contains the result of each step of stepped phases
+	Steps []StepResult
+	// Case is the key of the case or branch selected by switch phases and
+	// branch points
+	Case string
+	// Err is the error returned by the phase, if any
+	Err error
+	// Artifacts contains the artifacts attached by the phase
+	Artifacts Artifacts
+}
+
+// WithExecutionTrace fills trace with the trace of the run once it ends.
+func WithExecutionTrace(trace *ExecutionTrace) RunOption {
+	return func(c *runConfig) {
+		c.trace = trace
+	}
+}
+
+// NewExecutionTrace returns the trace of the run described by report.
+func NewExecutionTrace(report RunReport) ExecutionTrace {
+	trace := ExecutionTrace{
+		Start:    report.Start,
+		Duration: report.Duration,
+		Queued:   report.Queued,
+		Err:      report.Err,
+		Phases:   make([]PhaseTrace, len(report.Phases)),
+	}
+	for i, result := range report.Phases {
+		trace.Phases[i] = PhaseTrace{
+			Phase:     result.Phase,
+			Status:    result.Status,
+			Start:     result.Start,
+			Duration:  result.Duration,
+			Queued:    result.Queued,
+			Retries:   result.Retries,
+			Attempts:  result.Attempts,
+			Steps:     result.Steps,
+			Case:      result.Case,
+			Err:       result.Err,
+			Artifacts: result.Artifacts,
+		}
+	}
+	return trace
+}
+
+// MarshalJSON marshals the trace, leaving out the unset fields.
+func (t ExecutionTrace) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Start    time.Time    `json:"start"`
+		Duration string       `json:"duration"`
+		Queued   string       `json:"queued,omitempty"`
+		Err      string       `json:"error,omitempty"`
+		Phases   []PhaseTrace `json:"phases"`
+	}{
+		Start:    t.Start,
+		Duration: t.Duration.String(),
+		Queued:   formatDuration(t.Queued),
+		Err:      errorMessage(t.Err),
+		Phases:   t.Phases,
+	})
+}
+
+// MarshalJSON marshals the trace of the phase, leaving out the unset fields.
+func (t PhaseTrace) MarshalJSON() ([]byte, error) {
+	type attempt struct {
+		Attempt  int    `json:"attempt"`
+		PreHooks string `json:"preHooks,omitempty"`
+		Execute  string `json:"execute,omitempty"`
+		Err      string `json:"error,omitempty"`
+	}
+	type step struct {
+		Step     string `json:"step"`
+		Duration string `json:"duration"`
+		Err      string `json:"error,omitempty"`
+	}
+
+	var attempts []attempt
+	for _, a := range t.Attempts {
+		attempts = append(attempts, attempt{
+			Attempt:  a.Attempt,
+			PreHooks: formatDuration(a.PreHooks),
+			Execute:  formatDuration(a.Execute),
+			Err:      errorMessage(a.Err),
+		})
+	}
+	var steps []step
+	for _, s := range t.Steps {
+		steps = append(steps, step{Step: s.Step, Duration: s.Duration.String(), Err: errorMessage(s.Err)})
+	}
+	var start *time.Time
+	if !t.Start.IsZero() {
+		start = &t.Start
+	}
+
+	return json.Marshal(struct {
+		Phase     string      `json:"phase"`
+		Status    PhaseStatus `json:"status"`
+		Start     *time.Time  `json:"start,omitempty"`
+		Duration  string      `json:"duration,omitempty"`
+		Queued    string      `json:"queued,omitempty"`
+		Retries   int         `json:"retries,omitempty"`
+		Attempts  []attempt   `json:"attempts,omitempty"`
+		Steps     []step      `json:"steps,omitempty"`
+		Case      string      `json:"case,omitempty"`
+		Err       string      `json:"error,omitempty"`
+		Artifacts Artifacts   `json:"artifacts,omitempty"`
+	}{
+		Phase:     t.Phase,
+		Status:    t.Status,
+		Start:     start,
+		Duration:  formatDuration(t.Duration),
+		Queued:    formatDuration(t.Queued),
+		Retries:   t.Retries,
+		Attempts:  attempts,
+		Steps:     steps,
+		Case:      t.Case,
+		Err:       errorMessage(t.Err),
+		Artifacts: t.Artifacts,
+	})
+}
+
+// formatDuration formats d, formatting zero durations as an empty string.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// errorMessage returns the message of err, or an empty string when nil.
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,62 @@
+package phaser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionTrace(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewPhaseManager()
+	m.clock = clock
+	failures := 1
+	require.NoError(t, m.AddPhases(
+		sleepPhase("parse", clock, time.Second),
+		NewPhase("fetch", func(value interface{}) (interface{}, error) {
+			clock.now = clock.now.Add(2 * time.Second)
+			if failures > 0 {
+				failures--
+				return nil, errors.New("connection reset")
+			}
+			return value, nil
+		}, WithRetry(RetryPolicy{MaxAttempts: 3})),
+		sleepPhase("enrich", clock, time.Second),
+		NewPhaseContext("notify", func(ctx context.Context, value interface{}) (interface{}, error) {
+			AddArtifact(ctx, "recipient", "ops")
+			return nil, errors.New("mailbox full")
+		}, WithNonCritical()),
+	))
+	m.phases[2].Disabled = true
+
+	var trace ExecutionTrace
+	_, err := m.Run(1, WithExecutionTrace(&trace))
+	require.NoError(t, err)
+
+	require.Len(t, trace.Phases, 4)
+	assert.Equal(t, 1, trace.Phases[1].Retries)
+	assert.Equal(t, StatusSkipped, trace.Phases[2].Status)
+	assert.EqualError(t, trace.Phases[3].Err, "phase notify: mailbox full")
+	assert.Equal(t, 5*time.Second, trace.Duration)
+
+	data, err := json.MarshalIndent(trace, "", "  ")
+	require.NoError(t, err)
+	assertGolden(t, "exectrace.golden", append(data, '\n'))
+}
+
+func TestExecutionTraceWithReport(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(NewPhase("one", addOne), NewPhase("two", failWith(assert.AnError))))
+
+	var report RunReport
+	var trace ExecutionTrace
+	_, err := m.Run(0, WithReport(&report), WithExecutionTrace(&trace))
+	require.Error(t, err)
+	assert.Equal(t, NewExecutionTrace(report), trace)
+	assert.ErrorIs(t, trace.Err, assert.AnError)
+}
@@ -0,0 +1,31 @@
+package phaser
+
+import "context"
+
+// FlagProvider reports the state of feature flags, such as from a central
+// feature flag service. It is called before each phase with a FeatureFlag,
+// so that flags can be toggled between and during runs, and must be safe for
+// concurrent use.
+type FlagProvider interface {
+	// Enabled reports whether flag is enabled
+	Enabled(flag string) bool
+}
+
+// WithFlagProvider gates the phases with a FeatureFlag using provider: runs
+// skip them unless provider reports their flag enabled. Without a provider,
+// phases run regardless of their FeatureFlag.
+func WithFlagProvider(provider FlagProvider) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.flags = provider
+	}
+}
+
+// flagEnabled reports whether the feature flag of the phase is enabled for
+// the run using ctx.
+func (p *Phase) flagEnabled(ctx context.Context) bool {
+	if p.FeatureFlag == "" {
+		return true
+	}
+	flags := runStateFrom(ctx).flags
+	return flags == nil || flags.Enabled(p.FeatureFlag)
+}
@@ -0,0 +1,64 @@
+package phaser
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFlags is a FlagProvider holding the enabled flags.
+type fakeFlags struct {
+	mu      sync.Mutex
+	enabled map[string]bool
+}
+
+func (f *fakeFlags) Enabled(flag string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.enabled[flag]
+}
+
+func (f *fakeFlags) set(flag string, enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enabled[flag] = enabled
+}
+
+func TestFeatureFlag(t *testing.T) {
+	flags := &fakeFlags{enabled: map[string]bool{}}
+	m := NewPhaseManager(WithFlagProvider(flags))
+	gated := NewPhase("gated", addOne)
+	gated.FeatureFlag = "new-scoring"
+	require.NoError(t, m.AddPhase(NewPhase("first", addOne)))
+	require.NoError(t, m.AddPhase(gated))
+
+	var report RunReport
+	output, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 1, output)
+	assert.Equal(t, StatusSkipped, report.Phases[1].Status)
+
+	flags.set("new-scoring", true)
+	output, err = m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 2, output)
+	assert.Equal(t, StatusSucceeded, report.Phases[1].Status)
+
+	flags.set("new-scoring", false)
+	output, err = m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, output)
+}
+
+func TestFeatureFlagWithoutProvider(t *testing.T) {
+	m := NewPhaseManager()
+	gated := NewPhase("gated", addOne)
+	gated.FeatureFlag = "new-scoring"
+	require.NoError(t, m.AddPhase(gated))
+
+	output, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, output)
+}
@@ -0,0 +1,204 @@
+package phaser
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Checkpoint is called periodically by long running hooks and execute
+// functions with the context they receive. It returns the context's error
+// once the run is cancelled or times out, and ErrRunStopped once the run is
+// stopped using Stop, so that the caller can return early. Otherwise it
+// records a heartbeat of the running phase, returned by LastHeartbeat and
+// used by WithStallDetection.
+func Checkpoint(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	state := runStateFrom(ctx)
+	if state.stepper.isStopped() {
+		return ErrRunStopped
+	}
+	result := phaseResultFrom(ctx)
+	now := state.heartbeats.beat(result.Phase)
+	state.watchdog.beat(result, now)
+	return nil
+}
+
+// LastHeartbeat returns the time the phase named phase last called Checkpoint
+// in any run of the manager, or the zero time if it never did.
+func (m *DefaultPhaseManager) LastHeartbeat(phase string) time.Time {
+	return m.heartbeats.last(phase)
+}
+
+// heartbeats records the last heartbeat of the phases of a manager. A nil
+// *heartbeats records nothing.
+type heartbeats struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	beats map[string]time.Time
+}
+
+// beat records a heartbeat of the phase named phase, returning its time.
+func (h *heartbeats) beat(phase string) time.Time {
+	if h == nil {
+		return time.Now()
+	}
+	now := h.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.beats[phase] = now
+	return now
+}
+
+// last returns the last heartbeat of the phase named phase.
+func (h *heartbeats) last(phase string) time.Time {
+	if h == nil {
+		return time.Time{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.beats[phase]
+}
+
+// StallObserver is implemented by observers notified of stalled phases
+// detected by WithStallDetection. OnPhaseStalled is called from a separate
+// goroutine, while the phase keeps running.
+type StallObserver interface {
+	// OnPhaseStalled is called with the time elapsed since the phase named
+	// phase started or last called Checkpoint
+	OnPhaseStalled(phase string, silence time.Duration)
+}
+
+// WithStallDetection watches the phases of every run, flagging the ones that
+// do not call Checkpoint for longer than window, counting from their start.
+// Stalled phases are not interrupted: they are marked as Stalled in the run's
+// report, and the observers implementing StallObserver are notified.
+func WithStallDetection(window time.Duration) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.stallWindow = window
+	}
+}
+
+// watchdog detects the stalled phases of a run. A nil *watchdog detects
+// nothing.
+type watchdog struct {
+	window    time.Duration
+	now       func() time.Time
+	observers []Observer
+	done      chan struct{}
+
+	mu sync.Mutex
+	// frames contains the running phases, nested phases last
+	frames []*watchFrame
+}
+
+// watchFrame is a phase watched by a watchdog.
+type watchFrame struct {
+	result *PhaseResult
+	// last is the time of the phase's start or last heartbeat
+	last    time.Time
+	flagged bool
+}
+
+// startWatchdog starts watching the phases of the run of m, checking them
+// every interval.
+func (m *DefaultPhaseManager) startWatchdog(interval time.Duration) *watchdog {
+	w := &watchdog{window: m.stallWindow, now: m.now, observers: m.observers, done: make(chan struct{})}
+	if interval <= 0 {
+		interval = w.window / 4
+	}
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.check()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+// stop stops watching the run.
+func (w *watchdog) stop() {
+	if w != nil {
+		close(w.done)
+	}
+}
+
+// begin starts watching the phase whose result is result.
+func (w *watchdog) begin(result *PhaseResult) {
+	if w == nil {
+		return
+	}
+	now := w.now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.frames = append(w.frames, &watchFrame{result: result, last: now})
+}
+
+// end stops watching the phase whose result is result.
+func (w *watchdog) end(result *PhaseResult) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, frame := range w.frames {
+		if frame.result == result {
+			w.frames = append(w.frames[:i], w.frames[i+1:]...)
+			return
+		}
+	}
+}
+
+// beat records a heartbeat at now of the phase whose result is result.
+func (w *watchdog) beat(result *PhaseResult, now time.Time) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, frame := range w.frames {
+		if frame.result == result {
+			frame.last = now
+		}
+	}
+}
+
+// check flags the phases that stalled since the last check.
+func (w *watchdog) check() {
+	type stall struct {
+		phase   string
+		silence time.Duration
+	}
+	var stalls []stall
+
+	now := w.now()
+	w.mu.Lock()
+	for _, frame := range w.frames {
+		if silence := now.Sub(frame.last); !frame.flagged && silence > w.window {
+			frame.flagged = true
+			frame.result.Stalled = true
+			stalls = append(stalls, stall{frame.result.Phase, silence})
+		}
+	}
+	w.mu.Unlock()
+
+	for _, s := range stalls {
+		for _, o := range w.observers {
+			if so, ok := o.(StallObserver); ok {
+				so.OnPhaseStalled(s.phase, s.silence)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package phaser
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncClock is a manually advanced clock safe for concurrent use.
+type syncClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *syncClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep and After advance the clock by d at once.
+func (c *syncClock) Sleep(d time.Duration) {
+	c.advance(d)
+}
+
+func (c *syncClock) After(d time.Duration) <-chan time.Time {
+	c.advance(d)
+	return firedAfter(c.Now())
+}
+
+func (c *syncClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// stallObserver sends the phases it is notified as stalled to its channel.
+type stallObserver chan string
+
+func (o stallObserver) OnPhaseStart(phase string, value interface{}) {}
+func (o stallObserver) OnPhaseEnd(result PhaseResult)                {}
+func (o stallObserver) OnPhaseStalled(phase string, silence time.Duration) {
+	o <- phase
+}
+
+func TestCheckpointCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhaseContext("loop", func(ctx context.Context, value interface{}) (interface{}, error) {
+		for i := 0; ; i++ {
+			if i == 3 {
+				cancel()
+			}
+			if err := Checkpoint(ctx); err != nil {
+				return i, err
+			}
+		}
+	})))
+
+	_, err := m.RunContext(ctx, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCheckpointStopped(t *testing.T) {
+	m := NewPhaseManager(WithStepping())
+	m.Resume()
+	require.NoError(t, m.AddPhase(NewPhaseContext("loop", func(ctx context.Context, value interface{}) (interface{}, error) {
+		m.Stop()
+		return nil, Checkpoint(ctx)
+	})))
+
+	_, err := m.Run(0)
+	assert.ErrorIs(t, err, ErrRunStopped)
+}
+
+func TestLastHeartbeat(t *testing.T) {
+	clock := &syncClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewPhaseManager()
+	m.clock = clock
+	require.NoError(t, m.AddPhase(NewPhaseContext("beat", func(ctx context.Context, value interface{}) (interface{}, error) {
+		clock.advance(time.Minute)
+		return value, Checkpoint(ctx)
+	})))
+
+	assert.True(t, m.LastHeartbeat("beat").IsZero())
+	_, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now(), m.LastHeartbeat("beat"))
+}
+
+func TestStallDetection(t *testing.T) {
+	clock := &syncClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	stalls := make(stallObserver, 1)
+	m := NewPhaseManager(WithStallDetection(time.Minute), WithObserver(stalls))
+	m.clock = clock
+	m.stallInterval = time.Millisecond
+	require.NoError(t, m.AddPhases(
+		NewPhaseContext("beating", func(ctx context.Context, value interface{}) (interface{}, error) {
+			for i := 0; i < 4; i++ {
+				clock.advance(30 * time.Second)
+				if err := Checkpoint(ctx); err != nil {
+					return nil, err
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			return value, nil
+		}),
+		NewPhaseContext("stuck", func(ctx context.Context, value interface{}) (interface{}, error) {
+			clock.advance(2 * time.Minute)
+			// The phase is flagged, but keeps running
+			assert.Equal(t, "stuck", <-stalls)
+			return value, Checkpoint(ctx)
+		}),
+	))
+
+	var report RunReport
+	_, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.False(t, report.Phases[0].Stalled)
+	assert.True(t, report.Phases[1].Stalled)
+}
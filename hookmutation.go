@@ -0,0 +1,42 @@
+package phaser
+
+import "reflect"
+
+// HookMutation records whether a call to a hook changed the value.
+type HookMutation struct {
+	// Stage is the stage running the hook, StagePreHook or StagePostHook
+	Stage Stage
+	// Hook is the name of the hook, or its index for unnamed hooks
+	Hook string
+	// Mutated is set when the hook's output differs from its input
+	Mutated bool
+}
+
+// WithMutationRecorder records whether each call to the hooks of the phases
+// changed the value, comparing the input and output of the hooks using
+// equal, or reflect.DeepEqual when nil. The records are stored in the
+// Mutations of the phase results of the run's report, so that hooks passing
+// the value through can be found. Hooks run using ParallelHooks may not
+// change the value, so they are not recorded.
+//
+// Hooks changing values holding pointers, maps or slices in place return a
+// value equal to their input, so they are recorded as not mutating it.
+func WithMutationRecorder(equal func(a, b interface{}) bool) ManagerOption {
+	if equal == nil {
+		equal = reflect.DeepEqual
+	}
+	return func(m *DefaultPhaseManager) {
+		m.mutations = &mutationRecorder{equal: equal}
+	}
+}
+
+// mutationRecorder records which hooks changed the value.
+type mutationRecorder struct {
+	equal func(a, b interface{}) bool
+}
+
+// record adds to result whether the hook named hook of stage changed input
+// into output.
+func (r *mutationRecorder) record(result *PhaseResult, stage Stage, hook string, input, output interface{}) {
+	result.Mutations = append(result.Mutations, HookMutation{Stage: stage, Hook: hook, Mutated: !r.equal(input, output)})
+}
@@ -0,0 +1,63 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMutationRecorder(t *testing.T) {
+	pass := func(value interface{}) (interface{}, error) {
+		return value, nil
+	}
+	p := NewPhase("double", func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	})
+	p.AppendNamedPreHook("validate", pass)
+	p.AppendNamedPreHook("increment", addOne)
+	p.AppendNamedPostHook("", pass)
+	m := NewPhaseManager(WithMutationRecorder(func(a, b interface{}) bool {
+		return a == b
+	}))
+	require.NoError(t, m.AddPhases(p, NewPhase("plain", addOne)))
+
+	var report RunReport
+	value, err := m.Run(1, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 5, value)
+	assert.Equal(t, []HookMutation{
+		{Stage: StagePreHook, Hook: "validate"},
+		{Stage: StagePreHook, Hook: "increment", Mutated: true},
+		{Stage: StagePostHook, Hook: "0"},
+	}, report.Phases[0].Mutations)
+	assert.Empty(t, report.Phases[1].Mutations)
+}
+
+func TestMutationRecorderDefaultsToDeepEqual(t *testing.T) {
+	p := NewPhase("pass", func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+	p.AppendNamedPreHook("copy", func(value interface{}) (interface{}, error) {
+		return append([]int(nil), value.([]int)...), nil
+	})
+	m := NewPhaseManager(WithMutationRecorder(nil))
+	require.NoError(t, m.AddPhase(p))
+
+	var report RunReport
+	_, err := m.Run([]int{1, 2}, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, []HookMutation{{Stage: StagePreHook, Hook: "copy"}}, report.Phases[0].Mutations)
+}
+
+func TestMutationRecorderDisabled(t *testing.T) {
+	p := NewPhase("double", addOne)
+	p.AppendNamedPreHook("increment", addOne)
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	var report RunReport
+	_, err := m.Run(1, WithReport(&report))
+	require.NoError(t, err)
+	assert.Nil(t, report.Phases[0].Mutations)
+}
@@ -0,0 +1,73 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedHooksDeduplicated(t *testing.T) {
+	calls := 0
+	audit := func(value interface{}) (interface{}, error) {
+		calls++
+		return value, nil
+	}
+	double := func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	}
+
+	p := NewPhase("one", addOne)
+	p.DedupeHooks = true
+	p.AppendNamedPreHook("audit", audit)
+	p.appendPreHook(double)
+	p.AppendNamedPreHook("audit", audit)
+	p.AppendNamedPostHook("audit", audit)
+	p.AppendNamedPostHook("audit", audit)
+
+	require.Len(t, p.preHooks, 2)
+	require.Len(t, p.postHooks, 1)
+	assert.Equal(t, "audit", p.hookName(&p.preHooks, 0))
+	assert.Equal(t, "", p.hookName(&p.preHooks, 1))
+
+	value, err := p.run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+	assert.Equal(t, 2, calls)
+}
+
+func TestNamedHooksReplaceKeepsPosition(t *testing.T) {
+	hook := func(n int) PhaseHook {
+		return func(value interface{}) (interface{}, error) {
+			return append(value.([]int), n), nil
+		}
+	}
+
+	p := NewPhase("one", func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+	p.DedupeHooks = true
+	p.AppendNamedPreHook("first", hook(1))
+	p.AppendNamedPreHook("second", hook(2))
+	p.prependPreHook(hook(0))
+	p.AppendNamedPreHook("first", hook(10))
+
+	value, err := p.run([]int{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 10, 2}, value)
+}
+
+func TestNamedHooksWithoutDedupe(t *testing.T) {
+	calls := 0
+	p := NewPhase("one", addOne)
+	for i := 0; i < 2; i++ {
+		p.AppendNamedPreHook("audit", func(value interface{}) (interface{}, error) {
+			calls++
+			return value, nil
+		})
+	}
+
+	_, err := p.run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
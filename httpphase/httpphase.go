@@ -0,0 +1,94 @@
+// Package httpphase provides phases calling HTTP services, and adapters
+// between pipelines and net/http handlers.
+package httpphase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+)
+
+// RequestBuilder builds the request sent by a phase from its input. The
+// request must use ctx, which carries the phase's timeout.
+type RequestBuilder func(ctx context.Context, value interface{}) (*http.Request, error)
+
+// ResponseParser turns a successful response into the phase's output. The
+// response's body is closed after it returns.
+type ResponseParser func(resp *http.Response) (interface{}, error)
+
+// StatusError is returned when the server responds with a status other than
+// 2xx. Phases retry it like any other error, waiting for the delay of the
+// response's Retry-After header when it has one.
+type StatusError struct {
+	// StatusCode is the status code of the response
+	StatusCode int
+	// Status is the status line of the response
+	Status string
+	// Header is the header of the response
+	Header http.Header
+}
+
+var _ phaser.RetryAfterProvider = (*StatusError)(nil)
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status %s", e.Status)
+}
+
+// RetryAfter returns the delay of the response's Retry-After header, given
+// either in seconds or as a date.
+func (e *StatusError) RetryAfter() (time.Duration, bool) {
+	value := e.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, seconds >= 0
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+// ReadBody is a ResponseParser returning the response's body as a []byte.
+func ReadBody(resp *http.Response) (interface{}, error) {
+	return io.ReadAll(resp.Body)
+}
+
+// New returns a phase named name sending the request built by build from its
+// input using client, and returning the response parsed by parse. A nil
+// client uses http.DefaultClient, and a nil parse uses ReadBody.
+//
+// The phase relies on opts for its timeout and retries, as set by
+// phaser.WithTimeout and phaser.WithRetry: the timeout applies to each
+// request, and failing requests are retried.
+func New(name string, client *http.Client, build RequestBuilder, parse ResponseParser, opts ...phaser.PhaseOption) *phaser.Phase {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if parse == nil {
+		parse = ReadBody
+	}
+
+	return phaser.NewPhaseContext(name, func(ctx context.Context, value interface{}) (interface{}, error) {
+		req, err := build(ctx, value)
+		if err != nil {
+			return nil, fmt.Errorf("building request: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header}
+		}
+		return parse(resp)
+	}, opts...)
+}
@@ -0,0 +1,130 @@
+package httpphase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+	"github.com/AlejoAsd/go-phase-manager/phasertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getPath returns a RequestBuilder getting the path given as input from url.
+func getPath(url string) RequestBuilder {
+	return func(ctx context.Context, value interface{}) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, url+value.(string), nil)
+	}
+}
+
+// run runs p in a manager on value.
+func run(t *testing.T, p *phaser.Phase, value interface{}) (interface{}, error) {
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+	return m.Run(value)
+}
+
+func TestHTTPPhase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	value, err := run(t, New("get", server.Client(), getPath(server.URL), nil), "/world")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello /world"), value)
+}
+
+func TestHTTPPhaseRetriesUnavailable(t *testing.T) {
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	parse := func(resp *http.Response) (interface{}, error) {
+		body, err := ReadBody(resp)
+		return string(body.([]byte)), err
+	}
+	p := New("get", server.Client(), getPath(server.URL), parse, phaser.WithRetry(phaser.RetryPolicy{MaxAttempts: 2}))
+	value, err := run(t, p, "/")
+	require.NoError(t, err)
+	assert.Equal(t, "ok", value)
+	assert.Equal(t, int64(2), calls)
+
+	// Without retries the status is returned
+	atomic.StoreInt64(&calls, 0)
+	_, err = run(t, New("get", server.Client(), getPath(server.URL), parse), "/")
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+}
+
+func TestHTTPPhaseRetryAfter(t *testing.T) {
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// The fake clock measures the delay without waiting for it
+	clock := phasertest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := phaser.NewPhaseManager(phaser.WithClock(clock))
+	require.NoError(t, m.AddPhase(New("get", server.Client(), getPath(server.URL), nil,
+		phaser.WithRetry(phaser.RetryPolicy{MaxAttempts: 2, Backoff: time.Second}))))
+	var report phaser.RunReport
+	_, err := m.Run("/", phaser.WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, []phaser.RetryDelay{{Attempt: 1, Delay: 7 * time.Second, Hinted: true}}, report.Phases[0].RetryDelays)
+}
+
+func TestStatusErrorRetryAfter(t *testing.T) {
+	header := http.Header{}
+	err := &StatusError{StatusCode: http.StatusServiceUnavailable, Header: header}
+	_, ok := err.RetryAfter()
+	assert.False(t, ok)
+
+	header.Set("Retry-After", "120")
+	d, ok := err.RetryAfter()
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, d)
+
+	header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	d, ok = err.RetryAfter()
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour, d, float64(2*time.Second))
+
+	header.Set("Retry-After", "soon")
+	_, ok = err.RetryAfter()
+	assert.False(t, ok)
+}
+
+func TestHTTPPhaseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p := New("get", server.Client(), getPath(server.URL), nil, phaser.WithTimeout(10*time.Millisecond))
+	_, err := run(t, p, "/")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,135 @@
+package httpphase
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+)
+
+// ErrNotExchange is returned by the phases adapted from middleware when their
+// input is not an *HTTPExchange.
+var ErrNotExchange = errors.New("value is not an *HTTPExchange")
+
+// HTTPExchange is the value flowing through pipelines handling HTTP requests,
+// as run by AsHTTPHandler.
+type HTTPExchange struct {
+	// Request is the request being handled
+	Request *http.Request
+	// Response writes the response, such as an *httptest.ResponseRecorder in
+	// tests
+	Response http.ResponseWriter
+	// state is shared by the exchanges of a request when it is handled by
+	// AsHTTPHandler
+	state *exchangeState
+}
+
+// exchangeState tracks the handling of a request by AsHTTPHandler.
+type exchangeState struct {
+	// stopped is set once a middleware did not call its next handler
+	stopped bool
+}
+
+// FromHTTPMiddleware adapts mw into a phase execute function or hook taking
+// and returning an *HTTPExchange. The returned exchange holds the request and
+// response writer mw passed on to its next handler, so that the rest of the
+// pipeline sees the changes mw made. Code mw runs after its next handler
+// returns runs before the rest of the pipeline.
+//
+// When mw does not call its next handler, such as to reject the request, the
+// function returns phaser.ErrStopPipeline, ending the run successfully
+// without running the later phases or the final handler of AsHTTPHandler.
+func FromHTTPMiddleware(mw func(http.Handler) http.Handler) phaser.PhaseHook {
+	return func(value interface{}) (interface{}, error) {
+		ex, ok := value.(*HTTPExchange)
+		if !ok {
+			return nil, fmt.Errorf("%w: got %T", ErrNotExchange, value)
+		}
+		var next *HTTPExchange
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next = &HTTPExchange{Request: r, Response: w, state: ex.state}
+		})).ServeHTTP(ex.Response, ex.Request)
+
+		if next == nil {
+			if ex.state != nil {
+				ex.state.stopped = true
+			}
+			return ex, phaser.ErrStopPipeline
+		}
+		return next, nil
+	}
+}
+
+// HandlerOption configures the handlers returned by AsHTTPHandler.
+type HandlerOption func(c *handlerConfig)
+
+// handlerConfig is the configuration of a handler returned by AsHTTPHandler.
+type handlerConfig struct {
+	status func(err error) int
+}
+
+// WithErrorStatus sets the function returning the status code of the
+// responses to failed runs, which defaults to 500 Internal Server Error.
+func WithErrorStatus(status func(err error) int) HandlerOption {
+	return func(c *handlerConfig) {
+		c.status = status
+	}
+}
+
+// AsHTTPHandler returns a handler running the pipeline of m around final. Each
+// request runs the pipeline on an *HTTPExchange using the request's context,
+// so that the run stops when the client goes away, and final handles the
+// exchange returned by the run.
+//
+// Failed runs respond with the status returned by the WithErrorStatus
+// function. Once a phase wrote the response, or a middleware adapted using
+// FromHTTPMiddleware stopped the pipeline, neither final nor the error
+// response are written.
+func AsHTTPHandler(m *phaser.DefaultPhaseManager, final http.Handler, opts ...HandlerOption) http.Handler {
+	c := &handlerConfig{status: func(error) int { return http.StatusInternalServerError }}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		state := &exchangeState{}
+		value, err := m.RunContext(r.Context(), &HTTPExchange{Request: r, Response: tw, state: state})
+		if err == nil {
+			if _, ok := value.(*HTTPExchange); !ok {
+				err = fmt.Errorf("%w: pipeline returned %T", ErrNotExchange, value)
+			}
+		}
+		switch {
+		case tw.written:
+		case err != nil:
+			status := c.status(err)
+			http.Error(tw, http.StatusText(status), status)
+		case !state.stopped:
+			ex := value.(*HTTPExchange)
+			final.ServeHTTP(ex.Response, ex.Request)
+		}
+	})
+}
+
+// trackingWriter records whether the response was written.
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *trackingWriter) WriteHeader(statusCode int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,133 @@
+package httpphase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userKey struct{}
+
+// authenticate is a middleware rejecting the requests without a user, and
+// storing the user in the context of the others.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Header.Get("User")
+		if user == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
+
+// greet is a final handler greeting the user.
+var greet = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("hello " + r.Context().Value(userKey{}).(string)))
+})
+
+// serve handles a request to h with the User header set to user.
+func serve(h http.Handler, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if user != "" {
+		req.Header.Set("User", user)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAsHTTPHandler(t *testing.T) {
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhase(phaser.NewPhase("auth", FromHTTPMiddleware(authenticate))))
+	h := AsHTTPHandler(m, greet)
+
+	rec := serve(h, "ana")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "hello ana", rec.Body.String())
+
+	// The middleware short-circuits the pipeline and the final handler
+	rec = serve(h, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "unauthorized\n", rec.Body.String())
+}
+
+func TestAsHTTPHandlerShortCircuit(t *testing.T) {
+	after := false
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		phaser.NewPhase("auth", FromHTTPMiddleware(func(http.Handler) http.Handler {
+			// Neither writes nor calls next
+			return http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+		})),
+		phaser.NewPhase("after", func(value interface{}) (interface{}, error) {
+			after = true
+			return value, nil
+		}),
+	))
+	rec := serve(AsHTTPHandler(m, greet), "ana")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.False(t, after)
+}
+
+func TestAsHTTPHandlerPhaseWritesResponse(t *testing.T) {
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhase(phaser.NewPhase("cache", func(value interface{}) (interface{}, error) {
+		value.(*HTTPExchange).Response.Write([]byte("cached"))
+		return value, nil
+	})))
+	rec := serve(AsHTTPHandler(m, greet), "ana")
+	assert.Equal(t, "cached", rec.Body.String())
+}
+
+func TestAsHTTPHandlerErrors(t *testing.T) {
+	errMissing := errors.New("missing")
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhase(phaser.NewPhase("load", func(value interface{}) (interface{}, error) {
+		return nil, errMissing
+	})))
+
+	rec := serve(AsHTTPHandler(m, greet), "ana")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	status := func(err error) int {
+		if errors.Is(err, errMissing) {
+			return http.StatusNotFound
+		}
+		return http.StatusInternalServerError
+	}
+	rec = serve(AsHTTPHandler(m, greet, WithErrorStatus(status)), "ana")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Not Found\n", rec.Body.String())
+}
+
+func TestAsHTTPHandlerCancellation(t *testing.T) {
+	seen := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhase(phaser.NewPhaseContext("wait", func(ctx context.Context, value interface{}) (interface{}, error) {
+		// The client goes away once the phase started
+		cancel()
+		<-ctx.Done()
+		seen <- ctx.Err()
+		return nil, ctx.Err()
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	AsHTTPHandler(m, greet).ServeHTTP(rec, req)
+	assert.ErrorIs(t, <-seen, context.Canceled)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestFromHTTPMiddlewareRejectsOtherValues(t *testing.T) {
+	_, err := FromHTTPMiddleware(authenticate)("value")
+	assert.ErrorIs(t, err, ErrNotExchange)
+}
@@ -0,0 +1,258 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// InvariantViolationError is returned by RunChecked when an invariant does
+// not hold, telling apart the pipeline misbehaving from the errors of its
+// phases.
+type InvariantViolationError struct {
+	// Invariant is the name of the violated invariant
+	Invariant string
+	// Phase is the name of the phase after which the invariant was checked,
+	// empty when it was checked at the end of the run
+	Phase string
+	// Err describes the violation
+	Err error
+}
+
+func (e *InvariantViolationError) Error() string {
+	if e.Phase == "" {
+		return fmt.Sprintf("invariant %s violated at the end of the run: %v", e.Invariant, e.Err)
+	}
+	return fmt.Sprintf("invariant %s violated after phase %s: %v", e.Invariant, e.Phase, e.Err)
+}
+
+func (e *InvariantViolationError) Unwrap() error {
+	return e.Err
+}
+
+// RunSnapshot is the state of a run checked by an Invariant.
+type RunSnapshot struct {
+	// Phase is the name of the phase that just ended, empty once the run
+	// ended
+	Phase string
+	// Result is the outcome of the phase that just ended, nil once the run
+	// ended
+	Result *PhaseResult
+	// Input is the value the phase that just ended received
+	Input interface{}
+	// Report is the report of the run so far
+	Report *RunReport
+	// Err is the error returned by the run, once it ended
+	Err error
+	// Hooks and StartHooks are the number of hooks of each phase of the
+	// manager, by phase name, at the time of the snapshot and when the run
+	// started
+	Hooks, StartHooks map[string]int
+}
+
+// Ended reports whether the snapshot was taken at the end of the run.
+func (s *RunSnapshot) Ended() bool {
+	return s.Result == nil
+}
+
+// Invariant is a property of runs checked by RunChecked. Check is called
+// with a snapshot of the run after each phase ends, including the skipped
+// ones and the phases of branches, and once more when the run ends, and
+// returns an error describing the violation when the property does not hold.
+// Invariants are called synchronously by the run, and must not keep state
+// between calls, as they may be shared by concurrent runs.
+//
+// Custom invariants implement the interface, or use InvariantFunc:
+//
+//	positive := phaser.InvariantFunc("Positive", func(s *phaser.RunSnapshot) error {
+//		if !s.Ended() && s.Result.Status == phaser.StatusSucceeded && s.Result.Output.(int) < 0 {
+//			return fmt.Errorf("negative output %d", s.Result.Output)
+//		}
+//		return nil
+//	})
+type Invariant interface {
+	Check(s *RunSnapshot) error
+}
+
+// InvariantFunc returns an Invariant named name checked by check.
+func InvariantFunc(name string, check func(s *RunSnapshot) error) Invariant {
+	return namedInvariant{name: name, check: check}
+}
+
+// namedInvariant is an Invariant checked by a function.
+type namedInvariant struct {
+	name  string
+	check func(s *RunSnapshot) error
+}
+
+func (i namedInvariant) Check(s *RunSnapshot) error {
+	return i.check(s)
+}
+
+// invariantName returns the name of i, which is its type unless it was
+// created by InvariantFunc.
+func invariantName(i Invariant) string {
+	if named, ok := i.(namedInvariant); ok {
+		return named.name
+	}
+	return fmt.Sprintf("%T", i)
+}
+
+// NoNilValueBetweenPhases checks that no phase succeeds with a nil output.
+var NoNilValueBetweenPhases = InvariantFunc("NoNilValueBetweenPhases", func(s *RunSnapshot) error {
+	if !s.Ended() && s.Result.Status == StatusSucceeded && s.Result.Output == nil {
+		return errors.New("phase succeeded with a nil output")
+	}
+	return nil
+})
+
+// ValueTypes are the dynamic types expected of the input and output of a
+// phase by ValueTypeStable. Nil types are not checked.
+type ValueTypes struct {
+	In, Out reflect.Type
+}
+
+// ValueTypeStable checks that the dynamic types of the values entering and
+// leaving the phases named in types match their expectations, and that the
+// other phases return values of the type of their input. Nil values are not
+// checked.
+func ValueTypeStable(types map[string]ValueTypes) Invariant {
+	return InvariantFunc("ValueTypeStable", func(s *RunSnapshot) error {
+		if s.Ended() || s.Result.Status != StatusSucceeded {
+			return nil
+		}
+		in, out := reflect.TypeOf(s.Input), reflect.TypeOf(s.Result.Output)
+		expected, ok := types[s.Phase]
+		if !ok {
+			if in != nil && out != nil && in != out {
+				return fmt.Errorf("phase turned a %v into a %v", in, out)
+			}
+			return nil
+		}
+		if expected.In != nil && in != nil && in != expected.In {
+			return fmt.Errorf("phase received a %v, expected a %v", in, expected.In)
+		}
+		if expected.Out != nil && out != nil && out != expected.Out {
+			return fmt.Errorf("phase returned a %v, expected a %v", out, expected.Out)
+		}
+		return nil
+	})
+}
+
+// HookCountImmutableDuringRun checks that no hook is added to or removed from
+// the manager's phases while the run is in progress.
+var HookCountImmutableDuringRun = InvariantFunc("HookCountImmutableDuringRun", func(s *RunSnapshot) error {
+	for name, count := range s.Hooks {
+		if start := s.StartHooks[name]; count != start {
+			return fmt.Errorf("phase %s had %d hooks when the run started, now %d", name, start, count)
+		}
+	}
+	return nil
+})
+
+// ReportConsistent checks once the run ends that the statuses, timings and
+// errors of its report agree with each other and with the run's error.
+var ReportConsistent = InvariantFunc("ReportConsistent", func(s *RunSnapshot) error {
+	if !s.Ended() {
+		return nil
+	}
+	if !errors.Is(s.Report.Err, s.Err) || !errors.Is(s.Err, s.Report.Err) {
+		return fmt.Errorf("report error %v differs from the run error %v", s.Report.Err, s.Err)
+	}
+	if s.Report.Duration < 0 {
+		return fmt.Errorf("negative run duration %v", s.Report.Duration)
+	}
+	for _, r := range s.Report.Phases {
+		switch {
+		case r.Duration < 0 || r.Queued < 0:
+			return fmt.Errorf("phase %s has negative timings", r.Phase)
+		case (r.Status == StatusFailed || r.Status == StatusRejected) != (r.Err != nil):
+			return fmt.Errorf("phase %s is %s with error %v", r.Phase, r.Status, r.Err)
+		case (r.Status == StatusSkipped || r.Status == StatusQuarantined) && (!r.Start.IsZero() || r.Duration != 0):
+			return fmt.Errorf("phase %s is %s but has timings", r.Phase, r.Status)
+		case r.Status == StatusFailed && r.Output != nil:
+			return fmt.Errorf("phase %s failed with output %v", r.Phase, r.Output)
+		}
+	}
+	return nil
+})
+
+// RunChecked runs the pipeline on value like Run, checking invariants after
+// each phase and at the end of the run. It is meant for fuzzing pipelines:
+// the first violation is returned as an *InvariantViolationError, taking
+// precedence over the error of the run, and the run goes on unchecked.
+func (m *DefaultPhaseManager) RunChecked(value interface{}, invariants ...Invariant) (interface{}, error) {
+	checker := &invariantChecker{manager: m, invariants: invariants, report: &RunReport{}}
+	checker.startHooks = checker.hookCounts()
+	value, err := m.Run(value, WithReport(checker.report), func(c *runConfig) {
+		c.invariants = checker
+	})
+	checker.check(&RunSnapshot{Err: err})
+	if checker.violation != nil {
+		return value, checker.violation
+	}
+	return value, err
+}
+
+// invariantChecker checks the invariants of a run. A nil *invariantChecker
+// checks nothing.
+type invariantChecker struct {
+	manager    *DefaultPhaseManager
+	invariants []Invariant
+	report     *RunReport
+	startHooks map[string]int
+	// inputs contains the input of the running phases, by name
+	inputs map[string]interface{}
+	// violation is the first violation of the run
+	violation *InvariantViolationError
+}
+
+// started records the input of the phase named phase.
+func (c *invariantChecker) started(phase string, value interface{}) {
+	if c == nil {
+		return
+	}
+	if c.inputs == nil {
+		c.inputs = make(map[string]interface{})
+	}
+	c.inputs[phase] = value
+}
+
+// ended checks the invariants after the phase of result ended.
+func (c *invariantChecker) ended(result PhaseResult) {
+	if c == nil {
+		return
+	}
+	input, ok := c.inputs[result.Phase]
+	delete(c.inputs, result.Phase)
+	if !ok {
+		// Skipped phases pass their input on
+		input = result.Output
+	}
+	c.check(&RunSnapshot{Phase: result.Phase, Result: &result, Input: input})
+}
+
+// check checks the invariants on s, unless one was already violated.
+func (c *invariantChecker) check(s *RunSnapshot) {
+	if c.violation != nil {
+		return
+	}
+	s.Report = c.report
+	s.Hooks, s.StartHooks = c.hookCounts(), c.startHooks
+	for _, i := range c.invariants {
+		if err := i.Check(s); err != nil {
+			c.violation = &InvariantViolationError{Invariant: invariantName(i), Phase: s.Phase, Err: err}
+			return
+		}
+	}
+}
+
+// hookCounts returns the number of hooks of each phase of the manager.
+func (c *invariantChecker) hookCounts() map[string]int {
+	counts := make(map[string]int)
+	c.manager.ForEachPhase(func(_ int, p *Phase) bool {
+		counts[p.Name] = len(p.preHooks) + len(p.postHooks)
+		return true
+	})
+	return counts
+}
@@ -0,0 +1,145 @@
+package phaser
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCheckedNoViolation(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(NewPhase("one", addOne), NewPhase("two", addOne)))
+
+	value, err := m.RunChecked(0, NoNilValueBetweenPhases, ValueTypeStable(nil), HookCountImmutableDuringRun, ReportConsistent)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+func TestRunCheckedBusinessError(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("fail", failWith(errNotFound))))
+
+	_, err := m.RunChecked(0, ReportConsistent)
+	assert.ErrorIs(t, err, errNotFound)
+	var violation *InvariantViolationError
+	assert.False(t, errors.As(err, &violation))
+}
+
+func TestNoNilValueBetweenPhases(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("nil", func(interface{}) (interface{}, error) { return nil, nil }),
+		NewPhase("next", func(value interface{}) (interface{}, error) { return value, nil }),
+	))
+
+	_, err := m.RunChecked(0, NoNilValueBetweenPhases)
+	var violation *InvariantViolationError
+	require.True(t, errors.As(err, &violation))
+	assert.Equal(t, "NoNilValueBetweenPhases", violation.Invariant)
+	assert.Equal(t, "nil", violation.Phase)
+}
+
+func TestValueTypeStable(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("format", func(value interface{}) (interface{}, error) { return strconv.Itoa(value.(int)), nil }),
+		NewPhase("echo", func(value interface{}) (interface{}, error) { return value, nil }),
+	))
+
+	_, err := m.RunChecked(0, ValueTypeStable(map[string]ValueTypes{
+		"format": {In: reflect.TypeOf(0), Out: reflect.TypeOf("")},
+	}))
+	require.NoError(t, err)
+
+	_, err = m.RunChecked(0, ValueTypeStable(nil))
+	var violation *InvariantViolationError
+	require.True(t, errors.As(err, &violation))
+	assert.Equal(t, "format", violation.Phase)
+
+	_, err = m.RunChecked(0, ValueTypeStable(map[string]ValueTypes{
+		"format": {Out: reflect.TypeOf(0)},
+	}))
+	require.True(t, errors.As(err, &violation))
+	assert.Equal(t, "format", violation.Phase)
+}
+
+func TestHookCountImmutableDuringRun(t *testing.T) {
+	target := NewPhase("target", addOne)
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("mutate", func(value interface{}) (interface{}, error) {
+			target.appendPreHook(addOne)
+			return value, nil
+		}),
+		target,
+	))
+
+	_, err := m.RunChecked(0, HookCountImmutableDuringRun)
+	var violation *InvariantViolationError
+	require.True(t, errors.As(err, &violation))
+	assert.Equal(t, "HookCountImmutableDuringRun", violation.Invariant)
+	assert.Equal(t, "mutate", violation.Phase)
+}
+
+func TestReportConsistent(t *testing.T) {
+	inconsistent := InvariantFunc("Inconsistent", func(s *RunSnapshot) error {
+		if !s.Ended() {
+			// Corrupt the report as a misbehaving run would
+			s.Report.Phases[len(s.Report.Phases)-1].Err = errNotFound
+		}
+		return nil
+	})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+
+	_, err := m.RunChecked(0, inconsistent, ReportConsistent)
+	var violation *InvariantViolationError
+	require.True(t, errors.As(err, &violation))
+	assert.Equal(t, "ReportConsistent", violation.Invariant)
+	assert.Empty(t, violation.Phase)
+}
+
+// FuzzRunChecked runs a small pipeline with hooks, skipped, rejecting and
+// non-critical phases on random inputs, checking the built-in invariants.
+func FuzzRunChecked(f *testing.F) {
+	for _, seed := range []int{0, 1, -1, 7, 42, 1 << 20} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input int) {
+		validate := NewPhase("validate", func(value interface{}) (interface{}, error) {
+			if value.(int)%5 == 0 {
+				return nil, Reject("multiple of five", value)
+			}
+			return value, nil
+		})
+		double := NewPhase("double", func(value interface{}) (interface{}, error) { return value.(int) * 2, nil })
+		double.appendPreHook(addOne)
+		double.appendPostHook(addOne)
+		flaky := NewPhase("flaky", func(value interface{}) (interface{}, error) {
+			if value.(int)%3 == 0 {
+				return nil, errNotFound
+			}
+			return value, nil
+		}, WithNonCritical())
+		skipped := NewPhase("skipped", addOne)
+		skipped.Disabled = true
+		last := NewPhase("last", func(value interface{}) (interface{}, error) {
+			if value.(int)%7 == 0 {
+				return nil, errNotFound
+			}
+			return value, nil
+		})
+
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhases(validate, double, flaky, skipped, last))
+		_, err := m.RunChecked(input, NoNilValueBetweenPhases, ValueTypeStable(nil), HookCountImmutableDuringRun, ReportConsistent)
+		var violation *InvariantViolationError
+		if errors.As(err, &violation) {
+			t.Fatal(violation)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package phaser
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// WithValueIsolation gives each of the phase's ParallelHooks its own copy of
+// the value, made using cloner, so that hooks mutating it by mistake do not
+// race against each other. The mutations are then reported as
+// ErrHookChangedValue, as the value the hooks return no longer matches the
+// shared one. A nil cloner defaults to CloneJSON. Values that cannot be
+// cloned fail the phase with an error naming the hook.
+func WithValueIsolation(cloner func(value interface{}) (interface{}, error)) PhaseOption {
+	if cloner == nil {
+		cloner = CloneJSON
+	}
+	return func(p *Phase) {
+		p.cloner = cloner
+	}
+}
+
+// CloneJSON returns a deep copy of value of the same type, made by encoding
+// it to JSON and decoding it back. Only the fields encoded to JSON are
+// copied, so type-specific cloners should be preferred for values with
+// unexported fields or when performance matters.
+func CloneJSON(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	clone := reflect.New(reflect.TypeOf(value))
+	if err := json.Unmarshal(data, clone.Interface()); err != nil {
+		return nil, err
+	}
+	return clone.Elem().Interface(), nil
+}
+
+// isolate returns the value given to hook i of hooks, a copy of value when
+// the phase isolates its parallel hooks.
+func (p *Phase) isolate(stage Stage, i int, value interface{}) (interface{}, error) {
+	if p.cloner == nil {
+		return value, nil
+	}
+	clone, err := p.cloner(value)
+	if err != nil {
+		return nil, fmt.Errorf("cloning the value of %s %d of phase %s: %w", stage, i, p.Name, err)
+	}
+	return clone, nil
+}
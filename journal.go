@@ -0,0 +1,419 @@
+package phaser
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// JournalEntryType is the transition of a run recorded by a JournalEntry.
+type JournalEntryType string
+
+const (
+	// JournalRunStarted is the type of the entries of runs starting
+	JournalRunStarted JournalEntryType = "run-started"
+	// JournalPhaseStarted is the type of the entries of phases starting
+	JournalPhaseStarted JournalEntryType = "phase-started"
+	// JournalPhaseFinished is the type of the entries of phases ending,
+	// including the skipped ones, which have no JournalPhaseStarted entry
+	JournalPhaseFinished JournalEntryType = "phase-finished"
+	// JournalRunFinished is the type of the entries of runs ending
+	JournalRunFinished JournalEntryType = "run-finished"
+)
+
+// JournalEntry records a transition of a run in a Journal.
+type JournalEntry struct {
+	Type JournalEntryType `json:"type"`
+	// RunID identifies the run, as set by WithRunID or generated
+	RunID string `json:"runID"`
+	// Sequence is the position of the entry among the entries of the run,
+	// starting at 1
+	Sequence int `json:"sequence"`
+	// Timestamp is the time of the transition according to the manager's
+	// clock
+	Timestamp time.Time `json:"timestamp"`
+	// Fingerprint identifies the phases of the pipeline and their versions,
+	// for JournalRunStarted entries
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Initiator is who started the run, as set by WithInitiator, for
+	// JournalRunStarted entries
+	Initiator string `json:"initiator,omitempty"`
+	// Phase is the name of the phase of JournalPhaseStarted and
+	// JournalPhaseFinished entries
+	Phase string `json:"phase,omitempty"`
+	// Status is the outcome of the phase or run of finished entries, either
+	// StatusSucceeded or StatusFailed for runs
+	Status PhaseStatus `json:"status,omitempty"`
+	// Duration is how long the phase ran, for JournalPhaseFinished entries
+	Duration time.Duration `json:"duration,omitempty"`
+	// Error is the error of the failed phase or run of finished entries
+	Error string `json:"error,omitempty"`
+}
+
+// Journal records the transitions of runs as they happen, such as for audit
+// trails that outlive the process. It is called synchronously at each
+// transition, and concurrently by concurrent runs.
+type Journal interface {
+	// Append records entry after the entries appended before it
+	Append(entry JournalEntry) error
+}
+
+// JournalOption configures the journaling of a manager.
+type JournalOption func(j *journalConfig)
+
+// WithJournalErrorHandler makes the runs continue when the journal fails to
+// append an entry, passing the entry and the error to handle, such as to log
+// them. By default, runs fail once an entry cannot be appended.
+func WithJournalErrorHandler(handle func(entry JournalEntry, err error)) JournalOption {
+	return func(j *journalConfig) {
+		j.onError = handle
+	}
+}
+
+// WithJournal appends the transitions of every run of the manager to
+// journal: the start of the run, the start and end of each of its phases,
+// including the phases of branches, and the end of the run. Entries are
+// appended synchronously as the run goes, so that a run interrupted by a
+// crash leaves the entries of the transitions it went through.
+//
+// Unless WithJournalErrorHandler is used, a failure to append an entry fails
+// the run before its next phase, after appending the end of the run.
+func WithJournal(journal Journal, opts ...JournalOption) ManagerOption {
+	j := &journalConfig{journal: journal}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return func(m *DefaultPhaseManager) {
+		m.journal = j
+	}
+}
+
+// WithRunID sets the identifier of the run in the entries of the manager's
+// journal, which is otherwise generated.
+func WithRunID(id string) RunOption {
+	return func(c *runConfig) {
+		c.runID = id
+	}
+}
+
+// WithInitiator records initiator, who started the run, such as a user or a
+// service, in the entries of the manager's journal.
+func WithInitiator(initiator string) RunOption {
+	return func(c *runConfig) {
+		c.initiator = initiator
+	}
+}
+
+// journalConfig contains the journaling settings of a manager. A nil
+// *journalConfig journals nothing.
+type journalConfig struct {
+	journal Journal
+	onError func(entry JournalEntry, err error)
+}
+
+// start appends the start of the run configured by c of the pipeline with
+// the fingerprint fp, returning the journal of the run.
+func (j *journalConfig) start(c *runConfig, fp string, clock Clock) *runJournal {
+	if j == nil {
+		return nil
+	}
+	r := &runJournal{config: j, id: c.runID, clock: clock}
+	if r.id == "" {
+		r.id = newRunID()
+	}
+	r.append(JournalEntry{Type: JournalRunStarted, Fingerprint: fp, Initiator: c.initiator})
+	return r
+}
+
+// newRunID returns a random run identifier.
+func newRunID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// runJournal appends the entries of a run. A nil *runJournal appends
+// nothing.
+type runJournal struct {
+	config *journalConfig
+	id     string
+	clock  Clock
+
+	mu       sync.Mutex
+	sequence int
+	// err is the first failure to append an entry fatal to the run
+	err error
+}
+
+// append appends entry, numbered and timestamped.
+func (r *runJournal) append(entry JournalEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sequence++
+	entry.RunID, entry.Sequence, entry.Timestamp = r.id, r.sequence, r.clock.Now()
+	err := r.config.journal.Append(entry)
+	switch {
+	case err == nil:
+	case r.config.onError != nil:
+		r.config.onError(entry, err)
+	case r.err == nil:
+		r.err = fmt.Errorf("appending %s journal entry of run %s: %w", entry.Type, r.id, err)
+	}
+}
+
+// phaseStarted appends the start of the phase named phase.
+func (r *runJournal) phaseStarted(phase string) {
+	if r != nil {
+		r.append(JournalEntry{Type: JournalPhaseStarted, Phase: phase})
+	}
+}
+
+// phaseFinished appends the end of the phase of result.
+func (r *runJournal) phaseFinished(result PhaseResult) {
+	if r == nil {
+		return
+	}
+	entry := JournalEntry{Type: JournalPhaseFinished, Phase: result.Phase, Status: result.Status, Duration: result.Duration}
+	if result.Err != nil {
+		entry.Error = result.Err.Error()
+	}
+	r.append(entry)
+}
+
+// finished appends the end of the run, which failed with err if not nil, and
+// returns err, or the first failure to append an entry when the run
+// succeeded. Runs yielding to other runs are continued later, so that their
+// end is left for the part of the run continuing them.
+func (r *runJournal) finished(err error) error {
+	var yielded *yieldError
+	if r == nil || errors.As(err, &yielded) {
+		return err
+	}
+	entry := JournalEntry{Type: JournalRunFinished, Status: StatusSucceeded}
+	if err != nil {
+		entry.Status, entry.Error = StatusFailed, err.Error()
+	}
+	r.append(entry)
+	if err == nil {
+		err = r.failure()
+	}
+	return err
+}
+
+// failure returns the first failure to append an entry fatal to the run, if
+// any.
+func (r *runJournal) failure() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// FileJournal is a Journal appending entries to a file as JSON lines, each
+// written at once, so that the file of a crashed process holds the entries
+// appended before the crash and, at worst, a torn last line.
+type FileJournal struct {
+	mu   sync.Mutex
+	file *os.File
+	sync bool
+}
+
+// FileJournalOption configures a FileJournal.
+type FileJournalOption func(j *FileJournal)
+
+// WithJournalSync makes the journal sync the file to disk after each entry,
+// so that the entries survive crashes of the system, at the cost of slower
+// appends.
+func WithJournalSync() FileJournalOption {
+	return func(j *FileJournal) {
+		j.sync = true
+	}
+}
+
+// OpenFileJournal opens the journal stored in the file at path, creating it
+// if needed. Entries are appended after the existing ones, once the torn last
+// line left by a crash, if any, is removed, so that the journal stays
+// readable by ReplayJournal.
+func OpenFileJournal(path string, opts ...FileJournalOption) (*FileJournal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := truncateTornLine(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("removing the torn line of journal %s: %w", path, err)
+	}
+	j := &FileJournal{file: file}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j, nil
+}
+
+// truncateTornLine truncates file after its last newline.
+func truncateTornLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for offset := end; offset > 0; {
+		n := int64(len(buf))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := file.ReadAt(buf[:n], offset); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			if last := offset + int64(i) + 1; last < end {
+				return file.Truncate(last)
+			}
+			return nil
+		}
+	}
+	if end > 0 {
+		// The only line is torn
+		return file.Truncate(0)
+	}
+	return nil
+}
+
+func (j *FileJournal) Append(entry JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if j.sync {
+		return j.file.Sync()
+	}
+	return nil
+}
+
+// Close closes the file of the journal.
+func (j *FileJournal) Close() error {
+	return j.file.Close()
+}
+
+// RunSummary is the state of a run reconstructed from its journal entries by
+// ReplayJournal.
+type RunSummary struct {
+	RunID       string
+	Fingerprint string
+	Initiator   string
+	// Start and End are the times the run started and ended, End being zero
+	// for unfinished runs
+	Start, End time.Time
+	// Finished is set when the end of the run was journaled. Runs of crashed
+	// processes are left unfinished
+	Finished bool
+	// Status is the outcome of finished runs
+	Status PhaseStatus
+	// Error is the error of failed runs
+	Error string
+	// Phases contains the phases of the run in the order they started, or
+	// ended for skipped phases
+	Phases []JournalPhase
+}
+
+// JournalPhase is the state of a phase of a RunSummary.
+type JournalPhase struct {
+	Phase string
+	// Start is the time the phase started, zero for skipped phases
+	Start time.Time
+	// Status is the outcome of the phase, empty when its end was not
+	// journaled
+	Status   PhaseStatus
+	Duration time.Duration
+	Error    string
+}
+
+// ReplayJournal reconstructs the runs journaled as JSON lines by a
+// FileJournal, in the order they started. A torn last line, left by a crash
+// while appending it, is ignored, while other malformed lines fail.
+func ReplayJournal(r io.Reader) ([]RunSummary, error) {
+	var summaries []*RunSummary
+	runs := make(map[string]*RunSummary)
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		last := err != nil
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			var entry JournalEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				if last {
+					break
+				}
+				return nil, fmt.Errorf("decoding journal line %d: %w", line, err)
+			}
+			run, ok := runs[entry.RunID]
+			if !ok {
+				run = &RunSummary{RunID: entry.RunID}
+				runs[entry.RunID] = run
+				summaries = append(summaries, run)
+			}
+			run.apply(entry)
+		}
+		if last {
+			break
+		}
+	}
+
+	replayed := make([]RunSummary, len(summaries))
+	for i, run := range summaries {
+		replayed[i] = *run
+	}
+	return replayed, nil
+}
+
+// apply applies entry, one of the run's entries, to the summary.
+func (s *RunSummary) apply(entry JournalEntry) {
+	switch entry.Type {
+	case JournalRunStarted:
+		s.Fingerprint, s.Initiator, s.Start = entry.Fingerprint, entry.Initiator, entry.Timestamp
+	case JournalPhaseStarted:
+		s.Phases = append(s.Phases, JournalPhase{Phase: entry.Phase, Start: entry.Timestamp})
+	case JournalPhaseFinished:
+		phase := s.startedPhase(entry.Phase)
+		if phase == nil {
+			s.Phases = append(s.Phases, JournalPhase{Phase: entry.Phase})
+			phase = &s.Phases[len(s.Phases)-1]
+		}
+		phase.Status, phase.Duration, phase.Error = entry.Status, entry.Duration, entry.Error
+	case JournalRunFinished:
+		s.End, s.Finished, s.Status, s.Error = entry.Timestamp, true, entry.Status, entry.Error
+	}
+}
+
+// startedPhase returns the last phase named phase whose end was not applied,
+// or nil if there is none.
+func (s *RunSummary) startedPhase(phase string) *JournalPhase {
+	for i := len(s.Phases) - 1; i >= 0; i-- {
+		if s.Phases[i].Phase == phase && s.Phases[i].Status == "" {
+			return &s.Phases[i]
+		}
+	}
+	return nil
+}
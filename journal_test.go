@@ -0,0 +1,260 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryJournal keeps the appended entries, failing once it holds limit
+// entries when limit is positive.
+type memoryJournal struct {
+	entries []JournalEntry
+	limit   int
+}
+
+var errJournalFull = errors.New("journal full")
+
+func (j *memoryJournal) Append(entry JournalEntry) error {
+	if j.limit > 0 && len(j.entries) >= j.limit {
+		return errJournalFull
+	}
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+// crashingJournal appends to journal until it holds limit entries, and then
+// stops appending like a crashed process.
+type crashingJournal struct {
+	journal Journal
+	limit   int
+	count   int
+}
+
+func (j *crashingJournal) Append(entry JournalEntry) error {
+	if j.count++; j.count > j.limit {
+		return nil
+	}
+	return j.journal.Append(entry)
+}
+
+func journalPipeline(t *testing.T, clock *testClock, opts ...ManagerOption) *DefaultPhaseManager {
+	skipped := NewPhase("skipped", addOne)
+	skipped.Disabled = true
+	m := NewPhaseManager(append([]ManagerOption{WithClock(clock)}, opts...)...)
+	require.NoError(t, m.AddPhases(
+		sleepPhase("load", clock, time.Second),
+		skipped,
+		sleepPhase("save", clock, 2*time.Second),
+	))
+	return m
+}
+
+func TestJournal(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &testClock{now: start}
+	journal := &memoryJournal{}
+	m := journalPipeline(t, clock, WithJournal(journal))
+
+	_, err := m.Run(0, WithRunID("run-1"), WithInitiator("alice"))
+	require.NoError(t, err)
+	require.Len(t, journal.entries, 7)
+	assert.Equal(t, JournalEntry{
+		Type: JournalRunStarted, RunID: "run-1", Sequence: 1, Timestamp: start,
+		Fingerprint: fingerprint(m.phaseVersions()), Initiator: "alice",
+	}, journal.entries[0])
+	assert.Equal(t, JournalEntry{Type: JournalPhaseStarted, RunID: "run-1", Sequence: 2, Timestamp: start, Phase: "load"}, journal.entries[1])
+	assert.Equal(t, JournalEntry{
+		Type: JournalPhaseFinished, RunID: "run-1", Sequence: 3, Timestamp: start.Add(time.Second),
+		Phase: "load", Status: StatusSucceeded, Duration: time.Second,
+	}, journal.entries[2])
+	assert.Equal(t, JournalEntry{
+		Type: JournalPhaseFinished, RunID: "run-1", Sequence: 4, Timestamp: start.Add(time.Second),
+		Phase: "skipped", Status: StatusSkipped,
+	}, journal.entries[3])
+	assert.Equal(t, "save", journal.entries[5].Phase)
+	assert.Equal(t, JournalEntry{
+		Type: JournalRunFinished, RunID: "run-1", Sequence: 7, Timestamp: start.Add(3 * time.Second),
+		Status: StatusSucceeded,
+	}, journal.entries[6])
+
+	// Runs without an identifier get a generated one
+	journal.entries = nil
+	m.phases[2].execute = failWith(errNotFound)
+	_, err = m.Run(0)
+	assert.ErrorIs(t, err, errNotFound)
+	id := journal.entries[0].RunID
+	assert.NotEmpty(t, id)
+	assert.NotEqual(t, "run-1", id)
+	last := journal.entries[len(journal.entries)-1]
+	assert.Equal(t, id, last.RunID)
+	assert.Equal(t, StatusFailed, last.Status)
+	assert.Contains(t, last.Error, errNotFound.Error())
+	assert.Equal(t, StatusFailed, journal.entries[len(journal.entries)-2].Status)
+}
+
+func TestJournalAppendFailure(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	journal := &memoryJournal{limit: 3}
+	m := journalPipeline(t, clock, WithJournal(journal))
+
+	// The run stops before the phase after the failed append
+	var report RunReport
+	_, err := m.Run(0, WithReport(&report))
+	assert.ErrorIs(t, err, errJournalFull)
+	assert.Contains(t, err.Error(), "phase-finished journal entry")
+	require.Len(t, report.Phases, 2)
+	assert.Equal(t, "skipped", report.Phases[1].Phase)
+
+	// Handled failures let the run continue
+	var failed []JournalEntryType
+	journal.entries = nil
+	m = journalPipeline(t, clock, WithJournal(journal, WithJournalErrorHandler(func(entry JournalEntry, err error) {
+		assert.ErrorIs(t, err, errJournalFull)
+		failed = append(failed, entry.Type)
+	})))
+	_, err = m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Len(t, report.Phases, 3)
+	assert.Equal(t, []JournalEntryType{JournalPhaseFinished, JournalPhaseStarted, JournalPhaseFinished, JournalRunFinished}, failed)
+}
+
+func TestFileJournalReplay(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := OpenFileJournal(path, WithJournalSync())
+	require.NoError(t, err)
+
+	m := journalPipeline(t, clock, WithJournal(journal))
+	_, err = m.Run(0, WithRunID("ok"), WithInitiator("alice"))
+	require.NoError(t, err)
+
+	// The process crashes while the first phase runs, tearing the next
+	// entry it writes
+	crashing := &crashingJournal{journal: journal, limit: 2}
+	m = journalPipeline(t, clock, WithJournal(crashing))
+	_, err = m.Run(0, WithRunID("crashed"), WithInitiator("bob"))
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"type":"phase-start`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	summaries, err := ReplayJournal(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+
+	ok := summaries[0]
+	assert.Equal(t, "ok", ok.RunID)
+	assert.Equal(t, "alice", ok.Initiator)
+	assert.Equal(t, fingerprint(m.phaseVersions()), ok.Fingerprint)
+	assert.True(t, ok.Finished)
+	assert.Equal(t, StatusSucceeded, ok.Status)
+	assert.Equal(t, 3*time.Second, ok.End.Sub(ok.Start))
+	require.Len(t, ok.Phases, 3)
+	assert.Equal(t, JournalPhase{Phase: "load", Start: ok.Start, Status: StatusSucceeded, Duration: time.Second}, ok.Phases[0])
+	assert.Equal(t, JournalPhase{Phase: "skipped", Status: StatusSkipped}, ok.Phases[1])
+	assert.Equal(t, StatusSucceeded, ok.Phases[2].Status)
+
+	crashed := summaries[1]
+	assert.Equal(t, "crashed", crashed.RunID)
+	assert.Equal(t, "bob", crashed.Initiator)
+	assert.False(t, crashed.Finished)
+	assert.Zero(t, crashed.End)
+	require.Len(t, crashed.Phases, 1)
+	assert.Equal(t, "load", crashed.Phases[0].Phase)
+	assert.Empty(t, crashed.Phases[0].Status)
+}
+
+func TestReplayJournalMalformedLine(t *testing.T) {
+	journal := `{"type":"run-started","runID":"a","sequence":1}
+not json
+{"type":"run-finished","runID":"a","sequence":2,"status":"succeeded"}
+`
+	_, err := ReplayJournal(strings.NewReader(journal))
+	assert.ErrorContains(t, err, "journal line 2")
+}
+
+func TestFileJournalReopenAfterCrash(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	journal, err := OpenFileJournal(path)
+	require.NoError(t, err)
+	m := journalPipeline(t, clock, WithJournal(journal))
+	_, err = m.Run(0, WithRunID("before"))
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	// The process crashes while appending an entry, and restarts
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"type":"run-start`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	journal, err = OpenFileJournal(path)
+	require.NoError(t, err)
+	m = journalPipeline(t, clock, WithJournal(journal))
+	_, err = m.Run(0, WithRunID("after"))
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "run-start{")
+	summaries, err := ReplayJournal(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "before", summaries[0].RunID)
+	assert.Equal(t, "after", summaries[1].RunID)
+	assert.True(t, summaries[1].Finished)
+	assert.Len(t, summaries[1].Phases, 3)
+}
+
+func TestJournalYieldedRun(t *testing.T) {
+	clock := &syncClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	log := &eventLog{}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	journal := &memoryJournal{}
+	low := lowPriorityManager(t, log, started, release)
+	WithJournal(journal)(low)
+	high := orderManager(t, "high", &log.mu, &log.events)
+
+	s := NewScheduler(WithSchedulerClock(clock))
+	lowRun := s.Submit(low, 0, WithSubmitRunOptions(WithRunID("low")))
+	<-started
+	highRun := s.Submit(high, 0, WithPriority(10))
+	close(release)
+	_, err := highRun.Wait()
+	require.NoError(t, err)
+	_, err = lowRun.Wait()
+	require.NoError(t, err)
+	require.NoError(t, s.Drain(context.Background()))
+	require.Len(t, lowRun.Report().Yields, 1)
+
+	// Both parts of the run are journaled as a single run
+	var types []JournalEntryType
+	for i, entry := range journal.entries {
+		assert.Equal(t, "low", entry.RunID)
+		assert.Equal(t, i+1, entry.Sequence)
+		types = append(types, entry.Type)
+	}
+	assert.Equal(t, []JournalEntryType{
+		JournalRunStarted,
+		JournalPhaseStarted, JournalPhaseFinished,
+		JournalPhaseStarted, JournalPhaseFinished,
+		JournalPhaseStarted, JournalPhaseFinished,
+		JournalRunFinished,
+	}, types)
+	assert.Equal(t, StatusSucceeded, journal.entries[len(journal.entries)-1].Status)
+}
@@ -0,0 +1,118 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// WithPermanentFactoryError makes the lazy phase keep the error of its
+// factory, failing every later run with it instead of invoking the factory
+// again. It only applies to phases added using AddLazyPhase.
+func WithPermanentFactoryError() PhaseOption {
+	return func(p *Phase) {
+		p.permanentFactoryError = true
+	}
+}
+
+// AddLazyPhase registers a phase named name constructed by factory, for
+// phases that are expensive to construct and not needed by every run. The
+// factory is invoked once, by the first run where the phase executes rather
+// than being disabled or skipped, and the phase it returns, named name, is
+// used by the later runs. Concurrent runs wait for the same invocation.
+//
+// Factory errors fail the run with a *PhaseError naming the phase, and the
+// factory is invoked again by the next run, unless the phase uses
+// WithPermanentFactoryError. opts configure the registered phase, such as its
+// DependsOn, conditions and NonCritical, while the constructed phase brings
+// its own hooks, error handlers and retry policy.
+func (m *DefaultPhaseManager) AddLazyPhase(name string, factory func() (*Phase, error), opts ...PhaseOption) error {
+	l := &lazyPhase{name: name, factory: factory}
+	p := NewPhaseContext(name, l.run, opts...)
+	// The constructed phase acquires the concurrency limits itself
+	p.nested = true
+	l.permanent = p.permanentFactoryError
+	return m.AddPhase(p)
+}
+
+// lazyPhase constructs the phase run by a lazy phase.
+type lazyPhase struct {
+	name      string
+	factory   func() (*Phase, error)
+	permanent bool
+
+	mu sync.Mutex
+	// phase is the constructed phase, once the factory succeeds
+	phase *Phase
+	// err is the error of the factory when it is permanent
+	err error
+	// call is the invocation of the factory in progress, if any
+	call *factoryCall
+}
+
+// factoryCall is an invocation of a lazy phase's factory.
+type factoryCall struct {
+	done  chan struct{}
+	phase *Phase
+	err   error
+}
+
+// run runs the constructed phase on value, constructing it first if needed.
+func (l *lazyPhase) run(ctx context.Context, value interface{}) (interface{}, error) {
+	p, err := l.resolve(ctx)
+	if err != nil {
+		return value, err
+	}
+	return p.runStages(ctx, value)
+}
+
+// resolve returns the constructed phase, invoking the factory unless another
+// run is already invoking it.
+func (l *lazyPhase) resolve(ctx context.Context) (*Phase, error) {
+	l.mu.Lock()
+	if l.phase != nil || l.err != nil {
+		defer l.mu.Unlock()
+		return l.phase, l.err
+	}
+	call := l.call
+	if call == nil {
+		call = &factoryCall{done: make(chan struct{})}
+		l.call = call
+		l.mu.Unlock()
+
+		runStateFrom(ctx).trace.printf(l.name, "constructing the phase")
+		call.phase, call.err = l.construct()
+
+		l.mu.Lock()
+		if call.err == nil {
+			l.phase = call.phase
+		} else if l.permanent {
+			l.err = call.err
+		}
+		l.call = nil
+		close(call.done)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.phase, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// construct invokes the factory, checking the phase it returns.
+func (l *lazyPhase) construct() (*Phase, error) {
+	p, err := l.factory()
+	switch {
+	case err != nil:
+		return nil, err
+	case p == nil:
+		return nil, errors.New("factory returned no phase")
+	case p.Name != l.name:
+		return nil, fmt.Errorf("factory returned phase %s", p.Name)
+	}
+	return p, nil
+}
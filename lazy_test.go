@@ -0,0 +1,113 @@
+package phaser
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazyPhaseConstructedOnce(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddLazyPhase("model", func() (*Phase, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return NewPhase("model", addOne), nil
+	}))
+
+	var wg sync.WaitGroup
+	outputs := make([]interface{}, 8)
+	errs := make([]error, 8)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], errs[i] = m.Run(i)
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+
+	for i := range outputs {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, i+1, outputs[i])
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestLazyPhaseNotConstructedWhenSkipped(t *testing.T) {
+	calls := 0
+	m := NewPhaseManager()
+	require.NoError(t, m.AddLazyPhase("model", func() (*Phase, error) {
+		calls++
+		return NewPhase("model", addOne), nil
+	}))
+	m.phases[0].Disabled = true
+
+	output, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, output)
+	assert.Zero(t, calls)
+
+	m.phases[0].Disabled = false
+	output, err = m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, output)
+	assert.Equal(t, 1, calls)
+}
+
+func TestLazyPhaseFactoryError(t *testing.T) {
+	errLoad := errors.New("model unavailable")
+
+	t.Run("retried by the next run", func(t *testing.T) {
+		calls := 0
+		m := NewPhaseManager()
+		require.NoError(t, m.AddLazyPhase("model", func() (*Phase, error) {
+			calls++
+			if calls == 1 {
+				return nil, errLoad
+			}
+			return NewPhase("model", addOne), nil
+		}))
+
+		_, err := m.Run(1)
+		var phaseErr *PhaseError
+		require.ErrorAs(t, err, &phaseErr)
+		assert.Equal(t, "model", phaseErr.Phase)
+		assert.ErrorIs(t, err, errLoad)
+
+		output, err := m.Run(1)
+		require.NoError(t, err)
+		assert.Equal(t, 2, output)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		calls := 0
+		m := NewPhaseManager()
+		require.NoError(t, m.AddLazyPhase("model", func() (*Phase, error) {
+			calls++
+			return nil, errLoad
+		}, WithPermanentFactoryError()))
+
+		for i := 0; i < 3; i++ {
+			_, err := m.Run(1)
+			assert.ErrorIs(t, err, errLoad)
+		}
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("misnamed phase", func(t *testing.T) {
+		m := NewPhaseManager()
+		require.NoError(t, m.AddLazyPhase("model", func() (*Phase, error) {
+			return NewPhase("other", addOne), nil
+		}))
+		_, err := m.Run(1)
+		assert.EqualError(t, err, "phase model: factory returned phase other")
+	})
+}
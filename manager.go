@@ -0,0 +1,683 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ManagerOption configures a DefaultPhaseManager.
+type ManagerOption func(m *DefaultPhaseManager)
+
+var (
+	// ErrDuplicatePhase is returned when adding a phase whose name is already
+	// registered
+	ErrDuplicatePhase = errors.New("duplicate phase")
+	// ErrEmptyPhaseName is returned when adding a phase without a name
+	ErrEmptyPhaseName = errors.New("empty phase name")
+	// ErrPhaseNotFound is returned when referencing a phase that is not
+	// registered
+	ErrPhaseNotFound = errors.New("phase not found")
+	// ErrFailureBudgetExceeded is returned when more non-critical phases fail
+	// than allowed by WithMaxFailures
+	ErrFailureBudgetExceeded = errors.New("failure budget exceeded")
+	// ErrPhaseNotImplemented is returned when running a phase without an
+	// execute function
+	ErrPhaseNotImplemented = errors.New("phase not implemented")
+)
+
+// WithMaxFailures aborts runs once more than n non-critical phases have
+// failed. The returned error joins ErrFailureBudgetExceeded with the errors of
+// every failed non-critical phase, in the order the phases ran.
+func WithMaxFailures(n int) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.maxFailures = n
+	}
+}
+
+// RunOption configures a single run.
+type RunOption func(c *runConfig)
+
+// runConfig contains the configuration of a single run.
+type runConfig struct {
+	// allowAddedPhases tolerates resuming with phases added after the last
+	// checkpoint
+	allowAddedPhases bool
+	// report is filled with the outcome of the run when set
+	report *RunReport
+	// warnings collects the warnings of the run when set
+	warnings *warningCollector
+	// overrides contains the configuration overrides of the run's phases by
+	// phase name
+	overrides map[string][]PhaseOverride
+	// warmStart is the name of the phase runs started using RunFrom start
+	// after
+	warmStart string
+	// trace is filled with the execution trace of the run when set
+	trace *ExecutionTrace
+	// yield makes the run yield between its phases when it returns true
+	yield func() bool
+	// resumed is the yield of the run continued by the run when set
+	resumed *yieldError
+	// accounting measures the resources used by the run's phases when set
+	accounting *resourceAccounting
+	// invariants checks the invariants of the run when set
+	invariants *invariantChecker
+	// captured captures the values of the run when set
+	captured *CapturedRun
+	// maxCapturedBytes bounds the size of the values captured by the run
+	maxCapturedBytes int
+	// runID and initiator identify the run and who started it in the
+	// manager's journal
+	runID     string
+	initiator string
+}
+
+// newRunConfig returns the run configuration resulting of applying opts.
+func newRunConfig(opts []RunOption) *runConfig {
+	c := &runConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// DefaultPhaseManager is the default PhaseManager implementation. It runs its
+// phases sequentially in the order they were added, piping the output of each
+// phase into the input of the next one.
+type DefaultPhaseManager struct {
+	// StrictMode makes runs fail when a hook returns a nil value, unless its
+	// phase sets AllowNilValues, or changes the concrete type of the value.
+	// It is meant to catch misbehaving hooks while developing a pipeline
+	StrictMode bool
+	// RetryBudget caps the total number of retries of the phases of a single
+	// run. Once exhausted, failing phases are not retried regardless of their
+	// RetryPolicy. Zero disables the budget
+	RetryBudget int
+	// DefaultPhaseTimeout is the Timeout of the phases without one. Phases
+	// setting a Timeout use it instead, and phases whose Timeout is
+	// NoTimeout never time out. Zero disables the default
+	DefaultPhaseTimeout time.Duration
+	// OnValueChange is called after each phase whose output is passed on
+	// with the phase's input and output, even when they are equal. It is
+	// meant for debugging how the phases transform the value
+	OnValueChange func(phase string, before, after interface{})
+	// ContinueOnError makes runs attempt every phase regardless of the
+	// failures of critical phases, as for teardown pipelines made of
+	// independent cleanup steps. Failed phases pass their input on, and the
+	// run returns the errors of the failed critical phases joined once every
+	// phase ran. Runs still stop when their context is done
+	ContinueOnError bool
+
+	// phases contains the registered phases in execution order
+	phases []*Phase
+	// checkpointer persists the value produced by each phase. Checkpointing
+	// is disabled when nil
+	checkpointer Checkpointer
+	// maxFailures is the number of non-critical phase failures tolerated by a
+	// run. Negative values disable the limit
+	maxFailures int
+	// trace writes the debug trace of the runs when set
+	trace *debugTrace
+	// stats counts the hook invocations of every run when set
+	stats *hookStats
+	// observers are notified of the progress of every run
+	observers []Observer
+	// limit limits the concurrency of the phases of every run when set
+	limit *concurrencyLimit
+	// progress is called with the progress of every run when set
+	progress func(Progress)
+	// history contains the historical durations of the phases when set
+	history *durationHistory
+	// stepper pauses runs between phases when set
+	stepper *stepper
+	// clock is the source of time of the runs. The system time is used when
+	// nil
+	clock Clock
+	// inputValidator and outputValidator check the input and output of the
+	// runs when set
+	inputValidator  Validator
+	outputValidator Validator
+	// audit writes the audit records of the runs when set
+	audit *auditLog
+	// guard rejects changes to the phases during runs when set
+	guard *mutationGuard
+	// heartbeats records the heartbeats of the phases
+	heartbeats *heartbeats
+	// stallWindow is the time after which phases not calling Checkpoint are
+	// flagged as stalled. Zero disables stall detection
+	stallWindow time.Duration
+	// stallInterval is the interval between stall checks, derived from
+	// stallWindow when zero
+	stallInterval time.Duration
+	// quarantine quarantines the failing non-critical phases when set
+	quarantine *quarantine
+	// tracer starts the spans of the phases of every run when set
+	tracer Tracer
+	// artifactCount and artifactSize limit the artifacts of each phase when
+	// positive
+	artifactCount, artifactSize int
+	// sampling captures the values of the sampled runs when set
+	sampling *valueSampling
+	// lifecycle tracks the work in flight, to shut the manager down
+	lifecycle *lifecycle
+	// flags gates the phases with a FeatureFlag when set
+	flags FlagProvider
+	// panicUnimplemented makes runs panic on phases without an execute
+	// function instead of failing
+	panicUnimplemented bool
+	// mutations records which hooks changed the value when set
+	mutations *mutationRecorder
+	// codec serializes the values of the phases when set
+	codec Codec
+	// contracts handles the contract hooks of the runs when set
+	contracts *contractConfig
+	// maxRetryDelay caps the retry delays hinted by errors when positive
+	maxRetryDelay time.Duration
+	// profiles contains the registered profiles and the applied one
+	profiles *profiles
+	// ownership checks the changes phases make to the value when set
+	ownership *ownershipChecker
+	// journal journals the transitions of the runs when set
+	journal *journalConfig
+}
+
+var _ PhaseManager = (*DefaultPhaseManager)(nil)
+
+// NewPhaseManager returns an empty DefaultPhaseManager configured with opts.
+func NewPhaseManager(opts ...ManagerOption) *DefaultPhaseManager {
+	m := &DefaultPhaseManager{maxFailures: -1}
+	m.heartbeats = &heartbeats{now: m.now, beats: map[string]time.Time{}}
+	m.lifecycle = &lifecycle{}
+	m.profiles = &profiles{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Pipe returns a manager running fns in order, each as the execute function
+// of a phase named after its index: "phase-0", "phase-1", and so on. It is a
+// shorthand for linear pipelines that need no hooks or phase settings.
+func Pipe(fns ...func(value interface{}) (interface{}, error)) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	for i, fn := range fns {
+		// The generated names are unique, so adding the phases cannot fail
+		m.phases = append(m.phases, NewPhase(fmt.Sprintf("phase-%d", i), fn))
+	}
+	return m
+}
+
+// AddPhase registers phase under its name. The manager keeps the pointer
+// rather than a copy, so hooks appended to the phase and changes made to its
+// fields after adding it apply to later runs. Phases added to several
+// managers are shared by all of them. Changing a phase while it runs is a
+// data race, which WithStrictMutation turns into errors.
+func (m *DefaultPhaseManager) AddPhase(phase *Phase) error {
+	if err := m.guard.check(phase.Name); err != nil {
+		return err
+	}
+	if err := m.checkNewPhase(phase.Name); err != nil {
+		return err
+	}
+	if m.guard != nil {
+		phase.guard = m.guard
+	}
+	m.phases = append(m.phases, phase)
+	return nil
+}
+
+// AddPhases registers phases in order. On error, none of the phases is added
+// and the manager is left unchanged.
+func (m *DefaultPhaseManager) AddPhases(phases ...*Phase) error {
+	added := len(m.phases)
+	for i, p := range phases {
+		if err := m.AddPhase(p); err != nil {
+			m.phases = m.phases[:added]
+			return fmt.Errorf("adding phase %d (%s): %w", i, p.Name, err)
+		}
+	}
+	return nil
+}
+
+// PhaseNames returns the names of the phases in the order they run.
+func (m *DefaultPhaseManager) PhaseNames() []string {
+	names := make([]string, len(m.phases))
+	for i, p := range m.phases {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// ForEachPhase calls fn with each phase and its index, in the order they
+// run, without running them. It stops early when fn returns false. Phases
+// added by fn are not visited.
+func (m *DefaultPhaseManager) ForEachPhase(fn func(index int, p *Phase) bool) {
+	for i, p := range m.phases {
+		if !fn(i, p) {
+			return
+		}
+	}
+}
+
+// PhaseAt returns the phase at index in the order the phases run, or false
+// if index is out of range.
+func (m *DefaultPhaseManager) PhaseAt(index int) (*Phase, bool) {
+	if index < 0 || index >= len(m.phases) {
+		return nil, false
+	}
+	return m.phases[index], true
+}
+
+// AddPreHookToPhase appends hook to the pre-hooks of the phase named
+// phaseName.
+func (m *DefaultPhaseManager) AddPreHookToPhase(phaseName string, hook PhaseHook) error {
+	p := m.phase(phaseName)
+	if p == nil {
+		return fmt.Errorf("%w: %s", ErrPhaseNotFound, phaseName)
+	}
+	if err := p.guard.check(phaseName); err != nil {
+		return err
+	}
+	p.appendPreHook(hook)
+	return nil
+}
+
+// AddPostHookToPhase appends hook to the post-hooks of the phase named
+// phaseName.
+func (m *DefaultPhaseManager) AddPostHookToPhase(phaseName string, hook PhaseHook) error {
+	p := m.phase(phaseName)
+	if p == nil {
+		return fmt.Errorf("%w: %s", ErrPhaseNotFound, phaseName)
+	}
+	if err := p.guard.check(phaseName); err != nil {
+		return err
+	}
+	p.appendPostHook(hook)
+	return nil
+}
+
+// Run runs every phase in order starting from the first one. The value
+// returned by each phase is used as the input of the next phase, and the value
+// returned by the last phase is returned.
+//
+// When the run fails, the last good value is returned along with the error:
+// the output of the last phase that completed, or value when no phase did.
+// Failed phases never contribute their output.
+func (m *DefaultPhaseManager) Run(value interface{}, opts ...RunOption) (interface{}, error) {
+	return m.RunContext(context.Background(), value, opts...)
+}
+
+// RunContext is like Run, but phases waiting on ctx, such as rate limited
+// phases, stop waiting and fail once ctx is done.
+//
+// When ctx is cancelled or times out, including when it is cancelled on an
+// interrupt signal, the run stops and returns a *PartialResultError holding
+// the output of the last completed phase. Critical phases timing out stop the
+// run the same way. The deadline of ctx, such as the deadline of an HTTP
+// request's context, bounds the whole run as well as the Timeout of each
+// phase, so a run never outlives the request it serves. Phases that ignore
+// their context are abandoned, and finish in the background.
+func (m *DefaultPhaseManager) RunContext(ctx context.Context, value interface{}, opts ...RunOption) (interface{}, error) {
+	return m.run(ctx, newRunConfig(opts), 0, value)
+}
+
+// ResumeRun resumes a previously interrupted run. Phases with a saved
+// checkpoint are skipped, and the run continues from the first phase without
+// one using the value of the last checkpoint. Phases the run skips, such as
+// disabled, flagged off or quarantined phases, have no checkpoint and are
+// passed over. When no checkpoint exists, value is used as the input of the
+// first phase.
+//
+// Checkpoints saved by a pipeline with different phases or phase versions
+// are not resumed, and an *IncompatibleStateError detailing the differences
+// is returned instead.
+func (m *DefaultPhaseManager) ResumeRun(value interface{}, opts ...RunOption) (interface{}, error) {
+	c := newRunConfig(opts)
+	start := 0
+	if m.checkpointer != nil {
+		var record *CheckpointRecord
+		// checkpointed is the phase whose checkpoint is record
+		var checkpointed *Phase
+		// Phases skipped by the run have no checkpoint
+		state := &runState{flags: m.flags, overrides: m.profiles.withRun(c.overrides)}
+		ctx := withRunState(context.Background(), state)
+		for ; start < len(m.phases); start++ {
+			if p, _ := state.overridden(m.phases[start]); p.skipped(ctx) || m.quarantine.holds(p, m.now()) {
+				continue
+			}
+			name := m.phases[start].Name
+			saved, ok, err := m.checkpointer.Load(name)
+			if err != nil {
+				return nil, fmt.Errorf("loading checkpoint for phase %s: %w", name, err)
+			}
+			if !ok {
+				break
+			}
+			if record, ok = saved.(*CheckpointRecord); !ok {
+				return nil, fmt.Errorf("checkpoint for phase %s is not a versioned record: %w", name, ErrIncompatibleState)
+			}
+			checkpointed = m.phases[start]
+		}
+		if record != nil {
+			if err := m.checkCompatible(record, start-1, c.allowAddedPhases); err != nil {
+				return nil, err
+			}
+			var err error
+			if value, err = m.checkpointValue(checkpointed, record); err != nil {
+				return nil, err
+			}
+		} else {
+			// Only skipped phases were passed, run from the start
+			start = 0
+		}
+	}
+
+	return m.run(context.Background(), c, start, value)
+}
+
+// run starts a new run configured by c from the phase at index start.
+func (m *DefaultPhaseManager) run(ctx context.Context, c *runConfig, start int, value interface{}) (interface{}, error) {
+	if err := m.checkOverrides(c); err != nil {
+		return value, err
+	}
+	leave, err := m.lifecycle.enter()
+	if err != nil {
+		return value, err
+	}
+	defer leave()
+	defer m.guard.enter()()
+	if c.trace != nil && c.report == nil {
+		// The trace is built from the run's report
+		c.report = &RunReport{}
+	}
+	state := &runState{
+		strict:             m.StrictMode,
+		report:             c.report,
+		stats:              m.stats,
+		observers:          m.observers,
+		retryBudget:        m.RetryBudget,
+		maxRetryDelay:      m.maxRetryDelay,
+		defaultTimeout:     m.DefaultPhaseTimeout,
+		limit:              m.limit,
+		warnings:           c.warnings,
+		audit:              m.audit,
+		overrides:          m.profiles.withRun(c.overrides),
+		stepper:            m.stepper,
+		heartbeats:         m.heartbeats,
+		tracer:             m.tracer,
+		clock:              m.timeSource(),
+		lifecycle:          m.lifecycle,
+		flags:              m.flags,
+		panicUnimplemented: m.panicUnimplemented,
+		mutations:          m.mutations,
+		accounting:         c.accounting,
+		invariants:         c.invariants,
+		captured:           c.captured,
+		contracts:          m.contracts,
+		ownership:          m.ownership,
+		artifacts:          newArtifactStore(m.artifactCount, m.artifactSize),
+		capture:            m.sampling.sample(),
+	}
+	if c.yield != nil {
+		state.yield, state.top = c.yield, m
+	}
+	if c.resumed != nil && c.resumed.journal != nil {
+		state.journal = c.resumed.journal
+	} else if m.journal != nil {
+		state.journal = m.journal.start(c, fingerprint(m.phaseVersions()), state.clock)
+	}
+	if m.usesOutputs() {
+		state.outputs = make(map[string]interface{})
+		if c.resumed != nil {
+			for name, output := range c.resumed.outputs {
+				state.outputs[name] = output
+			}
+		}
+	}
+	if m.stallWindow > 0 {
+		state.watchdog = m.startWatchdog(m.stallInterval)
+		defer state.watchdog.stop()
+	}
+	if state.report != nil {
+		*state.report = RunReport{Start: m.now()}
+	}
+	defer m.stepper.reset()
+	var started time.Time
+	if m.trace != nil {
+		state.trace = m.trace.start(state.clock)
+		started = state.trace.started("", "run", value)
+	}
+	if c.warmStart != "" {
+		m.skipWarmStarted(state, c.warmStart, start, value)
+	}
+
+	value, err = m.runValidated(withRunState(ctx, state), start, value)
+	err = state.journal.finished(err)
+	if state.trace != nil {
+		state.trace.finished("", "run", started, value, err)
+	}
+
+	if state.report != nil {
+		state.report.Duration = m.now().Sub(state.report.Start)
+		state.report.Err = err
+	}
+	if c.trace != nil {
+		*c.trace = NewExecutionTrace(*state.report)
+	}
+	return value, err
+}
+
+// runFrom runs the phases starting at index start as part of the run whose
+// state is stored in ctx.
+func (m *DefaultPhaseManager) runFrom(ctx context.Context, start int, value interface{}) (interface{}, error) {
+	state := runStateFrom(ctx)
+	var versions []PhaseVersion
+	var fp string
+	if m.checkpointer != nil {
+		versions = m.phaseVersions()
+		fp = fingerprint(versions)
+	}
+
+	// completed is the name of the last phase completed by the run
+	var completed string
+	// failed contains the errors of the critical phases that failed when
+	// the manager continues on errors
+	var failed []error
+	// declared is the declared type of value, the OutputType of the phase
+	// that produced it
+	var declared reflect.Type
+	for i, p := range m.phases[start:] {
+		if err := ctx.Err(); err != nil {
+			return value, &PartialResultError{LastValue: value, CompletedPhase: completed, Err: withCancelCause(ctx, err)}
+		}
+		if err := state.journal.failure(); err != nil {
+			return value, err
+		}
+		if i > 0 && state.top == m && state.yield() {
+			state.trace.printf(p.Name, "yielding before the phase")
+			return value, &yieldError{next: p.Name, index: start + i, value: value, outputs: state.outputs, journal: state.journal}
+		}
+		p, config := state.overridden(p)
+		if p.skipped(ctx) {
+			if p.Disabled {
+				state.trace.printf(p.Name, "disabled, skipping")
+			} else if !p.flagEnabled(ctx) {
+				state.trace.printf(p.Name, "feature flag %s disabled, skipping", p.FeatureFlag)
+			} else {
+				state.trace.printf(p.Name, "skipped by context")
+			}
+			state.record(PhaseResult{Phase: p.Name, Status: StatusSkipped, Config: config})
+			m.reportProgress(start + i)
+			continue
+		}
+		if !m.quarantine.admit(p, m.now()) {
+			state.trace.printf(p.Name, "quarantined, skipping")
+			state.record(PhaseResult{Phase: p.Name, Status: StatusQuarantined, Config: config})
+			m.reportProgress(start + i)
+			continue
+		}
+		if err := m.stepper.wait(ctx); err != nil {
+			state.trace.printf(p.Name, "run stopped before the phase: %v", err)
+			return value, &PartialResultError{LastValue: value, CompletedPhase: completed, Err: withCancelCause(ctx, err)}
+		}
+
+		result := &PhaseResult{Phase: p.Name, Status: StatusSucceeded, Start: m.now(), Config: config}
+		input, err := p.input(value, state.outputs)
+		if err == nil && len(p.inputs) == 0 {
+			input, err = p.convertInput(declared, input)
+		}
+		state.start(p.Name, input)
+		if err == nil && state.capture != nil {
+			state.capture(p.Name, StagePreHook, input)
+		}
+		if err == nil {
+			state.captured.record(p, CaptureInput, "", "", input)
+		}
+		var output, snapshot interface{}
+		if err == nil {
+			snapshot, err = state.ownership.snapshot(m.codecFor(p), input)
+		}
+		if err == nil {
+			state.watchdog.begin(result)
+			before := state.accounting.sample()
+			output, err = p.runContext(withPhaseResult(ctx, result), input)
+			state.account(result, before)
+			state.watchdog.end(result)
+		}
+		if err == nil {
+			output, err = p.checkNil(withPhaseResult(ctx, result), "", -1, input, output)
+		}
+		if err == nil {
+			err = state.ownership.check(state, result, p, m.codecFor(p), snapshot, output)
+		}
+		result.Duration = m.now().Sub(result.Start)
+		result.Artifacts = state.artifacts.take(result)
+		if auditErr := state.audit.write(p.Name, m.codecFor(p), result.Start, input, output, err); auditErr != nil {
+			return value, auditErr
+		}
+		if errors.Is(err, ErrStopPipeline) {
+			// Left for run to clear, so that stops within branches end
+			// the whole run
+			state.trace.printf(p.Name, "pipeline stopped")
+			state.record(*result)
+			return output, err
+		}
+		var rejection *RejectionError
+		if errors.As(err, &rejection) {
+			state.trace.printf(p.Name, "input rejected: %s", rejection.Reason)
+			result.Status, result.Err, result.Output = StatusRejected, err, output
+			state.record(*result)
+			m.valueChanged(p.Name, input, output)
+			value, declared = output, p.OutputType
+			completed = p.Name
+			if state.outputs != nil {
+				state.outputs[p.Name] = output
+			}
+		} else if err != nil {
+			// Stepped phases identify the failing step themselves
+			var phaseErr *PhaseError
+			if !errors.As(err, &phaseErr) || phaseErr.Phase != p.Name {
+				err = &PhaseError{Phase: p.Name, Err: withCancelCause(ctx, err)}
+			} else {
+				phaseErr.Err = withCancelCause(ctx, phaseErr.Err)
+			}
+			result.Status, result.Err, result.Cause = StatusFailed, err, cancelCauseOf(err)
+			state.record(*result)
+			if ctx.Err() != nil || (!p.NonCritical && !m.ContinueOnError && isInterruption(err)) {
+				return value, &PartialResultError{LastValue: value, CompletedPhase: completed, Err: err}
+			}
+			if !p.NonCritical && !m.ContinueOnError {
+				return value, err
+			}
+			if p.NonCritical {
+				// Non-critical failures pass the phase's input on
+				state.trace.printf(p.Name, "non-critical failure, passing the input on")
+				state.failures = append(state.failures, err)
+				if m.maxFailures >= 0 && len(state.failures) > m.maxFailures {
+					budgetErr := fmt.Errorf("%w: %d non-critical phases failed", ErrFailureBudgetExceeded, len(state.failures))
+					return value, errors.Join(append([]error{budgetErr}, state.failures...)...)
+				}
+			} else {
+				state.trace.printf(p.Name, "failure, continuing on error with the input")
+				failed = append(failed, err)
+			}
+		} else {
+			result.Output = output
+			state.record(*result)
+			if state.capture != nil {
+				state.capture(p.Name, StagePostHook, output)
+			}
+			state.captured.record(p, CaptureOutput, "", "", output)
+			m.history.add(p.Name, result.Duration)
+			m.valueChanged(p.Name, input, output)
+			value, declared = output, p.OutputType
+			completed = p.Name
+			if state.outputs != nil {
+				state.outputs[p.Name] = output
+			}
+		}
+		m.quarantine.record(p, m.now(), err != nil && rejection == nil)
+		m.reportProgress(start + i)
+
+		if m.checkpointer != nil {
+			record := &CheckpointRecord{Fingerprint: fp, Phases: versions, Value: value}
+			if codec := m.codecFor(p); codec != nil {
+				data, err := codec.Marshal(value)
+				if err != nil {
+					return value, fmt.Errorf("encoding checkpoint for phase %s: %w", p.Name, err)
+				}
+				record.Codec, record.Data, record.Value = codec.Name(), data, nil
+			}
+			if err := m.checkpointer.Save(p.Name, record); err != nil {
+				return value, fmt.Errorf("saving checkpoint for phase %s: %w", p.Name, err)
+			}
+		}
+	}
+
+	return value, errors.Join(failed...)
+}
+
+// valueChanged calls OnValueChange, when set, with the input and output of
+// phase.
+func (m *DefaultPhaseManager) valueChanged(phase string, before, after interface{}) {
+	if m.OnValueChange != nil {
+		m.OnValueChange(phase, before, after)
+	}
+}
+
+// now returns the current time according to the manager's clock.
+func (m *DefaultPhaseManager) now() time.Time {
+	return m.timeSource().Now()
+}
+
+// timeSource returns the manager's clock.
+func (m *DefaultPhaseManager) timeSource() Clock {
+	if m.clock != nil {
+		return m.clock
+	}
+	return realClock{}
+}
+
+// checkNewPhase checks whether a phase named name can be added.
+func (m *DefaultPhaseManager) checkNewPhase(name string) error {
+	if name == "" {
+		return ErrEmptyPhaseName
+	}
+	if m.phase(name) != nil {
+		return fmt.Errorf("%w: %s", ErrDuplicatePhase, name)
+	}
+	return nil
+}
+
+// phase returns the phase registered as name, or nil if there is none.
+func (m *DefaultPhaseManager) phase(name string) *Phase {
+	for _, p := range m.phases {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
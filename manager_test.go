@@ -0,0 +1,312 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addOne is an execute function adding one to an int value.
+func addOne(value interface{}) (interface{}, error) {
+	return value.(int) + 1, nil
+}
+
+func TestManagerRunPipesValues(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	require.NoError(t, m.AddPhase(NewPhase("two", addOne)))
+	require.NoError(t, m.AddPhase(NewPhase("three", func(value interface{}) (interface{}, error) {
+		return value.(int) * 10, nil
+	})))
+
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 20, value)
+}
+
+func TestManagerAddPhaseErrors(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+
+	assert.ErrorIs(t, m.AddPhase(NewPhase("one", addOne)), ErrDuplicatePhase)
+	assert.ErrorIs(t, m.AddPhase(NewPhase("", addOne)), ErrEmptyPhaseName)
+	assert.ErrorIs(t, m.AddPreHookToPhase("missing", returnNil), ErrPhaseNotFound)
+	assert.ErrorIs(t, m.AddPostHookToPhase("missing", returnNil), ErrPhaseNotFound)
+	require.Len(t, m.phases, 1)
+}
+
+func TestManagerAddPhaseKeepsPointer(t *testing.T) {
+	m := NewPhaseManager()
+	p := NewPhase("one", addOne)
+	require.NoError(t, m.AddPhase(p))
+
+	// Hooks added through the manager are visible through the pointer
+	require.NoError(t, m.AddPreHookToPhase("one", func(value interface{}) (interface{}, error) {
+		return value.(int) * 10, nil
+	}))
+	assert.Len(t, p.preHooks, 1)
+
+	// Changes made through the pointer apply to later runs
+	p.appendPostHook(func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	})
+	value, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 22, value)
+}
+
+func TestManagerAddPhases(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+
+	err := m.AddPhases(
+		NewPhase("two", addOne),
+		NewPhase("three", addOne),
+	)
+	require.NoError(t, err)
+	require.Len(t, m.phases, 3)
+
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+}
+
+func TestManagerAddPhasesRollsBack(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+
+	// A duplicate of an existing phase
+	err := m.AddPhases(
+		NewPhase("two", addOne),
+		NewPhase("one", addOne),
+		NewPhase("three", addOne),
+	)
+	assert.ErrorIs(t, err, ErrDuplicatePhase)
+	assert.EqualError(t, err, "adding phase 1 (one): duplicate phase: one")
+	require.Len(t, m.phases, 1)
+	assert.Equal(t, "one", m.phases[0].Name)
+
+	// A duplicate within the batch
+	err = m.AddPhases(
+		NewPhase("two", addOne),
+		NewPhase("three", addOne),
+		NewPhase("two", addOne),
+	)
+	assert.EqualError(t, err, "adding phase 2 (two): duplicate phase: two")
+	require.Len(t, m.phases, 1)
+
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
+
+func TestManagerAddHooksToPhase(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	require.NoError(t, m.AddPreHookToPhase("one", func(value interface{}) (interface{}, error) {
+		return value.(int) * 10, nil
+	}))
+	require.NoError(t, m.AddPostHookToPhase("one", func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	}))
+
+	value, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 22, value)
+}
+
+func TestManagerRunWrapsPhaseErrors(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	require.NoError(t, m.AddPhase(NewPhase("two", func(value interface{}) (interface{}, error) {
+		return nil, assert.AnError
+	})))
+
+	_, err := m.Run(0)
+
+	var phaseErr *PhaseError
+	require.True(t, errors.As(err, &phaseErr))
+	assert.Equal(t, "two", phaseErr.Phase)
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestManagerSkipsDisabledPhases(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", failWith(assert.AnError)),
+		NewPhase("three", addOne),
+	))
+	m.phases[1].Disabled = true
+
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+func TestDefaultPhaseTimeout(t *testing.T) {
+	slow := func(value interface{}) (interface{}, error) {
+		time.Sleep(30 * time.Millisecond)
+		return value, nil
+	}
+
+	tests := []struct {
+		name string
+		opts []PhaseOption
+		err  error
+	}{
+		{name: "inherited", err: context.DeadlineExceeded},
+		{name: "overridden", opts: []PhaseOption{WithTimeout(time.Second)}},
+		{name: "unlimited", opts: []PhaseOption{WithTimeout(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewPhaseManager()
+			m.DefaultPhaseTimeout = time.Millisecond
+			require.NoError(t, m.AddPhase(NewPhase("slow", slow, tt.opts...)))
+
+			_, err := m.Run(0)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDefaultPhaseTimeoutOverride(t *testing.T) {
+	m := NewPhaseManager()
+	m.DefaultPhaseTimeout = time.Millisecond
+	require.NoError(t, m.AddPhase(NewPhase("slow", func(value interface{}) (interface{}, error) {
+		time.Sleep(30 * time.Millisecond)
+		return value, nil
+	})))
+
+	_, err := m.Run(0, WithPhaseOverride("slow", OverrideTimeout(0)))
+	assert.NoError(t, err)
+}
+
+func TestRunReturnsLastGoodValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		failAt int
+		want   int
+	}{
+		{name: "first phase", failAt: 0, want: 10},
+		{name: "middle phase", failAt: 1, want: 11},
+		{name: "last phase", failAt: 2, want: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewPhaseManager()
+			for i := 0; i < 3; i++ {
+				execute := addOne
+				if i == tt.failAt {
+					execute = func(value interface{}) (interface{}, error) {
+						return value.(int) + 100, assert.AnError
+					}
+				}
+				require.NoError(t, m.AddPhase(NewPhase(string(rune('a'+i)), execute)))
+			}
+
+			value, err := m.Run(10)
+			assert.ErrorIs(t, err, assert.AnError)
+			assert.Equal(t, tt.want, value)
+		})
+	}
+}
+
+func TestAddPhaseKeepsPointer(t *testing.T) {
+	m := NewPhaseManager()
+	p := NewPhase("one", addOne)
+	require.NoError(t, m.AddPhase(p))
+
+	p.appendPostHook(addOne)
+	p.Retry = &RetryPolicy{MaxAttempts: 2}
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Same(t, p, m.phase("one"))
+}
+
+func TestOnValueChange(t *testing.T) {
+	type change struct {
+		phase         string
+		before, after interface{}
+	}
+	var changes []change
+	m := NewPhaseManager()
+	m.OnValueChange = func(phase string, before, after interface{}) {
+		changes = append(changes, change{phase, before, after})
+	}
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("same", func(value interface{}) (interface{}, error) {
+			return value, nil
+		}),
+		NewPhase("skipped", addOne),
+		NewPhase("three", addOne),
+	))
+	m.phases[2].Disabled = true
+
+	_, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, []change{
+		{"one", 0, 1},
+		{"same", 1, 1},
+		{"three", 1, 2},
+	}, changes)
+}
+
+func TestForEachPhase(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(NewPhase("first", addOne), NewPhase("second", addOne), NewPhase("third", addOne)))
+
+	var visited []string
+	m.ForEachPhase(func(index int, p *Phase) bool {
+		assert.Equal(t, len(visited), index)
+		visited = append(visited, p.Name)
+		return true
+	})
+	assert.Equal(t, []string{"first", "second", "third"}, visited)
+
+	visited = nil
+	m.ForEachPhase(func(index int, p *Phase) bool {
+		visited = append(visited, p.Name)
+		return p.Name != "second"
+	})
+	assert.Equal(t, []string{"first", "second"}, visited)
+}
+
+func TestPhaseAt(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(NewPhase("first", addOne), NewPhase("second", addOne)))
+
+	p, ok := m.PhaseAt(1)
+	require.True(t, ok)
+	assert.Equal(t, "second", p.Name)
+	for _, index := range []int{-1, 2} {
+		_, ok = m.PhaseAt(index)
+		assert.False(t, ok, index)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	m := Pipe(
+		addOne,
+		func(value interface{}) (interface{}, error) { return value.(int) * 10, nil },
+		func(value interface{}) (interface{}, error) { return fmt.Sprint(value), nil },
+	)
+
+	value, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, "20", value)
+	assert.Equal(t, []string{"phase-0", "phase-1", "phase-2"}, m.PhaseNames())
+}
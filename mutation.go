@@ -0,0 +1,55 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrMutationDuringRun is returned when changing the phases or hooks of a
+// manager created using WithStrictMutation while it runs.
+var ErrMutationDuringRun = errors.New("mutation during run")
+
+// WithStrictMutation rejects changes to the manager's phases and hooks while
+// any of its runs is in progress, instead of letting them race with the runs.
+// The manager's methods return ErrMutationDuringRun, and the hook methods of
+// its phases panic with an error wrapping ErrMutationDuringRun.
+func WithStrictMutation() ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.guard = &mutationGuard{}
+	}
+}
+
+// mutationGuard counts the runs in progress of a manager using
+// WithStrictMutation. A nil *mutationGuard allows every mutation.
+type mutationGuard struct {
+	runs int64
+}
+
+// enter counts a run starting, returning the function counting its end.
+func (g *mutationGuard) enter() func() {
+	if g == nil {
+		return func() {}
+	}
+	atomic.AddInt64(&g.runs, 1)
+	return func() {
+		atomic.AddInt64(&g.runs, -1)
+	}
+}
+
+// check returns an error wrapping ErrMutationDuringRun for the phase named
+// phase when a run is in progress.
+func (g *mutationGuard) check(phase string) error {
+	if g != nil && atomic.LoadInt64(&g.runs) > 0 {
+		return fmt.Errorf("%w: phase %s", ErrMutationDuringRun, phase)
+	}
+	return nil
+}
+
+// checkMutation panics when the phase is changed while a run of its strict
+// manager is in progress.
+func (p *Phase) checkMutation() {
+	if err := p.guard.check(p.Name); err != nil {
+		panic(err)
+	}
+}
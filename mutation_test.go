@@ -0,0 +1,57 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictMutation(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	m := NewPhaseManager(WithStrictMutation())
+	p := NewPhase("slow", func(value interface{}) (interface{}, error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return value, nil
+	})
+	require.NoError(t, m.AddPhase(p))
+
+	done := startRun(m, 0)
+	<-started
+	err := m.AddPreHookToPhase("slow", addOne)
+	assert.ErrorIs(t, err, ErrMutationDuringRun)
+	assert.EqualError(t, err, "mutation during run: phase slow")
+	assert.ErrorIs(t, m.AddPhase(NewPhase("other", addOne)), ErrMutationDuringRun)
+	assert.PanicsWithError(t, "mutation during run: phase slow", func() {
+		p.AppendNamedPostHook("post", addOne)
+	})
+	close(release)
+	require.NoError(t, (<-done).err)
+
+	// Mutations are allowed once the run ends
+	require.NoError(t, m.AddPreHookToPhase("slow", addOne))
+	p.AppendNamedPostHook("post", addOne)
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+func TestNonStrictMutation(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("slow", func(value interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return value, nil
+	})))
+
+	done := startRun(m, 0)
+	<-started
+	assert.NoError(t, m.AddPhase(NewPhase("other", addOne)))
+	close(release)
+	require.NoError(t, (<-done).err)
+}
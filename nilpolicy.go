@@ -0,0 +1,81 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoNilValuePolicy is returned by Validate using Strict for the phases
+// without a NilValuePolicy.
+var ErrNoNilValuePolicy = errors.New("no nil value policy")
+
+// NilValuePolicy is how a phase handles its hooks and execute function
+// returning a nil value along with a nil error, which is usually a bug making
+// the next hook's type assertion panic.
+type NilValuePolicy int
+
+const (
+	// nilPolicyUnset is the policy of phases not using WithNilValuePolicy,
+	// which behaves like NilAllow
+	nilPolicyUnset NilValuePolicy = iota
+	// NilAllow passes nil values on, the default
+	NilAllow
+	// NilError fails the phase with a *NilValueError
+	NilError
+	// NilPassthrough replaces nil values with the value given to the hook or
+	// function that returned them, reporting a *NilValueError as a warning
+	NilPassthrough
+)
+
+// WithNilValuePolicy sets how the phase handles nil values returned by its
+// hooks and execute function, and passed on to the next phase, when no error
+// is returned. Hooks run using ParallelHooks are not checked, as they may not
+// change the value.
+func WithNilValuePolicy(policy NilValuePolicy) PhaseOption {
+	return func(p *Phase) {
+		p.nilPolicy = policy
+	}
+}
+
+// NilValueError reports a nil value returned by a phase using NilError, or
+// replaced by a phase using NilPassthrough.
+type NilValueError struct {
+	// Phase is the name of the phase
+	Phase string
+	// Stage is the stage that returned the nil value, StagePreHook,
+	// StageExecute or StagePostHook, or empty for the output of the phase
+	// passed on to the next phase
+	Stage Stage
+	// Hook is the index of the hook that returned the nil value, or -1 for
+	// other stages
+	Hook int
+}
+
+func (e *NilValueError) Error() string {
+	switch {
+	case e.Hook >= 0:
+		return fmt.Sprintf("%s %d of phase %s returned a nil value", e.Stage, e.Hook, displayName(e.Phase))
+	case e.Stage != "":
+		return fmt.Sprintf("%s of phase %s returned a nil value", e.Stage, displayName(e.Phase))
+	default:
+		return fmt.Sprintf("phase %s output a nil value", displayName(e.Phase))
+	}
+}
+
+// checkNil applies the phase's nil value policy to value, returned by the hook
+// at index hook of stage, or by stage when hook is negative, on previous.
+func (p *Phase) checkNil(ctx context.Context, stage Stage, hook int, previous, value interface{}) (interface{}, error) {
+	if value != nil {
+		return value, nil
+	}
+	switch p.nilPolicy {
+	case NilError:
+		return previous, &NilValueError{Phase: p.Name, Stage: stage, Hook: hook}
+	case NilPassthrough:
+		p.takeWarnings(ctx, AsWarning(&NilValueError{Phase: p.Name, Stage: stage, Hook: hook}))
+		return previous, nil
+	default:
+		return value, nil
+	}
+}
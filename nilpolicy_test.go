@@ -0,0 +1,111 @@
+package phaser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// passValue is a hook passing the value on, whether nil or not.
+func passValue(value interface{}) (interface{}, error) {
+	return value, nil
+}
+
+// nilPhase returns a phase returning a nil value at stage, or through its
+// DefaultOnError for the empty stage, using policy.
+func nilPhase(stage Stage, policy NilValuePolicy) *Phase {
+	execute := passValue
+	switch stage {
+	case StageExecute:
+		execute = returnNil
+	case "":
+		execute = failWith(errNotFound)
+	}
+	p := NewPhase("nil", execute, WithNilValuePolicy(policy))
+	p.appendPreHook(passValue)
+	p.appendPostHook(passValue)
+	switch stage {
+	case StagePreHook:
+		p.appendPreHook(returnNil)
+	case StagePostHook:
+		p.appendPostHook(returnNil)
+	case "":
+		p.DefaultOnError = func(error) (interface{}, bool) { return nil, true }
+	}
+	return p
+}
+
+func TestNilValuePolicy(t *testing.T) {
+	for _, stage := range []Stage{StagePreHook, StageExecute, StagePostHook, ""} {
+		hook := -1
+		if stage == StagePreHook || stage == StagePostHook {
+			hook = 1
+		}
+		expected := &NilValueError{Phase: "nil", Stage: stage, Hook: hook}
+
+		t.Run(string(stage)+"/allow", func(t *testing.T) {
+			var received []interface{}
+			m := NewPhaseManager()
+			require.NoError(t, m.AddPhases(nilPhase(stage, NilAllow), NewPhase("next", func(value interface{}) (interface{}, error) {
+				received = append(received, value)
+				return value, nil
+			})))
+			_, err := m.Run(0)
+			require.NoError(t, err)
+			assert.Equal(t, []interface{}{nil}, received)
+		})
+
+		t.Run(string(stage)+"/error", func(t *testing.T) {
+			m := NewPhaseManager()
+			require.NoError(t, m.AddPhases(nilPhase(stage, NilError), NewPhase("next", addOne)))
+			_, err := m.Run(0)
+			var nilErr *NilValueError
+			require.True(t, errors.As(err, &nilErr), err)
+			assert.Equal(t, expected, nilErr)
+		})
+
+		t.Run(string(stage)+"/passthrough", func(t *testing.T) {
+			m := NewPhaseManager()
+			require.NoError(t, m.AddPhases(nilPhase(stage, NilPassthrough), NewPhase("next", addOne)))
+			var report RunReport
+			value, err := m.Run(0, WithReport(&report))
+			require.NoError(t, err)
+			assert.NotNil(t, value)
+			result, _ := report.Result("nil")
+			require.Len(t, result.Warnings, 1)
+			var nilErr *NilValueError
+			require.True(t, errors.As(result.Warnings[0], &nilErr))
+			assert.Equal(t, expected, nilErr)
+		})
+	}
+}
+
+func TestNilValuePolicyPassthroughValue(t *testing.T) {
+	p := NewPhase("nil", addOne, WithNilValuePolicy(NilPassthrough))
+	p.appendPreHook(addOne)
+	p.appendPreHook(returnNil)
+	p.appendPostHook(returnNil)
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	// The nil values were replaced by the values given to the hooks
+	assert.Equal(t, 2, value)
+}
+
+func TestValidateStrictNilValuePolicy(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("set", addOne, WithNilValuePolicy(NilAllow)),
+		NewPhase("unset", addOne),
+	))
+
+	require.NoError(t, m.Validate())
+	err := m.Validate(Strict())
+	assert.ErrorIs(t, err, ErrNoNilValuePolicy)
+	assert.Contains(t, err.Error(), "phase unset")
+	assert.NotContains(t, err.Error(), "phase set")
+}
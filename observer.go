@@ -0,0 +1,19 @@
+package phaser
+
+// Observer is notified of the progress of runs. Observers are called
+// synchronously, so they should return quickly.
+type Observer interface {
+	// OnPhaseStart is called before a phase runs with its input
+	OnPhaseStart(phase string, value interface{})
+	// OnPhaseEnd is called with the outcome of every phase, including the
+	// skipped ones which are not started
+	OnPhaseEnd(result PhaseResult)
+}
+
+// WithObserver adds observer to the observers notified of the progress of the
+// manager's runs.
+func WithObserver(observer Observer) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.observers = append(m.observers, observer)
+	}
+}
@@ -0,0 +1,51 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingObserver records the events it is notified of.
+type recordingObserver struct {
+	started []string
+	results []PhaseResult
+}
+
+func (o *recordingObserver) OnPhaseStart(phase string, value interface{}) {
+	o.started = append(o.started, phase)
+}
+
+func (o *recordingObserver) OnPhaseEnd(result PhaseResult) {
+	o.results = append(o.results, result)
+}
+
+// statuses returns the status of each phase result, keyed by phase.
+func (o *recordingObserver) statuses() map[string]PhaseStatus {
+	statuses := make(map[string]PhaseStatus, len(o.results))
+	for _, result := range o.results {
+		statuses[result.Phase] = result.Status
+	}
+	return statuses
+}
+
+func TestObserverNotified(t *testing.T) {
+	o := &recordingObserver{}
+	m := NewPhaseManager(WithObserver(o))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", addOne),
+		NewPhase("three", failWith(assert.AnError)),
+	))
+	m.phases[1].Disabled = true
+
+	_, err := m.Run(0)
+	require.Error(t, err)
+	assert.Equal(t, []string{"one", "three"}, o.started)
+	assert.Equal(t, map[string]PhaseStatus{
+		"one":   StatusSucceeded,
+		"two":   StatusSkipped,
+		"three": StatusFailed,
+	}, o.statuses())
+}
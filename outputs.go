@@ -0,0 +1,136 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMissingOutput is returned when a phase depends on an output that was not
+// produced during the run.
+var ErrMissingOutput = errors.New("missing output")
+
+// Outputs is returned by phases producing several named outputs. Successors
+// added using AddPhaseWithDeps may consume any of them through
+// DependsOnOutput, while other successors receive the whole Outputs value.
+type Outputs map[string]interface{}
+
+// Dependency is an input of a phase added using AddPhaseWithDeps.
+type Dependency struct {
+	// Phase is the name of the phase producing the input
+	Phase string
+	// Output is the name of the output of Phase used as the input. The
+	// whole output of Phase is used when empty
+	Output string
+}
+
+// DependsOnPhase returns a dependency on the output of the phase named phase.
+func DependsOnPhase(phase string) Dependency {
+	return Dependency{Phase: phase}
+}
+
+// DependsOnOutput returns a dependency on the output named output of the
+// phase named phase, which must return Outputs.
+func DependsOnOutput(phase, output string) Dependency {
+	return Dependency{Phase: phase, Output: output}
+}
+
+// key returns the key of the dependency in the Outputs received by phases
+// with several dependencies.
+func (d Dependency) key() string {
+	if d.Output == "" {
+		return d.Phase
+	}
+	return d.Phase + "." + d.Output
+}
+
+// AddPhaseWithDeps registers phase, which receives its input from deps instead
+// of from the phase preceding it. With a single dependency, the phase receives
+// its value. With several, it receives Outputs holding the value of each
+// dependency keyed by the name of its phase, followed by a dot and the name of
+// the output for output dependencies. Dependencies must be registered before
+// the phase, and are added to its DependsOn.
+//
+// The outputs consumed by dependencies are only available within a run, so
+// runs resumed after them fail with ErrMissingOutput.
+func (m *DefaultPhaseManager) AddPhaseWithDeps(phase *Phase, deps ...Dependency) error {
+	for _, dep := range deps {
+		if m.phase(dep.Phase) == nil {
+			return fmt.Errorf("%w: %s, dependency of %s", ErrPhaseNotFound, dep.Phase, phase.Name)
+		}
+	}
+	if err := m.AddPhase(phase); err != nil {
+		return err
+	}
+	phase.inputs = append(phase.inputs, deps...)
+	for _, dep := range deps {
+		if !containsString(phase.DependsOn, dep.Phase) {
+			phase.DependsOn = append(phase.DependsOn, dep.Phase)
+		}
+	}
+	return nil
+}
+
+// usesOutputs reports whether any phase of m or of its branches has
+// dependencies.
+func (m *DefaultPhaseManager) usesOutputs() bool {
+	for _, p := range m.phases {
+		if len(p.inputs) > 0 {
+			return true
+		}
+		for _, branch := range p.branches {
+			if branch.usesOutputs() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// input returns the input of the phase from value, the output of the
+// preceding phase, and the outputs of the run.
+func (p *Phase) input(value interface{}, outputs map[string]interface{}) (interface{}, error) {
+	if len(p.inputs) == 0 {
+		return value, nil
+	}
+	values := make(Outputs, len(p.inputs))
+	for _, dep := range p.inputs {
+		value, ok := outputs[dep.Phase]
+		if !ok {
+			return nil, fmt.Errorf("%w: phase %s produced no output", ErrMissingOutput, dep.Phase)
+		}
+		if dep.Output != "" {
+			named, isOutputs := value.(Outputs)
+			if value, ok = named[dep.Output]; !isOutputs || !ok {
+				return nil, fmt.Errorf("%w: phase %s did not produce output %q", ErrMissingOutput, dep.Phase, dep.Output)
+			}
+		}
+		if len(p.inputs) == 1 {
+			return value, nil
+		}
+		values[dep.key()] = value
+	}
+	return values, nil
+}
+
+// outputLabel returns the names of the outputs of the phase named phase used
+// by p, joined by commas.
+func (p *Phase) outputLabel(phase string) string {
+	var names []string
+	for _, dep := range p.inputs {
+		if dep.Phase == phase && dep.Output != "" {
+			names = append(names, dep.Output)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// containsString reports whether values contains value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
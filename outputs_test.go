@@ -0,0 +1,105 @@
+package phaser
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// splitter returns a phase splitting a []int into its non-negative and
+// negative numbers.
+func splitter() *Phase {
+	return NewPhase("split", func(value interface{}) (interface{}, error) {
+		var valid, rejects []int
+		for _, n := range value.([]int) {
+			if n < 0 {
+				rejects = append(rejects, n)
+			} else {
+				valid = append(valid, n)
+			}
+		}
+		return Outputs{"valid": valid, "rejects": rejects}, nil
+	})
+}
+
+// count returns a phase returning the length of a []int.
+func count(name string) *Phase {
+	return NewPhase(name, func(value interface{}) (interface{}, error) {
+		return len(value.([]int)), nil
+	})
+}
+
+// outputsManager returns a pipeline splitting its input into two chains that
+// are merged by its last phase.
+func outputsManager(t *testing.T) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(splitter()))
+	require.NoError(t, m.AddPhaseWithDeps(NewPhase("double", func(value interface{}) (interface{}, error) {
+		doubled := make([]int, 0, len(value.([]int)))
+		for _, n := range value.([]int) {
+			doubled = append(doubled, n*2)
+		}
+		return doubled, nil
+	}), DependsOnOutput("split", "valid")))
+	require.NoError(t, m.AddPhaseWithDeps(count("count-rejects"), DependsOnOutput("split", "rejects")))
+	require.NoError(t, m.AddPhaseWithDeps(count("count-valid"), DependsOnPhase("double")))
+	require.NoError(t, m.AddPhaseWithDeps(NewPhase("merge", func(value interface{}) (interface{}, error) {
+		outputs := value.(Outputs)
+		var keys []string
+		for key := range outputs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return fmt.Sprintf("%v: %d valid, %d rejected", keys, outputs["count-valid"], outputs["count-rejects"]), nil
+	}), DependsOnPhase("count-valid"), DependsOnPhase("count-rejects")))
+	return m
+}
+
+func TestOutputs(t *testing.T) {
+	value, err := outputsManager(t).Run([]int{1, -2, 3, -4, -5})
+	require.NoError(t, err)
+	assert.Equal(t, "[count-rejects count-valid]: 2 valid, 3 rejected", value)
+}
+
+func TestOutputsPostHooks(t *testing.T) {
+	m := NewPhaseManager()
+	split := splitter()
+	split.appendPostHook(func(value interface{}) (interface{}, error) {
+		outputs := value.(Outputs)
+		outputs["all"] = append(outputs["valid"].([]int), outputs["rejects"].([]int)...)
+		return outputs, nil
+	})
+	require.NoError(t, m.AddPhase(split))
+	require.NoError(t, m.AddPhaseWithDeps(count("count"), DependsOnOutput("split", "all")))
+
+	value, err := m.Run([]int{1, -2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+func TestOutputsMissingOutput(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(splitter()))
+	require.NoError(t, m.AddPhaseWithDeps(count("count"), DependsOnOutput("split", "unknown")))
+
+	_, err := m.Run([]int{1})
+	assert.ErrorIs(t, err, ErrMissingOutput)
+	assert.ErrorContains(t, err, `phase split did not produce output "unknown"`)
+}
+
+func TestOutputsUnknownDependency(t *testing.T) {
+	m := NewPhaseManager()
+	err := m.AddPhaseWithDeps(count("count"), DependsOnOutput("split", "valid"))
+	assert.ErrorIs(t, err, ErrPhaseNotFound)
+	assert.Empty(t, m.phases)
+}
+
+func TestOutputsDOT(t *testing.T) {
+	dot := outputsManager(t).ToDOT()
+	assert.Contains(t, dot, `"split" -> "double" [label="valid"];`)
+	assert.Contains(t, dot, `"split" -> "count-rejects" [label="rejects"];`)
+	assert.Contains(t, dot, `"double" -> "count-valid";`)
+}
@@ -0,0 +1,159 @@
+package phaser
+
+import (
+	"fmt"
+	"time"
+)
+
+// PhaseConfig is the configuration of a phase that can be overridden for a
+// single run using WithPhaseOverride.
+type PhaseConfig struct {
+	// Timeout limits the duration of each call to the execute function when
+	// positive, as the Timeout of phases
+	Timeout time.Duration
+	// Retry is the retry policy of the phase
+	Retry RetryPolicy
+	// Skip skips the phase, as if it was disabled
+	Skip bool
+	// NonCritical lets the run continue when the phase fails
+	NonCritical bool
+	// BypassRateLimit runs the phase without waiting for its RateLimit
+	BypassRateLimit bool
+	// Execute replaces the execute function of the phase when set
+	Execute func(value interface{}) (interface{}, error)
+}
+
+// PhaseOverride changes the configuration of a phase for a single run.
+type PhaseOverride func(c *PhaseConfig)
+
+// OverrideTimeout sets the Timeout of the phase. Like for WithTimeout, an
+// explicit zero timeout sets it to NoTimeout.
+func OverrideTimeout(timeout time.Duration) PhaseOverride {
+	return func(c *PhaseConfig) {
+		c.Timeout = explicitTimeout(timeout)
+	}
+}
+
+// OverrideRetry sets the Retry policy of the phase.
+func OverrideRetry(policy RetryPolicy) PhaseOverride {
+	return func(c *PhaseConfig) {
+		c.Retry = policy
+	}
+}
+
+// OverrideSkip sets whether the phase is skipped.
+func OverrideSkip(skip bool) PhaseOverride {
+	return func(c *PhaseConfig) {
+		c.Skip = skip
+	}
+}
+
+// OverrideNonCritical sets whether the phase is non-critical.
+func OverrideNonCritical(nonCritical bool) PhaseOverride {
+	return func(c *PhaseConfig) {
+		c.NonCritical = nonCritical
+	}
+}
+
+// OverrideRateLimitBypass sets whether the phase ignores its RateLimit.
+func OverrideRateLimitBypass(bypass bool) PhaseOverride {
+	return func(c *PhaseConfig) {
+		c.BypassRateLimit = bypass
+	}
+}
+
+// OverrideExecute replaces the execute function of the phase, keeping its
+// hooks, error handlers and other settings, for example to stub a phase in a
+// test.
+func OverrideExecute(execute func(value interface{}) (interface{}, error)) PhaseOverride {
+	return func(c *PhaseConfig) {
+		c.Execute = execute
+	}
+}
+
+// WithPhaseOverride overrides the configuration of the phase named phase, which
+// may belong to a branch, for the run only. The phase itself is left
+// unchanged, so concurrent runs may override it differently. Runs overriding
+// unknown phases fail before running any phase. The effective configuration
+// of overridden phases is recorded in the run's report.
+func WithPhaseOverride(phase string, overrides ...PhaseOverride) RunOption {
+	return func(c *runConfig) {
+		if c.overrides == nil {
+			c.overrides = make(map[string][]PhaseOverride)
+		}
+		c.overrides[phase] = append(c.overrides[phase], overrides...)
+	}
+}
+
+// checkOverrides checks that every phase overridden by c exists.
+func (m *DefaultPhaseManager) checkOverrides(c *runConfig) error {
+	for name := range c.overrides {
+		if !m.hasPhase(name) {
+			return fmt.Errorf("%w: overriding %s", ErrPhaseNotFound, name)
+		}
+	}
+	return nil
+}
+
+// hasPhase reports whether m or any of its branches has a phase named name.
+func (m *DefaultPhaseManager) hasPhase(name string) bool {
+	for _, p := range m.phases {
+		if p.Name == name {
+			return true
+		}
+		for _, branch := range p.branches {
+			if branch.hasPhase(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// config returns the configuration of the phase.
+func (p *Phase) config() PhaseConfig {
+	c := PhaseConfig{Timeout: p.Timeout, Skip: p.Disabled, NonCritical: p.NonCritical}
+	if p.Retry != nil {
+		c.Retry = *p.Retry
+	}
+	return c
+}
+
+// overridden returns a copy of p with the run's overrides applied, along with
+// its effective configuration. p itself is returned when it is not
+// overridden, with a nil configuration.
+func (s *runState) overridden(p *Phase) (*Phase, *PhaseConfig) {
+	overrides, ok := s.overrides[p.Name]
+	if !ok {
+		return p, nil
+	}
+	c := p.config()
+	for _, override := range overrides {
+		override(&c)
+	}
+
+	effective := *p
+	effective.Timeout = c.Timeout
+	retry := c.Retry
+	effective.Retry = &retry
+	effective.Disabled = c.Skip
+	effective.NonCritical = c.NonCritical
+	if c.BypassRateLimit {
+		effective.RateLimit = nil
+	}
+	if c.Execute != nil {
+		effective.execute, effective.executeContext = c.Execute, nil
+	}
+	return &effective, &c
+}
+
+// RunWithOverrides runs the pipeline on value like Run, replacing the execute
+// functions of the phases named in overrides for this run only, as with
+// OverrideExecute. Phases not in overrides run normally, and the manager is
+// left unchanged, so later and concurrent runs are not affected.
+func (m *DefaultPhaseManager) RunWithOverrides(value interface{}, overrides map[string]func(interface{}) (interface{}, error), opts ...RunOption) (interface{}, error) {
+	for name, execute := range overrides {
+		opts = append(opts, WithPhaseOverride(name, OverrideExecute(execute)))
+	}
+	return m.Run(value, opts...)
+}
@@ -0,0 +1,160 @@
+package phaser
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhaseOverride(t *testing.T) {
+	calls := 0
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("skipped", addOne),
+		flakyPhase("flaky", 2, &calls),
+		NewPhase("broken", failWith(assert.AnError)),
+	))
+
+	var report RunReport
+	value, err := m.Run(0, WithReport(&report),
+		WithPhaseOverride("skipped", OverrideSkip(true)),
+		WithPhaseOverride("flaky", OverrideRetry(RetryPolicy{MaxAttempts: 3})),
+		WithPhaseOverride("broken", OverrideNonCritical(true)),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 3, calls)
+
+	require.Len(t, report.Phases, 3)
+	assert.Equal(t, StatusSkipped, report.Phases[0].Status)
+	assert.Equal(t, &PhaseConfig{Skip: true}, report.Phases[0].Config)
+	assert.Equal(t, &PhaseConfig{Retry: RetryPolicy{MaxAttempts: 3}}, report.Phases[1].Config)
+	assert.Equal(t, StatusFailed, report.Phases[2].Status)
+	assert.Equal(t, &PhaseConfig{NonCritical: true}, report.Phases[2].Config)
+
+	// The phases are left unchanged
+	assert.False(t, m.phases[0].Disabled)
+	assert.Nil(t, m.phases[1].Retry)
+	assert.False(t, m.phases[2].NonCritical)
+	_, err = m.Run(0)
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestPhaseOverrideTimeoutAndRateLimit(t *testing.T) {
+	p := NewPhase("slow", func(value interface{}) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return value, nil
+	}, WithTimeout(time.Millisecond))
+	p.RateLimit = &RateLimit{PerSecond: 0.1, Burst: 1}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	// The first run takes the only token and times out
+	_, err := m.Run(0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var report RunReport
+	value, err := m.Run(1, WithReport(&report), WithPhaseOverride("slow",
+		OverrideTimeout(time.Second), OverrideRateLimitBypass(true)))
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, &PhaseConfig{Timeout: time.Second, BypassRateLimit: true}, report.Phases[0].Config)
+}
+
+func TestPhaseOverrideUnknownPhase(t *testing.T) {
+	calls := 0
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(flakyPhase("one", 0, &calls)))
+
+	_, err := m.Run(0, WithPhaseOverride("missing", OverrideSkip(true)))
+	assert.ErrorIs(t, err, ErrPhaseNotFound)
+	assert.Equal(t, 0, calls)
+}
+
+func TestPhaseOverrideInBranch(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddBranch("classify", classify, map[string]*DefaultPhaseManager{
+		"i": branchManager(t, "-resize", "-thumbnail"),
+	}))
+
+	value, err := m.Run("i", WithPhaseOverride("-resize", OverrideSkip(true)))
+	require.NoError(t, err)
+	assert.Equal(t, "i-thumbnail", value)
+}
+
+func TestPhaseOverrideConcurrentRuns(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", addOne),
+	))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			skipped := []string{"one", "two"}[i%2]
+			var report RunReport
+			value, err := m.Run(0, WithReport(&report), WithPhaseOverride(skipped, OverrideSkip(true)))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, value)
+			result, ok := report.Result(skipped)
+			assert.True(t, ok)
+			assert.Equal(t, StatusSkipped, result.Status)
+		}(i)
+	}
+	wg.Wait()
+	assert.False(t, m.phases[0].Disabled)
+	assert.False(t, m.phases[1].Disabled)
+}
+
+func TestRunWithOverrides(t *testing.T) {
+	var posted []interface{}
+	two := NewPhase("two", addOne)
+	two.appendPostHook(func(value interface{}) (interface{}, error) {
+		posted = append(posted, value)
+		return value, nil
+	})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(NewPhase("one", addOne), two))
+
+	value, err := m.RunWithOverrides(0, map[string]func(interface{}) (interface{}, error){
+		"two": func(value interface{}) (interface{}, error) { return value.(int) * 10, nil },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 10, value)
+
+	value, err = m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	// The hooks of overridden phases still run
+	assert.Equal(t, []interface{}{10, 2}, posted)
+
+	_, err = m.RunWithOverrides(0, map[string]func(interface{}) (interface{}, error){
+		"missing": addOne,
+	})
+	assert.ErrorIs(t, err, ErrPhaseNotFound)
+}
+
+func TestPhaseOverrideKeepsRetryScope(t *testing.T) {
+	calls, pre := 0, 0
+	p := flakyPhase("flaky", 1, &calls, WithRetry(RetryPolicy{MaxAttempts: 2, Scope: HooksAndExecute}))
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		pre++
+		return value, nil
+	})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	value, err := m.Run(0, WithPhaseOverride("flaky", OverrideTimeout(time.Second)))
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 2, calls)
+	// Both attempts ran the pre-hooks
+	assert.Equal(t, 2, pre)
+}
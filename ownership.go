@@ -0,0 +1,221 @@
+package phaser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnauthorizedMutation is wrapped by the errors of phases changing parts
+// of the value they did not declare using WithMutates.
+var ErrUnauthorizedMutation = errors.New("unauthorized mutation")
+
+// UnauthorizedMutationError is returned, or reported as a warning, when a
+// phase of a manager created using WithOwnershipEnforcement changes parts of
+// the value it did not declare using WithMutates.
+type UnauthorizedMutationError struct {
+	// Phase is the name of the offending phase
+	Phase string
+	// Paths contains the sorted paths of the changed parts of the value
+	// that were not declared
+	Paths []string
+}
+
+func (e *UnauthorizedMutationError) Error() string {
+	return fmt.Sprintf("%v: phase %s changed %s", ErrUnauthorizedMutation, displayName(e.Phase), strings.Join(e.Paths, ", "))
+}
+
+func (e *UnauthorizedMutationError) Unwrap() error {
+	return ErrUnauthorizedMutation
+}
+
+// WithMutates declares the paths of the parts of the value the phase may
+// change, checked in runs of managers created using
+// WithOwnershipEnforcement. Paths name the fields and keys of the value's
+// JSON form separated by dots, and the elements of arrays by their index in
+// brackets, such as "Order.Items[0].Quantity". A path covers the parts within
+// it, so that "Order.Items" covers every item, and the path "$" covers the
+// whole value.
+func WithMutates(paths ...string) PhaseOption {
+	return func(p *Phase) {
+		p.mutates = append(p.mutates, paths...)
+	}
+}
+
+// WithOwnershipEnforcement fails the phases changing parts of the value they
+// did not declare using WithMutates with an *UnauthorizedMutationError, such
+// as phases changing the fields of a shared struct owned by other phases.
+//
+// The input of each phase is encoded using the phase's codec, or JSONCodec
+// when none is set, before the phase runs, and compared with its output once
+// it succeeded. Both are compared in their canonical form, the values decoded
+// by the codec in their JSON form: parts the codec does not encode, such as
+// unexported fields with JSONCodec, are not checked, and values with the same
+// JSON form are equal. Encoding every value is expensive, so that enforcement
+// is meant for tests and staging, while managers without it do not encode
+// anything.
+func WithOwnershipEnforcement() ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.ownership = &ownershipChecker{}
+	}
+}
+
+// WithOwnershipWarnOnly is like WithOwnershipEnforcement, but reports the
+// *UnauthorizedMutationError as a warning of the phase, in the run's report
+// and for RunWithWarnings, and lets the phase succeed.
+func WithOwnershipWarnOnly() ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.ownership = &ownershipChecker{warnOnly: true}
+	}
+}
+
+// ownershipChecker checks the changes phases make to the value. A nil
+// *ownershipChecker checks nothing.
+type ownershipChecker struct {
+	warnOnly bool
+}
+
+// snapshot returns the canonical form of value, the input of a phase whose
+// values are encoded using codec.
+func (c *ownershipChecker) snapshot(codec Codec, value interface{}) (interface{}, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return canonicalForm(codec, value)
+}
+
+// check returns an *UnauthorizedMutationError when output, the output of p,
+// changes parts of before, the snapshot of its input, that p did not declare.
+// In WarnOnly mode, the error is added to the warnings of result instead.
+func (c *ownershipChecker) check(state *runState, result *PhaseResult, p *Phase, codec Codec, before, output interface{}) error {
+	if c == nil {
+		return nil
+	}
+	after, err := canonicalForm(codec, output)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for _, path := range changedPaths("", before, after, nil) {
+		if !p.declaresMutation(path) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	sort.Strings(paths)
+	violation := &UnauthorizedMutationError{Phase: p.Name, Paths: paths}
+	if !c.warnOnly {
+		return violation
+	}
+	result.Warnings = append(result.Warnings, violation)
+	state.warnings.add(Warning{Phase: p.Name, Message: violation.Error(), Err: violation})
+	return nil
+}
+
+// declaresMutation reports whether a path declared by WithMutates covers
+// path.
+func (p *Phase) declaresMutation(path string) bool {
+	for _, declared := range p.mutates {
+		if declared == "$" || declared == path {
+			return true
+		}
+		if strings.HasPrefix(path, declared) && (path[len(declared)] == '.' || path[len(declared)] == '[') {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalForm returns value encoded and decoded using codec, or JSONCodec
+// when nil, in its JSON form made of maps, slices and scalars.
+func canonicalForm(codec Codec, value interface{}) (interface{}, error) {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encoding value for ownership checks: %w", err)
+	}
+	var decoded interface{}
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("decoding value for ownership checks: %w", err)
+	}
+	if _, ok := codec.(JSONCodec); ok {
+		return decoded, nil
+	}
+
+	// Other codecs may decode typed values
+	if data, err = json.Marshal(decoded); err != nil {
+		return nil, fmt.Errorf("encoding value for ownership checks: %w", err)
+	}
+	decoded = nil
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("decoding value for ownership checks: %w", err)
+	}
+	return decoded, nil
+}
+
+// changedPaths appends to paths the paths of the parts of a and b, the
+// canonical forms of values at path, that differ.
+func changedPaths(path string, a, b interface{}, paths []string) []string {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for key := range a {
+			keys = append(keys, key)
+		}
+		for key := range b {
+			if _, ok := a[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			valueA, okA := a[key]
+			valueB, okB := b[key]
+			if okA != okB {
+				paths = append(paths, keyPath)
+			} else {
+				paths = changedPaths(keyPath, valueA, valueB, paths)
+			}
+		}
+		return paths
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(a) || i < len(b); i++ {
+			indexPath := path + "[" + strconv.Itoa(i) + "]"
+			if i >= len(a) || i >= len(b) {
+				paths = append(paths, indexPath)
+			} else {
+				paths = changedPaths(indexPath, a[i], b[i], paths)
+			}
+		}
+		return paths
+	default:
+		if a == b {
+			return paths
+		}
+	}
+
+	if path == "" {
+		path = "$"
+	}
+	return append(paths, path)
+}
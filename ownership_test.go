@@ -0,0 +1,148 @@
+package phaser
+
+import (
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ownedItem struct {
+	SKU      string
+	Quantity int
+}
+
+type ownedOrder struct {
+	Status  string
+	History []string
+	Items   []ownedItem
+}
+
+type ownedState struct {
+	Order ownedOrder
+	Notes map[string]string
+}
+
+// mutate returns an execute function changing the shared state in place
+// using change.
+func mutate(change func(s *ownedState)) func(value interface{}) (interface{}, error) {
+	return func(value interface{}) (interface{}, error) {
+		change(value.(*ownedState))
+		return value, nil
+	}
+}
+
+func newOwnedState() *ownedState {
+	return &ownedState{
+		Order: ownedOrder{Status: "new", Items: []ownedItem{{SKU: "a", Quantity: 1}, {SKU: "b", Quantity: 2}}},
+		Notes: map[string]string{},
+	}
+}
+
+func TestOwnershipEnforcement(t *testing.T) {
+	m := NewPhaseManager(WithOwnershipEnforcement())
+	require.NoError(t, m.AddPhases(
+		NewPhase("ship", mutate(func(s *ownedState) {
+			s.Order.Status = "shipped"
+			s.Order.History = append(s.Order.History, "shipped")
+		}), WithMutates("Order.Status", "Order.History")),
+		NewPhase("restock", mutate(func(s *ownedState) {
+			s.Order.Items[1].Quantity = 0
+		}), WithMutates("Order.Items[1].Quantity")),
+		NewPhase("annotate", mutate(func(s *ownedState) {
+			s.Notes["ship"] = "fragile"
+		}), WithMutates("Notes")),
+		NewPhase("read", passValue),
+	))
+
+	value, err := m.Run(newOwnedState())
+	require.NoError(t, err)
+	state := value.(*ownedState)
+	assert.Equal(t, "shipped", state.Order.Status)
+	assert.Equal(t, 0, state.Order.Items[1].Quantity)
+	assert.Equal(t, "fragile", state.Notes["ship"])
+}
+
+func TestOwnershipEnforcementFails(t *testing.T) {
+	m := NewPhaseManager(WithOwnershipEnforcement())
+	require.NoError(t, m.AddPhases(
+		NewPhase("ship", mutate(func(s *ownedState) {
+			s.Order.Status = "shipped"
+			s.Order.Items[0].Quantity = 5
+			s.Order.Items = append(s.Order.Items, ownedItem{SKU: "c"})
+		}), WithMutates("Order.Status")),
+		NewPhase("next", passValue),
+	))
+
+	var report RunReport
+	_, err := m.Run(newOwnedState(), WithReport(&report))
+	assert.ErrorIs(t, err, ErrUnauthorizedMutation)
+	var mutationErr *UnauthorizedMutationError
+	require.ErrorAs(t, err, &mutationErr)
+	assert.Equal(t, "ship", mutationErr.Phase)
+	assert.Equal(t, []string{"Order.Items[0].Quantity", "Order.Items[2]"}, mutationErr.Paths)
+	require.Len(t, report.Phases, 1)
+	assert.Equal(t, StatusFailed, report.Phases[0].Status)
+}
+
+func TestOwnershipUndeclaredPhase(t *testing.T) {
+	m := NewPhaseManager(WithOwnershipEnforcement())
+	require.NoError(t, m.AddPhases(
+		NewPhase("sneaky", mutate(func(s *ownedState) {
+			s.Notes["sneaky"] = "was here"
+		})),
+		NewPhase("replace", func(value interface{}) (interface{}, error) {
+			return "replaced", nil
+		}),
+	))
+
+	_, err := m.Run(newOwnedState())
+	var mutationErr *UnauthorizedMutationError
+	require.ErrorAs(t, err, &mutationErr)
+	assert.Equal(t, "sneaky", mutationErr.Phase)
+	assert.Equal(t, []string{"Notes.sneaky"}, mutationErr.Paths)
+	assert.EqualError(t, mutationErr, "unauthorized mutation: phase sneaky changed Notes.sneaky")
+
+	// Replacing the whole value changes the root path
+	m.phases[0].mutates = []string{"Notes"}
+	_, err = m.Run(newOwnedState())
+	require.ErrorAs(t, err, &mutationErr)
+	assert.Equal(t, []string{"$"}, mutationErr.Paths)
+}
+
+func TestOwnershipWarnOnly(t *testing.T) {
+	gob.Register(&ownedState{})
+	m := NewPhaseManager(WithOwnershipWarnOnly(), WithCodec(GobCodec{}))
+	require.NoError(t, m.AddPhases(
+		NewPhase("sneaky", mutate(func(s *ownedState) {
+			s.Order.Status = "cancelled"
+		})),
+		NewPhase("next", passValue),
+	))
+
+	var report RunReport
+	value, warnings, err := m.RunWithWarnings(newOwnedState(), WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", value.(*ownedState).Order.Status)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "sneaky", warnings[0].Phase)
+	assert.ErrorIs(t, warnings[0].Err, ErrUnauthorizedMutation)
+	require.Len(t, report.Phases, 2)
+	require.Len(t, report.Phases[0].Warnings, 1)
+	var mutationErr *UnauthorizedMutationError
+	require.ErrorAs(t, report.Phases[0].Warnings[0], &mutationErr)
+	assert.Equal(t, []string{"Order.Status"}, mutationErr.Paths)
+	assert.Empty(t, report.Phases[1].Warnings)
+}
+
+func TestOwnershipDisabledByDefault(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("sneaky", mutate(func(s *ownedState) {
+		s.Order.Status = "cancelled"
+	}))))
+
+	value, err := m.Run(newOwnedState())
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", value.(*ownedState).Order.Status)
+}
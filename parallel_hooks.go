@@ -0,0 +1,114 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// ErrHookChangedValue is returned when a hook running in parallel returns a
+// value different from its input.
+var ErrHookChangedValue = errors.New("parallel hook changed the value")
+
+// WithParallelHookErrorHandler sets a function handling the failures of the
+// phase's ParallelHooks as a whole, before they fail the phase. It receives
+// the errors of the failed hooks of a stage by hook name, or index for
+// unnamed hooks and hooks sharing their name with another hook, including the siblings cancelled by the first failure. It
+// recovers the stage by returning a nil error along with the value to go on
+// with, or fails it with the error it returns.
+func WithParallelHookErrorHandler(handler func(hookErrors map[string]error) (interface{}, error)) PhaseOption {
+	return func(p *Phase) {
+		p.parallelErrorHandler = handler
+	}
+}
+
+// processHooksParallel calls every hook in hooks concurrently on value as
+// part of the run whose state is stored in ctx. The errors of the hooks are joined in
+// hook order. Failures take priority over ErrStopPipeline and rejections,
+// which are only returned, the first one in hook order, when no hook failed.
+// The first failure cancels the context of the other hooks, with a
+// *SiblingFailureError cause.
+func (p *Phase) processHooksParallel(ctx context.Context, value interface{}, hooks *[]PhaseHook) (interface{}, error) {
+	stage := p.hookStage(hooks)
+	errs := make([]error, len(*hooks))
+	// warnings contains the warnings returned by each hook, recorded once
+	// they all returned
+	warnings := make([]error, len(*hooks))
+
+	// Values are cloned before starting the hooks, which could mutate them
+	inputs := make([]interface{}, len(*hooks))
+	for i := range *hooks {
+		input, err := p.isolate(stage, i, value)
+		if err != nil {
+			return value, err
+		}
+		inputs[i] = input
+	}
+
+	hookCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var wg sync.WaitGroup
+	for i := range *hooks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			output, err := p.callHook(hookCtx, hooks, i, inputs[i])
+			if _, ok := err.(*WarningsError); ok {
+				warnings[i], err = err, nil
+			}
+			if err == nil && !reflect.DeepEqual(output, value) {
+				err = fmt.Errorf("%w: %s %d of phase %s", ErrHookChangedValue, stage, i, p.Name)
+			}
+			if err != nil && !endsPhase(err) && hookCtx.Err() == nil {
+				cancel(&SiblingFailureError{Sibling: p.hookLabel(hooks, i), Err: err})
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for _, warning := range warnings {
+		_ = p.takeWarnings(ctx, warning)
+	}
+	if ctx.Err() == nil {
+		for i, err := range errs {
+			errs[i] = withCancelCause(hookCtx, err)
+		}
+	}
+
+	var failures []error
+	var ended error
+	// failed contains the failures by hook key, the hooks sharing a name
+	// being keyed by index so that none is lost
+	failed := make(map[string]error)
+	names := make(map[string]int)
+	for i := range errs {
+		names[p.hookName(hooks, i)]++
+	}
+	for i, err := range errs {
+		switch {
+		case err == nil:
+		case endsPhase(err):
+			if ended == nil {
+				ended = err
+			}
+		default:
+			failures = append(failures, err)
+			key := p.hookKey(hooks, i)
+			if names[key] > 1 {
+				key = strconv.Itoa(i)
+			}
+			failed[key] = err
+		}
+	}
+	if len(failures) > 0 && p.parallelErrorHandler != nil {
+		runStateFrom(ctx).trace.printf(p.Name, "handling the failures of %d parallel %ss", len(failures), stage)
+		return p.parallelErrorHandler(failed)
+	}
+	if len(failures) > 0 {
+		return value, errors.Join(failures...)
+	}
+	return value, ended
+}
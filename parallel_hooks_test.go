@@ -0,0 +1,245 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelHooksRunConcurrently(t *testing.T) {
+	// Each hook waits for every other hook to start, which only completes
+	// when they run concurrently
+	const hooks = 3
+	var started sync.WaitGroup
+	started.Add(hooks)
+	validate := func(value interface{}) (interface{}, error) {
+		started.Done()
+		started.Wait()
+		return value, nil
+	}
+
+	p := NewPhase("one", addOne)
+	p.ParallelHooks = true
+	for i := 0; i < hooks; i++ {
+		p.appendPreHook(validate)
+	}
+
+	done := make(chan struct{})
+	var value interface{}
+	var err error
+	go func() {
+		defer close(done)
+		value, err = p.run(1)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("hooks did not run concurrently")
+	}
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+func TestParallelHooksErrors(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	p := NewPhase("one", addOne)
+	p.ParallelHooks = true
+	p.appendPostHook(failWith(errA))
+	p.appendPostHook(func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+	p.appendPostHook(failWith(errB))
+
+	_, err := p.run(1)
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errB)
+	assert.EqualError(t, err, "a\nb")
+}
+
+func TestParallelHooksRejectValueChanges(t *testing.T) {
+	calls := 0
+	p := countingPhase("one", &calls, nil)
+	p.ParallelHooks = true
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	})
+
+	_, err := p.run(1)
+	assert.ErrorIs(t, err, ErrHookChangedValue)
+	assert.EqualError(t, err, "parallel hook changed the value: pre-hook 1 of phase one")
+	assert.Zero(t, calls)
+}
+
+func TestParallelHooksFailuresOverrideControlErrors(t *testing.T) {
+	for _, control := range []error{ErrStopPipeline, Reject("invalid", 0)} {
+		p := NewPhase("one", addOne)
+		p.ParallelHooks = true
+		p.appendPreHook(func(value interface{}) (interface{}, error) {
+			return value, control
+		})
+		p.appendPreHook(failWith(assert.AnError))
+
+		m := NewPhaseManager()
+		require.NoError(t, m.AddPhase(p))
+		_, err := m.Run(5)
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.NotErrorIs(t, err, control)
+	}
+}
+
+func TestParallelHooksSingleHookRejectsValueChanges(t *testing.T) {
+	p := NewPhase("one", addOne)
+	p.ParallelHooks = true
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		return value.(int) * 10, nil
+	})
+
+	_, err := p.run(1)
+	assert.ErrorIs(t, err, ErrHookChangedValue)
+}
+
+// document is a value mutated by parallel hooks.
+type document struct {
+	Tags map[string]bool
+}
+
+// tagHook returns a hook tagging documents with tag. The tags are added under
+// mu, so that unisolated hooks corrupt the shared document without a data
+// race.
+func tagHook(mu *sync.Mutex, tag string) PhaseHook {
+	return func(value interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		value.(*document).Tags[tag] = true
+		return value, nil
+	}
+}
+
+// taggingPhase returns a phase tagging documents with a and b in parallel
+// pre-hooks.
+func taggingPhase(opts ...PhaseOption) *Phase {
+	var mu sync.Mutex
+	p := NewPhase("tag", func(value interface{}) (interface{}, error) {
+		return value, nil
+	}, opts...)
+	p.ParallelHooks = true
+	p.appendPreHook(tagHook(&mu, "a"))
+	p.appendPreHook(tagHook(&mu, "b"))
+	return p
+}
+
+func TestParallelHooksSharedValue(t *testing.T) {
+	// Both hooks mutate the same document unnoticed
+	input := &document{Tags: map[string]bool{}}
+	_, err := taggingPhase().run(input)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, input.Tags)
+}
+
+func TestParallelHooksValueIsolation(t *testing.T) {
+	input := &document{Tags: map[string]bool{"draft": true}}
+	_, err := taggingPhase(WithValueIsolation(nil)).run(input)
+	assert.ErrorIs(t, err, ErrHookChangedValue)
+	assert.Equal(t, map[string]bool{"draft": true}, input.Tags)
+
+	// Type-specific cloners replace the JSON round trip
+	clones := 0
+	_, err = taggingPhase(WithValueIsolation(func(value interface{}) (interface{}, error) {
+		clones++
+		return &document{Tags: map[string]bool{}}, nil
+	})).run(input)
+	assert.ErrorIs(t, err, ErrHookChangedValue)
+	assert.Equal(t, 2, clones)
+}
+func TestParallelHooksValueIsolationFailure(t *testing.T) {
+	errClone := errors.New("not cloneable")
+	p := NewPhase("one", addOne, WithValueIsolation(func(value interface{}) (interface{}, error) {
+		return nil, errClone
+	}))
+	p.ParallelHooks = true
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+
+	_, err := p.run(1)
+	assert.ErrorIs(t, err, errClone)
+	assert.ErrorContains(t, err, "pre-hook 0 of phase one")
+}
+
+func TestCloneJSON(t *testing.T) {
+	type item struct {
+		Tags []string
+	}
+	original := &item{Tags: []string{"a"}}
+	clone, err := CloneJSON(original)
+	require.NoError(t, err)
+	assert.Equal(t, original, clone)
+	clone.(*item).Tags[0] = "b"
+	assert.Equal(t, "a", original.Tags[0])
+
+	_, err = CloneJSON(func() {})
+	assert.Error(t, err)
+}
+
+func TestParallelHookErrorHandler(t *testing.T) {
+	var handled map[string]error
+	p := NewPhase("one", addOne, WithParallelHookErrorHandler(func(hookErrors map[string]error) (interface{}, error) {
+		handled = hookErrors
+		return 10, nil
+	}))
+	p.ParallelHooks = true
+	p.AppendNamedPreHook("a", func(value interface{}) (interface{}, error) { return value, nil })
+	p.AppendNamedPreHook("b", failWith(errNotFound))
+	p.AppendNamedPreHook("c", func(value interface{}) (interface{}, error) { return value, nil })
+
+	value, err := p.run(1)
+	require.NoError(t, err)
+	// The execute function ran on the recovered value
+	assert.Equal(t, 11, value)
+	require.Len(t, handled, 1)
+	assert.ErrorIs(t, handled["b"], errNotFound)
+}
+
+func TestParallelHookErrorHandlerSharedNames(t *testing.T) {
+	var handled map[string]error
+	p := NewPhase("one", addOne, WithParallelHookErrorHandler(func(hookErrors map[string]error) (interface{}, error) {
+		handled = hookErrors
+		return 10, nil
+	}))
+	p.ParallelHooks = true
+	p.AppendNamedPreHook("check", failWith(errNotFound))
+	p.AppendNamedPreHook("other", failWith(assert.AnError))
+	p.AppendNamedPreHook("check", failWith(errNegative))
+
+	_, err := p.run(1)
+	require.NoError(t, err)
+	// The hooks sharing a name are keyed by index
+	require.Len(t, handled, 3)
+	assert.ErrorIs(t, handled["0"], errNotFound)
+	assert.ErrorIs(t, handled["other"], assert.AnError)
+	assert.ErrorIs(t, handled["2"], errNegative)
+}
+
+func TestParallelHookErrorHandlerPropagates(t *testing.T) {
+	calls := 0
+	p := countingPhase("one", &calls, nil)
+	WithParallelHookErrorHandler(func(hookErrors map[string]error) (interface{}, error) {
+		return nil, fmt.Errorf("%d hooks failed: %w", len(hookErrors), hookErrors["0"])
+	})(p)
+	p.ParallelHooks = true
+	p.appendPostHook(failWith(errNotFound))
+
+	_, err := p.run(1)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.EqualError(t, err, "1 hooks failed: not found")
+	assert.Equal(t, 1, calls)
+}
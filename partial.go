@@ -0,0 +1,115 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// PartialResultError is returned when a run or a phase is interrupted by the
+// cancellation or timeout of its context. It carries the last good value so
+// that partial work is not lost.
+//
+// LastValue may be mid-transformation: it is the output of the last completed
+// phase, or the input of the interrupted execute function, and might not
+// satisfy the invariants of the pipeline's final output. It should not be
+// persisted or used as a complete result without checking it first.
+type PartialResultError struct {
+	// LastValue is the value after the last completed step. For runs, it is
+	// the output of CompletedPhase. For phases, it is the value returned by
+	// the pre-hooks
+	LastValue interface{}
+	// CompletedPhase is the name of the last phase that completed during the
+	// run. It is empty when no phase completed, and for phase level errors
+	CompletedPhase string
+	// Err is the error caused by the interruption
+	Err error
+}
+
+func (e *PartialResultError) Error() string {
+	if e.CompletedPhase == "" {
+		return fmt.Sprintf("interrupted: %v", e.Err)
+	}
+	return fmt.Sprintf("interrupted after phase %s: %v", e.CompletedPhase, e.Err)
+}
+
+func (e *PartialResultError) Unwrap() error {
+	return e.Err
+}
+
+// isInterruption reports whether err was caused by a context cancellation or
+// timeout. Work cancelled by the failure of a sibling failed rather than
+// being interrupted.
+func isInterruption(err error) bool {
+	return (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) && !errors.Is(err, ErrFailFastSibling)
+}
+
+// executeWithin runs the phase's execute function on value, returning early
+// with a *PartialResultError when ctx is done first. Execute functions that do
+// not take a context cannot be stopped, so they keep running in the
+// background until they return. release is called once the execute function
+// returns, so that the concurrency limits it holds stay held by abandoned
+// executions.
+//
+// Phases running nested phases run them synchronously, the nested phases
+// being abandoned instead, so that no abandoned execution keeps reporting to
+// the run.
+func (p *Phase) executeWithin(ctx context.Context, value interface{}, release func()) (interface{}, error) {
+	if ctx.Done() == nil {
+		defer release()
+		return p.executeValue(ctx, value)
+	}
+	if err := ctx.Err(); err != nil {
+		release()
+		return nil, &PartialResultError{LastValue: value, Err: err}
+	}
+	if p.nested {
+		defer release()
+		return p.executeValue(ctx, value)
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+		// panicked holds the value the execute function panicked with
+		panicked interface{}
+	}
+	done := make(chan result, 1)
+	// abandoned is set when the run stops waiting for the execution
+	var abandoned int32
+	finished := runStateFrom(ctx).lifecycle.background(p.Name)
+	go func() {
+		defer release()
+		var r result
+		defer func() {
+			if r.panicked = recover(); r.panicked != nil {
+				r.err = fmt.Errorf("panic: %v", r.panicked)
+			}
+			done <- r
+			finished(atomic.LoadInt32(&abandoned) == 1, r.err)
+		}()
+		r.value, r.err = p.executeValue(ctx, value)
+	}()
+
+	select {
+	case r := <-done:
+		if r.panicked != nil {
+			// Panics reach the caller as if the execute function ran in
+			// its goroutine
+			panic(r.panicked)
+		}
+		return r.value, r.err
+	case <-ctx.Done():
+		atomic.StoreInt32(&abandoned, 1)
+		return nil, &PartialResultError{LastValue: value, Err: ctx.Err()}
+	}
+}
+
+// executeValue calls the phase's execute function on value.
+func (p *Phase) executeValue(ctx context.Context, value interface{}) (interface{}, error) {
+	if p.executeContext != nil {
+		return p.executeContext(ctx, value)
+	}
+	return p.execute(value)
+}
@@ -0,0 +1,276 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunContextCancelledReturnsPartialResult(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls [4]int
+
+	// The second phase completes and cancels the run right after
+	two := NewPhase("two", func(value interface{}) (interface{}, error) {
+		calls[1]++
+		return value.(int) * 10, nil
+	})
+	two.appendPostHook(func(value interface{}) (interface{}, error) {
+		cancel()
+		return value, nil
+	})
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		countingPhase("one", &calls[0], nil),
+		two,
+		countingPhase("three", &calls[2], nil),
+		countingPhase("four", &calls[3], nil),
+	))
+
+	value, err := m.RunContext(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var partial *PartialResultError
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, 20, partial.LastValue)
+	assert.Equal(t, "two", partial.CompletedPhase)
+	assert.Equal(t, 20, value)
+	assert.Equal(t, [4]int{1, 1, 0, 0}, calls)
+}
+
+func TestPhaseTimeoutReturnsPartialResult(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	p := NewPhase("slow", func(value interface{}) (interface{}, error) {
+		<-release
+		return value, nil
+	}, WithTimeout(10*time.Millisecond))
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		return value.(int) + 1, nil
+	})
+
+	_, err := p.run(1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var partial *PartialResultError
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, 2, partial.LastValue)
+	assert.Empty(t, partial.CompletedPhase)
+}
+
+func TestManagerPhaseTimeoutReturnsPartialResult(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("slow", func(value interface{}) (interface{}, error) {
+			<-release
+			return value, nil
+		}, WithTimeout(10*time.Millisecond)),
+		NewPhase("three", addOne),
+	))
+
+	_, err := m.Run(1)
+	var partial *PartialResultError
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, 2, partial.LastValue)
+	assert.Equal(t, "one", partial.CompletedPhase)
+
+	var phaseErr *PhaseError
+	require.True(t, errors.As(err, &phaseErr))
+	assert.Equal(t, "slow", phaseErr.Phase)
+}
+
+func TestRunContextSuccessUnaffected(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", addOne, WithTimeout(time.Second)),
+	))
+
+	value, err := m.RunContext(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+// pipelineHandler returns a handler running m on the context of each request,
+// sending the run's error to errs.
+func pipelineHandler(m *DefaultPhaseManager, errs chan<- error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := m.RunContext(r.Context(), 0)
+		errs <- err
+	})
+}
+
+func TestRunContextClientCancelledRequest(t *testing.T) {
+	started, exited := make(chan struct{}), make(chan struct{})
+	calls := 0
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhaseContext("slow", func(ctx context.Context, value interface{}) (interface{}, error) {
+			defer close(exited)
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}, WithTimeout(time.Minute)),
+		countingPhase("next", &calls, nil),
+	))
+	errs := make(chan error, 1)
+	server := httptest.NewServer(pipelineHandler(m, errs))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	go func() {
+		if resp, err := server.Client().Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run did not stop when the request was cancelled")
+	}
+	<-exited
+	assert.Zero(t, calls)
+}
+
+func TestRunContextRequestDeadline(t *testing.T) {
+	release := make(chan struct{})
+	m := NewPhaseManager()
+	// The phase ignores its context and outlives the request's deadline,
+	// despite allowing itself more time
+	require.NoError(t, m.AddPhase(NewPhase("stuck", func(value interface{}) (interface{}, error) {
+		<-release
+		return value, nil
+	}, WithTimeout(time.Minute))))
+	errs := make(chan error, 1)
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	pipelineHandler(m, errs).ServeHTTP(httptest.NewRecorder(), req)
+	assert.ErrorIs(t, <-errs, context.DeadlineExceeded)
+
+	// The abandoned execution exits once the phase returns. The goroutines
+	// are counted here, as assert.Eventually starts goroutines of its own
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestPhaseTimeoutScopedToPhase(t *testing.T) {
+	for name, opt := range map[string]PhaseOption{
+		"non-critical": WithNonCritical(),
+		"default on error": func(p *Phase) {
+			p.DefaultOnError = func(err error) (interface{}, bool) {
+				return -1, errors.Is(err, context.DeadlineExceeded)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			exited := make(chan struct{})
+			var nextErr error
+			m := NewPhaseManager()
+			require.NoError(t, m.AddPhases(
+				NewPhaseContext("bounded", func(ctx context.Context, value interface{}) (interface{}, error) {
+					defer close(exited)
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}, WithTimeout(10*time.Millisecond), opt),
+				NewPhaseContext("next", func(ctx context.Context, value interface{}) (interface{}, error) {
+					nextErr = ctx.Err()
+					return value, nil
+				}, WithTimeout(time.Minute)),
+			))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			_, err := m.RunContext(ctx, 0)
+			require.NoError(t, err)
+			assert.NoError(t, nextErr)
+			assert.NoError(t, ctx.Err())
+			<-exited
+		})
+	}
+}
+
+func TestPhaseTimeoutDoesNotLeakGoroutines(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhaseContext("bounded", func(ctx context.Context, value interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}, WithTimeout(time.Millisecond), WithNonCritical()),
+		NewPhase("next", addOne),
+	))
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		_, err := m.Run(0)
+		require.NoError(t, err)
+	}
+	// Polled without assert.Eventually, which runs the condition in a
+	// goroutine of its own
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestBranchTimeoutStopsReporting(t *testing.T) {
+	slow := branchManager(t, "s")
+	require.NoError(t, slow.AddPhase(NewPhase("sleep", func(value interface{}) (interface{}, error) {
+		time.Sleep(30 * time.Millisecond)
+		return value, nil
+	})))
+	require.NoError(t, slow.AddPhase(appendPhase("after", "!")))
+	m := NewPhaseManager()
+	require.NoError(t, m.AddBranch("route", classify, map[string]*DefaultPhaseManager{"s": slow}))
+
+	var report RunReport
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := m.RunContext(ctx, "s", WithReport(&report))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The abandoned phase of the branch ends without reporting to the run
+	phases := len(report.Phases)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, report.Phases, phases)
+	_, ok := report.Result("after")
+	assert.False(t, ok)
+}
+
+func TestPhaseTimeoutPropagatesPanics(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("panics", func(value interface{}) (interface{}, error) {
+		panic("boom")
+	}, WithTimeout(time.Second))))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = m.Run(0)
+	})
+}
@@ -1,10 +1,59 @@
 package phaser
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
 
 // PhaseHook is the hook type used by Phaser implementations.
 type PhaseHook func(value interface{}) (interface{}, error)
 
+// SideEffectHook returns a PhaseHook calling fn with the value, which is
+// passed through unchanged. It suits hooks such as logging hooks.
+func SideEffectHook(fn func(value interface{})) PhaseHook {
+	return func(value interface{}) (interface{}, error) {
+		fn(value)
+		return value, nil
+	}
+}
+
+// SideEffectHookErr is like SideEffectHook for functions that may fail. The
+// error returned by fn is returned by the hook.
+func SideEffectHookErr(fn func(value interface{}) error) PhaseHook {
+	return func(value interface{}) (interface{}, error) {
+		return value, fn(value)
+	}
+}
+
+// ContextHook is a hook receiving the context of the run, which can be used
+// to report warnings through Warn.
+type ContextHook func(ctx context.Context, value interface{}) (interface{}, error)
+
+// phaseHook returns a PhaseHook calling h with a background context, used
+// when h is called outside of a run.
+func (h ContextHook) phaseHook() PhaseHook {
+	return func(value interface{}) (interface{}, error) {
+		return h(context.Background(), value)
+	}
+}
+
+// Stage identifies a step of a phase's run.
+type Stage string
+
+const (
+	// StageTransform is the stage running the phase's Transform
+	StageTransform Stage = "transform"
+	// StagePreHook is the stage running the phase's pre-hooks
+	StagePreHook Stage = "pre-hook"
+	// StageExecute is the stage running the phase's execute function
+	StageExecute Stage = "execute"
+	// StagePostHook is the stage running the phase's post-hooks
+	StagePostHook Stage = "post-hook"
+)
+
 // Phaser is an interface for phases. You should rarely need to implement Phaser
 // from scratch. Instead, include the Phase struct in your own struct and
 // override the necessary methods.
@@ -33,33 +82,303 @@ type Phase struct {
 	// Name contains the name of the phase. This value should be unique as it
 	// will be the phase identifier
 	Name string
+	// DependsOn contains the names of the phases this phase depends on. It
+	// documents the pipeline's structure and is used by ToDOT
+	DependsOn []string
+	// Disabled phases are skipped by runs, passing their input on to the next
+	// phase
+	Disabled bool
+	// FeatureFlag gates the phase behind a flag of the manager's
+	// FlagProvider when set: runs skip the phase unless the flag is enabled
+	FeatureFlag string
+	// NonCritical phases are best-effort: they do not abort the run when
+	// they fail. Their failure still reaches their error handlers, is
+	// recorded in the run's report and their input is passed on to the next
+	// phase
+	NonCritical bool
+	// ParallelHooks runs the phase's pre-hooks concurrently, and then its
+	// post-hooks. Parallel hooks may not change the value, so it is meant
+	// for validation hooks. Every hook receives the same value, so hooks
+	// mutating values holding pointers, maps or slices race against each
+	// other unless the phase uses WithValueIsolation
+	ParallelHooks bool
+	// DedupeHooks makes named hooks replace the hook previously added with
+	// the same name, keeping its position, instead of being added again
+	DedupeHooks bool
+	// AllowNilValues lets the phase's hooks return nil values when the
+	// manager runs in StrictMode
+	AllowNilValues bool
+	// RateLimit throttles the phase's executions when set. Runs wait for the
+	// limit before invoking execute
+	RateLimit *RateLimit
+	// Quota rejects the phase's executions past its limit when set, failing
+	// them with ErrQuotaExceeded instead of waiting
+	Quota *Quota
+	// Timeout limits the duration of each call to the phase's execute
+	// function when positive. Phases timing out return a *PartialResultError
+	// holding the value returned by their pre-hooks. The deadline only
+	// applies to the context of the call, so the run goes on with a live
+	// context when the phase is non-critical or its error is handled.
+	// Phases without a Timeout inherit the manager's DefaultPhaseTimeout,
+	// unless it is NoTimeout
+	Timeout time.Duration
+	// Retry retries the phase's execute function when it fails if set
+	Retry *RetryPolicy
+	// Transform converts the phase's input when set, such as to unmarshal
+	// it. It runs first, before the input is validated and the pre-hooks
+	// run, and its failures fail the phase like hook failures
+	Transform func(value interface{}) (interface{}, error)
+	// DefaultOnError turns the expected failures of the phase into a
+	// default output when set. It is called with the errors no error
+	// handler handled, and when it returns true the phase succeeds with the
+	// returned value, which the next phase receives. Other errors fail the
+	// phase as usual
+	DefaultOnError func(err error) (interface{}, bool)
+	// ErrorTransform maps the errors of the phase's stages when set, such as
+	// to enrich them or strip internal details. It runs before the error
+	// handlers, DefaultOnError and handleError, which see the returned
+	// error, and the run returns it. Stops and rejections are not
+	// transformed, and transforms returning nil leave the error unchanged
+	ErrorTransform func(err error) error
+	// InputType and OutputType declare the types of the values the phase
+	// receives and returns when set, such as by TypedPhase.AsPhase. When the
+	// OutputType of a phase differs from the InputType of the next phase,
+	// runs convert the value using the converter registered for the two
+	// types by RegisterConverter, failing the next phase when there is none
+	InputType, OutputType reflect.Type
+	// Weight is the relative cost of the phase used to compute the progress
+	// of runs. Non-positive weights count as one
+	Weight float64
+	// Version identifies the implementation of the phase. It should be changed
+	// whenever the phase's input or output format changes, so that state
+	// persisted by a previous version is not resumed by accident
+	Version string
 	// preHooks contains the hooks ran before the execution phase. Used to
 	// validate/preprocess phase input data
 	preHooks []PhaseHook
 	// execute performs the phase's action.
 	execute func (value interface{}) (interface{}, error)
+	// executeContext performs the phase's action for phases that need the
+	// run's context. It takes precedence over execute
+	executeContext func(ctx context.Context, value interface{}) (interface{}, error)
+	// errorHandlers contains the handlers ran, in order, when the phase fails
+	errorHandlers []ErrorHandler
+	// branches contains the sub-pipelines of a branch point, keyed by the
+	// values returned by its selector
+	branches map[string]*DefaultPhaseManager
 	// postHooks contains the hooks ran after the execution phase. Used to
 	// validate/postprocess phase output data
 	postHooks []PhaseHook
+	// preHookInfos and postHookInfos describe the hooks at the same
+	// positions of preHooks and postHooks. Missing trailing descriptions are
+	// empty
+	preHookInfos  []hookInfo
+	postHookInfos []hookInfo
+	// trace writes the debug trace of the phase's runs when set
+	trace *debugTrace
+	// sem limits the concurrent executions of the phase when set
+	sem semaphore
+	// flight coalesces concurrent executions with the same key when set
+	flight *singleFlight
+	// skipWhen skips the phase in runs whose context satisfies it when set
+	skipWhen func(ctx context.Context) bool
+	// codec serializes the values of the phase when set, overriding the
+	// manager's
+	codec Codec
+	// nilPolicy is how the phase handles nil values
+	nilPolicy NilValuePolicy
+	// valueType is the type the values of the phase are decoded into when
+	// set
+	valueType reflect.Type
+	// inputs contains the dependencies providing the phase's input when set
+	inputs []Dependency
+	// inputSchema and outputSchema check the phase's input and output when
+	// set
+	inputSchema  Validator
+	outputSchema Validator
+	// mutates contains the paths of the parts of the value the phase may
+	// change, declared by WithMutates
+	mutates []string
+	// cloner copies the value given to each parallel hook when set
+	cloner func(value interface{}) (interface{}, error)
+	// parallelErrorHandler handles the failures of the parallel hooks when
+	// set
+	parallelErrorHandler func(hookErrors map[string]error) (interface{}, error)
+	// guard rejects changes to the phase while its manager runs when set
+	guard *mutationGuard
+	// resumeSteps makes stepped phases retry from their failed step
+	resumeSteps bool
+	// nested is set on phases running nested phases, such as branch points
+	nested bool
+	// permanentFactoryError keeps the factory errors of lazy phases
+	permanentFactoryError bool
+	// onShutdown is called when a manager running the phase shuts down
+	onShutdown func()
+}
+
+// PhaseOption configures a Phase.
+type PhaseOption func(p *Phase)
+
+// NewPhase returns a phase named name that performs execute, configured with
+// opts.
+func NewPhase(name string, execute func(value interface{}) (interface{}, error), opts ...PhaseOption) *Phase {
+	p := &Phase{Name: name, execute: execute}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// NewPhaseContext is like NewPhase for an execute function receiving the
+// run's context, which can be used to report warnings through Warn or to stop
+// early when the run is cancelled.
+func NewPhaseContext(name string, execute func(ctx context.Context, value interface{}) (interface{}, error), opts ...PhaseOption) *Phase {
+	p := &Phase{Name: name, executeContext: execute}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithNonCritical marks the phase as NonCritical.
+func WithNonCritical() PhaseOption {
+	return func(p *Phase) {
+		p.NonCritical = true
+	}
+}
+
+// NoTimeout is the Timeout of phases that must not time out, regardless of
+// the manager's DefaultPhaseTimeout.
+const NoTimeout time.Duration = -1
+
+// WithTimeout sets the phase's Timeout. An explicit zero timeout sets it to
+// NoTimeout, overriding the manager's DefaultPhaseTimeout.
+func WithTimeout(timeout time.Duration) PhaseOption {
+	return func(p *Phase) {
+		p.Timeout = explicitTimeout(timeout)
+	}
+}
+
+// explicitTimeout returns the Timeout of a phase explicitly set to timeout.
+func explicitTimeout(timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return NoTimeout
+	}
+	return timeout
+}
+
+// timeout returns the timeout of each call to the phase's execute function
+// in the run whose state is stored in ctx, zero meaning no timeout.
+func (p *Phase) timeout(ctx context.Context) time.Duration {
+	switch {
+	case p.Timeout > 0:
+		return p.Timeout
+	case p.Timeout < 0:
+		return 0
+	}
+	return runStateFrom(ctx).defaultTimeout
+}
+
+// WithWeight sets the phase's Weight.
+func WithWeight(weight float64) PhaseOption {
+	return func(p *Phase) {
+		p.Weight = weight
+	}
+}
+
+// WithVersion sets the phase's Version.
+func WithVersion(version string) PhaseOption {
+	return func(p *Phase) {
+		p.Version = version
+	}
 }
 
 func (p *Phase) run(value interface{}) (interface{}, error) {
+	return p.runContext(context.Background(), value)
+}
+
+// runContext runs the phase as part of the run whose state is stored in ctx.
+func (p *Phase) runContext(ctx context.Context, value interface{}) (output interface{}, err error) {
+	state := runStateFrom(ctx)
+	if p.trace != nil && state.trace == nil {
+		traced := *state
+		traced.trace = p.trace.start(ClockFrom(ctx))
+		state = &traced
+		ctx = withRunState(ctx, state)
+	}
+	span := state.startSpan(p.Name)
+	defer func() { span.End(err) }()
+	if !state.trace.traces(p.Name) {
+		return p.runStages(ctx, value)
+	}
+
+	tr := state.trace
+	started := tr.started(p.Name, "phase", value)
+	output, err = p.runStages(ctx, value)
+	tr.finished(p.Name, "phase", started, output, err)
+	return output, err
+}
+
+// runStages runs the pre-hooks, execute function and post-hooks of the phase.
+func (p *Phase) runStages(ctx context.Context, value interface{}) (interface{}, error) {
 	var err error
 
-	// Process pre-hooks
-	if value, err = p.processHooks(value, &p.preHooks); err != nil {
+	if l := runStateFrom(ctx).limit; l != nil && l.hooks {
+		release, err := p.acquireLimits(ctx, true)
+		if err != nil {
+			return nil, &PartialResultError{LastValue: value, Err: err}
+		}
+		defer release()
+	}
+
+	if p.Transform != nil {
+		input := value
+		if value, err = p.Transform(value); err != nil {
+			return p.handleErrorChain(StageTransform, input, err)
+		}
+	}
+	if err := p.checkSchema(StageInputValidation, p.inputSchema, ErrInvalidInput, value); err != nil {
+		return p.handleErrorChain(StageInputValidation, value, err)
+	}
+	if p.execute == nil && p.executeContext == nil {
+		if runStateFrom(ctx).panicUnimplemented {
+			panic(fmt.Sprintf("phase %s not implemented", p.Name))
+		}
+		return p.handleErrorChain(StageExecute, value, p.notImplemented())
+	}
+	var input interface{}
+	if p.retriesHooks() {
+		// Process pre-hooks and execute phase, retrying both
+		var stage Stage
+		value, input, stage, err = p.hookAttempts(ctx, value)
+		if stage == StagePreHook && err != nil {
+			return p.handleErrorChain(StagePreHook, value, err)
+		}
+	} else {
+		// Process pre-hooks
+		if value, err = p.processHooksContext(ctx, value, &p.preHooks); err != nil {
+			return p.handleErrorChain(StagePreHook, value, err)
+		}
+		// Execute phase
+		input = value
+		value, err = p.executeShared(ctx, value)
+	}
+	if errors.Is(err, ErrStopPipeline) {
 		return value, err
 	}
-	// Execute phase
-	if p.execute == nil {
-		panic(fmt.Sprintf("phase %s not implemented", p.Name))
+	if err == nil {
+		value, err = p.checkNil(ctx, StageExecute, -1, input, value)
 	}
-	if value, err = p.execute(value); err != nil {
-		return p.handleError(err)
+	if err != nil {
+		return p.handleErrorChain(StageExecute, input, err)
 	}
+	runStateFrom(ctx).captured.record(p, CaptureExecute, StageExecute, "", value)
 	// Process post-hooks
-	if value, err = p.processHooks(value, &p.postHooks); err != nil {
-		return value, err
+	if value, err = p.processHooksContext(ctx, value, &p.postHooks); err != nil {
+		return p.handleErrorChain(StagePostHook, value, err)
+	}
+	if err := p.checkSchema(StageOutputValidation, p.outputSchema, ErrInvalidOutput, value); err != nil {
+		return p.handleErrorChain(StageOutputValidation, value, err)
 	}
 
 	return value, nil
@@ -76,17 +395,89 @@ func (p *Phase) handleError(err error) (interface{}, error) {
 func (p *Phase) processHooks(value interface{}, hooks *[]PhaseHook) (interface{}, error) {
 	var err error
 
-	for _, hook := range *hooks {
-		if value, err = hook(value); err != nil {
-			return p.handleError(err)
+	if value, err = p.processHooksContext(context.Background(), value, hooks); err != nil {
+		return p.handleErrorChain(p.hookStage(hooks), value, err)
+	}
+
+	return value, nil
+}
+
+// processHooksContext processes the hooks as part of the run whose state is
+// stored in ctx. Errors are returned along with the input of the failing hook
+// and are left for the caller to handle, except for ErrStopPipeline which is
+// returned along with the hook's output.
+func (p *Phase) processHooksContext(ctx context.Context, value interface{}, hooks *[]PhaseHook) (interface{}, error) {
+	var err error
+	state := runStateFrom(ctx)
+	stage := p.hookStage(hooks)
+
+	if p.ParallelHooks {
+		return p.processHooksParallel(ctx, value, hooks)
+	}
+
+	for i := range *hooks {
+		input := value
+		value, err = p.callHook(ctx, hooks, i, value)
+		if err = p.takeWarnings(ctx, err); err != nil {
+			if errors.Is(err, ErrStopPipeline) {
+				return value, err
+			}
+			return input, err
+		}
+		if value, err = p.checkNil(ctx, stage, i, input, value); err != nil {
+			return input, err
+		}
+		if state.strict {
+			if err = p.checkHookValue(stage, i, input, value); err != nil {
+				return input, err
+			}
 		}
+		if state.mutations != nil {
+			state.mutations.record(phaseResultFrom(ctx), stage, p.hookKey(hooks, i), input, value)
+		}
+		state.captured.record(p, CaptureHook, stage, p.hookKey(hooks, i), value)
 	}
 
 	return value, nil
 }
 
+// callHook calls the hook at index i of hooks on value as part of the run
+// whose state is stored in ctx.
+func (p *Phase) callHook(ctx context.Context, hooks *[]PhaseHook, i int, value interface{}) (interface{}, error) {
+	state := runStateFrom(ctx)
+	stage := p.hookStage(hooks)
+	if state.stats != nil {
+		state.stats.add(HookKey{Phase: p.Name, Stage: stage, Hook: p.hookKey(hooks, i)})
+	}
+
+	hook := (*hooks)[i]
+	if contextHook := p.hookInfo(hooks, i).context; contextHook != nil {
+		hook = func(value interface{}) (interface{}, error) {
+			return contextHook(ctx, value)
+		}
+	}
+	if state.trace.traces(p.Name) {
+		return p.traceHook(state.trace, stage, i, func(value interface{}) (interface{}, error) {
+			output, err := hook(value)
+			return output, p.checkContract(state, hooks, i, output, err)
+		}, value)
+	}
+	output, err := hook(value)
+	return output, p.checkContract(state, hooks, i, output, err)
+}
+
+// hookStage returns the stage running hooks.
+func (p *Phase) hookStage(hooks *[]PhaseHook) Stage {
+	if hooks == &p.preHooks {
+		return StagePreHook
+	}
+	return StagePostHook
+}
 
 func (p *Phase) prependHook(hooks *[]PhaseHook, newHook PhaseHook) {
+	p.checkMutation()
+	infos := p.hookInfos(hooks)
+	*infos = append([]hookInfo{{}}, alignInfos(*infos, len(*hooks))...)
 	*hooks = append([]PhaseHook{newHook}, *hooks...)
 }
 
@@ -99,7 +490,7 @@ func (p *Phase) appendPreHook(hook PhaseHook) {
 }
 
 func (p *Phase) appendHook(hooks *[]PhaseHook, newHook PhaseHook) {
-	*hooks = append(*hooks, newHook)
+	p.appendHookInfo(hooks, hookInfo{}, newHook)
 }
 
 func (p *Phase) appendPostHook(hook PhaseHook) {
@@ -110,4 +501,83 @@ func (p *Phase) prependPostHook(hook PhaseHook) {
 	p.prependHook(&p.postHooks, hook)
 }
 
+// AppendNamedPreHook appends hook to the phase's pre-hooks under name. When
+// DedupeHooks is set and a pre-hook named name exists, it is replaced instead.
+func (p *Phase) AppendNamedPreHook(name string, hook PhaseHook) {
+	p.appendHookInfo(&p.preHooks, hookInfo{name: name}, hook)
+}
+
+// AppendNamedPostHook appends hook to the phase's post-hooks under name. When
+// DedupeHooks is set and a post-hook named name exists, it is replaced
+// instead.
+func (p *Phase) AppendNamedPostHook(name string, hook PhaseHook) {
+	p.appendHookInfo(&p.postHooks, hookInfo{name: name}, hook)
+}
+
+// AppendPreHookContext appends hook, which receives the run's context, to the
+// phase's pre-hooks.
+func (p *Phase) AppendPreHookContext(hook ContextHook) {
+	p.appendHookInfo(&p.preHooks, hookInfo{context: hook}, hook.phaseHook())
+}
+
+// AppendPostHookContext appends hook, which receives the run's context, to
+// the phase's post-hooks.
+func (p *Phase) AppendPostHookContext(hook ContextHook) {
+	p.appendHookInfo(&p.postHooks, hookInfo{context: hook}, hook.phaseHook())
+}
+
+// appendHookInfo appends newHook, described by info, to hooks, replacing the
+// hook with the same name when deduplicating hooks.
+func (p *Phase) appendHookInfo(hooks *[]PhaseHook, info hookInfo, newHook PhaseHook) {
+	p.checkMutation()
+	infos := p.hookInfos(hooks)
+	*infos = alignInfos(*infos, len(*hooks))
+	if info.name != "" && p.DedupeHooks {
+		for i, existing := range *infos {
+			if existing.name == info.name {
+				(*infos)[i] = info
+				(*hooks)[i] = newHook
+				return
+			}
+		}
+	}
+	*infos = append(*infos, info)
+	*hooks = append(*hooks, newHook)
+}
+
+// hookInfo describes a hook.
+type hookInfo struct {
+	// name is the name of the hook, empty for unnamed hooks
+	name string
+	// context is set for hooks receiving the run's context
+	context ContextHook
+}
 
+// hookInfos returns the descriptions of hooks.
+func (p *Phase) hookInfos(hooks *[]PhaseHook) *[]hookInfo {
+	if hooks == &p.preHooks {
+		return &p.preHookInfos
+	}
+	return &p.postHookInfos
+}
+
+// hookInfo returns the description of the hook at index i of hooks.
+func (p *Phase) hookInfo(hooks *[]PhaseHook, i int) hookInfo {
+	if infos := *p.hookInfos(hooks); i < len(infos) {
+		return infos[i]
+	}
+	return hookInfo{}
+}
+
+// hookName returns the name of the hook at index i of hooks.
+func (p *Phase) hookName(hooks *[]PhaseHook, i int) string {
+	return p.hookInfo(hooks, i).name
+}
+
+// alignInfos pads infos with empty descriptions up to n entries.
+func alignInfos(infos []hookInfo, n int) []hookInfo {
+	for len(infos) < n {
+		infos = append(infos, hookInfo{})
+	}
+	return infos
+}
@@ -13,11 +13,21 @@ func TestDefaultExecutePanics(t *testing.T) {
 	assert.Panics(t, func() { _, _ = p.execute(struct{}{}) })
 }
 
-func TestDefaultRunPanics(t *testing.T) {
+func TestDefaultRunNotImplemented(t *testing.T) {
 	p := Phase{}
 
-	// The default Phase should panic
-	assert.Panics(t, func() { _, _ = p.run(struct{}{}) })
+	// The default Phase should fail
+	_, err := p.run(struct{}{})
+	assert.ErrorIs(t, err, ErrPhaseNotImplemented)
+	assert.EqualError(t, err, "phase not implemented: <unnamed>")
+}
+
+func TestDefaultRunPanicsOnUnimplemented(t *testing.T) {
+	m := NewPhaseManager(WithPanicOnUnimplemented())
+	require.NoError(t, m.AddPhase(&Phase{Name: "plugin"}))
+
+	// The default Phase should panic when asked to
+	assert.PanicsWithValue(t, "phase plugin not implemented", func() { _, _ = m.Run(struct{}{}) })
 }
 
 func TestAddPreHooks(t *testing.T) {
@@ -174,3 +184,67 @@ func TestTestPhaseRun(t *testing.T) {
 	assert.Equal(t, value.(int), val)
 }
 
+
+func TestSideEffectHooks(t *testing.T) {
+	var seen []interface{}
+	p := NewPhase("one", addOne)
+	p.appendPreHook(SideEffectHook(func(value interface{}) {
+		seen = append(seen, value)
+	}))
+	p.appendPostHook(SideEffectHookErr(func(value interface{}) error {
+		seen = append(seen, value)
+		if value.(int) > 5 {
+			return assert.AnError
+		}
+		return nil
+	}))
+
+	value, err := p.run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, []interface{}{1, 2}, seen)
+
+	_, err = p.run(5)
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestTransformRunsFirst(t *testing.T) {
+	var order []string
+	p := NewPhase("parse", func(value interface{}) (interface{}, error) {
+		order = append(order, "execute")
+		return value.(int) + 1, nil
+	})
+	p.Transform = func(value interface{}) (interface{}, error) {
+		order = append(order, "transform")
+		return len(value.([]byte)), nil
+	}
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		order = append(order, "pre-hook")
+		return value, nil
+	})
+
+	value, err := p.run([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, value)
+	assert.Equal(t, []string{"transform", "pre-hook", "execute"}, order)
+}
+
+func TestTransformErrorHandled(t *testing.T) {
+	hooks := 0
+	p := NewPhase("parse", addOne)
+	p.Transform = failWith(assert.AnError)
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		hooks++
+		return value, nil
+	})
+	var contexts []ErrorContext
+	p.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		contexts = append(contexts, ec)
+		return false, nil, nil
+	})
+
+	_, err := p.run(1)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Zero(t, hooks)
+	assert.Equal(t, []ErrorContext{{Phase: "parse", Stage: StageTransform, Value: 1}}, contexts)
+}
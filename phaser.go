@@ -1,7 +1,15 @@
 package phaser
 
+// PhaseManager registers phases and the hooks attached to them. Phases are
+// identified by their names, which must be unique.
 type PhaseManager interface {
-	AddPhase(phaseName string, phase Phase)
-	AddPreHookToPhase(phaseName string, phase Phase, hook PhaseHook)
-	AddPostHookToPhase(phaseName string, phase Phase, hook PhaseHook)
+	// AddPhase registers phase, returning ErrDuplicatePhase if its name is
+	// already registered and ErrEmptyPhaseName if it has no name
+	AddPhase(phase *Phase) error
+	// AddPreHookToPhase appends hook to the pre-hooks of the phase named
+	// phaseName, returning ErrPhaseNotFound if there is no such phase
+	AddPreHookToPhase(phaseName string, hook PhaseHook) error
+	// AddPostHookToPhase appends hook to the post-hooks of the phase named
+	// phaseName, returning ErrPhaseNotFound if there is no such phase
+	AddPostHookToPhase(phaseName string, hook PhaseHook) error
 }
@@ -0,0 +1,53 @@
+package phasertest
+
+import (
+	"sync"
+	"time"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+)
+
+// FakeClock is a phaser.Clock whose time only changes when advanced, safe for
+// concurrent use. Waits advance it by their duration at once, so that retry
+// backoffs and rate limits run without sleeping while reports record the
+// time they would have taken.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ phaser.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep advances the clock by d.
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+// After advances the clock by d, and returns a channel holding the new time.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Advance(d)
+	return ch
+}
+
+// Advance advances the clock by d, returning the new time.
+func (c *FakeClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	return c.now
+}
@@ -0,0 +1,37 @@
+package phasertest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClockRetryBackoff(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	failures := 2
+	m := phaser.NewPhaseManager(phaser.WithClock(clock))
+	require.NoError(t, m.AddPhase(phaser.NewPhase("flaky", func(value interface{}) (interface{}, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("unavailable")
+		}
+		return value, nil
+	}, phaser.WithRetry(phaser.RetryPolicy{MaxAttempts: 3, Backoff: time.Hour}))))
+
+	began := time.Now()
+	var report phaser.RunReport
+	_, err := m.Run(1, phaser.WithReport(&report))
+	require.NoError(t, err)
+
+	assert.Less(t, time.Since(began), time.Minute)
+	assert.Equal(t, start, report.Start)
+	assert.Equal(t, 2*time.Hour, report.Duration)
+	result, _ := report.Result("flaky")
+	assert.Equal(t, 2, result.Retries)
+	assert.Equal(t, start.Add(2*time.Hour), clock.Now())
+}
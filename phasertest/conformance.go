@@ -0,0 +1,346 @@
+package phasertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+)
+
+// Runner is implemented by managers running their pipeline, such as
+// phaser.DefaultPhaseManager.
+type Runner interface {
+	RunContext(ctx context.Context, value interface{}, opts ...phaser.RunOption) (interface{}, error)
+}
+
+// Capabilities selects the optional contracts checked by
+// RunManagerConformance, so that partial implementations are checked for
+// what they claim to support.
+type Capabilities struct {
+	// Run checks the runs of the pipeline: phases run in order, piping their
+	// outputs, around their hooks, and fail with a *phaser.PhaseError. The
+	// manager must implement Runner
+	Run bool
+	// Skip checks that runs skip the disabled phases, and the phases skipped
+	// by the run's context, passing their input on
+	Skip bool
+	// NonCritical checks that the failures of non-critical phases do not end
+	// the run
+	NonCritical bool
+	// Cancellation checks that runs whose context is done stop before their
+	// next phase
+	Cancellation bool
+	// Reports checks the reports recorded by runs using phaser.WithReport
+	Reports bool
+}
+
+// RunManagerConformance checks that the managers returned by newManager
+// behave as phaser.PhaseManager implementations are expected to, reporting
+// each violated contract as a failure of the subtest named after it. Every
+// subtest uses a new manager. The contracts of caps are checked along with
+// the ones of the PhaseManager interface.
+func RunManagerConformance(t *testing.T, newManager func() phaser.PhaseManager, caps Capabilities) {
+	t.Run("registration", func(t *testing.T) {
+		t.Run("duplicate name", func(t *testing.T) {
+			m := newManager()
+			if err := m.AddPhase(passPhase("a")); err != nil {
+				t.Fatalf("adding phase a: %v", err)
+			}
+			if err := m.AddPhase(passPhase("a")); !errors.Is(err, phaser.ErrDuplicatePhase) {
+				t.Errorf("adding phase a twice returned %v, expected ErrDuplicatePhase", err)
+			}
+		})
+		t.Run("empty name", func(t *testing.T) {
+			if err := newManager().AddPhase(passPhase("")); !errors.Is(err, phaser.ErrEmptyPhaseName) {
+				t.Errorf("adding a phase without a name returned %v, expected ErrEmptyPhaseName", err)
+			}
+		})
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		t.Run("unknown phase", func(t *testing.T) {
+			m := newManager()
+			hook := func(value interface{}) (interface{}, error) { return value, nil }
+			if err := m.AddPreHookToPhase("missing", hook); !errors.Is(err, phaser.ErrPhaseNotFound) {
+				t.Errorf("adding a pre-hook to a missing phase returned %v, expected ErrPhaseNotFound", err)
+			}
+			if err := m.AddPostHookToPhase("missing", hook); !errors.Is(err, phaser.ErrPhaseNotFound) {
+				t.Errorf("adding a post-hook to a missing phase returned %v, expected ErrPhaseNotFound", err)
+			}
+		})
+		if !caps.Run {
+			return
+		}
+		t.Run("by name", func(t *testing.T) {
+			m := newManager()
+			mustAdd(t, m, appendPhase("a"), appendPhase("b"))
+			mustHook(t, m.AddPreHookToPhase("b", appendHook("pre1")))
+			mustHook(t, m.AddPreHookToPhase("b", appendHook("pre2")))
+			mustHook(t, m.AddPostHookToPhase("b", appendHook("post")))
+			expectOutput(t, m, []string{}, []string{"a", "pre1", "pre2", "b", "post"})
+		})
+	})
+
+	if caps.Run {
+		t.Run("run", func(t *testing.T) {
+			t.Run("order", func(t *testing.T) {
+				m := newManager()
+				mustAdd(t, m, appendPhase("c"), appendPhase("a"), appendPhase("b"))
+				expectOutput(t, m, []string{}, []string{"c", "a", "b"})
+			})
+			t.Run("error wrapping", func(t *testing.T) {
+				errBroken := errors.New("broken")
+				m := newManager()
+				ran := false
+				mustAdd(t, m, FailingPhase("fail", errBroken), phaser.NewPhase("after", func(value interface{}) (interface{}, error) {
+					ran = true
+					return value, nil
+				}))
+				_, err := run(t, m, context.Background(), 1)
+				var phaseErr *phaser.PhaseError
+				switch {
+				case !errors.As(err, &phaseErr):
+					t.Errorf("run returned %v, expected a *PhaseError", err)
+				case phaseErr.Phase != "fail":
+					t.Errorf("run failed in phase %q, expected fail", phaseErr.Phase)
+				case !errors.Is(err, errBroken):
+					t.Errorf("run returned %v, expected it to wrap the phase's error", err)
+				case phaseErr.Error() != "phase fail: broken":
+					t.Errorf("phase error reads %q, expected %q", phaseErr.Error(), "phase fail: broken")
+				}
+				if ran {
+					t.Error("the phase after the failed phase ran")
+				}
+			})
+			t.Run("stop pipeline", func(t *testing.T) {
+				m := newManager()
+				mustAdd(t, m, phaser.NewPhase("stop", func(value interface{}) (interface{}, error) {
+					return append(value.([]string), "stop"), phaser.ErrStopPipeline
+				}), appendPhase("after"))
+				expectOutput(t, m, []string{}, []string{"stop"})
+			})
+		})
+	}
+
+	if caps.Skip {
+		t.Run("skip", func(t *testing.T) {
+			t.Run("disabled", func(t *testing.T) {
+				m := newManager()
+				disabled := appendPhase("b")
+				disabled.Disabled = true
+				mustAdd(t, m, appendPhase("a"), disabled, appendPhase("c"))
+				expectOutput(t, m, []string{}, []string{"a", "c"})
+			})
+			t.Run("context", func(t *testing.T) {
+				type skipKey struct{}
+				m := newManager()
+				mustAdd(t, m, appendPhase("a"), appendPhase("b", phaser.SkipOnContextKey(skipKey{})))
+				output, err := run(t, m, context.WithValue(context.Background(), skipKey{}, true), []string{})
+				expectEqual(t, []string{"a"}, output, err)
+				output, err = run(t, m, context.Background(), []string{})
+				expectEqual(t, []string{"a", "b"}, output, err)
+			})
+		})
+	}
+
+	if caps.NonCritical {
+		t.Run("non-critical", func(t *testing.T) {
+			m := newManager()
+			failing := FailingPhase("fail", errors.New("broken"))
+			failing.NonCritical = true
+			mustAdd(t, m, appendPhase("a"), failing, appendPhase("b"))
+			expectOutput(t, m, []string{}, []string{"a", "b"})
+		})
+	}
+
+	if caps.Cancellation {
+		t.Run("cancellation", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			m := newManager()
+			mustAdd(t, m, phaser.NewPhase("cancel", func(value interface{}) (interface{}, error) {
+				cancel()
+				return value, nil
+			}), appendPhase("after"))
+			output, err := run(t, m, ctx, []string{})
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("cancelled run returned %v, expected context.Canceled", err)
+			}
+			if reflect.DeepEqual(output, []string{"after"}) {
+				t.Error("the phase after the cancellation ran")
+			}
+		})
+	}
+
+	if caps.Reports {
+		t.Run("reports", func(t *testing.T) {
+			m := newManager()
+			disabled := appendPhase("skipped")
+			disabled.Disabled = true
+			mustAdd(t, m, appendPhase("ok"), disabled, FailingPhase("fail", errors.New("broken")))
+
+			var report phaser.RunReport
+			_, err := run(t, m, context.Background(), []string{}, phaser.WithReport(&report))
+			if report.Err != err {
+				t.Errorf("report holds error %v, expected the run's error %v", report.Err, err)
+			}
+			var statuses []string
+			for _, result := range report.Phases {
+				statuses = append(statuses, fmt.Sprintf("%s:%s", result.Phase, result.Status))
+			}
+			expected := []string{"ok:succeeded", "skipped:skipped", "fail:failed"}
+			if !reflect.DeepEqual(statuses, expected) {
+				t.Errorf("report holds phase statuses %q, expected %q", statuses, expected)
+			}
+		})
+	}
+}
+
+// RunPhaserConformance checks that the phases returned by newPhase, such as
+// the phases of a custom phase type delegating to execute, behave as phases
+// created using phaser.NewPhase when run by a phaser.DefaultPhaseManager. The
+// phases must be named name, and run execute on their input. Each violated
+// contract is reported as a failure of the subtest named after it.
+func RunPhaserConformance(t *testing.T, newPhase func(name string, execute phaser.PhaseHook) *phaser.Phase) {
+	double := func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	}
+
+	t.Run("name", func(t *testing.T) {
+		if p := newPhase("double", double); p.Name != "double" {
+			t.Errorf("phase is named %q, expected double", p.Name)
+		}
+	})
+	t.Run("output", func(t *testing.T) {
+		m := phaser.NewPhaseManager()
+		mustAdd(t, m, newPhase("double", double))
+		output, err := m.Run(2)
+		expectEqual(t, 4, output, err)
+	})
+	t.Run("hooks", func(t *testing.T) {
+		p := newPhase("double", double)
+		p.AppendNamedPreHook("increment", func(value interface{}) (interface{}, error) {
+			return value.(int) + 1, nil
+		})
+		p.AppendNamedPostHook("increment", func(value interface{}) (interface{}, error) {
+			return value.(int) + 1, nil
+		})
+		m := phaser.NewPhaseManager()
+		mustAdd(t, m, p)
+		output, err := m.Run(2)
+		expectEqual(t, 7, output, err)
+	})
+	t.Run("error wrapping", func(t *testing.T) {
+		errBroken := errors.New("broken")
+		m := phaser.NewPhaseManager()
+		mustAdd(t, m, newPhase("fail", func(value interface{}) (interface{}, error) {
+			return nil, errBroken
+		}))
+		_, err := m.Run(1)
+		var phaseErr *phaser.PhaseError
+		if !errors.As(err, &phaseErr) || phaseErr.Phase != "fail" || !errors.Is(err, errBroken) {
+			t.Errorf("run returned %v, expected a *PhaseError of phase fail wrapping the phase's error", err)
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		called := false
+		p := newPhase("double", func(value interface{}) (interface{}, error) {
+			called = true
+			return double(value)
+		})
+		p.Disabled = true
+		m := phaser.NewPhaseManager()
+		mustAdd(t, m, p)
+		output, err := m.Run(2)
+		expectEqual(t, 2, output, err)
+		if called {
+			t.Error("disabled phase ran")
+		}
+	})
+	t.Run("concurrent runs", func(t *testing.T) {
+		m := phaser.NewPhaseManager()
+		mustAdd(t, m, newPhase("double", double))
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				output, err := m.Run(i)
+				if err != nil || output != i*2 {
+					t.Errorf("concurrent run on %d returned %v, %v, expected %d", i, output, err, i*2)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+// passPhase returns a phase named name passing its input through.
+func passPhase(name string) *phaser.Phase {
+	return phaser.NewPhase(name, func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+}
+
+// appendPhase returns a phase named name appending its name to its input,
+// a []string.
+func appendPhase(name string, opts ...phaser.PhaseOption) *phaser.Phase {
+	return phaser.NewPhase(name, appendHook(name), opts...)
+}
+
+// appendHook returns a hook appending name to its input, a []string.
+func appendHook(name string) phaser.PhaseHook {
+	return func(value interface{}) (interface{}, error) {
+		values := value.([]string)
+		return append(values[:len(values):len(values)], name), nil
+	}
+}
+
+// mustAdd adds phases to m, failing t if any is rejected.
+func mustAdd(t *testing.T, m phaser.PhaseManager, phases ...*phaser.Phase) {
+	t.Helper()
+	for _, p := range phases {
+		if err := m.AddPhase(p); err != nil {
+			t.Fatalf("adding phase %s: %v", p.Name, err)
+		}
+	}
+}
+
+// mustHook fails t if adding a hook returned err.
+func mustHook(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("adding hook: %v", err)
+	}
+}
+
+// run runs the pipeline of m, which must implement Runner.
+func run(t *testing.T, m phaser.PhaseManager, ctx context.Context, value interface{}, opts ...phaser.RunOption) (interface{}, error) {
+	t.Helper()
+	runner, ok := m.(Runner)
+	if !ok {
+		t.Fatalf("%T claims to run pipelines, but does not implement Runner", m)
+	}
+	return runner.RunContext(ctx, value, opts...)
+}
+
+// expectOutput runs the pipeline of m on value, failing t unless it
+// succeeds with expected.
+func expectOutput(t *testing.T, m phaser.PhaseManager, value, expected interface{}) {
+	t.Helper()
+	output, err := run(t, m, context.Background(), value)
+	expectEqual(t, expected, output, err)
+}
+
+// expectEqual fails t unless a run returned expected without an error.
+func expectEqual(t *testing.T, expected, output interface{}, err error) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("run failed: %v", err)
+	} else if !reflect.DeepEqual(output, expected) {
+		t.Errorf("run returned %v, expected %v", output, expected)
+	}
+}
@@ -0,0 +1,87 @@
+package phasertest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+)
+
+// registry is a PhaseManager registering phases without running them.
+type registry struct {
+	phases map[string]*phaser.Phase
+}
+
+func (r *registry) AddPhase(phase *phaser.Phase) error {
+	if phase.Name == "" {
+		return phaser.ErrEmptyPhaseName
+	}
+	if _, ok := r.phases[phase.Name]; ok {
+		return fmt.Errorf("%w: %s", phaser.ErrDuplicatePhase, phase.Name)
+	}
+	r.phases[phase.Name] = phase
+	return nil
+}
+
+func (r *registry) AddPreHookToPhase(phaseName string, hook phaser.PhaseHook) error {
+	p, ok := r.phases[phaseName]
+	if !ok {
+		return fmt.Errorf("%w: %s", phaser.ErrPhaseNotFound, phaseName)
+	}
+	p.AppendNamedPreHook("", hook)
+	return nil
+}
+
+func (r *registry) AddPostHookToPhase(phaseName string, hook phaser.PhaseHook) error {
+	p, ok := r.phases[phaseName]
+	if !ok {
+		return fmt.Errorf("%w: %s", phaser.ErrPhaseNotFound, phaseName)
+	}
+	p.AppendNamedPostHook("", hook)
+	return nil
+}
+
+func TestManagerConformance(t *testing.T) {
+	t.Run("DefaultPhaseManager", func(t *testing.T) {
+		RunManagerConformance(t, func() phaser.PhaseManager {
+			return phaser.NewPhaseManager()
+		}, Capabilities{Run: true, Skip: true, NonCritical: true, Cancellation: true, Reports: true})
+	})
+
+	t.Run("registration only", func(t *testing.T) {
+		RunManagerConformance(t, func() phaser.PhaseManager {
+			return &registry{phases: map[string]*phaser.Phase{}}
+		}, Capabilities{})
+	})
+}
+
+func TestPhaserConformance(t *testing.T) {
+	t.Run("Phase", func(t *testing.T) {
+		RunPhaserConformance(t, func(name string, execute phaser.PhaseHook) *phaser.Phase {
+			return phaser.NewPhase(name, execute)
+		})
+	})
+
+	t.Run("ConditionalPhase", func(t *testing.T) {
+		RunPhaserConformance(t, func(name string, execute phaser.PhaseHook) *phaser.Phase {
+			return phaser.ConditionalPhase(func(interface{}) bool { return true }, phaser.NewPhase(name, execute))
+		})
+	})
+
+	t.Run("BatchPhase", func(t *testing.T) {
+		RunPhaserConformance(t, func(name string, execute phaser.PhaseHook) *phaser.Phase {
+			return phaser.NewBatchPhase(name, 4, time.Millisecond, func(inputs []interface{}) ([]interface{}, error) {
+				outputs := make([]interface{}, len(inputs))
+				for i, input := range inputs {
+					output, err := execute(input)
+					if err != nil {
+						return nil, err
+					}
+					outputs[i] = output
+				}
+				return outputs, nil
+			}).Phase
+		})
+	})
+}
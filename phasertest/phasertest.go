@@ -0,0 +1,38 @@
+// Package phasertest provides helpers for testing pipelines.
+package phasertest
+
+import (
+	"reflect"
+	"testing"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+)
+
+// RecordingPhase returns a phase passing its input through, and the inputs it
+// received in order. The inputs are not synchronized, so the phase must not
+// run concurrently.
+func RecordingPhase(name string) (*phaser.Phase, *[]interface{}) {
+	inputs := &[]interface{}{}
+	return phaser.NewPhase(name, func(value interface{}) (interface{}, error) {
+		*inputs = append(*inputs, value)
+		return value, nil
+	}), inputs
+}
+
+// FailingPhase returns a phase always failing with err.
+func FailingPhase(name string, err error) *phaser.Phase {
+	return phaser.NewPhase(name, func(value interface{}) (interface{}, error) {
+		return nil, err
+	})
+}
+
+// AssertOrder checks that the phases of m run in the expected order,
+// reporting an error to t otherwise. It returns whether they do.
+func AssertOrder(t testing.TB, m *phaser.DefaultPhaseManager, expected []string) bool {
+	t.Helper()
+	if names := m.PhaseNames(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("phases run in order %q, expected %q", names, expected)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package phasertest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records the errors reported by AssertOrder.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestHelpers(t *testing.T) {
+	errBroken := errors.New("broken")
+	first, firstInputs := RecordingPhase("first")
+	second, secondInputs := RecordingPhase("second")
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhases(first, second, FailingPhase("broken", errBroken)))
+	AssertOrder(t, m, []string{"first", "second", "broken"})
+
+	_, err := m.Run(1)
+	assert.ErrorIs(t, err, errBroken)
+	_, err = m.Run(2)
+	assert.ErrorIs(t, err, errBroken)
+	assert.Equal(t, []interface{}{1, 2}, *firstInputs)
+	assert.Equal(t, []interface{}{1, 2}, *secondInputs)
+}
+
+func TestAssertOrderMismatch(t *testing.T) {
+	first, _ := RecordingPhase("first")
+	second, _ := RecordingPhase("second")
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhases(first, second))
+
+	rt := &recordingT{TB: t}
+	assert.False(t, AssertOrder(rt, m, []string{"second", "first"}))
+	assert.Equal(t, []string{`phases run in order ["first" "second"], expected ["second" "first"]`}, rt.errors)
+}
@@ -0,0 +1,290 @@
+package phasertest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+)
+
+// UpdateSnapshotsEnv is the environment variable that, when set to a non-empty
+// value, makes MatchSnapshot rewrite the snapshots instead of comparing them.
+const UpdateSnapshotsEnv = "PHASERTEST_UPDATE_SNAPSHOTS"
+
+// Fingerprint returns a canonical description of the structure of the
+// pipeline of m, one setting per line, meant to be stored and compared by
+// MatchSnapshot. Phases are listed in order with their settings, hook names
+// and branches, the branches sorted by key. Functions are only described by
+// the names of their phases and hooks, unnamed hooks being listed as
+// <unnamed>, so the fingerprint only changes when the structure does.
+func Fingerprint(m *phaser.DefaultPhaseManager) string {
+	var b strings.Builder
+	writePipeline(&b, m.Describe(), "")
+	return b.String()
+}
+
+// writePipeline writes the fingerprint of def to b, indenting its lines with
+// indent.
+func writePipeline(b *strings.Builder, def phaser.PipelineDefinition, indent string) {
+	var settings []string
+	if def.StrictMode {
+		settings = append(settings, "strict")
+	}
+	if def.RetryBudget != 0 {
+		settings = append(settings, fmt.Sprintf("retryBudget=%d", def.RetryBudget))
+	}
+	writeLine(b, indent, fmt.Sprintf("pipeline (%d phases)", len(def.Phases)), settings)
+	for i, p := range def.Phases {
+		writePhase(b, i, p, indent+"  ")
+	}
+}
+
+// writePhase writes the fingerprint of the phase def at index i to b.
+func writePhase(b *strings.Builder, i int, def phaser.PhaseDefinition, indent string) {
+	var settings []string
+	flag := func(set bool, name string) {
+		if set {
+			settings = append(settings, name)
+		}
+	}
+	flag(def.Disabled, "disabled")
+	flag(def.NonCritical, "nonCritical")
+	flag(def.ParallelHooks, "parallelHooks")
+	flag(def.DedupeHooks, "dedupeHooks")
+	flag(def.AllowNilValues, "allowNilValues")
+	if def.FeatureFlag != "" {
+		settings = append(settings, "flag="+strconv.Quote(def.FeatureFlag))
+	}
+	if def.Version != "" {
+		settings = append(settings, "version="+strconv.Quote(def.Version))
+	}
+	if def.Timeout != 0 {
+		settings = append(settings, "timeout="+def.Timeout.String())
+	}
+	if def.Retry != nil {
+		settings = append(settings, fmt.Sprintf("retry=%d", def.Retry.MaxAttempts))
+		if def.Retry.Backoff != 0 {
+			settings = append(settings, "backoff="+def.Retry.Backoff.String())
+		}
+		if def.Retry.Scope != phaser.ExecuteOnly {
+			settings = append(settings, "scope="+def.Retry.Scope.String())
+		}
+	}
+	if def.RateLimit != nil {
+		settings = append(settings, "rate="+strconv.FormatFloat(def.RateLimit.PerSecond, 'g', -1, 64)+"/s")
+		if def.RateLimit.Burst != 0 {
+			settings = append(settings, fmt.Sprintf("burst=%d", def.RateLimit.Burst))
+		}
+	}
+	if def.MaxConcurrent != 0 {
+		settings = append(settings, fmt.Sprintf("maxConcurrent=%d", def.MaxConcurrent))
+	}
+	if def.Weight != 0 {
+		settings = append(settings, "weight="+strconv.FormatFloat(def.Weight, 'g', -1, 64))
+	}
+	writeLine(b, indent, fmt.Sprintf("phase %d %s", i, strconv.Quote(def.Name)), settings)
+
+	indent += "  "
+	if len(def.DependsOn) > 0 {
+		deps := append([]string(nil), def.DependsOn...)
+		sort.Strings(deps)
+		writeLine(b, indent, "dependsOn: "+strings.Join(quoteAll(deps), ", "), nil)
+	}
+	writeHooks(b, indent, "preHooks", def.PreHooks)
+	writeHooks(b, indent, "postHooks", def.PostHooks)
+
+	keys := make([]string, 0, len(def.Branches))
+	for key := range def.Branches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeLine(b, indent, "branch "+strconv.Quote(key)+":", nil)
+		writePipeline(b, def.Branches[key], indent+"  ")
+	}
+}
+
+// writeHooks writes the names of hooks, the hooks of stage, in order.
+func writeHooks(b *strings.Builder, indent, stage string, hooks []string) {
+	if len(hooks) == 0 {
+		return
+	}
+	names := make([]string, len(hooks))
+	for i, name := range hooks {
+		if name == "" {
+			names[i] = "<unnamed>"
+		} else {
+			names[i] = strconv.Quote(name)
+		}
+	}
+	writeLine(b, indent, fmt.Sprintf("%s (%d): %s", stage, len(hooks), strings.Join(names, ", ")), nil)
+}
+
+// writeLine writes line followed by settings to b.
+func writeLine(b *strings.Builder, indent, line string, settings []string) {
+	b.WriteString(indent)
+	b.WriteString(line)
+	if len(settings) > 0 {
+		b.WriteString(" [")
+		b.WriteString(strings.Join(settings, " "))
+		b.WriteString("]")
+	}
+	b.WriteString("\n")
+}
+
+// quoteAll returns values quoted.
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return quoted
+}
+
+// MatchSnapshot checks that the Fingerprint of m matches the snapshot stored
+// at path, reporting a unified diff of the two to t otherwise. The snapshot is
+// written instead when it does not exist, or when UpdateSnapshotsEnv is set,
+// so that intended changes to the pipeline are recorded by running the tests
+// again with it. It returns whether the fingerprint matches.
+func MatchSnapshot(t testing.TB, m *phaser.DefaultPhaseManager, path string) bool {
+	t.Helper()
+	fingerprint := Fingerprint(m)
+	snapshot, err := os.ReadFile(path)
+	if os.Getenv(UpdateSnapshotsEnv) != "" || errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Errorf("writing snapshot %s: %v", path, err)
+			return false
+		}
+		if err := os.WriteFile(path, []byte(fingerprint), 0o644); err != nil {
+			t.Errorf("writing snapshot %s: %v", path, err)
+			return false
+		}
+		return true
+	}
+	if err != nil {
+		t.Errorf("reading snapshot %s: %v", path, err)
+		return false
+	}
+	if string(snapshot) != fingerprint {
+		t.Errorf("pipeline does not match snapshot %s, set %s to update it:\n%s", path, UpdateSnapshotsEnv,
+			unifiedDiff(path, "pipeline", string(snapshot), fingerprint))
+		return false
+	}
+	return true
+}
+
+// splitLines returns the lines of s, with their line endings.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffContext is the number of unchanged lines around the changes of a
+// unified diff.
+const diffContext = 3
+
+// unifiedDiff returns the unified diff turning a, named from, into b, named
+// to.
+func unifiedDiff(from, to, a, b string) string {
+	linesA, linesB := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// linesA[i:] and linesB[j:]
+	lcs := make([][]int, len(linesA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(linesB)+1)
+	}
+	for i := len(linesA) - 1; i >= 0; i-- {
+		for j := len(linesB) - 1; j >= 0; j-- {
+			if linesA[i] == linesB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// edit is a line of the diff, kept (' '), removed ('-') or added ('+'),
+	// at line i of a and j of b
+	type edit struct {
+		op   byte
+		line string
+		i, j int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(linesA) || j < len(linesB) {
+		switch {
+		case i < len(linesA) && j < len(linesB) && linesA[i] == linesB[j]:
+			edits = append(edits, edit{' ', linesA[i], i, j})
+			i++
+			j++
+		case j == len(linesB) || (i < len(linesA) && lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', linesA[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', linesB[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", from, to)
+	for start := 0; start < len(edits); {
+		// Find the next change, and the end of its hunk, where changes are
+		// separated by more than twice the context
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		end, unchanged := first, 0
+		for k := first; k < len(edits) && unchanged <= 2*diffContext; k++ {
+			if edits[k].op == ' ' {
+				unchanged++
+			} else {
+				unchanged, end = 0, k+1
+			}
+		}
+		begin := first - diffContext
+		if begin < start {
+			begin = start
+		}
+		stop := end + diffContext
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		var countA, countB int
+		for _, e := range edits[begin:stop] {
+			if e.op != '+' {
+				countA++
+			}
+			if e.op != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", edits[begin].i+1, countA, edits[begin].j+1, countB)
+		for _, e := range edits[begin:stop] {
+			out.WriteByte(e.op)
+			out.WriteString(strings.TrimSuffix(e.line, "\n"))
+			out.WriteByte('\n')
+		}
+		start = stop
+	}
+	return out.String()
+}
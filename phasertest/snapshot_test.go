@@ -0,0 +1,93 @@
+package phasertest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// examplePipeline returns an order processing pipeline using most of the
+// settings described by Fingerprint.
+func examplePipeline(t *testing.T) *phaser.DefaultPhaseManager {
+	pass := func(value interface{}) (interface{}, error) {
+		return value, nil
+	}
+	m := phaser.NewPhaseManager()
+	m.RetryBudget = 5
+
+	validate := phaser.NewPhase("validate", pass, phaser.WithTimeout(2*time.Second))
+	validate.AppendNamedPreHook("normalize", pass)
+	validate.AppendNamedPreHook("trim", pass)
+	validate.AppendNamedPostHook("audit", pass)
+	charge := phaser.NewPhase("charge", pass, phaser.WithRetry(phaser.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     100 * time.Millisecond,
+		Scope:       phaser.HooksAndExecute,
+	}), phaser.WithMaxConcurrent(4))
+	charge.RateLimit = &phaser.RateLimit{PerSecond: 50, Burst: 10}
+	charge.DependsOn = []string{"validate"}
+	require.NoError(t, m.AddPhases(validate, charge))
+
+	digital := phaser.NewPhaseManager()
+	require.NoError(t, digital.AddPhase(phaser.NewPhase("email", pass)))
+	physical := phaser.NewPhaseManager()
+	pack := phaser.NewPhase("pack", pass, phaser.WithVersion("2"))
+	require.NoError(t, physical.AddPhases(pack, phaser.NewPhase("ship", pass, phaser.WithWeight(3))))
+	require.NoError(t, physical.AddPreHookToPhase("pack", pass))
+	require.NoError(t, m.AddBranch("fulfill", func(value interface{}) (string, error) {
+		return "digital", nil
+	}, map[string]*phaser.DefaultPhaseManager{"physical": physical, "digital": digital}))
+
+	notify := phaser.NewPhase("notify", pass, phaser.WithNonCritical())
+	notify.FeatureFlag = "notifications"
+	notify.DependsOn = []string{"fulfill", "charge"}
+	require.NoError(t, m.AddPhase(notify))
+	return m
+}
+
+func TestExamplePipelineSnapshot(t *testing.T) {
+	MatchSnapshot(t, examplePipeline(t), "testdata/pipeline.snap")
+}
+
+func TestFingerprintDeterministic(t *testing.T) {
+	expected := Fingerprint(examplePipeline(t))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expected, Fingerprint(examplePipeline(t)))
+	}
+	assert.Contains(t, expected, "preHooks (1): <unnamed>\n")
+	assert.NotContains(t, expected, "0x")
+}
+
+func TestMatchSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots", "pipeline.snap")
+	m := examplePipeline(t)
+
+	// The missing snapshot is written
+	assert.True(t, MatchSnapshot(t, m, path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, Fingerprint(m), string(data))
+	assert.True(t, MatchSnapshot(t, m, path))
+
+	changed := examplePipeline(t)
+	validate, _ := changed.PhaseAt(0)
+	validate.AppendNamedPostHook("log", func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+	recorder := &recordingT{TB: t}
+	assert.False(t, MatchSnapshot(recorder, changed, path))
+	require.Len(t, recorder.errors, 1)
+	assert.Contains(t, recorder.errors[0], "--- "+path+"\n+++ pipeline\n@@ -1,")
+	assert.Contains(t, recorder.errors[0], "\n-    postHooks (1): \"audit\"\n+    postHooks (2): \"audit\", \"log\"\n")
+
+	// The snapshot is updated when requested
+	t.Setenv(UpdateSnapshotsEnv, "1")
+	assert.True(t, MatchSnapshot(t, changed, path))
+	t.Setenv(UpdateSnapshotsEnv, "")
+	assert.True(t, MatchSnapshot(t, changed, path))
+}
@@ -0,0 +1,114 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithPriority sets the priority of the submitted run, which defaults to
+// zero. Runs with a higher priority are dequeued first. While they wait for
+// a worker, the running runs with a lower priority yield before their next
+// phase: they are queued again, after the runs with a higher priority, and
+// continue from that phase with the value they reached. Phases are never
+// interrupted, and the handle of a run stays valid while it yields.
+func WithPriority(priority int) SubmitOption {
+	return func(sub *submission) {
+		sub.priority = priority
+	}
+}
+
+// WithMaxYields limits the number of times each run yields to runs with a
+// higher priority to n, so that runs with a low priority are not starved.
+// Zero disables yielding. Yields are not limited by default.
+func WithMaxYields(n int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.maxYields = n
+	}
+}
+
+// RunYield describes a run yielding to runs with a higher priority.
+type RunYield struct {
+	// Before is the name of the phase the run yielded before, and continued
+	// from
+	Before string
+	// At is the time the run yielded
+	At time.Time
+	// Resumed is the time the run continued
+	Resumed time.Time
+}
+
+// yieldError is returned by runs yielding between their phases.
+type yieldError struct {
+	// next is the name of the phase the run yielded before, at index
+	next  string
+	index int
+	// value is the input of the next phase
+	value interface{}
+	// outputs contains the outputs of the completed phases by phase name
+	// when the pipeline has phases with dependencies
+	outputs map[string]interface{}
+	// journal journals the run when set, so that its parts are journaled
+	// as a single run
+	journal *runJournal
+}
+
+func (e *yieldError) Error() string {
+	return fmt.Sprintf("run yielded before phase %s", e.next)
+}
+
+// run runs sub using m with ctx, continuing it from its last yield if any,
+// after it waited in the queue for queued. The run yields while runs with a
+// higher priority are waiting. Its handle's report accumulates the reports
+// of the parts of the run.
+func (s *Scheduler) run(ctx context.Context, m *DefaultPhaseManager, sub *submission, queued time.Duration) (interface{}, error) {
+	started := s.now()
+	var part RunReport
+	c := newRunConfig(append(sub.opts, WithReport(&part)))
+	c.yield = func() bool {
+		return s.shouldYield(sub)
+	}
+
+	var value interface{}
+	var err error
+	if yielded := sub.yielded; yielded != nil {
+		c.resumed = yielded
+		value, err = m.run(ctx, c, yielded.index, yielded.value)
+	} else {
+		value, err = m.run(ctx, c, 0, sub.value)
+	}
+
+	report := &sub.handle.report
+	if sub.yielded == nil {
+		*report = part
+	} else {
+		report.Yields[len(report.Yields)-1].Resumed = started
+		report.Phases = append(report.Phases, part.Phases...)
+		report.Duration = part.Start.Add(part.Duration).Sub(report.Start)
+		report.Err = part.Err
+	}
+	report.Queued += queued
+	var yielded *yieldError
+	if errors.As(err, &yielded) {
+		report.Yields = append(report.Yields, RunYield{Before: yielded.next, At: s.now()})
+		report.Err = nil
+	}
+	return value, err
+}
+
+// shouldYield reports whether the running sub yields to a run with a higher
+// priority waiting for a worker.
+func (s *Scheduler) shouldYield(sub *submission) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxYields >= 0 && len(sub.handle.report.Yields) >= s.maxYields || s.idle > 0 {
+		return false
+	}
+	for _, q := range s.queues {
+		if len(q.runs) > 0 && q.runs[0].priority > sub.priority {
+			return true
+		}
+	}
+	return false
+}
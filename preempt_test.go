@@ -0,0 +1,135 @@
+package phaser
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventLog records events from concurrent runs.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+// lowPriorityManager returns a manager whose first phase adds one to its
+// input after signalling started and waiting for release, and whose other
+// phases add ten and a hundred, logging each phase.
+func lowPriorityManager(t *testing.T, log *eventLog, started chan<- struct{}, release <-chan struct{}) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("first", func(value interface{}) (interface{}, error) {
+			started <- struct{}{}
+			<-release
+			log.add("low first")
+			return value.(int) + 1, nil
+		}),
+		NewPhase("second", func(value interface{}) (interface{}, error) {
+			log.add("low second")
+			return value.(int) + 10, nil
+		}),
+		NewPhase("third", func(value interface{}) (interface{}, error) {
+			log.add("low third")
+			return value.(int) + 100, nil
+		}),
+	))
+	return m
+}
+
+func TestSchedulerPriorityYield(t *testing.T) {
+	clock := &syncClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	log := &eventLog{}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	low := lowPriorityManager(t, log, started, release)
+	high := orderManager(t, "high", &log.mu, &log.events)
+
+	s := NewScheduler(WithSchedulerClock(clock))
+	lowRun := s.Submit(low, 0)
+	<-started
+	highRun := s.Submit(high, 0, WithPriority(10))
+	clock.advance(time.Second)
+	close(release)
+
+	_, err := highRun.Wait()
+	require.NoError(t, err)
+	value, err := lowRun.Wait()
+	require.NoError(t, err)
+	require.NoError(t, s.Drain(context.Background()))
+
+	// The low priority run yields before its second phase, and resumes with
+	// the value of its first phase
+	assert.Equal(t, []string{"low first", "high", "low second", "low third"}, log.list())
+	assert.Equal(t, 111, value)
+
+	report := lowRun.Report()
+	assert.NoError(t, report.Err)
+	require.Len(t, report.Yields, 1)
+	assert.Equal(t, "second", report.Yields[0].Before)
+	assert.Equal(t, clock.Now(), report.Yields[0].At)
+	assert.Equal(t, clock.Now(), report.Yields[0].Resumed)
+	var phases []string
+	for _, result := range report.Phases {
+		phases = append(phases, result.Phase)
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, phases)
+	assert.Equal(t, 1, report.Phases[0].Output)
+	assert.Equal(t, 11, report.Phases[1].Output)
+}
+
+func TestSchedulerMaxYields(t *testing.T) {
+	log := &eventLog{}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	low := lowPriorityManager(t, log, started, release)
+	high := orderManager(t, "high", &log.mu, &log.events)
+
+	s := NewScheduler(WithMaxYields(0))
+	lowRun := s.Submit(low, 0)
+	<-started
+	highRun := s.Submit(high, 0, WithPriority(10))
+	close(release)
+
+	_, err := highRun.Wait()
+	require.NoError(t, err)
+	value, err := lowRun.Wait()
+	require.NoError(t, err)
+	require.NoError(t, s.Drain(context.Background()))
+
+	assert.Equal(t, []string{"low first", "low second", "low third", "high"}, log.list())
+	assert.Equal(t, 111, value)
+	assert.Empty(t, lowRun.Report().Yields)
+}
+
+func TestSchedulerPriorityDequeue(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	s := NewScheduler()
+	s.Submit(gateManager(t, started, release), nil)
+	<-started
+
+	lowRun := s.Submit(orderManager(t, "low", &mu, &order), 1)
+	highRun := s.Submit(orderManager(t, "high", &mu, &order), 1, WithPriority(1))
+	close(release)
+	for _, h := range []*RunHandle{lowRun, highRun} {
+		_, err := h.Wait()
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Drain(context.Background()))
+	assert.Equal(t, []string{"high", "low"}, order)
+}
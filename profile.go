@@ -0,0 +1,127 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrUnknownProfile is returned by ApplyProfile for profiles that are not
+// registered.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Profile is a named set of phase overrides, such as the settings of an
+// environment, registered with RegisterProfile and applied to every run of
+// the manager by ApplyProfile. Phases are named as in WithPhaseOverride, so
+// they may belong to branches.
+type Profile struct {
+	// Enabled contains the phases to run, even when they are disabled
+	Enabled []string
+	// Disabled contains the phases to skip
+	Disabled []string
+	// Timeouts contains the timeouts of phases, as set by OverrideTimeout
+	Timeouts map[string]time.Duration
+	// Retries contains the retry policies of phases
+	Retries map[string]RetryPolicy
+	// Overrides contains other overrides of phases, applied after the
+	// settings above
+	Overrides map[string][]PhaseOverride
+}
+
+// overrides returns the overrides of the profile by phase name.
+func (p Profile) overrides() map[string][]PhaseOverride {
+	overrides := make(map[string][]PhaseOverride)
+	for _, name := range p.Enabled {
+		overrides[name] = append(overrides[name], OverrideSkip(false))
+	}
+	for _, name := range p.Disabled {
+		overrides[name] = append(overrides[name], OverrideSkip(true))
+	}
+	for name, timeout := range p.Timeouts {
+		overrides[name] = append(overrides[name], OverrideTimeout(timeout))
+	}
+	for name, policy := range p.Retries {
+		overrides[name] = append(overrides[name], OverrideRetry(policy))
+	}
+	for name, phaseOverrides := range p.Overrides {
+		overrides[name] = append(overrides[name], phaseOverrides...)
+	}
+	return overrides
+}
+
+// profiles contains the profiles of a manager.
+type profiles struct {
+	mu         sync.RWMutex
+	registered map[string]Profile
+	// active is the name of the applied profile, empty when none is
+	active string
+	// overrides contains the overrides of the applied profile
+	overrides map[string][]PhaseOverride
+}
+
+// RegisterProfile registers profile under name, replacing the profile
+// previously registered with the same name. Registering the applied profile
+// does not change the runs until it is applied again.
+func (m *DefaultPhaseManager) RegisterProfile(name string, profile Profile) {
+	m.profiles.mu.Lock()
+	defer m.profiles.mu.Unlock()
+	if m.profiles.registered == nil {
+		m.profiles.registered = make(map[string]Profile)
+	}
+	m.profiles.registered[name] = profile
+}
+
+// ApplyProfile applies the profile registered under name to the runs started
+// from now on, replacing the profile previously applied, without changing
+// the phases. The empty name removes the applied profile. The overrides of
+// runs using WithPhaseOverride are applied after the profile's. It fails with
+// ErrUnknownProfile for unregistered profiles, and with ErrPhaseNotFound for
+// profiles naming unknown phases, leaving the applied profile unchanged.
+func (m *DefaultPhaseManager) ApplyProfile(name string) error {
+	var overrides map[string][]PhaseOverride
+	if name != "" {
+		m.profiles.mu.RLock()
+		profile, ok := m.profiles.registered[name]
+		m.profiles.mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+		}
+		overrides = profile.overrides()
+		for phase := range overrides {
+			if !m.hasPhase(phase) {
+				return fmt.Errorf("%w: profile %s overriding %s", ErrPhaseNotFound, name, phase)
+			}
+		}
+	}
+
+	m.profiles.mu.Lock()
+	defer m.profiles.mu.Unlock()
+	m.profiles.active, m.profiles.overrides = name, overrides
+	return nil
+}
+
+// ActiveProfile returns the name of the applied profile, empty when none is.
+func (m *DefaultPhaseManager) ActiveProfile() string {
+	m.profiles.mu.RLock()
+	defer m.profiles.mu.RUnlock()
+	return m.profiles.active
+}
+
+// withRun returns the overrides of the applied profile followed by
+// overrides, the overrides of a run.
+func (p *profiles) withRun(overrides map[string][]PhaseOverride) map[string][]PhaseOverride {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.overrides) == 0 {
+		return overrides
+	}
+	merged := make(map[string][]PhaseOverride, len(p.overrides)+len(overrides))
+	for name, phaseOverrides := range p.overrides {
+		merged[name] = phaseOverrides
+	}
+	for name, phaseOverrides := range overrides {
+		merged[name] = append(merged[name][:len(merged[name]):len(merged[name])], phaseOverrides...)
+	}
+	return merged
+}
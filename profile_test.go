@@ -0,0 +1,83 @@
+package phaser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles(t *testing.T) {
+	calls := 0
+	debug := NewPhase("debug", addOne)
+	debug.Disabled = true
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		debug,
+		flakyPhase("flaky", 2, &calls),
+		NewPhase("slow", func(value interface{}) (interface{}, error) {
+			time.Sleep(20 * time.Millisecond)
+			return value, nil
+		}),
+	))
+	m.RegisterProfile("prod", Profile{
+		Timeouts: map[string]time.Duration{"slow": time.Second},
+		Retries:  map[string]RetryPolicy{"flaky": {MaxAttempts: 3}},
+	})
+	m.RegisterProfile("test", Profile{
+		Enabled:  []string{"debug"},
+		Disabled: []string{"flaky"},
+		Timeouts: map[string]time.Duration{"slow": time.Millisecond},
+	})
+
+	require.NoError(t, m.ApplyProfile("prod"))
+	assert.Equal(t, "prod", m.ActiveProfile())
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 3, calls)
+
+	require.NoError(t, m.ApplyProfile("test"))
+	assert.Equal(t, "test", m.ActiveProfile())
+	var report RunReport
+	_, err = m.Run(0, WithReport(&report))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 3, calls)
+	require.Len(t, report.Phases, 3)
+	assert.Equal(t, StatusSucceeded, report.Phases[0].Status)
+	assert.Equal(t, StatusSkipped, report.Phases[1].Status)
+
+	// The overrides of the run apply after the profile's
+	value, err = m.Run(0, WithPhaseOverride("slow", OverrideTimeout(time.Second)))
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	// The phases are left unchanged
+	require.NoError(t, m.ApplyProfile(""))
+	assert.Empty(t, m.ActiveProfile())
+	assert.True(t, m.phases[0].Disabled)
+	assert.Nil(t, m.phases[1].Retry)
+	assert.Zero(t, m.phases[2].Timeout)
+}
+
+func TestApplyProfileErrors(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("add", addOne)))
+	m.RegisterProfile("prod", Profile{Disabled: []string{"add"}})
+	m.RegisterProfile("broken", Profile{Disabled: []string{"missing"}})
+	require.NoError(t, m.ApplyProfile("prod"))
+
+	err := m.ApplyProfile("staging")
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	err = m.ApplyProfile("broken")
+	assert.ErrorIs(t, err, ErrPhaseNotFound)
+	assert.Contains(t, err.Error(), "missing")
+
+	// The applied profile is kept
+	assert.Equal(t, "prod", m.ActiveProfile())
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, value)
+}
@@ -0,0 +1,126 @@
+package phaser
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// historyWeight is the weight of the latest duration in the moving
+	// average of the durations of a phase
+	historyWeight = 0.3
+	// maxHistoryPhases is the maximum number of phases whose durations are
+	// kept in a duration history
+	maxHistoryPhases = 1024
+)
+
+// Progress describes the progress of a run.
+type Progress struct {
+	// Phase is the name of the phase that was just processed
+	Phase string
+	// Completed is the number of phases processed, including skipped phases
+	Completed int
+	// Total is the number of phases of the run
+	Total int
+	// Fraction is the weighted fraction of the run completed, between 0 and
+	// 1, where each phase counts according to its Weight
+	Fraction float64
+	// EstimatedRemaining is the estimated time left to complete the run. It
+	// is only set when EstimateKnown is
+	EstimatedRemaining time.Duration
+	// EstimateKnown tells whether there is enough history to estimate the
+	// remaining time
+	EstimateKnown bool
+}
+
+// WithProgress calls fn with the progress of every run after each phase is
+// processed.
+func WithProgress(fn func(Progress)) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.progress = fn
+	}
+}
+
+// WithDurationHistory keeps a moving average of the durations of each phase
+// across the manager's runs, which is used to estimate the time remaining in
+// the progress of runs.
+func WithDurationHistory() ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.history = &durationHistory{averages: map[string]time.Duration{}}
+	}
+}
+
+// reportProgress calls the manager's progress function once the phase at
+// index i has been processed.
+func (m *DefaultPhaseManager) reportProgress(i int) {
+	if m.progress == nil {
+		return
+	}
+
+	progress := Progress{Phase: m.phases[i].Name, Completed: i + 1, Total: len(m.phases)}
+	var done, total float64
+	for j, p := range m.phases {
+		weight := p.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		if j <= i {
+			done += weight
+		}
+	}
+	progress.Fraction = done / total
+
+	progress.EstimateKnown = true
+	for _, p := range m.phases[i+1:] {
+		if p.Disabled {
+			continue
+		}
+		average, ok := m.history.average(p.Name)
+		if !ok {
+			progress.EstimatedRemaining, progress.EstimateKnown = 0, false
+			break
+		}
+		progress.EstimatedRemaining += average
+	}
+
+	m.progress(progress)
+}
+
+// durationHistory keeps a moving average of the durations of phases. A nil
+// *durationHistory keeps nothing.
+type durationHistory struct {
+	mu       sync.Mutex
+	averages map[string]time.Duration
+}
+
+// add adds d to the durations of the phase named phase. Phases are not added
+// once the history holds maxHistoryPhases phases.
+func (h *durationHistory) add(phase string, d time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	average, ok := h.averages[phase]
+	switch {
+	case ok:
+		h.averages[phase] = time.Duration(historyWeight*float64(d) + (1-historyWeight)*float64(average))
+	case len(h.averages) < maxHistoryPhases:
+		h.averages[phase] = d
+	}
+}
+
+// average returns the average duration of the phase named phase, and whether
+// there is any.
+func (h *durationHistory) average(phase string) (time.Duration, bool) {
+	if h == nil {
+		return 0, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	average, ok := h.averages[phase]
+	return average, ok
+}
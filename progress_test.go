@@ -0,0 +1,108 @@
+package phaser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClock is a manually advanced clock.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// Sleep and After advance the clock by d at once.
+func (c *testClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	c.Sleep(d)
+	return firedAfter(c.now)
+}
+
+// firedAfter returns a channel holding now.
+func firedAfter(now time.Time) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+// sleepPhase returns a phase advancing clock by d.
+func sleepPhase(name string, clock *testClock, d time.Duration, opts ...PhaseOption) *Phase {
+	return NewPhase(name, func(value interface{}) (interface{}, error) {
+		clock.now = clock.now.Add(d)
+		return value, nil
+	}, opts...)
+}
+
+func TestProgressWeightedFraction(t *testing.T) {
+	var progress []Progress
+	m := NewPhaseManager(WithProgress(func(p Progress) {
+		progress = append(progress, p)
+	}))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", addOne, WithWeight(8)),
+		NewPhase("three", addOne),
+	))
+
+	_, err := m.Run(0)
+	require.NoError(t, err)
+	require.Len(t, progress, 3)
+	assert.Equal(t, "two", progress[1].Phase)
+	assert.Equal(t, 2, progress[1].Completed)
+	assert.Equal(t, 3, progress[1].Total)
+	assert.InDelta(t, 0.1, progress[0].Fraction, 1e-9)
+	assert.InDelta(t, 0.9, progress[1].Fraction, 1e-9)
+	assert.InDelta(t, 1, progress[2].Fraction, 1e-9)
+}
+
+func TestProgressEstimatedRemaining(t *testing.T) {
+	clock := &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var progress []Progress
+	m := NewPhaseManager(WithDurationHistory(), WithProgress(func(p Progress) {
+		progress = append(progress, p)
+	}))
+	m.clock = clock
+	require.NoError(t, m.AddPhases(
+		sleepPhase("one", clock, time.Second),
+		sleepPhase("two", clock, 10*time.Second),
+		sleepPhase("three", clock, 5*time.Second),
+	))
+
+	// Without history, the estimate is unknown
+	_, err := m.Run(0)
+	require.NoError(t, err)
+	require.Len(t, progress, 3)
+	assert.False(t, progress[0].EstimateKnown)
+	assert.Zero(t, progress[0].EstimatedRemaining)
+
+	progress = nil
+	_, err = m.Run(0)
+	require.NoError(t, err)
+	require.Len(t, progress, 3)
+	assert.True(t, progress[0].EstimateKnown)
+	assert.Equal(t, 15*time.Second, progress[0].EstimatedRemaining)
+	assert.Equal(t, 5*time.Second, progress[1].EstimatedRemaining)
+	assert.True(t, progress[2].EstimateKnown)
+	assert.Zero(t, progress[2].EstimatedRemaining)
+}
+
+func TestDurationHistoryMovingAverage(t *testing.T) {
+	h := &durationHistory{averages: map[string]time.Duration{}}
+	_, ok := h.average("one")
+	assert.False(t, ok)
+
+	h.add("one", 10*time.Second)
+	h.add("one", 20*time.Second)
+	average, ok := h.average("one")
+	assert.True(t, ok)
+	assert.Equal(t, 13*time.Second, average)
+}
@@ -0,0 +1,121 @@
+package phaser
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// WithQuarantine quarantines the non-critical phases failing in threshold
+// consecutive runs. Quarantined phases are skipped with the StatusQuarantined
+// status, except for one probing run per probeInterval which runs them. A
+// successful probe lifts the quarantine, while a failed one keeps the phase
+// quarantined until the next probe. Critical phases are never quarantined.
+func WithQuarantine(threshold int, probeInterval time.Duration) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.quarantine = &quarantine{threshold: threshold, probeInterval: probeInterval, phases: map[string]*quarantineState{}}
+	}
+}
+
+// QuarantinedPhases returns the sorted names of the quarantined phases.
+func (m *DefaultPhaseManager) QuarantinedPhases() []string {
+	q := m.quarantine
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var names []string
+	for name, s := range q.phases {
+		if s.quarantined {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ClearQuarantine lifts the quarantine of the phase named name, and resets its
+// count of consecutive failures.
+func (m *DefaultPhaseManager) ClearQuarantine(name string) {
+	q := m.quarantine
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.phases, name)
+}
+
+// quarantine tracks the failures of the non-critical phases of a manager
+// across runs. A nil *quarantine never quarantines phases.
+type quarantine struct {
+	threshold     int
+	probeInterval time.Duration
+
+	mu     sync.Mutex
+	phases map[string]*quarantineState
+}
+
+// quarantineState is the quarantine state of a phase.
+type quarantineState struct {
+	// failures counts the consecutive failures of the phase
+	failures    int
+	quarantined bool
+	// nextProbe is the time from which the quarantined phase may be probed
+	nextProbe time.Time
+}
+
+// admit reports whether p may run at now. Quarantined phases are admitted
+// once per probe interval.
+func (q *quarantine) admit(p *Phase, now time.Time) bool {
+	if q == nil || !p.NonCritical {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s, ok := q.phases[p.Name]
+	if !ok || !s.quarantined {
+		return true
+	}
+	if now.Before(s.nextProbe) {
+		return false
+	}
+	// Concurrent runs wait for the next interval instead of probing too
+	s.nextProbe = now.Add(q.probeInterval)
+	return true
+}
+
+// holds reports whether p is quarantined at now, until its next probe.
+func (q *quarantine) holds(p *Phase, now time.Time) bool {
+	if q == nil || !p.NonCritical {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s, ok := q.phases[p.Name]
+	return ok && s.quarantined && now.Before(s.nextProbe)
+}
+
+// record records the outcome of p at now.
+func (q *quarantine) record(p *Phase, now time.Time, failed bool) {
+	if q == nil || !p.NonCritical {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !failed {
+		delete(q.phases, p.Name)
+		return
+	}
+	s, ok := q.phases[p.Name]
+	if !ok {
+		s = &quarantineState{}
+		q.phases[p.Name] = s
+	}
+	s.failures++
+	if s.failures >= q.threshold {
+		s.quarantined = true
+		s.nextProbe = now.Add(q.probeInterval)
+	}
+}
@@ -0,0 +1,76 @@
+package phaser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantine(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	calls, fail := 0, true
+	m := NewPhaseManager(WithQuarantine(2, time.Minute))
+	m.clock = clock
+	optional := countingPhase("optional", &calls, &fail)
+	optional.NonCritical = true
+	require.NoError(t, m.AddPhases(NewPhase("one", addOne), optional))
+
+	status := func() PhaseStatus {
+		var report RunReport
+		_, err := m.Run(0, WithReport(&report))
+		require.NoError(t, err)
+		return report.Phases[1].Status
+	}
+
+	// Entry after two consecutive failures
+	assert.Equal(t, StatusFailed, status())
+	assert.Empty(t, m.QuarantinedPhases())
+	assert.Equal(t, StatusFailed, status())
+	assert.Equal(t, []string{"optional"}, m.QuarantinedPhases())
+	assert.Equal(t, StatusQuarantined, status())
+	assert.Equal(t, 2, calls)
+
+	// A failed probe keeps the phase quarantined until the next interval
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal(t, StatusFailed, status())
+	assert.Equal(t, StatusQuarantined, status())
+	assert.Equal(t, 3, calls)
+
+	// A successful probe lifts the quarantine
+	clock.now = clock.now.Add(time.Minute)
+	fail = false
+	assert.Equal(t, StatusSucceeded, status())
+	assert.Empty(t, m.QuarantinedPhases())
+	assert.Equal(t, StatusSucceeded, status())
+}
+
+func TestClearQuarantine(t *testing.T) {
+	calls, fail := 0, true
+	m := NewPhaseManager(WithQuarantine(1, time.Hour))
+	optional := countingPhase("optional", &calls, &fail)
+	optional.NonCritical = true
+	require.NoError(t, m.AddPhase(optional))
+
+	_, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"optional"}, m.QuarantinedPhases())
+
+	m.ClearQuarantine("optional")
+	assert.Empty(t, m.QuarantinedPhases())
+	_, err = m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestQuarantineIgnoresCriticalPhases(t *testing.T) {
+	m := NewPhaseManager(WithQuarantine(1, time.Hour))
+	require.NoError(t, m.AddPhase(NewPhase("critical", failWith(assert.AnError))))
+
+	for i := 0; i < 3; i++ {
+		_, err := m.Run(0)
+		assert.ErrorIs(t, err, assert.AnError)
+	}
+	assert.Empty(t, m.QuarantinedPhases())
+}
@@ -0,0 +1,47 @@
+package phaser
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by phases whose Quota is exhausted.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits the number of executions of a phase within a sliding time
+// window, such as to protect a downstream system. Unlike a RateLimit, runs
+// do not wait for the quota to refill: executions past the limit fail with
+// ErrQuotaExceeded without calling the execute function. The quota is shared
+// by every run of the phase, each retry attempt counting as an execution,
+// and its time is read from the run's clock.
+type Quota struct {
+	// Limit is the number of executions allowed within Window. Values lower
+	// than one reject every execution
+	Limit int
+	// Window is the duration over which executions are counted
+	Window time.Duration
+
+	mu sync.Mutex
+	// executions contains the times of the executions within the window, in
+	// order
+	executions []time.Time
+}
+
+// take records an execution at now, returning false without recording it
+// when the quota is exhausted.
+func (q *Quota) take(now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	expired := 0
+	for expired < len(q.executions) && !q.executions[expired].After(now.Add(-q.Window)) {
+		expired++
+	}
+	q.executions = q.executions[expired:]
+	if len(q.executions) >= q.Limit {
+		return false
+	}
+	q.executions = append(q.executions, now)
+	return true
+}
@@ -0,0 +1,67 @@
+package phaser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuota(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	calls := 0
+	limited := NewPhase("limited", func(value interface{}) (interface{}, error) {
+		calls++
+		return value, nil
+	})
+	limited.Quota = &Quota{Limit: 3, Window: time.Minute}
+	m := NewPhaseManager(WithClock(clock))
+	require.NoError(t, m.AddPhase(limited))
+
+	run := func() error {
+		_, err := m.Run(0)
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, run())
+		clock.Sleep(10 * time.Second)
+	}
+
+	// The quota is exhausted, so runs fail without executing, and without
+	// counting towards the quota
+	for i := 0; i < 2; i++ {
+		err := run()
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.Contains(t, err.Error(), "phase limited executed 3 times within 1m0s")
+	}
+	assert.Equal(t, 3, calls)
+
+	// The first execution leaves the window, allowing one more
+	clock.Sleep(30 * time.Second)
+	require.NoError(t, run())
+	assert.ErrorIs(t, run(), ErrQuotaExceeded)
+
+	// The window elapsed, refilling the quota
+	clock.Sleep(time.Minute)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, run())
+	}
+	assert.ErrorIs(t, run(), ErrQuotaExceeded)
+	assert.Equal(t, 7, calls)
+}
+
+func TestQuotaRetries(t *testing.T) {
+	calls := 0
+	fail := true
+	p := countingPhase("limited", &calls, &fail)
+	p.Quota = &Quota{Limit: 2, Window: time.Hour}
+	p.Retry = &RetryPolicy{MaxAttempts: 5}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	// Each attempt counts towards the quota
+	_, err := m.Run(0)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,102 @@
+package phaser
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter throttles the executions of a phase. *rate.Limiter from
+// golang.org/x/time/rate satisfies this interface.
+type Limiter interface {
+	// Wait blocks until an execution is allowed or ctx is done, in which case
+	// it returns the context's error
+	Wait(ctx context.Context) error
+}
+
+// RateLimit configures the rate at which a phase executes. The limit is shared
+// by every run of the phase.
+type RateLimit struct {
+	// PerSecond is the number of executions allowed per second
+	PerSecond float64
+	// Burst is the number of executions allowed at once. Values lower than one
+	// are treated as one
+	Burst int
+	// Limiter replaces the built-in token bucket when set. PerSecond and Burst
+	// are ignored in that case
+	Limiter Limiter
+
+	once   sync.Once
+	bucket *tokenBucket
+}
+
+// wait blocks until the phase is allowed to execute.
+func (r *RateLimit) wait(ctx context.Context) error {
+	if r.Limiter != nil {
+		return r.Limiter.Wait(ctx)
+	}
+	r.once.Do(func() {
+		r.bucket = newTokenBucket(r.PerSecond, r.Burst)
+	})
+	return r.bucket.Wait(ctx)
+}
+
+// tokenBucket is the built-in Limiter. It holds up to burst tokens, refilled
+// at rate tokens per second, and each execution takes one token.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	// last is the time of the last refill, zero before the first wait
+	last time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	b := math.Max(float64(burst), 1)
+	return &tokenBucket{rate: rate, burst: b, tokens: b}
+}
+
+// Wait takes a token, waiting for it to be refilled if necessary according to
+// the run's clock. Tokens taken by cancelled waits are given back.
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	clock := ClockFrom(ctx)
+
+	b.mu.Lock()
+	now := clock.Now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens--
+	missing := -b.tokens
+	b.mu.Unlock()
+
+	if missing <= 0 {
+		return nil
+	}
+	if b.rate <= 0 {
+		<-ctx.Done()
+		b.giveBack()
+		return ctx.Err()
+	}
+
+	select {
+	case <-clock.After(time.Duration(missing / b.rate * float64(time.Second))):
+		return nil
+	case <-ctx.Done():
+		b.giveBack()
+		return ctx.Err()
+	}
+}
+
+// giveBack returns a token taken by a cancelled wait.
+func (b *tokenBucket) giveBack() {
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
@@ -0,0 +1,108 @@
+package phaser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitThrottlesExecute(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &testClock{now: start}
+	var executions []time.Time
+	p := NewPhase("limited", func(value interface{}) (interface{}, error) {
+		executions = append(executions, clock.Now())
+		return value, nil
+	})
+	p.RateLimit = &RateLimit{PerSecond: 50, Burst: 2}
+	m := NewPhaseManager(WithClock(clock))
+	require.NoError(t, m.AddPhase(p))
+
+	for i := 0; i < 6; i++ {
+		_, err := m.Run(i)
+		require.NoError(t, err)
+	}
+
+	// The burst runs at once, while the remaining four wait 20ms each
+	require.Len(t, executions, 6)
+	assert.Equal(t, start, executions[1])
+	for i := 2; i < len(executions); i++ {
+		assert.Equal(t, 20*time.Millisecond, executions[i].Sub(executions[i-1]))
+	}
+	assert.Equal(t, 80*time.Millisecond, clock.Now().Sub(start))
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	p := NewPhase("limited", addOne)
+	p.RateLimit = &RateLimit{PerSecond: 0.1, Burst: 2}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	// The burst doesn't wait, while the next run would wait ten seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		_, err := m.RunContext(ctx, i)
+		require.NoError(t, err)
+	}
+	_, err := m.RunContext(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRateLimitCancelledWait(t *testing.T) {
+	calls := 0
+	p := NewPhase("limited", func(value interface{}) (interface{}, error) {
+		calls++
+		return value, nil
+	})
+	p.RateLimit = &RateLimit{PerSecond: 0.1, Burst: 1}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	_, err := m.Run(0)
+	require.NoError(t, err)
+
+	// The bucket is empty, so the next run waits until cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = m.RunContext(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, calls)
+}
+
+// fakeLimiter is a Limiter counting its waits.
+type fakeLimiter struct {
+	waits int
+}
+
+func (l *fakeLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	return nil
+}
+
+func TestRateLimitCustomLimiter(t *testing.T) {
+	limiter := &fakeLimiter{}
+	p := NewPhase("limited", addOne)
+	p.RateLimit = &RateLimit{Limiter: limiter}
+
+	for i := 0; i < 3; i++ {
+		_, err := p.run(i)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, limiter.waits)
+}
+
+func TestRateLimitAppliesToRetries(t *testing.T) {
+	limiter := &fakeLimiter{}
+	calls := 0
+	p := flakyPhase("limited", 2, &calls, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	p.RateLimit = &RateLimit{Limiter: limiter}
+
+	_, err := p.run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 3, limiter.waits)
+}
@@ -0,0 +1,104 @@
+package phaser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Reducer folds item into the accumulated value acc, returning the new
+// accumulated value.
+type Reducer func(acc, item interface{}) (interface{}, error)
+
+// ReducePhase returns a phase that folds its input into a single value using
+// reducer, starting from initial. The input must be a slice, which is folded
+// in order, or a map, which is folded in ascending key order. Empty and nil
+// inputs return initial.
+func ReducePhase(name string, initial interface{}, reducer Reducer) *Phase {
+	return NewPhase(name, func(value interface{}) (interface{}, error) {
+		return reduce(value, initial, reducer)
+	})
+}
+
+// reduce folds value using reducer, reporting the index or key of the element
+// that made reducer fail.
+func reduce(value interface{}, acc interface{}, reducer Reducer) (interface{}, error) {
+	var err error
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		// A nil input is empty
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if acc, err = reducer(acc, v.Index(i).Interface()); err != nil {
+				return nil, fmt.Errorf("reducing element %d: %w", i, err)
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return lessKey(keys[i], keys[j])
+		})
+		for _, key := range keys {
+			if acc, err = reducer(acc, v.MapIndex(key).Interface()); err != nil {
+				return nil, fmt.Errorf("reducing key %v: %w", key.Interface(), err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("cannot reduce %T: input must be a slice or a map", value)
+	}
+
+	return acc, nil
+}
+
+// lessKey orders map keys. Numeric and string keys use their natural order,
+// other keys are ordered by their formatted value.
+func lessKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	}
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+// Collect is a Reducer appending every item to a []interface{}. The initial
+// value should be nil or a []interface{}.
+func Collect(acc, item interface{}) (interface{}, error) {
+	if acc == nil {
+		return []interface{}{item}, nil
+	}
+	items, ok := acc.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("collect: accumulator must be a []interface{}, got %T", acc)
+	}
+	return append(items[:len(items):len(items)], item), nil
+}
+
+// CountErrors is a Reducer counting the items that are non-nil errors. The
+// initial value should be an int, usually 0.
+func CountErrors(acc, item interface{}) (interface{}, error) {
+	count, ok := acc.(int)
+	if !ok {
+		return nil, fmt.Errorf("count errors: accumulator must be an int, got %T", acc)
+	}
+	if err, ok := item.(error); ok && err != nil {
+		count++
+	}
+	return count, nil
+}
+
+// FirstNonNil is a Reducer returning the first non-nil item. The initial value
+// should be nil, otherwise it is returned.
+func FirstNonNil(acc, item interface{}) (interface{}, error) {
+	if acc != nil {
+		return acc, nil
+	}
+	return item, nil
+}
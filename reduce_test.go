@@ -0,0 +1,98 @@
+package phaser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sum is a Reducer adding int items.
+func sum(acc, item interface{}) (interface{}, error) {
+	return acc.(int) + item.(int), nil
+}
+
+func TestReducePhaseSlice(t *testing.T) {
+	p := ReducePhase("sum", 0, sum)
+
+	value, err := p.run([]int{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, 6, value)
+}
+
+func TestReducePhaseEmptyInput(t *testing.T) {
+	p := ReducePhase("sum", 10, sum)
+
+	value, err := p.run([]int{})
+	require.NoError(t, err)
+	assert.Equal(t, 10, value)
+
+	value, err = p.run(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 10, value)
+
+	value, err = p.run(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 10, value)
+}
+
+func TestReducePhaseMapOrder(t *testing.T) {
+	p := ReducePhase("collect", nil, Collect)
+	input := map[string]interface{}{"c": 3, "a": 1, "b": 2, "d": 4}
+
+	// Iteration order must not depend on map ordering
+	for i := 0; i < 10; i++ {
+		value, err := p.run(input)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{1, 2, 3, 4}, value)
+	}
+}
+
+func TestReducePhaseErrors(t *testing.T) {
+	failOnTwo := func(acc, item interface{}) (interface{}, error) {
+		if item.(int) == 2 {
+			return nil, assert.AnError
+		}
+		return acc.(int) + item.(int), nil
+	}
+
+	_, err := ReducePhase("slice", 0, failOnTwo).run([]int{1, 2, 3})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Contains(t, err.Error(), "reducing element 1")
+
+	_, err = ReducePhase("map", 0, failOnTwo).run(map[string]int{"x": 1, "y": 2})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Contains(t, err.Error(), "reducing key y")
+
+	_, err = ReducePhase("invalid", 0, failOnTwo).run(1)
+	assert.EqualError(t, err, "cannot reduce int: input must be a slice or a map")
+}
+
+func TestReducePhaseHooks(t *testing.T) {
+	p := ReducePhase("sum", 0, sum)
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		return append(value.([]int), 4), nil
+	})
+	p.appendPostHook(func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	})
+
+	value, err := p.run([]int{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, 20, value)
+}
+
+func TestBuiltinReducers(t *testing.T) {
+	value, err := ReducePhase("count", 0, CountErrors).run([]interface{}{nil, errors.New("a"), 1, errors.New("b")})
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+
+	value, err = ReducePhase("first", nil, FirstNonNil).run([]interface{}{nil, nil, 3, 4})
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+
+	value, err = ReducePhase("collect", nil, Collect).run([]string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, value)
+}
@@ -0,0 +1,122 @@
+package phaser
+
+import "time"
+
+// PhaseStatus is the outcome of a phase in a run.
+type PhaseStatus string
+
+const (
+	// StatusSucceeded is the status of phases that completed successfully
+	StatusSucceeded PhaseStatus = "succeeded"
+	// StatusFailed is the status of phases that returned an error
+	StatusFailed PhaseStatus = "failed"
+	// StatusSkipped is the status of phases that did not run
+	StatusSkipped PhaseStatus = "skipped"
+	// StatusRejected is the status of phases that rejected their input
+	// using Reject
+	StatusRejected PhaseStatus = "rejected"
+	// StatusQuarantined is the status of non-critical phases skipped because
+	// they are quarantined, as configured by WithQuarantine
+	StatusQuarantined PhaseStatus = "quarantined"
+)
+
+// PhaseResult describes the outcome of a phase in a run.
+type PhaseResult struct {
+	// Phase is the name of the phase
+	Phase string
+	// Status is the outcome of the phase
+	Status PhaseStatus
+	// Err is the error returned by the phase, if any
+	Err error
+	// Cause is the cause of the cancellation that failed the phase, if any,
+	// matching one of the cancellation sentinels such as ErrUserCancelled
+	Cause error
+	// Start is the time the phase started running
+	Start time.Time
+	// Duration is the time the phase took to run, including the time it
+	// was queued
+	Duration time.Duration
+	// Queued is the time the phase spent waiting for concurrency limits
+	Queued time.Duration
+	// Mutations records whether each successful call to the phase's hooks
+	// changed the value, in the order they ran, when the manager uses
+	// WithMutationRecorder
+	Mutations []HookMutation
+	// Warnings contains the warnings returned by the phase's hooks and
+	// execute function through AsWarnings, in order
+	Warnings []error
+	// Case is the key of the case or branch selected by switch phases and
+	// branch points
+	Case string
+	// Deduplicated is set when the phase did not execute, but received the
+	// result of a concurrent execution with the same single flight key
+	Deduplicated bool
+	// Config is the effective configuration of phases overridden using
+	// WithPhaseOverride. It is nil for other phases
+	Config *PhaseConfig
+	// Steps contains the result of each step ran by phases created using
+	// NewSteppedPhase, including the steps of failed attempts
+	Steps []StepResult
+	// Output is the value produced by phases that succeeded or rejected
+	// their input
+	Output interface{}
+	// Retries is the number of times the phase was retried, as allowed by its
+	// RetryPolicy
+	Retries int
+	// RetryDelays contains the delay waited after each retried attempt
+	RetryDelays []RetryDelay
+	// Attempts contains the result of each attempt of phases retried with
+	// the HooksAndExecute scope
+	Attempts []AttemptResult
+	// Artifacts contains the artifacts attached by the phase through
+	// AddArtifact
+	Artifacts Artifacts
+	// Stalled is set when the phase did not call Checkpoint in time, as
+	// configured by WithStallDetection
+	Stalled bool
+	// Resources is the change in the process' resources measured around the
+	// phase in runs using WithResourceAccounting. It is nil for other runs
+	Resources *ResourceUsage
+}
+
+// RunReport describes the outcome of a run.
+type RunReport struct {
+	// Phases contains the result of each phase in the order they ran
+	Phases []PhaseResult
+	// Path contains the names of the phases that ran, in the order they
+	// started, leaving out skipped phases. Phases of branches follow their
+	// branch point, and branch points, switch phases and A/B phases are
+	// recorded along with the key they selected, as in "classify:pdf", so
+	// that the path shows the decisions of the run
+	Path []string
+	// Err is the error returned by the run, if any
+	Err error
+	// Start is the time the run started
+	Start time.Time
+	// Duration is the time the run took
+	Duration time.Duration
+	// Queued is the time the run waited in a Scheduler's queue before
+	// starting, zero for runs not submitted to a Scheduler. It includes the
+	// time waited after yielding
+	Queued time.Duration
+	// Yields contains the yields of runs submitted to a Scheduler to runs
+	// with a higher priority, as described by WithPriority
+	Yields []RunYield
+}
+
+// WithReport fills report with the outcome of the run.
+func WithReport(report *RunReport) RunOption {
+	return func(c *runConfig) {
+		c.report = report
+	}
+}
+
+// Result returns the result of the phase named phase.
+func (r *RunReport) Result(phase string) (PhaseResult, bool) {
+	for _, result := range r.Phases {
+		if result.Phase == phase {
+			return result, true
+		}
+	}
+	return PhaseResult{}, false
+}
@@ -0,0 +1,161 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunReport(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", addOne),
+		NewPhase("three", failWith(assert.AnError)),
+	))
+	m.phases[1].Disabled = true
+
+	var report RunReport
+	_, err := m.Run(0, WithReport(&report))
+	require.Error(t, err)
+
+	assert.Equal(t, err, report.Err)
+	require.Len(t, report.Phases, 3)
+	assert.Equal(t, StatusSucceeded, report.Phases[0].Status)
+	assert.Equal(t, StatusSkipped, report.Phases[1].Status)
+	assert.Equal(t, StatusFailed, report.Phases[2].Status)
+	assert.ErrorIs(t, report.Phases[2].Err, assert.AnError)
+
+	result, ok := report.Result("three")
+	assert.True(t, ok)
+	assert.Equal(t, "three", result.Phase)
+	_, ok = report.Result("missing")
+	assert.False(t, ok)
+}
+
+func TestNonCriticalFailuresWithinBudget(t *testing.T) {
+	m := NewPhaseManager(WithMaxFailures(3))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("analytics", failWith(assert.AnError), WithNonCritical()),
+		NewPhase("two", addOne),
+		NewPhase("cache", failWith(errNotFound), WithNonCritical()),
+		NewPhase("three", addOne),
+	))
+
+	var report RunReport
+	value, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	// Failed non-critical phases pass their input through
+	assert.Equal(t, 3, value)
+
+	result, _ := report.Result("analytics")
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.ErrorIs(t, result.Err, assert.AnError)
+	result, _ = report.Result("cache")
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.ErrorIs(t, result.Err, errNotFound)
+	result, _ = report.Result("three")
+	assert.Equal(t, StatusSucceeded, result.Status)
+}
+
+func TestNonCriticalFailureNotifiesErrorHandlers(t *testing.T) {
+	var handled []error
+	optional := NewPhase("optional", failWith(assert.AnError), WithNonCritical())
+	optional.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		handled = append(handled, err)
+		return false, nil, nil
+	})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		optional,
+		NewPhase("critical", addOne),
+	))
+
+	// The failure reaches the error handlers and the report, but not the
+	// caller, and the last good value is passed on
+	var report RunReport
+	value, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	require.Len(t, handled, 1)
+	assert.ErrorIs(t, handled[0], assert.AnError)
+	result, _ := report.Result("optional")
+	assert.ErrorIs(t, result.Err, assert.AnError)
+	result, _ = report.Result("critical")
+	assert.Equal(t, StatusSucceeded, result.Status)
+}
+
+func TestNonCriticalFailuresExceedBudget(t *testing.T) {
+	errCache := assert.AnError
+	m := NewPhaseManager(WithMaxFailures(2))
+	require.NoError(t, m.AddPhases(
+		NewPhase("analytics", failWith(errNotFound), WithNonCritical()),
+		NewPhase("cache", failWith(errCache), WithNonCritical()),
+		NewPhase("warmup", failWith(errNotFound), WithNonCritical()),
+		NewPhase("one", addOne),
+	))
+
+	var report RunReport
+	_, err := m.Run(0, WithReport(&report))
+	assert.ErrorIs(t, err, ErrFailureBudgetExceeded)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.ErrorIs(t, err, errCache)
+	// The run aborted before the last phase
+	assert.Len(t, report.Phases, 3)
+}
+
+func TestCriticalFailureAbortsImmediately(t *testing.T) {
+	m := NewPhaseManager(WithMaxFailures(5))
+	require.NoError(t, m.AddPhases(
+		NewPhase("critical", failWith(assert.AnError)),
+		NewPhase("one", addOne),
+	))
+
+	var report RunReport
+	_, err := m.Run(0, WithReport(&report))
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NotErrorIs(t, err, ErrFailureBudgetExceeded)
+	assert.Len(t, report.Phases, 1)
+}
+
+func TestContinueOnError(t *testing.T) {
+	var calls [4]int
+	fail := true
+	m := NewPhaseManager()
+	m.ContinueOnError = true
+	require.NoError(t, m.AddPhases(
+		countingPhase("one", &calls[0], &fail),
+		countingPhase("two", &calls[1], nil),
+		NewPhase("three", func(interface{}) (interface{}, error) {
+			calls[2]++
+			return nil, errNotFound
+		}),
+		countingPhase("four", &calls[3], nil),
+	))
+
+	var report RunReport
+	value, err := m.Run(0, WithReport(&report))
+	assert.Equal(t, [4]int{1, 1, 1, 1}, calls)
+	// Failed phases pass the last good value on
+	assert.Equal(t, 2, value)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.ErrorIs(t, err, errNotFound)
+
+	var phaseErr *PhaseError
+	require.ErrorAs(t, err, &phaseErr)
+	assert.Equal(t, "one", phaseErr.Phase)
+	statuses := make([]PhaseStatus, 0, len(report.Phases))
+	for _, result := range report.Phases {
+		statuses = append(statuses, result.Status)
+	}
+	assert.Equal(t, []PhaseStatus{StatusFailed, StatusSucceeded, StatusFailed, StatusSucceeded}, statuses)
+
+	fail = false
+	value, err = m.Run(0)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.NotErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 3, value)
+}
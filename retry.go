@@ -0,0 +1,217 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryScope is the part of a phase retried by its RetryPolicy.
+type RetryScope int
+
+const (
+	// ExecuteOnly retries the execute function alone, so pre-hooks run once
+	// before the first attempt
+	ExecuteOnly RetryScope = iota
+	// HooksAndExecute runs the pre-hooks again on the phase's input before
+	// each attempt, such as to fetch fresh credentials. Failing pre-hooks
+	// consume an attempt. Stepped phases restart from their first step
+	HooksAndExecute
+)
+
+func (s RetryScope) String() string {
+	switch s {
+	case ExecuteOnly:
+		return "execute-only"
+	case HooksAndExecute:
+		return "hooks-and-execute"
+	}
+	return fmt.Sprintf("RetryScope(%d)", int(s))
+}
+
+// RetryPolicy configures how a phase's execute function is retried when it
+// fails. By default, only the execute function is retried: pre-hooks run once
+// before the first attempt and post-hooks once after the successful one.
+// Retries are also limited by the manager's RetryBudget.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of calls to the execute function,
+	// including the first one. Values lower than two disable retries
+	MaxAttempts int
+	// Backoff is the time waited between attempts, unless the error of the
+	// failed attempt hints at a delay through RetryAfter or a
+	// RetryAfterProvider
+	Backoff time.Duration
+	// Scope is the part of the phase retried, ExecuteOnly by default
+	Scope RetryScope
+}
+
+// AttemptResult describes an attempt of a phase retried with the
+// HooksAndExecute scope.
+type AttemptResult struct {
+	// Attempt is the number of the attempt, starting at one
+	Attempt int
+	// PreHooks is the time the attempt spent running the pre-hooks
+	PreHooks time.Duration
+	// Execute is the time the attempt spent running the execute function.
+	// It is zero when a pre-hook failed
+	Execute time.Duration
+	// Err is the error that failed the attempt, if any
+	Err error
+}
+
+// WithRetry sets the phase's Retry policy.
+func WithRetry(policy RetryPolicy) PhaseOption {
+	return func(p *Phase) {
+		p.Retry = &policy
+	}
+}
+
+// executeAttempts runs the phase's execute function on value, retrying it as
+// allowed by the phase's Retry policy. Runs interrupted while waiting between
+// attempts return a *PartialResultError.
+func (p *Phase) executeAttempts(ctx context.Context, value interface{}) (interface{}, error) {
+	// The cursor outlives attempts, so that stepped phases can resume from
+	// their failed step
+	cursor := &stepCursor{}
+	ctx = withStepCursor(ctx, cursor)
+	defer cursor.close()
+
+	if p.retriesHooks() {
+		return p.executeAttempt(ctx, value)
+	}
+	return p.retrying(ctx, value, func(ctx context.Context, attempt int) (interface{}, error) {
+		return p.executeAttempt(ctx, value)
+	})
+}
+
+// hookAttempts runs the phase's pre-hooks and execute function on value,
+// retrying both as allowed by the phase's Retry policy. The stage of the last
+// attempt's failure is returned along with its error, as well as the input of
+// its execute function.
+func (p *Phase) hookAttempts(ctx context.Context, value interface{}) (output, input interface{}, stage Stage, err error) {
+	result := phaseResultFrom(ctx)
+	clock := ClockFrom(ctx)
+	output, err = p.retrying(ctx, value, func(ctx context.Context, attempt int) (interface{}, error) {
+		started := clock.Now()
+		hooked, err := p.processHooksContext(ctx, value, &p.preHooks)
+		attemptResult := AttemptResult{Attempt: attempt, PreHooks: clock.Now().Sub(started), Err: err}
+		if err != nil {
+			stage, input = StagePreHook, hooked
+			result.Attempts = append(result.Attempts, attemptResult)
+			return hooked, err
+		}
+
+		started = clock.Now()
+		stage, input = StageExecute, hooked
+		output, err := p.executeShared(ctx, hooked)
+		attemptResult.Execute, attemptResult.Err = clock.Now().Sub(started), err
+		result.Attempts = append(result.Attempts, attemptResult)
+		return output, err
+	})
+	return output, input, stage, err
+}
+
+// retrying calls attempt until it succeeds, as allowed by the phase's Retry
+// policy. Runs interrupted while waiting between attempts return a
+// *PartialResultError holding value.
+func (p *Phase) retrying(ctx context.Context, value interface{}, try func(ctx context.Context, attempt int) (interface{}, error)) (interface{}, error) {
+	attempts := 1
+	if p.Retry != nil && p.Retry.MaxAttempts > 1 {
+		attempts = p.Retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		output, err := try(ctx, attempt)
+		if err == nil || attempt >= attempts || endsPhase(err) || ctx.Err() != nil {
+			return output, err
+		}
+		state := runStateFrom(ctx)
+		if !state.takeRetry() {
+			state.trace.printf(p.Name, "execute attempt %d failed, retry budget exhausted", attempt)
+			return output, err
+		}
+
+		delay, hinted := p.retryDelay(ctx, err)
+		if hinted {
+			// Waiting past the deadline would only delay the failure
+			if err := errDelayPastDeadline(ctx, delay); err != nil {
+				state.trace.printf(p.Name, "execute attempt %d failed, hinted delay %v ends past the deadline", attempt, delay)
+				return nil, &PartialResultError{LastValue: value, Err: err}
+			}
+		}
+		result := phaseResultFrom(ctx)
+		result.Retries++
+		result.RetryDelays = append(result.RetryDelays, RetryDelay{Attempt: attempt, Delay: delay, Hinted: hinted})
+		state.trace.printf(p.Name, "execute attempt %d failed, retrying in %v", attempt, delay)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, &PartialResultError{LastValue: value, Err: err}
+		}
+	}
+}
+
+// retriesHooks reports whether the phase's pre-hooks are retried along with
+// its execute function.
+func (p *Phase) retriesHooks() bool {
+	return p.Retry != nil && p.Retry.MaxAttempts > 1 && p.Retry.Scope == HooksAndExecute
+}
+
+// executeAttempt calls the phase's execute function on value once, within
+// the phase's RateLimit, Quota and Timeout.
+func (p *Phase) executeAttempt(ctx context.Context, value interface{}) (interface{}, error) {
+	if p.RateLimit != nil {
+		if err := p.RateLimit.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if p.Quota != nil {
+		if !p.Quota.take(ClockFrom(ctx).Now()) {
+			return nil, fmt.Errorf("%w: phase %s executed %d times within %v", ErrQuotaExceeded, p.Name, p.Quota.Limit, p.Quota.Window)
+		}
+	}
+	state := runStateFrom(ctx)
+	state.stats.add(HookKey{Phase: p.Name, Stage: StageExecute})
+
+	// The time queued does not count towards the timeout
+	release, err := p.acquireLimits(ctx, false)
+	if err != nil {
+		return nil, &PartialResultError{LastValue: value, Err: err}
+	}
+	if timeout := p.timeout(ctx); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if !state.trace.traces(p.Name) {
+		output, err := p.executeWithin(ctx, value, release)
+		return output, p.takeWarnings(ctx, err)
+	}
+
+	started := state.trace.started(p.Name, "execute", value)
+	output, err := p.executeWithin(ctx, value, release)
+	state.trace.finished(p.Name, "execute", started, output, err)
+	return output, p.takeWarnings(ctx, err)
+}
+
+// endsPhase reports whether err ends the phase without it failing, so that it
+// must not be retried.
+func endsPhase(err error) bool {
+	var rejection *RejectionError
+	return errors.Is(err, ErrStopPipeline) || errors.As(err, &rejection)
+}
+
+// sleep waits for d according to the run's clock, returning early with the
+// context's error if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	select {
+	case <-ClockFrom(ctx).After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,155 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyPhase returns a phase adding one to its input that fails the first
+// failures times it is executed, counting its executions in calls.
+func flakyPhase(name string, failures int, calls *int, opts ...PhaseOption) *Phase {
+	return NewPhase(name, func(value interface{}) (interface{}, error) {
+		*calls++
+		if *calls <= failures {
+			return nil, assert.AnError
+		}
+		return value.(int) + 1, nil
+	}, opts...)
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	calls, hooks := 0, 0
+	p := flakyPhase("flaky", 2, &calls, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		hooks++
+		return value, nil
+	})
+
+	value, err := p.run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 3, calls)
+	// Only execute is retried
+	assert.Equal(t, 1, hooks)
+}
+
+func TestRetryHooksAndExecute(t *testing.T) {
+	calls := 0
+	var inputs []interface{}
+	p := flakyPhase("flaky", 2, &calls, WithRetry(RetryPolicy{MaxAttempts: 3, Scope: HooksAndExecute}))
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		inputs = append(inputs, value)
+		return value.(int) + 10, nil
+	})
+	posts := 0
+	p.appendPostHook(func(value interface{}) (interface{}, error) {
+		posts++
+		return value, nil
+	})
+
+	var report RunReport
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+	value, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 11, value)
+	assert.Equal(t, 3, calls)
+	// Every attempt runs the pre-hooks on the phase's input
+	assert.Equal(t, []interface{}{0, 0, 0}, inputs)
+	assert.Equal(t, 1, posts)
+
+	attempts := report.Phases[0].Attempts
+	require.Len(t, attempts, 3)
+	for i, attempt := range attempts {
+		assert.Equal(t, i+1, attempt.Attempt)
+	}
+	assert.ErrorIs(t, attempts[0].Err, assert.AnError)
+	assert.NoError(t, attempts[2].Err)
+}
+
+func TestRetryHooksAndExecutePreHookFailure(t *testing.T) {
+	calls, hooks := 0, 0
+	p := flakyPhase("flaky", 0, &calls, WithRetry(RetryPolicy{MaxAttempts: 3, Scope: HooksAndExecute}))
+	p.appendPreHook(func(value interface{}) (interface{}, error) {
+		hooks++
+		if hooks < 3 {
+			return nil, errNotFound
+		}
+		return value, nil
+	})
+
+	// Failing pre-hooks consume attempts
+	var report RunReport
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+	value, err := m.Run(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 3, hooks)
+	assert.Equal(t, 1, calls)
+	attempts := report.Phases[0].Attempts
+	require.Len(t, attempts, 3)
+	assert.ErrorIs(t, attempts[0].Err, errNotFound)
+	assert.Zero(t, attempts[0].Execute)
+
+	// Once exhausted, the pre-hook's failure fails the phase
+	hooks = -10
+	_, err = m.Run(0)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.Equal(t, -7, hooks)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	calls := 0
+	p := flakyPhase("flaky", 5, &calls, WithRetry(RetryPolicy{MaxAttempts: 3}))
+
+	_, err := p.run(0)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryBackoffInterrupted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	calls := 0
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(flakyPhase("flaky", 5, &calls, WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}))))
+
+	_, err := m.RunContext(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var partial *PartialResultError
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, 0, partial.LastValue)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryBudgetCapsRetries(t *testing.T) {
+	var calls [3]int
+	policy := WithRetry(RetryPolicy{MaxAttempts: 10})
+
+	m := NewPhaseManager()
+	m.RetryBudget = 4
+	require.NoError(t, m.AddPhases(
+		flakyPhase("one", 3, &calls[0], policy),
+		flakyPhase("two", 3, &calls[1], policy),
+		flakyPhase("three", 3, &calls[2], policy),
+	))
+
+	// The first phase uses three retries, the second fails after the last one
+	_, err := m.Run(0)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, [3]int{4, 2, 0}, calls)
+
+	// The budget is per run
+	calls = [3]int{}
+	m.RetryBudget = 9
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+	assert.Equal(t, [3]int{4, 4, 4}, calls)
+}
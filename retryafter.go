@@ -0,0 +1,95 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryAfterProvider is implemented by errors knowing when the failed call
+// may be retried, such as errors carrying the Retry-After header of an HTTP
+// response. Phases retried by their RetryPolicy wait for the hinted delay
+// instead of their Backoff.
+type RetryAfterProvider interface {
+	// RetryAfter returns the delay to wait before the next attempt, and
+	// false when the error has no hint
+	RetryAfter() (time.Duration, bool)
+}
+
+// RetryAfter returns an error wrapping err hinting that the failed call may be
+// retried after d. It returns nil when err is nil.
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, after: d}
+}
+
+// retryAfterError is the error returned by RetryAfter.
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+func (e *retryAfterError) RetryAfter() (time.Duration, bool) {
+	return e.after, true
+}
+
+// WithMaxRetryDelay caps the delays hinted by the errors of failed attempts,
+// through RetryAfter or a RetryAfterProvider, to d. Hinted delays are not
+// capped by default.
+func WithMaxRetryDelay(d time.Duration) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.maxRetryDelay = d
+	}
+}
+
+// RetryDelay describes the wait between two attempts of a phase.
+type RetryDelay struct {
+	// Attempt is the number of the failed attempt, starting at one
+	Attempt int
+	// Delay is the time waited before the next attempt
+	Delay time.Duration
+	// Hinted is set when the delay was hinted by the attempt's error instead
+	// of being the Backoff of the phase's RetryPolicy
+	Hinted bool
+}
+
+// retryDelay returns the delay to wait after an attempt failing with err, and
+// whether it was hinted by err.
+func (p *Phase) retryDelay(ctx context.Context, err error) (time.Duration, bool) {
+	var provider RetryAfterProvider
+	if !errors.As(err, &provider) {
+		return p.Retry.Backoff, false
+	}
+	d, ok := provider.RetryAfter()
+	if !ok {
+		return p.Retry.Backoff, false
+	}
+	if max := runStateFrom(ctx).maxRetryDelay; max > 0 && d > max {
+		d = max
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// errDelayPastDeadline is returned when a hinted delay ends past the deadline
+// of the run's context, wrapping context.DeadlineExceeded.
+func errDelayPastDeadline(ctx context.Context, d time.Duration) error {
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) >= d {
+		return nil
+	}
+	return fmt.Errorf("retry delay of %v ends past the deadline: %w", d, context.DeadlineExceeded)
+}
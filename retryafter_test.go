@@ -0,0 +1,114 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// throttledError is a RetryAfterProvider hinting at after.
+type throttledError struct {
+	after time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return "throttled"
+}
+
+func (e *throttledError) RetryAfter() (time.Duration, bool) {
+	return e.after, e.after > 0
+}
+
+// hintedRun runs a phase failing once with err, retried with a one second
+// backoff, using a fake clock and opts. It returns the run's report.
+func hintedRun(t *testing.T, err error, opts ...ManagerOption) RunReport {
+	calls := 0
+	p := NewPhase("throttled", func(value interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, err
+		}
+		return value, nil
+	}, WithRetry(RetryPolicy{MaxAttempts: 2, Backoff: time.Second}))
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewPhaseManager(append(opts, WithClock(clock))...)
+	require.NoError(t, m.AddPhase(p))
+
+	var report RunReport
+	_, runErr := m.Run(0, WithReport(&report))
+	require.NoError(t, runErr)
+	assert.Equal(t, 2, calls)
+	return report
+}
+
+func TestRetryAfter(t *testing.T) {
+	for name, test := range map[string]struct {
+		err      error
+		opts     []ManagerOption
+		expected RetryDelay
+	}{
+		"hinted": {
+			err:      RetryAfter(errNotFound, 5*time.Second),
+			expected: RetryDelay{Attempt: 1, Delay: 5 * time.Second, Hinted: true},
+		},
+		"provider": {
+			err:      fmt.Errorf("calling upstream: %w", &throttledError{after: 3 * time.Second}),
+			expected: RetryDelay{Attempt: 1, Delay: 3 * time.Second, Hinted: true},
+		},
+		"capped": {
+			err:      RetryAfter(errNotFound, time.Hour),
+			opts:     []ManagerOption{WithMaxRetryDelay(2 * time.Second)},
+			expected: RetryDelay{Attempt: 1, Delay: 2 * time.Second, Hinted: true},
+		},
+		"absent": {
+			err:      errNotFound,
+			expected: RetryDelay{Attempt: 1, Delay: time.Second},
+		},
+		"provider without hint": {
+			err:      &throttledError{},
+			expected: RetryDelay{Attempt: 1, Delay: time.Second},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			report := hintedRun(t, test.err, test.opts...)
+			assert.Equal(t, []RetryDelay{test.expected}, report.Phases[0].RetryDelays)
+			assert.Equal(t, test.expected.Delay, report.Duration)
+		})
+	}
+}
+
+func TestRetryAfterWrapsError(t *testing.T) {
+	err := RetryAfter(errNotFound, time.Second)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.EqualError(t, err, errNotFound.Error())
+	assert.NoError(t, RetryAfter(nil, time.Second))
+}
+
+func TestRetryAfterPastDeadline(t *testing.T) {
+	calls := 0
+	p := NewPhase("throttled", func(value interface{}) (interface{}, error) {
+		calls++
+		return nil, RetryAfter(errNotFound, time.Hour)
+	}, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewPhaseManager(WithClock(clock))
+	require.NoError(t, m.AddPhase(p))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	started := time.Now()
+	_, err := m.RunContext(ctx, 0)
+
+	// The run failed at once instead of waiting for the deadline
+	assert.Less(t, time.Since(started), 10*time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "retry delay of 1h0m0s ends past the deadline")
+	var partial *PartialResultError
+	assert.True(t, errors.As(err, &partial))
+	assert.Equal(t, 1, calls)
+}
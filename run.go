@@ -0,0 +1,164 @@
+package phaser
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// runState contains the state shared by every phase of a single run.
+type runState struct {
+	// strict enables the StrictMode checks
+	strict bool
+	// report is filled with the outcome of each phase when set
+	report *RunReport
+	// trace is the run's debug trace, nil when not enabled
+	trace *runTrace
+	// stats counts the invocations of hooks when set
+	stats *hookStats
+	// observers are notified of the progress of the run
+	observers []Observer
+	// limit is the manager's concurrency limit when set
+	limit *concurrencyLimit
+	// retryBudget is the number of retries allowed in the run. Zero disables
+	// the budget
+	retryBudget int
+	// retries counts the retries made in the run
+	retries int64
+	// maxRetryDelay caps the delays hinted by errors when positive
+	maxRetryDelay time.Duration
+	// warnings collects the warnings reported during the run when set
+	warnings *warningCollector
+	// audit writes the audit records of the run's phases when set
+	audit *auditLog
+	// overrides contains the configuration overrides of the run's phases by
+	// phase name
+	overrides map[string][]PhaseOverride
+	// outputs contains the outputs of the completed phases by phase name
+	// when the pipeline has phases with dependencies
+	outputs map[string]interface{}
+	// defaultTimeout is the timeout of the phases without one
+	defaultTimeout time.Duration
+	// stepper is the manager's stepper when it uses WithStepping
+	stepper *stepper
+	// heartbeats records the heartbeats of the manager's phases
+	heartbeats *heartbeats
+	// watchdog detects the stalled phases of the run when set
+	watchdog *watchdog
+	// tracer starts the spans of the run's phases when set
+	tracer Tracer
+	// artifacts collects the artifacts of the run's phases
+	artifacts *artifactStore
+	// capture captures the values of the run's phases when it is sampled
+	capture func(phase string, stage Stage, value interface{})
+	// failures contains the errors of the failed non-critical phases
+	failures []error
+	// clock is the source of time of the run when set
+	clock Clock
+	// yield makes the run yield between the phases of top when set and
+	// returning true
+	yield func() bool
+	// top is the manager whose phases may yield, leaving out nested
+	// pipelines such as branches
+	top *DefaultPhaseManager
+	// lifecycle tracks the executions abandoned by the run when set
+	lifecycle *lifecycle
+	// flags gates the phases with a FeatureFlag when set
+	flags FlagProvider
+	// panicUnimplemented makes the run panic on phases without an execute
+	// function instead of failing
+	panicUnimplemented bool
+	// mutations records which hooks changed the value when set
+	mutations *mutationRecorder
+	// accounting measures the resources used by the phases when set
+	accounting *resourceAccounting
+	// invariants checks the invariants of the run after each phase when set
+	invariants *invariantChecker
+	// captured captures the values of the phases when set
+	captured *CapturedRun
+	// contracts handles the contract hooks of the run when set
+	contracts *contractConfig
+	// ownership checks the changes phases make to the value when set
+	ownership *ownershipChecker
+	// journal journals the transitions of the run when set
+	journal *runJournal
+}
+
+// start notifies the run's observers that the phase named phase started
+// with value.
+func (s *runState) start(phase string, value interface{}) {
+	if s.report != nil {
+		s.report.Path = append(s.report.Path, phase)
+	}
+	s.invariants.started(phase, value)
+	s.journal.phaseStarted(phase)
+	for _, o := range s.observers {
+		o.OnPhaseStart(phase, value)
+	}
+}
+
+// decided records key as the key selected by the running phase named phase
+// in the path of the run's report.
+func (s *runState) decided(phase, key string) {
+	if s.report == nil {
+		return
+	}
+	if n := len(s.report.Path); n > 0 && s.report.Path[n-1] == phase {
+		s.report.Path[n-1] = phase + ":" + key
+	}
+}
+
+// record adds the outcome of a phase to the run's report and notifies the
+// run's observers.
+func (s *runState) record(result PhaseResult) {
+	if s.report != nil {
+		s.report.Phases = append(s.report.Phases, result)
+	}
+	s.invariants.ended(result)
+	s.journal.phaseFinished(result)
+	for _, o := range s.observers {
+		o.OnPhaseEnd(result)
+	}
+}
+
+// takeRetry reports whether the retry budget allows another retry, and counts
+// it if so.
+func (s *runState) takeRetry() bool {
+	retries := atomic.AddInt64(&s.retries, 1)
+	return s.retryBudget <= 0 || retries <= int64(s.retryBudget)
+}
+
+// runStateKey is the context key of the run state.
+type runStateKey struct{}
+
+// withRunState returns a copy of ctx carrying state.
+func withRunState(ctx context.Context, state *runState) context.Context {
+	return context.WithValue(ctx, runStateKey{}, state)
+}
+
+// runStateFrom returns the run state stored in ctx. Phases ran outside of a
+// manager get an empty state.
+func runStateFrom(ctx context.Context) *runState {
+	if state, ok := ctx.Value(runStateKey{}).(*runState); ok {
+		return state
+	}
+	return &runState{}
+}
+
+// phaseResultKey is the context key of the result of the running phase.
+type phaseResultKey struct{}
+
+// withPhaseResult returns a copy of ctx carrying result, the result of the
+// phase running with the returned context.
+func withPhaseResult(ctx context.Context, result *PhaseResult) context.Context {
+	return context.WithValue(ctx, phaseResultKey{}, result)
+}
+
+// phaseResultFrom returns the result of the running phase stored in ctx.
+// Phases ran outside of a manager get a result that is discarded.
+func phaseResultFrom(ctx context.Context) *PhaseResult {
+	if result, ok := ctx.Value(phaseResultKey{}).(*PhaseResult); ok {
+		return result
+	}
+	return &PhaseResult{}
+}
@@ -0,0 +1,110 @@
+package phaser
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// WithValueSampling captures the values flowing through the phases of a
+// fraction rate of the runs, clamped to between 0 and 1. Runs are sampled as a
+// whole when they start, so that sampled runs capture every phase while the
+// others only pay for the sampling decision.
+//
+// capture is called with the input of each phase as it enters its pre-hooks,
+// with StagePreHook, and with the output of each phase that succeeded as it
+// leaves its post-hooks, with StagePostHook. It is called synchronously, and
+// concurrently by concurrent runs. JSONCapture returns a capture function
+// writing the values to an io.Writer.
+func WithValueSampling(rate float64, capture func(phase string, stage Stage, value interface{})) ManagerOption {
+	switch {
+	case math.IsNaN(rate) || rate < 0:
+		rate = 0
+	case rate > 1:
+		rate = 1
+	}
+	return func(m *DefaultPhaseManager) {
+		m.sampling = &valueSampling{rate: rate, capture: capture}
+	}
+}
+
+// valueSampling decides which runs capture their values. A nil
+// *valueSampling samples no run.
+type valueSampling struct {
+	rate    float64
+	capture func(phase string, stage Stage, value interface{})
+}
+
+// sample returns the capture function of a new run, nil when it is not
+// sampled.
+func (s *valueSampling) sample() func(phase string, stage Stage, value interface{}) {
+	if s == nil || rand.Float64() >= s.rate {
+		return nil
+	}
+	return s.capture
+}
+
+// CaptureOption configures the capture function returned by JSONCapture.
+type CaptureOption func(c *jsonCapture)
+
+// Redact sets a function redacting the captured values before they are
+// encoded, for example by replacing sensitive fields. It must not modify value
+// in place, as value is used by the run.
+func Redact(redact func(value interface{}) interface{}) CaptureOption {
+	return func(c *jsonCapture) {
+		c.redact = redact
+	}
+}
+
+// CapturedValue is a value captured by the function returned by JSONCapture.
+type CapturedValue struct {
+	Phase string          `json:"phase"`
+	Stage Stage           `json:"stage"`
+	Value json.RawMessage `json:"value,omitempty"`
+	// MarshalError describes why the value could not be encoded, in which
+	// case Value is empty
+	MarshalError string `json:"marshalError,omitempty"`
+}
+
+// JSONCapture returns a capture function for WithValueSampling writing each
+// value to w as a JSON encoded CapturedValue, followed by a newline. Errors
+// writing to w are dropped, as they must not fail the run.
+func JSONCapture(w io.Writer, opts ...CaptureOption) func(phase string, stage Stage, value interface{}) {
+	c := &jsonCapture{w: w}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c.capture
+}
+
+// jsonCapture writes captured values to w.
+type jsonCapture struct {
+	// mu serializes the writes to w
+	mu     sync.Mutex
+	w      io.Writer
+	redact func(value interface{}) interface{}
+}
+
+// capture writes value, the value of phase at stage, to c.w.
+func (c *jsonCapture) capture(phase string, stage Stage, value interface{}) {
+	if c.redact != nil {
+		value = c.redact(value)
+	}
+	captured := CapturedValue{Phase: phase, Stage: stage}
+	data, err := json.Marshal(value)
+	if err != nil {
+		captured.MarshalError = err.Error()
+	} else {
+		captured.Value = data
+	}
+	line, err := json.Marshal(captured)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.w.Write(append(line, '\n'))
+}
@@ -0,0 +1,95 @@
+package phaser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturedValue is a value passed to a capture function.
+type capturedValue struct {
+	phase string
+	stage Stage
+	value interface{}
+}
+
+func TestValueSamplingCapturesRuns(t *testing.T) {
+	var captured []capturedValue
+	m := NewPhaseManager(WithValueSampling(1, func(phase string, stage Stage, value interface{}) {
+		captured = append(captured, capturedValue{phase, stage, value})
+	}))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", addOne),
+		NewPhase("three", failWith(assert.AnError)),
+	))
+
+	_, err := m.Run(0)
+	require.Error(t, err)
+	assert.Equal(t, []capturedValue{
+		{"one", StagePreHook, 0},
+		{"one", StagePostHook, 1},
+		{"two", StagePreHook, 1},
+		{"two", StagePostHook, 2},
+		{"three", StagePreHook, 2},
+	}, captured)
+}
+
+func TestValueSamplingDisabled(t *testing.T) {
+	for _, rate := range []float64{0, -1} {
+		calls := 0
+		m := NewPhaseManager(WithValueSampling(rate, func(phase string, stage Stage, value interface{}) {
+			calls++
+		}))
+		require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+
+		for i := 0; i < 100; i++ {
+			_, err := m.Run(i)
+			require.NoError(t, err)
+		}
+		assert.Zero(t, calls)
+	}
+}
+
+func TestJSONCapture(t *testing.T) {
+	type user struct {
+		Name     string
+		Password string
+	}
+	var buf bytes.Buffer
+	m := NewPhaseManager(WithValueSampling(2, JSONCapture(&buf, Redact(func(value interface{}) interface{} {
+		if u, ok := value.(user); ok {
+			u.Password = "redacted"
+			return u
+		}
+		return value
+	}))))
+	require.NoError(t, m.AddPhases(
+		NewPhase("login", func(value interface{}) (interface{}, error) {
+			return user{Name: value.(string), Password: "secret"}, nil
+		}),
+		NewPhase("session", func(value interface{}) (interface{}, error) {
+			return func() {}, nil
+		}),
+	))
+
+	_, err := m.Run("alice")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	var values []CapturedValue
+	for _, line := range lines {
+		var value CapturedValue
+		require.NoError(t, json.Unmarshal([]byte(line), &value))
+		values = append(values, value)
+	}
+	assert.Equal(t, CapturedValue{Phase: "login", Stage: StagePreHook, Value: json.RawMessage(`"alice"`)}, values[0])
+	assert.JSONEq(t, `{"Name":"alice","Password":"redacted"}`, string(values[1].Value))
+	assert.Equal(t, StagePostHook, values[3].Stage)
+	assert.Empty(t, values[3].Value)
+	assert.Contains(t, values[3].MarshalError, "unsupported type")
+}
@@ -0,0 +1,417 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned by runs submitted to a full Scheduler using
+	// the DropNewest policy
+	ErrQueueFull = errors.New("scheduler queue full")
+	// ErrRunShed is returned by queued runs shed to make room for newer runs
+	// by a Scheduler using the ShedOldest policy
+	ErrRunShed = errors.New("run shed from scheduler queue")
+	// ErrSchedulerClosed is returned by runs submitted to a Scheduler after
+	// Drain was called
+	ErrSchedulerClosed = errors.New("scheduler closed")
+)
+
+// RejectionPolicy decides what happens to the runs submitted to a Scheduler
+// whose queue is full.
+type RejectionPolicy int
+
+const (
+	// Block makes Submit wait until the queue has room
+	Block RejectionPolicy = iota
+	// DropNewest fails the submitted run with ErrQueueFull
+	DropNewest
+	// ShedOldest fails the run queued the longest with ErrRunShed, and
+	// queues the submitted run in its place
+	ShedOldest
+)
+
+// SchedulerOption configures a Scheduler.
+type SchedulerOption func(s *Scheduler)
+
+// WithWorkers sets the number of runs the scheduler runs concurrently, which
+// defaults to one.
+func WithWorkers(n int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.workers = n
+	}
+}
+
+// WithQueueSize bounds the number of runs waiting in the queue of the
+// scheduler to n, applying policy to the runs submitted while it is full.
+// Non-positive values leave the queue unbounded.
+func WithQueueSize(n int, policy RejectionPolicy) SchedulerOption {
+	return func(s *Scheduler) {
+		s.queueSize, s.policy = n, policy
+	}
+}
+
+// WithPipelineWeight sets the weight of the pipeline of m, which defaults to
+// one. While several pipelines have queued runs, each pipeline gets a share
+// of the started runs proportional to its weight. It panics when weight is
+// not a positive finite number, as such weights would starve the pipeline or
+// let it monopolize the workers.
+func WithPipelineWeight(m *DefaultPhaseManager, weight float64) SchedulerOption {
+	if !(weight > 0) || math.IsInf(weight, 1) {
+		panic(fmt.Sprintf("phaser: invalid pipeline weight %v", weight))
+	}
+	return func(s *Scheduler) {
+		s.queue(m).weight = weight
+	}
+}
+
+// WithSchedulerClock sets the clock measuring the time runs wait in the
+// queue, which defaults to the system time. The runs use the clocks of their
+// managers.
+func WithSchedulerClock(clock Clock) SchedulerOption {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// SubmitOption configures a run submitted to a Scheduler.
+type SubmitOption func(sub *submission)
+
+// WithSubmitRunOptions runs the submitted run with opts.
+func WithSubmitRunOptions(opts ...RunOption) SubmitOption {
+	return func(sub *submission) {
+		sub.opts = append(sub.opts, opts...)
+	}
+}
+
+// Scheduler runs the runs submitted for several pipelines on a shared pool of
+// workers, dequeuing them fairly according to the weights of their pipelines,
+// so that bursts of runs of a pipeline do not starve the others. Runs with a
+// higher priority are dequeued first, and make the runs with a lower priority
+// yield between their phases, as described by WithPriority.
+type Scheduler struct {
+	workers   int
+	queueSize int
+	policy    RejectionPolicy
+	// maxYields is the maximum number of times a run yields. Negative values
+	// do not limit yields
+	maxYields int
+	// clock is the source of time of the queue. The system time is used
+	// when nil
+	clock Clock
+	// ctx is the context of the runs, cancelled with the ErrInterrupted
+	// cause when draining times out
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// changed is signaled when runs are queued or dequeued, and when the
+	// scheduler is closed
+	changed *sync.Cond
+	// queues contains the queue of each pipeline, in order of first use
+	queues []*pipelineQueue
+	// queued is the number of runs in the queues
+	queued int
+	// idle is the number of workers waiting for runs
+	idle int
+	// seq numbers the submitted runs in order
+	seq uint64
+	// pass is the pass of the last dequeued run
+	pass   float64
+	closed bool
+}
+
+// pipelineQueue contains the queued runs of a pipeline.
+type pipelineQueue struct {
+	manager *DefaultPhaseManager
+	weight  float64
+	// pass grows by the inverse of weight with every dequeued run, and the
+	// queue with the lowest pass is dequeued first
+	pass float64
+	runs []*submission
+}
+
+// submission is a run submitted to a Scheduler.
+type submission struct {
+	value     interface{}
+	opts      []RunOption
+	seq       uint64
+	submitted time.Time
+	handle    *RunHandle
+	priority  int
+	// yielded is the last yield of the run, which continues from it when set
+	yielded *yieldError
+}
+
+// RunHandle is the handle of a run submitted to a Scheduler, or fired on a
+// Trigger.
+type RunHandle struct {
+	done   chan struct{}
+	value  interface{}
+	err    error
+	report RunReport
+
+	mu sync.Mutex
+	// cancelled is set once Cancel is called
+	cancelled bool
+	// cancel cancels the context of the run once it started
+	cancel context.CancelCauseFunc
+}
+
+// Cancel cancels the run with the ErrUserCancelled cause. Queued runs fail
+// without running.
+func (h *RunHandle) Cancel() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cancelled = true
+	if h.cancel != nil {
+		h.cancel(ErrUserCancelled)
+	}
+}
+
+// start returns the context of the run derived from ctx, or false if the run
+// was cancelled before starting.
+func (h *RunHandle) start(ctx context.Context) (context.Context, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cancelled {
+		return nil, false
+	}
+	ctx, h.cancel = context.WithCancelCause(ctx)
+	return ctx, true
+}
+
+// Done returns a channel closed once the run ends.
+func (h *RunHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait waits for the run to end, and returns its output and error.
+func (h *RunHandle) Wait() (interface{}, error) {
+	<-h.done
+	return h.value, h.err
+}
+
+// Report waits for the run to end, and returns its report. Its Queued field
+// holds the time the run waited in the scheduler's queue.
+func (h *RunHandle) Report() RunReport {
+	<-h.done
+	return h.report
+}
+
+// finish ends the run with value and err.
+func (h *RunHandle) finish(value interface{}, err error) {
+	h.value, h.err = value, err
+	if h.report.Err == nil {
+		h.report.Err = err
+	}
+	close(h.done)
+}
+
+// NewScheduler returns a Scheduler configured with opts, and starts its
+// workers.
+func NewScheduler(opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{workers: 1, maxYields: -1}
+	s.changed = sync.NewCond(&s.mu)
+	s.ctx, s.cancel = context.WithCancelCause(context.Background())
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.workers < 1 {
+		s.workers = 1
+	}
+	s.wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// Submit queues a run of the pipeline of m on value, returning its handle.
+// Runs submitted while the queue is full are handled according to the
+// scheduler's RejectionPolicy.
+func (s *Scheduler) Submit(m *DefaultPhaseManager, value interface{}, opts ...SubmitOption) *RunHandle {
+	sub := &submission{value: value, handle: &RunHandle{done: make(chan struct{})}}
+	for _, opt := range opts {
+		opt(sub)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.queueSize > 0 && s.queued >= s.queueSize && !s.closed {
+		switch s.policy {
+		case DropNewest:
+			sub.handle.finish(value, ErrQueueFull)
+			return sub.handle
+		case ShedOldest:
+			s.shedOldest()
+		default:
+			s.changed.Wait()
+		}
+	}
+	if s.closed {
+		sub.handle.finish(value, ErrSchedulerClosed)
+		return sub.handle
+	}
+
+	s.seq++
+	sub.seq = s.seq
+	s.enqueue(s.queue(m), sub)
+	return sub.handle
+}
+
+// enqueue adds sub to q, after the runs with the same or a higher priority.
+// Runs resuming after yielding go before the runs with the same priority.
+func (s *Scheduler) enqueue(q *pipelineQueue, sub *submission) {
+	if len(q.runs) == 0 && q.pass < s.pass {
+		// Idle pipelines do not accumulate a share to catch up on
+		q.pass = s.pass
+	}
+	i := len(q.runs)
+	for i > 0 && (q.runs[i-1].priority < sub.priority || sub.yielded != nil && q.runs[i-1].priority == sub.priority) {
+		i--
+	}
+	q.runs = append(q.runs, nil)
+	copy(q.runs[i+1:], q.runs[i:])
+	q.runs[i] = sub
+
+	sub.submitted = s.now()
+	s.queued++
+	s.changed.Broadcast()
+}
+
+// Drain stops accepting runs, and waits for the queued and running runs to
+// end. When ctx is done first, the running runs are cancelled and the queued
+// runs fail, both with the ErrInterrupted cause, and the context's error is
+// returned.
+func (s *Scheduler) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.changed.Broadcast()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.cancel(nil)
+		return nil
+	case <-ctx.Done():
+		s.cancel(ErrInterrupted)
+		s.mu.Lock()
+		for _, q := range s.queues {
+			for _, sub := range q.runs {
+				sub.handle.finish(sub.value, &CancelError{Cause: ErrInterrupted, Err: ctx.Err()})
+			}
+			q.runs = nil
+		}
+		s.queued = 0
+		s.changed.Broadcast()
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// work runs the queued runs until the scheduler is closed and its queue is
+// empty.
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+	for {
+		s.mu.Lock()
+		s.idle++
+		for s.queued == 0 && !s.closed {
+			s.changed.Wait()
+		}
+		s.idle--
+		if s.queued == 0 {
+			s.mu.Unlock()
+			return
+		}
+		q, sub := s.dequeue()
+		s.mu.Unlock()
+
+		queued := s.now().Sub(sub.submitted)
+		ctx, ok := sub.handle.start(s.ctx)
+		if !ok {
+			sub.handle.finish(sub.value, &CancelError{Cause: ErrUserCancelled, Err: context.Canceled})
+			continue
+		}
+		value, err := s.run(ctx, q.manager, sub, queued)
+		sub.handle.cancel(nil)
+
+		var yielded *yieldError
+		if errors.As(err, &yielded) {
+			s.mu.Lock()
+			sub.yielded = yielded
+			s.enqueue(q, sub)
+			s.mu.Unlock()
+			continue
+		}
+		sub.handle.finish(value, err)
+	}
+}
+
+// dequeue removes the next run to start from the queues, from the queue with
+// the highest priority run, the one with the lowest pass among them, and
+// returns it along with its queue.
+func (s *Scheduler) dequeue() (*pipelineQueue, *submission) {
+	var next *pipelineQueue
+	for _, q := range s.queues {
+		if len(q.runs) == 0 {
+			continue
+		}
+		if next == nil || q.runs[0].priority > next.runs[0].priority ||
+			q.runs[0].priority == next.runs[0].priority && q.pass < next.pass {
+			next = q
+		}
+	}
+	sub := next.runs[0]
+	next.runs = next.runs[1:]
+	s.pass = next.pass
+	next.pass += 1 / next.weight
+	s.queued--
+	s.changed.Broadcast()
+	return next, sub
+}
+
+// shedOldest fails the run queued the longest with ErrRunShed.
+func (s *Scheduler) shedOldest() {
+	var oldest *pipelineQueue
+	for _, q := range s.queues {
+		if len(q.runs) > 0 && (oldest == nil || q.runs[0].seq < oldest.runs[0].seq) {
+			oldest = q
+		}
+	}
+	sub := oldest.runs[0]
+	oldest.runs = oldest.runs[1:]
+	s.queued--
+	sub.handle.finish(sub.value, ErrRunShed)
+}
+
+// queue returns the queue of the pipeline of m, adding it if needed.
+func (s *Scheduler) queue(m *DefaultPhaseManager) *pipelineQueue {
+	for _, q := range s.queues {
+		if q.manager == m {
+			return q
+		}
+	}
+	q := &pipelineQueue{manager: m, weight: 1, pass: s.pass}
+	s.queues = append(s.queues, q)
+	return q
+}
+
+// now returns the current time according to the scheduler's clock.
+func (s *Scheduler) now() time.Time {
+	if s.clock != nil {
+		return s.clock.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,205 @@
+package phaser
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gateManager returns a manager whose runs signal started, then block until
+// release is closed or their context is done.
+func gateManager(t *testing.T, started chan<- struct{}, release <-chan struct{}) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhaseContext("gate", func(ctx context.Context, value interface{}) (interface{}, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return value, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})))
+	return m
+}
+
+// orderManager returns a manager appending name to order on every run.
+func orderManager(t *testing.T, name string, mu *sync.Mutex, order *[]string) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("record", func(value interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		*order = append(*order, name)
+		return value, nil
+	})))
+	return m
+}
+
+func TestSchedulerRejectsInvalidWeights(t *testing.T) {
+	m := NewPhaseManager()
+	for _, weight := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		assert.Panics(t, func() { WithPipelineWeight(m, weight) }, weight)
+	}
+	assert.NotPanics(t, func() { WithPipelineWeight(m, 0.5) })
+}
+
+func TestSchedulerWeightedFairness(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	gate := gateManager(t, started, release)
+	a := orderManager(t, "a", &mu, &order)
+	b := orderManager(t, "b", &mu, &order)
+
+	s := NewScheduler(WithPipelineWeight(a, 3), WithPipelineWeight(b, 1))
+	s.Submit(gate, nil)
+	<-started
+	// Both pipelines queue the same volume while the worker is busy
+	var handles []*RunHandle
+	for i := 0; i < 8; i++ {
+		handles = append(handles, s.Submit(a, i), s.Submit(b, i))
+	}
+	close(release)
+	for _, h := range handles {
+		_, err := h.Wait()
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.Drain(context.Background()))
+
+	// While both have queued runs, a gets three runs for each run of b, and b
+	// gets the worker to itself once a runs out
+	count := map[string]int{}
+	for _, name := range order[:8] {
+		count[name]++
+	}
+	assert.Equal(t, map[string]int{"a": 6, "b": 2}, count)
+	assert.Equal(t, []string{"b", "b", "b", "b", "b"}, order[11:])
+}
+
+func TestSchedulerRejectionPolicies(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+
+	t.Run("drop newest", func(t *testing.T) {
+		started, release := make(chan struct{}, 1), make(chan struct{})
+		m := orderManager(t, "run", &mu, &order)
+		s := NewScheduler(WithQueueSize(1, DropNewest))
+		s.Submit(gateManager(t, started, release), nil)
+		<-started
+
+		queued, dropped := s.Submit(m, 1), s.Submit(m, 2)
+		_, err := dropped.Wait()
+		assert.ErrorIs(t, err, ErrQueueFull)
+		close(release)
+		value, err := queued.Wait()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, value)
+		require.NoError(t, s.Drain(context.Background()))
+	})
+
+	t.Run("shed oldest", func(t *testing.T) {
+		started, release := make(chan struct{}, 1), make(chan struct{})
+		m := orderManager(t, "run", &mu, &order)
+		s := NewScheduler(WithQueueSize(1, ShedOldest))
+		s.Submit(gateManager(t, started, release), nil)
+		<-started
+
+		shed, queued := s.Submit(m, 1), s.Submit(m, 2)
+		_, err := shed.Wait()
+		assert.ErrorIs(t, err, ErrRunShed)
+		close(release)
+		value, err := queued.Wait()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, value)
+		require.NoError(t, s.Drain(context.Background()))
+	})
+
+	t.Run("block", func(t *testing.T) {
+		started, release := make(chan struct{}, 1), make(chan struct{})
+		m := orderManager(t, "run", &mu, &order)
+		s := NewScheduler(WithQueueSize(1, Block))
+		s.Submit(gateManager(t, started, release), nil)
+		<-started
+		s.Submit(m, 1)
+
+		submitted := make(chan *RunHandle)
+		go func() {
+			submitted <- s.Submit(m, 2)
+		}()
+		select {
+		case <-submitted:
+			t.Fatal("submitted to a full queue")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		value, err := (<-submitted).Wait()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, value)
+		require.NoError(t, s.Drain(context.Background()))
+	})
+}
+
+func TestSchedulerQueueWait(t *testing.T) {
+	clock := &syncClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	s := NewScheduler(WithSchedulerClock(clock))
+	gate := s.Submit(gateManager(t, started, release), nil)
+	<-started
+	h := s.Submit(orderManager(t, "run", &mu, &order), 1)
+	clock.advance(5 * time.Second)
+	close(release)
+
+	report := h.Report()
+	assert.NoError(t, report.Err)
+	assert.Equal(t, 5*time.Second, report.Queued)
+	assert.Len(t, report.Phases, 1)
+	assert.Zero(t, gate.Report().Queued)
+	require.NoError(t, s.Drain(context.Background()))
+}
+
+func TestSchedulerDrain(t *testing.T) {
+	t.Run("completes queued runs", func(t *testing.T) {
+		var mu sync.Mutex
+		var order []string
+		m := orderManager(t, "run", &mu, &order)
+		s := NewScheduler(WithWorkers(2))
+		var handles []*RunHandle
+		for i := 0; i < 5; i++ {
+			handles = append(handles, s.Submit(m, i))
+		}
+		require.NoError(t, s.Drain(context.Background()))
+		for _, h := range handles {
+			_, err := h.Wait()
+			assert.NoError(t, err)
+		}
+		assert.Len(t, order, 5)
+
+		_, err := s.Submit(m, 5).Wait()
+		assert.ErrorIs(t, err, ErrSchedulerClosed)
+	})
+
+	t.Run("deadline cancels runs", func(t *testing.T) {
+		started := make(chan struct{}, 1)
+		var mu sync.Mutex
+		var order []string
+		s := NewScheduler()
+		running := s.Submit(gateManager(t, started, nil), nil)
+		<-started
+		queued := s.Submit(orderManager(t, "run", &mu, &order), 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, s.Drain(ctx), context.DeadlineExceeded)
+		_, err := running.Wait()
+		assert.Error(t, err)
+		_, err = queued.Wait()
+		assert.Error(t, err)
+		assert.Empty(t, order)
+	})
+}
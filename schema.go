@@ -0,0 +1,144 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// StageInputValidation is the stage checking the phase's input using the
+	// validator set by WithInputSchema
+	StageInputValidation Stage = "input-validation"
+	// StageOutputValidation is the stage checking the phase's output using
+	// the validator set by WithOutputSchema
+	StageOutputValidation Stage = "output-validation"
+)
+
+// WithInputSchema checks the input of the phase using validator before its
+// pre-hooks run. Invalid inputs fail the phase with a *PhaseError whose Stage
+// is StageInputValidation, wrapping ErrInvalidInput.
+func WithInputSchema(validator Validator) PhaseOption {
+	return func(p *Phase) {
+		p.inputSchema = validator
+	}
+}
+
+// WithOutputSchema checks the output of the phase using validator after its
+// post-hooks run. Invalid outputs fail the phase with a *PhaseError whose
+// Stage is StageOutputValidation, wrapping ErrInvalidOutput.
+func WithOutputSchema(validator Validator) PhaseOption {
+	return func(p *Phase) {
+		p.outputSchema = validator
+	}
+}
+
+// TypeOf returns a Validator checking that values are of type T.
+func TypeOf[T any]() Validator {
+	return func(value interface{}) error {
+		if _, ok := value.(T); !ok {
+			return fmt.Errorf("expected %v, got %T", reflect.TypeOf((*T)(nil)).Elem(), value)
+		}
+		return nil
+	}
+}
+
+// StructValidator is a Validator checking that the fields of struct values
+// tagged with `phaser:"required"` are not zero. Pointers to structs are
+// checked too, and other values are invalid.
+func StructValidator(value interface{}) error {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("expected a struct, got %T", value)
+	}
+
+	var missing []string
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("phaser") == "required" && v.Field(i).IsZero() {
+			missing = append(missing, t.Field(i).Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required fields %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkSchema checks value, processed by stage, using validator, returning a
+// *PhaseError wrapping sentinel when it is invalid.
+func (p *Phase) checkSchema(stage Stage, validator Validator, sentinel error, value interface{}) error {
+	if validator == nil {
+		return nil
+	}
+	if err := validator(value); err != nil {
+		return &PhaseError{Phase: p.Name, Stage: stage, Err: fmt.Errorf("%w: %w", sentinel, err)}
+	}
+	return nil
+}
+
+// Validate calls v on value, so that Validator functions can be used as
+// ValueValidators.
+func (v Validator) Validate(value interface{}) error {
+	return v(value)
+}
+
+// ValueValidator validates values, such as against a schema.
+type ValueValidator interface {
+	// Validate returns an error if value is invalid, preferably a
+	// *ViolationsError listing every violation
+	Validate(value interface{}) error
+}
+
+// Violation is a rule broken by a validated value.
+type Violation struct {
+	// Field is the path to the invalid field, empty when the violation is
+	// about the value as a whole
+	Field string
+	// Rule is the name of the broken rule, such as required, when known
+	Rule string
+	// Message describes the violation
+	Message string
+}
+
+// ViolationsError is returned by validation phases for invalid values,
+// listing every violation. It wraps ErrInvalidInput.
+type ViolationsError struct {
+	// Violations contains the violations in the order they were found
+	Violations []Violation
+}
+
+func (e *ViolationsError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidInput, strings.Join(messages, "; "))
+}
+
+func (e *ViolationsError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+// ValidationPhase returns a phase named name validating its input using
+// validator, and passing valid inputs on unchanged. Invalid inputs fail the
+// phase with a *ViolationsError. Errors returned by validator that are not
+// *ViolationsErrors are listed as a single violation of the value as a
+// whole.
+func ValidationPhase(name string, validator ValueValidator, opts ...PhaseOption) *Phase {
+	return NewPhase(name, func(value interface{}) (interface{}, error) {
+		err := validator.Validate(value)
+		if err == nil {
+			return value, nil
+		}
+		var violations *ViolationsError
+		if !errors.As(err, &violations) {
+			violations = &ViolationsError{Violations: []Violation{{Message: err.Error()}}}
+		}
+		return nil, violations
+	}, opts...)
+}
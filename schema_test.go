@@ -0,0 +1,115 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type order struct {
+	ID    string `phaser:"required"`
+	Total int    `phaser:"required"`
+	Note  string
+}
+
+func TestSchemaInputValidation(t *testing.T) {
+	calls := 0
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("typed", func(value interface{}) (interface{}, error) {
+		calls++
+		return value, nil
+	}, WithInputSchema(TypeOf[int]()))))
+
+	_, err := m.Run(1)
+	require.NoError(t, err)
+
+	_, err = m.Run("one")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	var phaseErr *PhaseError
+	require.ErrorAs(t, err, &phaseErr)
+	assert.Equal(t, "typed", phaseErr.Phase)
+	assert.Equal(t, StageInputValidation, phaseErr.Stage)
+	assert.Equal(t, 1, calls)
+}
+
+func TestSchemaOutputValidationAfterPostHooks(t *testing.T) {
+	p := NewPhase("order", func(value interface{}) (interface{}, error) {
+		return order{ID: "a"}, nil
+	}, WithOutputSchema(StructValidator))
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	_, err := m.Run(nil)
+	assert.ErrorIs(t, err, ErrInvalidOutput)
+	assert.ErrorContains(t, err, "missing required fields Total")
+	var phaseErr *PhaseError
+	require.ErrorAs(t, err, &phaseErr)
+	assert.Equal(t, StageOutputValidation, phaseErr.Stage)
+
+	// The post-hook completes the output before it is validated
+	require.NoError(t, m.AddPostHookToPhase("order", func(value interface{}) (interface{}, error) {
+		o := value.(order)
+		o.Total = 10
+		return o, nil
+	}))
+	value, err := m.Run(nil)
+	require.NoError(t, err)
+	assert.Equal(t, order{ID: "a", Total: 10}, value)
+}
+
+func TestStructValidator(t *testing.T) {
+	assert.NoError(t, StructValidator(order{ID: "a", Total: 1}))
+	assert.NoError(t, StructValidator(&order{ID: "a", Total: 1}))
+	assert.EqualError(t, StructValidator(&order{}), "missing required fields ID, Total")
+	assert.Error(t, StructValidator(1))
+	assert.Error(t, StructValidator((*order)(nil)))
+}
+
+// orderRules is a ValueValidator listing the violations of orders.
+type orderRules struct{}
+
+func (orderRules) Validate(value interface{}) error {
+	o := value.(order)
+	var violations []Violation
+	if o.ID == "" {
+		violations = append(violations, Violation{Field: "ID", Rule: "required", Message: "ID is required"})
+	}
+	if o.Total < 0 {
+		violations = append(violations, Violation{Field: "Total", Rule: "min", Message: "Total must not be negative"})
+	}
+	if len(violations) > 0 {
+		return &ViolationsError{Violations: violations}
+	}
+	return nil
+}
+
+func TestValidationPhase(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(ValidationPhase("validate", orderRules{})))
+
+	valid := order{ID: "a", Total: 1}
+	value, err := m.Run(valid)
+	require.NoError(t, err)
+	assert.Equal(t, valid, value)
+
+	_, err = m.Run(order{Total: -1})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.EqualError(t, err, "phase validate: invalid input: ID is required; Total must not be negative")
+	var violations *ViolationsError
+	require.ErrorAs(t, err, &violations)
+	assert.Equal(t, []Violation{
+		{Field: "ID", Rule: "required", Message: "ID is required"},
+		{Field: "Total", Rule: "min", Message: "Total must not be negative"},
+	}, violations.Violations)
+}
+
+func TestValidationPhaseWithValidator(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(ValidationPhase("validate", Validator(StructValidator))))
+
+	_, err := m.Run(order{Total: 1})
+	var violations *ViolationsError
+	require.ErrorAs(t, err, &violations)
+	assert.Equal(t, []Violation{{Message: "missing required fields ID"}}, violations.Violations)
+}
@@ -0,0 +1,121 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrManagerShutdown is returned by runs started after the manager's Shutdown
+// was called.
+var ErrManagerShutdown = errors.New("manager shut down")
+
+// Shutdown stops the manager, such as from a server's shutdown hook. Runs
+// started afterwards fail with ErrManagerShutdown, while the runs in flight
+// go on: batch phases flush their waiting inputs and stop batching, so that
+// runs do not wait for batches that will not fill. Shutdown waits for the
+// runs in flight, and for the executions they abandoned on cancellation or
+// timeout, to end.
+//
+// It returns the errors of the abandoned executions ending during the
+// shutdown, which no run reports, joined with the context's error if ctx is
+// done before the work ends.
+func (m *DefaultPhaseManager) Shutdown(ctx context.Context) error {
+	if m.lifecycle == nil {
+		return nil
+	}
+	m.lifecycle.close()
+
+	done := make(chan struct{})
+	go func() {
+		for _, p := range m.phases {
+			p.shutdown()
+		}
+		m.lifecycle.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("shutting down: %w", ctx.Err())
+	}
+	return errors.Join(append(m.lifecycle.failures(), err)...)
+}
+
+// shutdown stops the batching of the phase and of the phases of its
+// branches.
+func (p *Phase) shutdown() {
+	if p.onShutdown != nil {
+		p.onShutdown()
+	}
+	for _, branch := range p.branches {
+		for _, nested := range branch.phases {
+			nested.shutdown()
+		}
+	}
+}
+
+// lifecycle tracks the work in flight of a manager, so that it can be shut
+// down. A nil *lifecycle tracks nothing.
+type lifecycle struct {
+	// wg counts the runs and abandoned executions in flight
+	wg sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	// errs contains the errors of the abandoned executions ending after the
+	// shutdown started
+	errs []error
+}
+
+// enter starts tracking a run, returning the function ending it. Runs fail
+// with ErrManagerShutdown once the manager is shut down.
+func (l *lifecycle) enter() (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrManagerShutdown
+	}
+	l.wg.Add(1)
+	return l.wg.Done, nil
+}
+
+// background starts tracking an execution of the phase named phase that may
+// be abandoned by its run, returning the function ending it with its error.
+// It must be called while the run is tracked.
+func (l *lifecycle) background(phase string) func(abandoned bool, err error) {
+	if l == nil {
+		return func(bool, error) {}
+	}
+	l.wg.Add(1)
+	return func(abandoned bool, err error) {
+		defer l.wg.Done()
+		if !abandoned || err == nil {
+			return
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.closed {
+			l.errs = append(l.errs, &PhaseError{Phase: phase, Err: err})
+		}
+	}
+}
+
+// close stops accepting runs.
+func (l *lifecycle) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+}
+
+// failures returns the errors of the abandoned executions.
+func (l *lifecycle) failures() []error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]error(nil), l.errs...)
+}
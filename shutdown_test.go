@@ -0,0 +1,97 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownFlushesBatches(t *testing.T) {
+	d := &doubleBatch{}
+	b := NewBatchPhase("write", 10, 0, d.process)
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(b.Phase))
+
+	var wg sync.WaitGroup
+	outputs := make([]interface{}, 3)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], _ = m.Run(i)
+		}(i)
+	}
+	require.Eventually(t, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.pending) == 3
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, m.Shutdown(context.Background()))
+	wg.Wait()
+	assert.Equal(t, []interface{}{0, 2, 4}, outputs)
+	assert.Equal(t, []int{3}, d.sizes)
+
+	_, err := m.Run(1)
+	assert.ErrorIs(t, err, ErrManagerShutdown)
+}
+
+func TestShutdownWaitsForRuns(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	m := gateManager(t, started, release)
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Run(1)
+		done <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- m.Shutdown(context.Background())
+	}()
+	select {
+	case <-shutdown:
+		t.Fatal("shut down with a run in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	assert.NoError(t, <-shutdown)
+	assert.NoError(t, <-done)
+}
+
+func TestShutdownAbandonedExecutions(t *testing.T) {
+	errLate := errors.New("late failure")
+	release := make(chan struct{})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("slow", func(value interface{}) (interface{}, error) {
+		<-release
+		return nil, errLate
+	}, WithTimeout(10*time.Millisecond))))
+
+	// The run times out, abandoning the execution
+	_, err := m.Run(1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	t.Run("timed out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, m.Shutdown(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("completed", func(t *testing.T) {
+		close(release)
+		err := m.Shutdown(context.Background())
+		assert.ErrorIs(t, err, errLate)
+		assert.NotErrorIs(t, err, context.DeadlineExceeded)
+		var phaseErr *PhaseError
+		require.ErrorAs(t, err, &phaseErr)
+		assert.Equal(t, "slow", phaseErr.Phase)
+	})
+}
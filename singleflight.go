@@ -0,0 +1,99 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// errFlightAborted is received by the executions coalesced with an execution
+// that panicked.
+var errFlightAborted = errors.New("coalesced execution panicked")
+
+// WithSingleFlight coalesces concurrent executions of the phase whose values
+// have the same key, as returned by key. The first execution, the leader,
+// calls the execute function, retrying it as allowed by the phase's Retry
+// policy, and every execution coalesced with it receives its output and
+// error. Coalesced executions share the leader's output, so it must not be
+// modified in place by later steps.
+//
+// Coalesced executions are marked as Deduplicated in the run's report, and do
+// not count towards the phase's retries or limits. An execution whose context
+// is done stops waiting for the leader without cancelling it, while the
+// executions coalesced with a leader interrupted by its own context execute
+// again, one of them becoming the new leader.
+func WithSingleFlight(key func(value interface{}) (string, error)) PhaseOption {
+	return func(p *Phase) {
+		p.flight = &singleFlight{key: key, calls: make(map[string]*flightCall)}
+	}
+}
+
+// singleFlight tracks the in-flight executions of a phase by key.
+type singleFlight struct {
+	key   func(value interface{}) (string, error)
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is an in-flight execution shared by the executions with its key.
+type flightCall struct {
+	// done is closed once output and err are set
+	done   chan struct{}
+	output interface{}
+	err    error
+	// followers counts the executions coalesced with the call
+	followers int
+}
+
+// executeShared runs the phase's execute function on value, retrying it as
+// allowed by the phase's Retry policy, or waits for the result of the
+// in-flight execution with the same key when the phase uses WithSingleFlight.
+func (p *Phase) executeShared(ctx context.Context, value interface{}) (interface{}, error) {
+	f := p.flight
+	if f == nil {
+		return p.executeAttempts(ctx, value)
+	}
+	key, err := f.key(value)
+	if err != nil {
+		return nil, fmt.Errorf("computing single flight key: %w", err)
+	}
+
+	for {
+		f.mu.Lock()
+		call, ok := f.calls[key]
+		if !ok {
+			break
+		}
+		call.followers++
+		f.mu.Unlock()
+		phaseResultFrom(ctx).Deduplicated = true
+		runStateFrom(ctx).trace.printf(p.Name, "execute coalesced with in-flight execution %q", key)
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, &PartialResultError{LastValue: value, Err: ctx.Err()}
+		}
+		if isInterruption(call.err) && ctx.Err() == nil {
+			// The leader was interrupted by its own context, so that the
+			// execution is made again, by a new leader
+			runStateFrom(ctx).trace.printf(p.Name, "coalesced execution %q interrupted, executing again", key)
+			phaseResultFrom(ctx).Deduplicated = false
+			continue
+		}
+		return call.output, call.err
+	}
+	call := &flightCall{done: make(chan struct{}), err: errFlightAborted}
+	f.calls[key] = call
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(call.done)
+	}()
+	call.output, call.err = p.executeAttempts(ctx, value)
+	return call.output, call.err
+}
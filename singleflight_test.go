@@ -0,0 +1,201 @@
+package phaser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// currencyKey is a single flight key function keying values by their currency,
+// the part of the value before the first colon.
+func currencyKey(value interface{}) (string, error) {
+	var currency string
+	_, err := fmt.Sscanf(value.(string), "%3s:", &currency)
+	return currency, err
+}
+
+// awaitFollowers waits until n executions of p are coalesced with the
+// in-flight execution with key.
+func awaitFollowers(t *testing.T, p *Phase, key string, n int) {
+	require.Eventually(t, func() bool {
+		p.flight.mu.Lock()
+		defer p.flight.mu.Unlock()
+		call, ok := p.flight.calls[key]
+		return ok && call.followers == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestSingleFlightCoalescesExecutions(t *testing.T) {
+	const runs = 20
+	var calls sync.Map
+	release := make(chan struct{})
+	p := NewPhase("rates", func(value interface{}) (interface{}, error) {
+		currency, _ := currencyKey(value)
+		count, _ := calls.LoadOrStore(currency, new(int64))
+		atomic.AddInt64(count.(*int64), 1)
+		<-release
+		return "rates for " + currency, nil
+	}, WithSingleFlight(currencyKey))
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	var wg sync.WaitGroup
+	var deduplicated int64
+	for _, currency := range []string{"EUR", "USD"} {
+		for i := 0; i < runs; i++ {
+			wg.Add(1)
+			go func(value string) {
+				defer wg.Done()
+				var report RunReport
+				output, err := m.Run(value, WithReport(&report))
+				assert.NoError(t, err)
+				assert.Equal(t, "rates for "+value[:3], output)
+				if report.Phases[0].Deduplicated {
+					atomic.AddInt64(&deduplicated, 1)
+				}
+			}(fmt.Sprintf("%s:%d", currency, i))
+		}
+	}
+	awaitFollowers(t, p, "EUR", runs-1)
+	awaitFollowers(t, p, "USD", runs-1)
+	close(release)
+	wg.Wait()
+
+	for _, currency := range []string{"EUR", "USD"} {
+		count, ok := calls.Load(currency)
+		require.True(t, ok)
+		assert.Equal(t, int64(1), *count.(*int64), currency)
+	}
+	assert.Equal(t, int64(2*(runs-1)), deduplicated)
+	assert.Empty(t, p.flight.calls)
+}
+
+func TestSingleFlightSharesErrors(t *testing.T) {
+	release := make(chan struct{})
+	calls := 0
+	p := NewPhase("rates", func(value interface{}) (interface{}, error) {
+		calls++
+		<-release
+		return nil, assert.AnError
+	}, WithSingleFlight(currencyKey))
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	leader := startRun(m, "EUR:1")
+	awaitFollowers(t, p, "EUR", 0)
+	follower := startRun(m, "EUR:2")
+	awaitFollowers(t, p, "EUR", 1)
+	close(release)
+
+	assert.ErrorIs(t, (<-leader).err, assert.AnError)
+	assert.ErrorIs(t, (<-follower).err, assert.AnError)
+	assert.Equal(t, 1, calls)
+}
+
+func TestSingleFlightRetriesOnlyLeader(t *testing.T) {
+	release := make(chan struct{})
+	var calls int64
+	p := NewPhase("rates", func(value interface{}) (interface{}, error) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			<-release
+			return nil, assert.AnError
+		}
+		return "rates", nil
+	}, WithSingleFlight(currencyKey), WithRetry(RetryPolicy{MaxAttempts: 2}))
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	leader := startRun(m, "EUR:1")
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&calls) == 1 }, time.Second, time.Millisecond)
+	follower := startRun(m, "EUR:2")
+	awaitFollowers(t, p, "EUR", 1)
+	close(release)
+
+	for _, result := range []runResult{<-leader, <-follower} {
+		require.NoError(t, result.err)
+		assert.Equal(t, "rates", result.value)
+	}
+	assert.Equal(t, int64(2), calls)
+}
+
+func TestSingleFlightCancelledFollower(t *testing.T) {
+	release := make(chan struct{})
+	p := NewPhase("rates", func(value interface{}) (interface{}, error) {
+		<-release
+		return "rates", nil
+	}, WithSingleFlight(currencyKey))
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	leader := startRun(m, "EUR:1")
+	awaitFollowers(t, p, "EUR", 0)
+
+	// The follower stops waiting, while the leader keeps running
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := m.RunContext(ctx, "EUR:2")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	result := <-leader
+	require.NoError(t, result.err)
+	assert.Equal(t, "rates", result.value)
+}
+
+func TestSingleFlightKeyError(t *testing.T) {
+	calls := 0
+	p := NewPhase("rates", func(value interface{}) (interface{}, error) {
+		calls++
+		return value, nil
+	}, WithSingleFlight(func(value interface{}) (string, error) {
+		return "", assert.AnError
+	}))
+
+	_, err := p.run("EUR:1")
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 0, calls)
+}
+
+func TestSingleFlightInterruptedLeader(t *testing.T) {
+	var calls int64
+	release := make(chan struct{})
+	p := NewPhaseContext("rates", func(ctx context.Context, value interface{}) (interface{}, error) {
+		atomic.AddInt64(&calls, 1)
+		select {
+		case <-release:
+			return "rates", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}, WithSingleFlight(currencyKey))
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := m.RunContext(ctx, "EUR:1")
+		leader <- err
+	}()
+	awaitFollowers(t, p, "EUR", 0)
+	follower := startRun(m, "EUR:2")
+	awaitFollowers(t, p, "EUR", 1)
+
+	// The follower executes again once the leader is cancelled
+	cancel()
+	assert.ErrorIs(t, <-leader, context.Canceled)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&calls) == 2
+	}, time.Second, time.Millisecond)
+	close(release)
+	result := <-follower
+	require.NoError(t, result.err)
+	assert.Equal(t, "rates", result.value)
+	assert.Empty(t, p.flight.calls)
+}
@@ -0,0 +1,49 @@
+package phaser
+
+import "context"
+
+// SkipWhenContext skips the phase in runs whose context satisfies skip,
+// passing its input on to the next phase as if it was disabled. It allows
+// toggling phases per request.
+func SkipWhenContext(skip func(ctx context.Context) bool) PhaseOption {
+	return func(p *Phase) {
+		p.skipWhen = skip
+	}
+}
+
+// SkipOnContextKey skips the phase in runs whose context holds a value for
+// key, unless the value is false.
+func SkipOnContextKey(key interface{}) PhaseOption {
+	return SkipWhenContext(func(ctx context.Context) bool {
+		value := ctx.Value(key)
+		return value != nil && value != false
+	})
+}
+
+// skipped reports whether the phase is skipped in the run using ctx.
+func (p *Phase) skipped(ctx context.Context) bool {
+	return p.Disabled || p.skipWhen != nil && p.skipWhen(ctx) || !p.flagEnabled(ctx)
+}
+
+// ConditionalPhase returns a phase named after inner running inner, with its
+// hooks, error handlers and other settings, on the values satisfying cond.
+// Other values are passed on to the next phase unchanged. The returned phase
+// takes the NonCritical, DependsOn, Weight and Version of inner, while
+// settings applying to the phase as a whole, such as SkipWhenContext, must be
+// set on the returned phase.
+func ConditionalPhase(cond func(value interface{}) bool, inner *Phase) *Phase {
+	p := NewPhaseContext(inner.Name, func(ctx context.Context, value interface{}) (interface{}, error) {
+		if !cond(value) {
+			runStateFrom(ctx).trace.printf(inner.Name, "condition not met, passing the value on")
+			return value, nil
+		}
+		return inner.runStages(ctx, value)
+	})
+	p.NonCritical = inner.NonCritical
+	p.DependsOn = inner.DependsOn
+	p.Weight = inner.Weight
+	p.Version = inner.Version
+	// The inner phase acquires the concurrency limits itself
+	p.nested = true
+	return p
+}
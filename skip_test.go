@@ -0,0 +1,117 @@
+package phaser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// skipNotifications is the context key of the flag skipping notifications.
+type skipNotifications struct{}
+
+func TestSkipOnContextKey(t *testing.T) {
+	calls := 0
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		flakyPhase("notify", 0, &calls, SkipOnContextKey(skipNotifications{})),
+	))
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		calls int
+		want  int
+	}{
+		{name: "unset", ctx: context.Background(), calls: 1, want: 2},
+		{name: "set", ctx: context.WithValue(context.Background(), skipNotifications{}, true), calls: 0, want: 1},
+		{name: "false", ctx: context.WithValue(context.Background(), skipNotifications{}, false), calls: 1, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			var report RunReport
+			value, err := m.RunContext(tt.ctx, 0, WithReport(&report))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, value)
+			assert.Equal(t, tt.calls, calls)
+			if tt.calls == 0 {
+				assert.Equal(t, StatusSkipped, report.Phases[1].Status)
+			}
+		})
+	}
+}
+
+func TestSkipWhenContext(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne, SkipWhenContext(func(ctx context.Context) bool {
+		return ctx.Err() == nil && ctx.Value(skipNotifications{}) == "all"
+	}))))
+
+	value, err := m.RunContext(context.WithValue(context.Background(), skipNotifications{}, "all"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, value)
+
+	value, err = m.RunContext(context.WithValue(context.Background(), skipNotifications{}, "some"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
+
+func TestConditionalPhase(t *testing.T) {
+	var order []string
+	inner := NewPhase("double", func(value interface{}) (interface{}, error) {
+		order = append(order, "execute")
+		if value.(int) > 100 {
+			return nil, assert.AnError
+		}
+		return value.(int) * 2, nil
+	})
+	inner.appendPreHook(func(value interface{}) (interface{}, error) {
+		order = append(order, "pre-hook")
+		return value, nil
+	})
+	inner.appendPostHook(func(value interface{}) (interface{}, error) {
+		order = append(order, "post-hook")
+		return value.(int) + 1, nil
+	})
+	inner.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		order = append(order, "error handler")
+		return true, -1, nil
+	})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(ConditionalPhase(func(value interface{}) bool {
+		return value.(int)%2 == 0
+	}, inner)))
+
+	value, err := m.Run(3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+	assert.Empty(t, order)
+
+	value, err = m.Run(4)
+	require.NoError(t, err)
+	assert.Equal(t, 9, value)
+	assert.Equal(t, []string{"pre-hook", "execute", "post-hook"}, order)
+
+	order = nil
+	value, err = m.Run(102)
+	require.NoError(t, err)
+	assert.Equal(t, -1, value)
+	assert.Equal(t, []string{"pre-hook", "execute", "error handler"}, order)
+}
+
+func TestConditionalPhaseWithinConcurrencyLimit(t *testing.T) {
+	m := NewPhaseManager(WithConcurrencyLimit(1))
+	always := func(value interface{}) bool { return true }
+	require.NoError(t, m.AddPhase(ConditionalPhase(always, NewPhase("one", addOne))))
+
+	// The phase must not hold the slot its inner phase waits for
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	value, err := m.RunContext(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
@@ -0,0 +1,69 @@
+package phaser
+
+import (
+	"strconv"
+	"sync"
+)
+
+// HookKey identifies a hook in the hook statistics.
+type HookKey struct {
+	// Phase is the name of the phase the hook belongs to
+	Phase string
+	// Stage is the stage running the hook. Calls to execute functions are
+	// counted under StageExecute
+	Stage Stage
+	// Hook is the name of the hook, or its index for unnamed hooks. It is
+	// empty for execute functions
+	Hook string
+}
+
+// WithHookStats makes the manager count how many times each hook and execute
+// function is invoked across its runs, including retries. The counts are
+// returned by HookStats.
+func WithHookStats() ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.stats = &hookStats{counts: map[HookKey]int{}}
+	}
+}
+
+// HookStats returns the number of invocations of each hook and execute
+// function since the manager was created. It returns nil unless the manager
+// was created using WithHookStats.
+func (m *DefaultPhaseManager) HookStats() map[HookKey]int {
+	if m.stats == nil {
+		return nil
+	}
+
+	m.stats.mu.Lock()
+	defer m.stats.mu.Unlock()
+	counts := make(map[HookKey]int, len(m.stats.counts))
+	for key, count := range m.stats.counts {
+		counts[key] = count
+	}
+	return counts
+}
+
+// hookStats counts hook invocations. A nil *hookStats counts nothing.
+type hookStats struct {
+	mu     sync.Mutex
+	counts map[HookKey]int
+}
+
+// add counts an invocation of the hook identified by key.
+func (s *hookStats) add(key HookKey) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[key]++
+}
+
+// hookKey returns the name of the hook at index i of hooks, or its index if
+// it is unnamed.
+func (p *Phase) hookKey(hooks *[]PhaseHook, i int) string {
+	if name := p.hookName(hooks, i); name != "" {
+		return name
+	}
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,43 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookStatsCountRetries(t *testing.T) {
+	calls := 0
+	flaky := flakyPhase("flaky", 2, &calls, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	flaky.AppendNamedPreHook("validate", func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+	flaky.appendPostHook(func(value interface{}) (interface{}, error) {
+		return value, nil
+	})
+
+	m := NewPhaseManager(WithHookStats())
+	require.NoError(t, m.AddPhases(NewPhase("one", addOne), flaky))
+
+	// The first run retries the flaky phase twice, the others succeed at once
+	for i := 0; i < 3; i++ {
+		_, err := m.Run(0)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, map[HookKey]int{
+		{Phase: "one", Stage: StageExecute}:                     3,
+		{Phase: "flaky", Stage: StagePreHook, Hook: "validate"}: 3,
+		{Phase: "flaky", Stage: StageExecute}:                   5,
+		{Phase: "flaky", Stage: StagePostHook, Hook: "0"}:       3,
+	}, m.HookStats())
+}
+
+func TestHookStatsDisabled(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	_, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Nil(t, m.HookStats())
+}
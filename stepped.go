@@ -0,0 +1,137 @@
+package phaser
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Step is a step of a phase created using NewSteppedPhase.
+type Step struct {
+	// Name identifies the step in reports, traces and errors
+	Name string
+	// Fn performs the step, turning the output of the previous step into the
+	// input of the next one
+	Fn func(value interface{}) (interface{}, error)
+}
+
+// StepResult describes the outcome of a step of a phase.
+type StepResult struct {
+	// Step is the name of the step
+	Step string
+	// Duration is the time the step took
+	Duration time.Duration
+	// Err is the error returned by the step, if any
+	Err error
+}
+
+// NewSteppedPhase returns a phase named name whose execute function runs steps
+// in order, piping the output of each step into the next one. The result of
+// each step is recorded in the phase's result and debug trace, and failing
+// steps return a *PhaseError naming the step.
+func NewSteppedPhase(name string, steps []Step, opts ...PhaseOption) *Phase {
+	p := &Phase{Name: name}
+	p.executeContext = func(ctx context.Context, value interface{}) (interface{}, error) {
+		cursor := stepCursorFrom(ctx)
+		attempt, first, value := cursor.begin(p.resumeSteps, value)
+		state, result := runStateFrom(ctx), phaseResultFrom(ctx)
+		clock := ClockFrom(ctx)
+
+		for i := first; i < len(steps); i++ {
+			step := steps[i]
+			started := clock.Now()
+			var traced time.Time
+			if state.trace.traces(name) {
+				traced = state.trace.started(name, "step "+step.Name, value)
+			}
+			output, err := step.Fn(value)
+			if state.trace.traces(name) {
+				state.trace.finished(name, "step "+step.Name, traced, output, err)
+			}
+
+			stepResult := StepResult{Step: step.Name, Duration: clock.Now().Sub(started), Err: err}
+			if err != nil {
+				cursor.record(attempt, result, stepResult, i, value)
+				return nil, &PhaseError{Phase: name, Step: step.Name, Err: err}
+			}
+			value = output
+			cursor.record(attempt, result, stepResult, i+1, value)
+		}
+		return value, nil
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithResumeFromFailedStep makes retries of phases created using
+// NewSteppedPhase restart from the step that failed, using the output of the
+// last successful step, instead of from the first step.
+func WithResumeFromFailedStep() PhaseOption {
+	return func(p *Phase) {
+		p.resumeSteps = true
+	}
+}
+
+// stepCursor tracks the progress of the attempts of a stepped phase.
+type stepCursor struct {
+	mu sync.Mutex
+	// attempt identifies the current attempt. Steps of previous attempts,
+	// abandoned after a timeout, are not recorded
+	attempt int
+	// next is the index of the step following the last successful one, and
+	// value its input
+	next  int
+	value interface{}
+	// closed is set once the phase stops attempting its execute function
+	closed bool
+}
+
+// begin starts a new attempt on value, returning its identifier along with the
+// index and input of its first step.
+func (c *stepCursor) begin(resume bool, value interface{}) (int, int, interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempt++
+	if !resume || c.next == 0 {
+		c.next, c.value = 0, value
+	}
+	return c.attempt, c.next, c.value
+}
+
+// record adds the result of a step of attempt to the phase's result, and
+// stores the input of the next step to run.
+func (c *stepCursor) record(attempt int, result *PhaseResult, stepResult StepResult, next int, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || attempt != c.attempt {
+		return
+	}
+	result.Steps = append(result.Steps, stepResult)
+	c.next, c.value = next, value
+}
+
+// close stops recording the steps of the phase.
+func (c *stepCursor) close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+}
+
+// stepCursorKey is the context key of the step cursor.
+type stepCursorKey struct{}
+
+// withStepCursor returns a copy of ctx carrying cursor.
+func withStepCursor(ctx context.Context, cursor *stepCursor) context.Context {
+	return context.WithValue(ctx, stepCursorKey{}, cursor)
+}
+
+// stepCursorFrom returns the step cursor stored in ctx, or a new one when
+// there is none.
+func stepCursorFrom(ctx context.Context) *stepCursor {
+	if cursor, ok := ctx.Value(stepCursorKey{}).(*stepCursor); ok {
+		return cursor
+	}
+	return &stepCursor{}
+}
@@ -0,0 +1,116 @@
+package phaser
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSteps returns the steps download, checksum and extract, appending
+// their names to a string value and counting their calls in calls. checksum
+// fails the first failures times.
+func countingSteps(calls map[string]int, failures int) []Step {
+	step := func(name string) Step {
+		return Step{Name: name, Fn: func(value interface{}) (interface{}, error) {
+			calls[name]++
+			if name == "checksum" && calls[name] <= failures {
+				return nil, assert.AnError
+			}
+			return value.(string) + "-" + name, nil
+		}}
+	}
+	return []Step{step("download"), step("checksum"), step("extract")}
+}
+
+func TestSteppedPhase(t *testing.T) {
+	calls := map[string]int{}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewSteppedPhase("fetch", countingSteps(calls, 0))))
+
+	var report RunReport
+	value, err := m.Run("file", WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, "file-download-checksum-extract", value)
+
+	steps := report.Phases[0].Steps
+	require.Len(t, steps, 3)
+	for i, name := range []string{"download", "checksum", "extract"} {
+		assert.Equal(t, name, steps[i].Step)
+		assert.NoError(t, steps[i].Err)
+	}
+}
+
+func TestSteppedPhaseFailure(t *testing.T) {
+	calls := map[string]int{}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewSteppedPhase("fetch", countingSteps(calls, 1))))
+
+	var report RunReport
+	_, err := m.Run("file", WithReport(&report))
+	require.ErrorIs(t, err, assert.AnError)
+	var phaseErr *PhaseError
+	require.True(t, errors.As(err, &phaseErr))
+	assert.Equal(t, &PhaseError{Phase: "fetch", Step: "checksum", Err: assert.AnError}, phaseErr)
+	assert.Equal(t, "phase fetch: step checksum: "+assert.AnError.Error(), err.Error())
+
+	steps := report.Phases[0].Steps
+	require.Len(t, steps, 2)
+	assert.ErrorIs(t, steps[1].Err, assert.AnError)
+	assert.Equal(t, 0, calls["extract"])
+}
+
+func TestSteppedPhaseRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []PhaseOption
+		downloads int
+		steps     []string
+	}{
+		{
+			name:      "from first step",
+			downloads: 2,
+			steps:     []string{"download", "checksum", "download", "checksum", "extract"},
+		},
+		{
+			name:      "from failed step",
+			opts:      []PhaseOption{WithResumeFromFailedStep()},
+			downloads: 1,
+			steps:     []string{"download", "checksum", "checksum", "extract"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := map[string]int{}
+			opts := append(tt.opts, WithRetry(RetryPolicy{MaxAttempts: 2}))
+			m := NewPhaseManager()
+			require.NoError(t, m.AddPhase(NewSteppedPhase("fetch", countingSteps(calls, 1), opts...)))
+
+			var report RunReport
+			value, err := m.Run("file", WithReport(&report))
+			require.NoError(t, err)
+			assert.Equal(t, "file-download-checksum-extract", value)
+			assert.Equal(t, tt.downloads, calls["download"])
+
+			var steps []string
+			for _, step := range report.Phases[0].Steps {
+				steps = append(steps, step.Step)
+			}
+			assert.Equal(t, tt.steps, steps)
+		})
+	}
+}
+
+func TestSteppedPhaseTrace(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewPhaseManager(WithDebugTrace(&buf, WithTraceValues(false)))
+	require.NoError(t, m.AddPhase(NewSteppedPhase("fetch", countingSteps(map[string]int{}, 0))))
+
+	_, err := m.Run("file")
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "phase fetch: step checksum started")
+	assert.Contains(t, buf.String(), "phase fetch: step checksum finished")
+}
@@ -0,0 +1,131 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrRunStopped is returned by runs stopped using Stop.
+var ErrRunStopped = errors.New("run stopped")
+
+// WithStepping enables the debugging controls of the manager: runs start
+// paused and wait before each phase until Step or Resume is called. It is
+// meant to debug a single run at a time.
+func WithStepping() ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.stepper = &stepper{paused: true, signal: make(chan struct{}, 1)}
+	}
+}
+
+// Pause makes the runs of a manager using WithStepping wait before their next
+// phase.
+func (m *DefaultPhaseManager) Pause() {
+	m.stepper.update(func(s *stepper) {
+		s.paused = true
+	})
+}
+
+// Resume makes the paused runs of a manager using WithStepping run the
+// remaining phases without waiting.
+func (m *DefaultPhaseManager) Resume() {
+	m.stepper.update(func(s *stepper) {
+		s.paused = false
+	})
+}
+
+// Step makes a paused run of a manager using WithStepping run its next phase
+// and pause again.
+func (m *DefaultPhaseManager) Step() {
+	m.stepper.update(func(s *stepper) {
+		s.steps++
+	})
+}
+
+// Stop stops the run of a manager using WithStepping before its next phase.
+// The run returns a *PartialResultError wrapping ErrRunStopped.
+func (m *DefaultPhaseManager) Stop() {
+	m.stepper.update(func(s *stepper) {
+		s.stopped = true
+	})
+}
+
+// stepper implements the debugging controls of a manager. A nil *stepper
+// never pauses runs.
+type stepper struct {
+	mu      sync.Mutex
+	paused  bool
+	stopped bool
+	// steps is the number of phases runs may run while paused
+	steps int
+	// signal wakes up the runs waiting for a control
+	signal chan struct{}
+}
+
+// update applies fn to s and wakes up the waiting runs.
+func (s *stepper) update(fn func(s *stepper)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	fn(s)
+	s.mu.Unlock()
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// reset pauses s for the next run once a run ends. Controls are not reset when
+// runs start, so that controls sent before a run reaches its first phase are
+// not lost.
+func (s *stepper) reset() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused, s.stopped, s.steps = true, false, 0
+}
+
+// isStopped reports whether the run was stopped, without consuming the stop.
+func (s *stepper) isStopped() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// wait waits until the run may run its next phase, returning ErrRunStopped
+// when stopped or the context's error if ctx is done first.
+func (s *stepper) wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	for {
+		s.mu.Lock()
+		switch {
+		case s.stopped:
+			s.stopped = false
+			s.mu.Unlock()
+			return ErrRunStopped
+		case !s.paused:
+			s.mu.Unlock()
+			return nil
+		case s.steps > 0:
+			s.steps--
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.signal:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package phaser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// endedObserver sends the name of every ended phase to a channel.
+type endedObserver chan string
+
+func (o endedObserver) OnPhaseStart(phase string, value interface{}) {}
+
+func (o endedObserver) OnPhaseEnd(result PhaseResult) {
+	o <- result.Phase
+}
+
+// runResult is the outcome of a run started by startRun.
+type runResult struct {
+	value interface{}
+	err   error
+}
+
+// startRun runs m on value in the background.
+func startRun(m *DefaultPhaseManager, value interface{}) <-chan runResult {
+	done := make(chan runResult, 1)
+	go func() {
+		value, err := m.Run(value)
+		done <- runResult{value, err}
+	}()
+	return done
+}
+
+func TestSteppingStepsThroughPhases(t *testing.T) {
+	ended := make(endedObserver, 3)
+	m := NewPhaseManager(WithStepping(), WithObserver(ended))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", addOne),
+		NewPhase("three", addOne),
+	))
+
+	done := startRun(m, 0)
+	m.Step()
+	assert.Equal(t, "one", <-ended)
+	m.Step()
+	assert.Equal(t, "two", <-ended)
+	assert.Empty(t, ended)
+
+	m.Resume()
+	assert.Equal(t, "three", <-ended)
+	result := <-done
+	require.NoError(t, result.err)
+	assert.Equal(t, 3, result.value)
+}
+
+func TestSteppingStop(t *testing.T) {
+	ended := make(endedObserver, 3)
+	m := NewPhaseManager(WithStepping(), WithObserver(ended))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", addOne),
+	))
+
+	done := startRun(m, 0)
+	m.Step()
+	assert.Equal(t, "one", <-ended)
+	m.Stop()
+
+	result := <-done
+	assert.ErrorIs(t, result.err, ErrRunStopped)
+	var partial *PartialResultError
+	require.True(t, errors.As(result.err, &partial))
+	assert.Equal(t, 1, partial.LastValue)
+	assert.Equal(t, "one", partial.CompletedPhase)
+	assert.Empty(t, ended)
+}
+
+func TestSteppingPause(t *testing.T) {
+	ended := make(endedObserver, 3)
+	pause := NewPhase("two", addOne)
+	m := NewPhaseManager(WithStepping(), WithObserver(ended))
+	pause.appendPostHook(func(value interface{}) (interface{}, error) {
+		m.Pause()
+		return value, nil
+	})
+	require.NoError(t, m.AddPhases(NewPhase("one", addOne), pause, NewPhase("three", addOne)))
+
+	done := startRun(m, 0)
+	m.Resume()
+	assert.Equal(t, "one", <-ended)
+	assert.Equal(t, "two", <-ended)
+	assert.Empty(t, ended)
+
+	m.Step()
+	assert.Equal(t, "three", <-ended)
+	require.NoError(t, (<-done).err)
+}
@@ -0,0 +1,397 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStreamOverflow is returned by streams using FailStream when the buffer of
+// one of their stages stays full for too long.
+var ErrStreamOverflow = errors.New("stream overflow")
+
+// DefaultStageBuffer is the size of the buffers of the stages of a stream
+// without a WithStageBuffer.
+const DefaultStageBuffer = 1
+
+// OverflowPolicy is what a stream does with a value handed to a stage whose
+// buffer stayed full for longer than the duration set by WithOverflowPolicy.
+type OverflowPolicy struct {
+	fail   bool
+	onDrop func(value interface{})
+}
+
+var (
+	// BlockForever keeps waiting for room in the buffer, so that slow stages
+	// throttle the stages before them. It is the default
+	BlockForever = OverflowPolicy{}
+	// FailStream stops the stream with an error wrapping ErrStreamOverflow
+	FailStream = OverflowPolicy{fail: true}
+)
+
+// DropNewestValue drops the value handed to the full stage, calling onDrop with
+// it, so that the stages before it keep running at their own pace.
+func DropNewestValue(onDrop func(value interface{})) OverflowPolicy {
+	if onDrop == nil {
+		onDrop = func(interface{}) {}
+	}
+	return OverflowPolicy{onDrop: onDrop}
+}
+
+// StreamOption configures a RunStream call.
+type StreamOption func(c *streamConfig)
+
+// streamConfig contains the settings of a RunStream call.
+type streamConfig struct {
+	// buffers contains the buffer sizes of the stages by phase name
+	buffers map[string]int
+	policy  OverflowPolicy
+	// after is how long a buffer must stay full for the policy to apply
+	after time.Duration
+}
+
+// WithStageBuffer sets the size of the buffer of values waiting for the phase
+// named phase, which defaults to DefaultStageBuffer. Sizes lower than one
+// hand each value over directly.
+func WithStageBuffer(phase string, n int) StreamOption {
+	return func(c *streamConfig) {
+		c.buffers[phase] = n
+	}
+}
+
+// WithOverflowPolicy sets what happens to values handed to a stage whose
+// buffer stayed full for longer than after, according to the manager's
+// clock.
+func WithOverflowPolicy(policy OverflowPolicy, after time.Duration) StreamOption {
+	return func(c *streamConfig) {
+		c.policy, c.after = policy, after
+	}
+}
+
+// StreamResult is the outcome of the run of a value of a stream.
+type StreamResult struct {
+	// Value is the output of the last phase, or the value given to the
+	// failed phase
+	Value interface{}
+	// Err is the error of the failed phase, if any
+	Err error
+}
+
+// StageStats contains the statistics of a stage of a stream.
+type StageStats struct {
+	// Phase is the name of the phase of the stage
+	Phase string
+	// Buffer is the size of the stage's buffer
+	Buffer int
+	// Queued is the number of values waiting in the stage's buffer
+	Queued int
+	// Processed is the number of values the stage processed
+	Processed int64
+	// Dropped is the number of values dropped because the stage's buffer
+	// was full, using DropNewestValue
+	Dropped int64
+	// Blocked is the total time spent waiting for room in the stage's
+	// buffer, by the stage before it or by the input for the first stage
+	Blocked time.Duration
+}
+
+// streamItem is a value flowing through the stages of a stream.
+type streamItem struct {
+	value interface{}
+	err   error
+	// stopped is set once a phase stopped the pipeline, so that the
+	// remaining stages pass the value on
+	stopped bool
+}
+
+// streamStage is the stage of a stream running a phase.
+type streamStage struct {
+	phase *Phase
+	in    chan streamItem
+
+	processed int64
+	dropped   int64
+	// blocked is the time spent waiting for room in the buffer, in
+	// nanoseconds
+	blocked int64
+}
+
+// Stream is a pipeline run by RunStream.
+type Stream struct {
+	results chan StreamResult
+	stages  []*streamStage
+	config  *streamConfig
+	cancel  context.CancelCauseFunc
+	ctx     context.Context
+	// clock measures the time blocked and the overflow durations
+	clock Clock
+}
+
+// RunStream runs the pipeline on each value received from in, each phase
+// running as a stage of its own, so that a value runs through a phase while
+// the next values run through the phases before it. Stages hand their
+// outputs to the next stage through a buffer: once it is full, the stage
+// waits for room, so that slow stages throttle the stages before them,
+// unless WithOverflowPolicy decides otherwise.
+//
+// The results are received in order from Results, which is closed once in
+// is closed and every value ran, or once the stream stops because ctx is
+// done, Stop was called or a buffer overflowed using FailStream. The values
+// left in the buffers of a stopped stream are discarded.
+//
+// Each phase runs like within Run, with its hooks, retries and timeouts, and
+// failed values skip the remaining phases. Disabled phases and phases
+// skipped by their feature flag or SkipWhenContext pass values on, while
+// reports, observers, checkpoints, dependencies, overrides and the other
+// settings of whole runs do not apply.
+func (m *DefaultPhaseManager) RunStream(ctx context.Context, in <-chan interface{}, opts ...StreamOption) *Stream {
+	c := &streamConfig{buffers: make(map[string]int)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	s := &Stream{results: make(chan StreamResult), config: c, cancel: cancel, ctx: ctx, clock: m.timeSource()}
+	for _, p := range m.phases {
+		n, ok := c.buffers[p.Name]
+		if !ok {
+			n = DefaultStageBuffer
+		}
+		if n < 0 {
+			n = 0
+		}
+		s.stages = append(s.stages, &streamStage{phase: p, in: make(chan streamItem, n)})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(s.stages) + 1)
+	go func() {
+		defer wg.Done()
+		s.feed(in)
+	}()
+	for i, stage := range s.stages {
+		state := &runState{
+			strict:         m.StrictMode,
+			stats:          m.stats,
+			retryBudget:    m.RetryBudget,
+			maxRetryDelay:  m.maxRetryDelay,
+			defaultTimeout: m.DefaultPhaseTimeout,
+			limit:          m.limit,
+			clock:          s.clock,
+			flags:          m.flags,
+			contracts:      m.contracts,
+		}
+		go func(i int, stage *streamStage) {
+			defer wg.Done()
+			s.runStage(withRunState(ctx, state), i, stage)
+		}(i, stage)
+	}
+	go func() {
+		wg.Wait()
+		close(s.results)
+	}()
+	return s
+}
+
+// Results returns the channel receiving the results of the stream in order.
+func (s *Stream) Results() <-chan StreamResult {
+	return s.results
+}
+
+// Channels returns the outputs and the errors of the stream on separate
+// channels, like the outputs and errors returned by RunEach: the outputs of
+// the values that ran through every phase are received in order from the
+// first channel, while the errors of the failed values are received from the
+// second one, followed by the reason the stream stopped, if it did. Both are
+// closed once the stream ends, and are to be received from until then, such
+// as using a select. Once the stream stopped, the results not received yet
+// are discarded, and the reason it stopped waits in the buffer of the errors
+// channel, so that receivers may stop receiving.
+//
+// Channels and Results receive the same results, so that only one of them is
+// to be used.
+func (s *Stream) Channels() (<-chan interface{}, <-chan error) {
+	// The buffer of errs holds the reason the stream stopped for receivers
+	// that stopped receiving
+	values, errs := make(chan interface{}), make(chan error, 1)
+	go func() {
+		defer close(values)
+		defer close(errs)
+		for result := range s.results {
+			if result.Err != nil {
+				select {
+				case errs <- result.Err:
+				case <-s.ctx.Done():
+				}
+				continue
+			}
+			select {
+			case values <- result.Value:
+			case <-s.ctx.Done():
+			}
+		}
+		if err := s.Err(); err != nil {
+			select {
+			case errs <- err:
+			default:
+				// The unreceived error is discarded for the reason the
+				// stream stopped
+				select {
+				case <-errs:
+				default:
+				}
+				errs <- err
+			}
+		}
+	}()
+	return values, errs
+}
+
+// Stop stops the stream, discarding the values it holds. Results is closed
+// once every stage stopped.
+func (s *Stream) Stop() {
+	s.cancel(context.Canceled)
+}
+
+// Err returns the reason the stream stopped, or nil while it runs or when it
+// ended because its input was closed.
+func (s *Stream) Err() error {
+	if s.ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(s.ctx)
+}
+
+// StreamStats returns the statistics of the stages of the stream, in the
+// order of the phases. It is safe to call while the stream runs.
+func (s *Stream) StreamStats() []StageStats {
+	stats := make([]StageStats, len(s.stages))
+	for i, stage := range s.stages {
+		stats[i] = StageStats{
+			Phase:     stage.phase.Name,
+			Buffer:    cap(stage.in),
+			Queued:    len(stage.in),
+			Processed: atomic.LoadInt64(&stage.processed),
+			Dropped:   atomic.LoadInt64(&stage.dropped),
+			Blocked:   time.Duration(atomic.LoadInt64(&stage.blocked)),
+		}
+	}
+	return stats
+}
+
+// feed hands the values received from in to the first stage.
+func (s *Stream) feed(in <-chan interface{}) {
+	if len(s.stages) > 0 {
+		defer close(s.stages[0].in)
+	}
+	for {
+		select {
+		case value, ok := <-in:
+			if !ok {
+				return
+			}
+			if !s.send(0, streamItem{value: value}) {
+				return
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// runStage runs the phase of stage, the stage at index i, on the values it
+// receives.
+func (s *Stream) runStage(ctx context.Context, i int, stage *streamStage) {
+	if i+1 < len(s.stages) {
+		defer close(s.stages[i+1].in)
+	}
+	for {
+		var item streamItem
+		var ok bool
+		select {
+		case item, ok = <-stage.in:
+			if !ok || ctx.Err() != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		if item.err == nil && !item.stopped && !stage.phase.skipped(ctx) {
+			item = stage.run(ctx, item.value)
+		}
+		atomic.AddInt64(&stage.processed, 1)
+		if !s.send(i+1, item) {
+			return
+		}
+	}
+}
+
+// run runs the phase of the stage on value.
+func (stage *streamStage) run(ctx context.Context, value interface{}) streamItem {
+	p := stage.phase
+	output, err := p.runContext(withPhaseResult(ctx, &PhaseResult{Phase: p.Name}), value)
+	var rejection *RejectionError
+	switch {
+	case err == nil || errors.As(err, &rejection):
+		return streamItem{value: output}
+	case errors.Is(err, ErrStopPipeline):
+		return streamItem{value: output, stopped: true}
+	case p.NonCritical:
+		// Non-critical failures pass the phase's input on
+		return streamItem{value: value}
+	}
+	var phaseErr *PhaseError
+	if !errors.As(err, &phaseErr) || phaseErr.Phase != p.Name {
+		err = &PhaseError{Phase: p.Name, Err: err}
+	}
+	return streamItem{value: value, err: err}
+}
+
+// send hands item to the stage at index i, or to Results past the last
+// stage, applying the overflow policy when its buffer stays full. It returns
+// false when the stream stopped.
+func (s *Stream) send(i int, item streamItem) bool {
+	if s.ctx.Err() != nil {
+		return false
+	}
+	if i == len(s.stages) {
+		select {
+		case s.results <- StreamResult{Value: item.value, Err: item.err}:
+			return true
+		case <-s.ctx.Done():
+			return false
+		}
+	}
+
+	stage := s.stages[i]
+	select {
+	case stage.in <- item:
+		return true
+	default:
+	}
+
+	started := s.clock.Now()
+	defer func() { atomic.AddInt64(&stage.blocked, int64(s.clock.Now().Sub(started))) }()
+	var overflow <-chan time.Time
+	if s.config.policy.fail || s.config.policy.onDrop != nil {
+		overflow = s.clock.After(s.config.after)
+	}
+	select {
+	case stage.in <- item:
+		return true
+	case <-overflow:
+	case <-s.ctx.Done():
+		return false
+	}
+
+	if s.config.policy.fail {
+		s.cancel(fmt.Errorf("%w: the buffer of phase %s stayed full for %v", ErrStreamOverflow, stage.phase.Name, s.config.after))
+		return false
+	}
+	atomic.AddInt64(&stage.dropped, 1)
+	s.config.policy.onDrop(item.value)
+	return true
+}
@@ -0,0 +1,323 @@
+package phaser
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamInput returns a closed channel holding values.
+func streamInput(values ...interface{}) <-chan interface{} {
+	in := make(chan interface{}, len(values))
+	for _, v := range values {
+		in <- v
+	}
+	close(in)
+	return in
+}
+
+// collect receives the results of s until it ends.
+func collect(s *Stream) []StreamResult {
+	var results []StreamResult
+	for result := range s.Results() {
+		results = append(results, result)
+	}
+	return results
+}
+
+// slowPipeline returns a pipeline whose middle phase waits for release
+// before handling each value.
+func slowPipeline(t *testing.T, release <-chan struct{}) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("fast", passValue),
+		NewPhase("slow", func(value interface{}) (interface{}, error) {
+			<-release
+			return value, nil
+		}),
+		NewPhase("sink", passValue),
+	))
+	return m
+}
+
+func TestRunStream(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("add", addOne),
+		NewPhase("check", func(value interface{}) (interface{}, error) {
+			if value.(int) == 3 {
+				return nil, errNotFound
+			}
+			return value, nil
+		}),
+		NewPhase("optional", failWith(errNotFound), WithNonCritical()),
+		NewPhase("double", func(value interface{}) (interface{}, error) {
+			return value.(int) * 2, nil
+		}),
+	))
+
+	s := m.RunStream(context.Background(), streamInput(0, 1, 2, 3))
+	results := collect(s)
+	require.Len(t, results, 4)
+	assert.Equal(t, StreamResult{Value: 2}, results[0])
+	assert.Equal(t, StreamResult{Value: 4}, results[1])
+	// The failed value skipped the remaining phases
+	assert.Equal(t, 3, results[2].Value)
+	assert.ErrorIs(t, results[2].Err, errNotFound)
+	var phaseErr *PhaseError
+	require.ErrorAs(t, results[2].Err, &phaseErr)
+	assert.Equal(t, "check", phaseErr.Phase)
+	assert.Equal(t, StreamResult{Value: 8}, results[3])
+	assert.NoError(t, s.Err())
+
+	for _, stats := range s.StreamStats() {
+		assert.Equal(t, int64(4), stats.Processed, stats.Phase)
+		assert.Equal(t, DefaultStageBuffer, stats.Buffer)
+	}
+}
+
+func TestRunStreamBackPressure(t *testing.T) {
+	release := make(chan struct{})
+	in := make(chan interface{})
+	s := slowPipeline(t, release).RunStream(context.Background(), in, WithStageBuffer("slow", 2))
+	go func() {
+		for i := 0; i < 10; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+
+	// The slow phase holds a value and its buffer two, while the fast
+	// phase waits to hand it a fourth one
+	require.Eventually(t, func() bool {
+		stats := s.StreamStats()
+		return stats[0].Processed == 4 && stats[0].Queued == 1 && stats[1].Queued == 2
+	}, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stats := s.StreamStats()
+	assert.Equal(t, int64(4), stats[0].Processed)
+	assert.Equal(t, int64(0), stats[1].Processed)
+	assert.Equal(t, 2, stats[1].Buffer)
+
+	close(release)
+	results := collect(s)
+	require.Len(t, results, 10)
+	for i, result := range results {
+		assert.Equal(t, StreamResult{Value: i}, result)
+	}
+	stats = s.StreamStats()
+	assert.Greater(t, stats[1].Blocked, 10*time.Millisecond)
+	assert.Zero(t, stats[1].Dropped)
+}
+
+func TestRunStreamDropNewest(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var dropped []interface{}
+	s := slowPipeline(t, release).RunStream(context.Background(), streamInput(0, 1, 2, 3, 4, 5),
+		WithOverflowPolicy(DropNewestValue(func(value interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			dropped = append(dropped, value)
+		}), 50*time.Millisecond))
+
+	// The stages before the slow one keep running while it is stuck, the
+	// input dropping values too while the fast phase waits for room
+	require.Eventually(t, func() bool {
+		stats := s.StreamStats()
+		return stats[0].Dropped+stats[1].Dropped == 4
+	}, time.Second, time.Millisecond)
+	close(release)
+	results := collect(s)
+
+	// The slow phase got the first value, and its buffer the second one
+	assert.Equal(t, []StreamResult{{Value: 0}, {Value: 1}}, results)
+	assert.ElementsMatch(t, []interface{}{2, 3, 4, 5}, dropped)
+	stats := s.StreamStats()
+	assert.Positive(t, stats[1].Dropped)
+	assert.Equal(t, int64(2), stats[1].Processed)
+	assert.NoError(t, s.Err())
+}
+
+func TestRunStreamFailStream(t *testing.T) {
+	release := make(chan struct{})
+	s := slowPipeline(t, release).RunStream(context.Background(), streamInput(0, 1, 2, 3),
+		WithOverflowPolicy(FailStream, 5*time.Millisecond))
+
+	require.Eventually(t, func() bool {
+		return s.Err() != nil
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, s.Err(), ErrStreamOverflow)
+	assert.Contains(t, s.Err().Error(), "phase slow")
+	close(release)
+	assert.Empty(t, collect(s))
+}
+
+func TestRunStreamStop(t *testing.T) {
+	release := make(chan struct{})
+	in := make(chan interface{})
+	s := slowPipeline(t, release).RunStream(context.Background(), in)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case in <- i:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+	require.Eventually(t, func() bool {
+		stats := s.StreamStats()
+		return stats[0].Queued == 1 && stats[1].Queued == 1
+	}, time.Second, time.Millisecond)
+
+	// Stopping with values left in the buffers ends every stage
+	s.Stop()
+	close(release)
+	assert.Empty(t, collect(s))
+	assert.ErrorIs(t, s.Err(), context.Canceled)
+	stats := s.StreamStats()
+	assert.Equal(t, 1, stats[0].Queued)
+	assert.Equal(t, 1, stats[1].Queued)
+}
+
+// drain receives from values and errs until both are closed.
+func drain(values <-chan interface{}, errs <-chan error) ([]interface{}, []error) {
+	var outputs []interface{}
+	var failures []error
+	for values != nil || errs != nil {
+		select {
+		case value, ok := <-values:
+			if !ok {
+				values = nil
+				continue
+			}
+			outputs = append(outputs, value)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			failures = append(failures, err)
+		}
+	}
+	return outputs, failures
+}
+
+func TestStreamChannels(t *testing.T) {
+	const n = 100
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("add", addOne),
+		NewPhase("check", func(value interface{}) (interface{}, error) {
+			if value.(int)%10 == 0 {
+				return nil, errNotFound
+			}
+			return value, nil
+		}),
+		NewPhase("double", func(value interface{}) (interface{}, error) {
+			return value.(int) * 2, nil
+		}),
+	))
+
+	in := make(chan interface{})
+	go func() {
+		for i := 0; i < n; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+	s := m.RunStream(context.Background(), in, WithStageBuffer("double", 4))
+	outputs, failures := drain(s.Channels())
+
+	var expected []interface{}
+	for i := 0; i < n; i++ {
+		if (i+1)%10 != 0 {
+			expected = append(expected, (i+1)*2)
+		}
+	}
+	assert.Equal(t, expected, outputs)
+	require.Len(t, failures, n/10)
+	for _, err := range failures {
+		assert.ErrorIs(t, err, errNotFound)
+	}
+	assert.NoError(t, s.Err())
+	for _, stats := range s.StreamStats() {
+		assert.Equal(t, int64(n), stats.Processed, stats.Phase)
+		assert.Zero(t, stats.Queued, stats.Phase)
+	}
+}
+
+func TestStreamChannelsCancel(t *testing.T) {
+	release := make(chan struct{})
+	in := make(chan interface{})
+	ctx, cancel := context.WithCancel(context.Background())
+	s := slowPipeline(t, release).RunStream(ctx, in)
+	values, errs := s.Channels()
+	in <- 0
+	in <- 1
+
+	// Canceling ends every stage and closes both channels, leaving the
+	// input open
+	cancel()
+	close(release)
+	outputs, failures := drain(values, errs)
+	assert.Empty(t, outputs)
+	require.Len(t, failures, 1)
+	assert.ErrorIs(t, failures[0], context.Canceled)
+}
+
+func TestRunStreamOverflowClock(t *testing.T) {
+	clock := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	release := make(chan struct{})
+	m := slowPipeline(t, release)
+	WithClock(clock)(m)
+	s := m.RunStream(context.Background(), streamInput(0, 1, 2, 3),
+		WithOverflowPolicy(FailStream, time.Second))
+
+	// The overflow waits for the manager's clock
+	require.Eventually(t, func() bool {
+		stats := s.StreamStats()
+		return stats[0].Processed == 3 && stats[1].Queued == 1
+	}, time.Second, time.Millisecond)
+	// Lets the fast phase start waiting for room
+	time.Sleep(10 * time.Millisecond)
+	clock.advance(999 * time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, s.Err())
+	clock.advance(time.Millisecond)
+	require.Eventually(t, func() bool {
+		return s.Err() != nil
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, s.Err(), ErrStreamOverflow)
+	close(release)
+	assert.Empty(t, collect(s))
+	assert.Equal(t, time.Second, s.StreamStats()[1].Blocked)
+}
+
+func TestStreamChannelsStopWithoutReceiving(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	in := make(chan interface{})
+	s := slowPipeline(t, release).RunStream(context.Background(), in)
+	values, errs := s.Channels()
+	in <- 0
+
+	// Nothing is received until the channels are closed
+	s.Stop()
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-values:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	_, ok := <-errs
+	assert.False(t, ok)
+}
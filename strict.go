@@ -0,0 +1,28 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrStrictMode is wrapped by the errors returned when a hook violates the
+// StrictMode checks.
+var ErrStrictMode = errors.New("strict mode violation")
+
+// checkHookValue checks the value returned by the hook at index i of stage
+// against the StrictMode rules: hooks may not return nil values unless the
+// phase allows them, and may not change the concrete type of the value.
+func (p *Phase) checkHookValue(stage Stage, i int, input, output interface{}) error {
+	if output == nil {
+		if p.AllowNilValues {
+			return nil
+		}
+		return fmt.Errorf("%w: %s %d of phase %s returned a nil value", ErrStrictMode, stage, i, p.Name)
+	}
+	if input != nil && reflect.TypeOf(input) != reflect.TypeOf(output) {
+		return fmt.Errorf("%w: %s %d of phase %s changed the value type from %T to %T",
+			ErrStrictMode, stage, i, p.Name, input, output)
+	}
+	return nil
+}
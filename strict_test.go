@@ -0,0 +1,57 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// returnNil is a hook returning a nil value.
+func returnNil(value interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+// toString is a hook changing the value's concrete type.
+func toString(value interface{}) (interface{}, error) {
+	return "changed", nil
+}
+
+func TestStrictModeNilHookValue(t *testing.T) {
+	m := NewPhaseManager()
+	m.StrictMode = true
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	require.NoError(t, m.AddPostHookToPhase("one", returnNil))
+
+	_, err := m.Run(0)
+	assert.ErrorIs(t, err, ErrStrictMode)
+	assert.Contains(t, err.Error(), "post-hook 0 of phase one returned a nil value")
+
+	// Nil values are accepted when allowed by the phase
+	m.phases[0].AllowNilValues = true
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestStrictModeTypeChange(t *testing.T) {
+	m := NewPhaseManager()
+	m.StrictMode = true
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	require.NoError(t, m.AddPreHookToPhase("one", toString))
+
+	_, err := m.Run(0)
+	assert.ErrorIs(t, err, ErrStrictMode)
+	assert.Contains(t, err.Error(), "pre-hook 0 of phase one changed the value type from int to string")
+}
+
+func TestStrictModeDisabled(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	require.NoError(t, m.AddPostHookToPhase("one", toString))
+	require.NoError(t, m.AddPostHookToPhase("one", returnNil))
+
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
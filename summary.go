@@ -0,0 +1,140 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// defaultErrorSamples is the default number of sample errors kept per error
+// class by batch summaries.
+const defaultErrorSamples = 3
+
+// BatchSummary aggregates the outcome of the runs of a RunEach call.
+type BatchSummary struct {
+	// Total is the number of values ran
+	Total int `json:"total"`
+	// Succeeded is the number of runs that succeeded
+	Succeeded int `json:"succeeded"`
+	// Failed is the number of runs that failed
+	Failed int `json:"failed"`
+	// PhaseFailures counts the failed runs by the name of their failing
+	// phase. Runs failing outside of a phase, such as on an invalid input,
+	// are not counted
+	PhaseFailures map[string]int `json:"phaseFailures,omitempty"`
+	// Classes groups the errors of the failed runs by class, most frequent
+	// first
+	Classes []ErrorClass `json:"classes,omitempty"`
+}
+
+// ErrorClass is a group of errors of a batch sharing a classification key.
+type ErrorClass struct {
+	// Key is the key the errors were classified as
+	Key string `json:"key"`
+	// Count is the number of errors in the class
+	Count int `json:"count"`
+	// Samples contains the messages of the first errors of the class, in
+	// the order of their values
+	Samples []string `json:"samples,omitempty"`
+}
+
+// WithBatchSummary fills summary with the aggregated outcome of the runs.
+func WithBatchSummary(summary *BatchSummary) BatchOption {
+	return func(c *batchConfig) {
+		c.summary = summary
+	}
+}
+
+// WithErrorClassifier sets the function returning the class of the errors of
+// the batch summary. By default, errors are classified by the name of the
+// failing phase and the type of the error it returned.
+func WithErrorClassifier(classify func(err error) string) BatchOption {
+	return func(c *batchConfig) {
+		c.classify = classify
+	}
+}
+
+// WithErrorSamples keeps up to k sample errors per class in the batch
+// summary, which defaults to 3. Zero keeps no samples.
+func WithErrorSamples(k int) BatchOption {
+	return func(c *batchConfig) {
+		c.samples = k
+	}
+}
+
+// ClassifyError is the default error classifier of batch summaries. Errors
+// failing a phase are classified as the name of the phase followed by the type
+// of the error the phase returned, and other errors as their type.
+func ClassifyError(err error) string {
+	var phaseErr *PhaseError
+	if errors.As(err, &phaseErr) && phaseErr.Err != nil {
+		return fmt.Sprintf("%s: %T", phaseErr.Phase, phaseErr.Err)
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// summarize returns the summary of the runs that returned errs.
+func summarize(errs []error, classify func(error) string, samples int) BatchSummary {
+	summary := BatchSummary{Total: len(errs)}
+	classes := make(map[string]*ErrorClass)
+	for _, err := range errs {
+		if err == nil {
+			summary.Succeeded++
+			continue
+		}
+		summary.Failed++
+
+		var phaseErr *PhaseError
+		if errors.As(err, &phaseErr) {
+			if summary.PhaseFailures == nil {
+				summary.PhaseFailures = make(map[string]int)
+			}
+			summary.PhaseFailures[phaseErr.Phase]++
+		}
+		key := classify(err)
+		class, ok := classes[key]
+		if !ok {
+			class = &ErrorClass{Key: key}
+			classes[key] = class
+		}
+		class.Count++
+		if len(class.Samples) < samples {
+			class.Samples = append(class.Samples, err.Error())
+		}
+	}
+
+	for _, class := range classes {
+		summary.Classes = append(summary.Classes, *class)
+	}
+	sort.Slice(summary.Classes, func(i, j int) bool {
+		a, b := summary.Classes[i], summary.Classes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Key < b.Key
+	})
+	return summary
+}
+
+// String returns the summary as a human readable table of its error classes.
+func (s BatchSummary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d runs: %d succeeded, %d failed\n", s.Total, s.Succeeded, s.Failed)
+	if len(s.Classes) == 0 {
+		return b.String()
+	}
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLASS\tCOUNT\tSAMPLE")
+	for _, class := range s.Classes {
+		sample := ""
+		if len(class.Samples) > 0 {
+			sample = class.Samples[0]
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", class.Key, class.Count, sample)
+	}
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package phaser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeError is returned for values out of range.
+type rangeError struct {
+	value int
+}
+
+func (e rangeError) Error() string {
+	return fmt.Sprintf("%d out of range", e.value)
+}
+
+// summaryManager returns a manager failing in three distinct ways.
+func summaryManager(t *testing.T) *DefaultPhaseManager {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("parse", func(value interface{}) (interface{}, error) {
+			if value.(int)%5 == 0 {
+				return nil, errors.New("unparsable")
+			}
+			return value, nil
+		}),
+		NewPhase("validate", func(value interface{}) (interface{}, error) {
+			switch {
+			case value.(int)%3 == 0:
+				return nil, rangeError{value.(int)}
+			case value.(int) == 7:
+				return nil, errors.New("unlucky")
+			}
+			return value, nil
+		}),
+	))
+	return m
+}
+
+// batchValues returns the values from 1 to n.
+func batchValues(n int) []interface{} {
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = i + 1
+	}
+	return values
+}
+
+func TestBatchSummary(t *testing.T) {
+	var summary BatchSummary
+	_, errs := summaryManager(t).RunEach(batchValues(10), WithBatchSummary(&summary), WithErrorSamples(2))
+	require.Len(t, errs, 10)
+
+	assert.Equal(t, BatchSummary{
+		Total:         10,
+		Succeeded:     4,
+		Failed:        6,
+		PhaseFailures: map[string]int{"parse": 2, "validate": 4},
+		Classes: []ErrorClass{
+			{Key: "validate: phaser.rangeError", Count: 3, Samples: []string{
+				"phase validate: 3 out of range",
+				"phase validate: 6 out of range",
+			}},
+			{Key: "parse: *errors.errorString", Count: 2, Samples: []string{
+				"phase parse: unparsable",
+				"phase parse: unparsable",
+			}},
+			{Key: "validate: *errors.errorString", Count: 1, Samples: []string{
+				"phase validate: unlucky",
+			}},
+		},
+	}, summary)
+
+	data, err := json.Marshal(summary)
+	require.NoError(t, err)
+	var decoded BatchSummary
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, summary, decoded)
+
+	assert.Equal(t, "10 runs: 4 succeeded, 6 failed\n"+
+		"CLASS                          COUNT  SAMPLE\n"+
+		"validate: phaser.rangeError    3      phase validate: 3 out of range\n"+
+		"parse: *errors.errorString     2      phase parse: unparsable\n"+
+		"validate: *errors.errorString  1      phase validate: unlucky\n", summary.String())
+}
+
+func TestBatchSummaryClassifier(t *testing.T) {
+	var summary BatchSummary
+	summaryManager(t).RunEach(batchValues(10), WithBatchSummary(&summary), WithErrorSamples(0),
+		WithErrorClassifier(func(err error) string {
+			var rangeErr rangeError
+			if errors.As(err, &rangeErr) {
+				return "range"
+			}
+			return "other"
+		}))
+
+	// Classes as frequent are sorted by key
+	assert.Equal(t, []ErrorClass{
+		{Key: "other", Count: 3},
+		{Key: "range", Count: 3},
+	}, summary.Classes)
+}
+
+func TestBatchSummarySucceeded(t *testing.T) {
+	var summary BatchSummary
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	m.RunEach(batchValues(3), WithBatchSummary(&summary))
+
+	assert.Equal(t, BatchSummary{Total: 3, Succeeded: 3}, summary)
+	assert.Equal(t, "3 runs: 3 succeeded, 0 failed\n", summary.String())
+	assert.Equal(t, "*errors.errorString", ClassifyError(assert.AnError))
+}
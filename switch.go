@@ -0,0 +1,55 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultCase is the case recorded when a switch phase runs its default case.
+const DefaultCase = "default"
+
+// ErrUnknownCase is returned when the key of a switch phase has no case and
+// there is no default case.
+var ErrUnknownCase = errors.New("unknown case")
+
+// SwitchPhase returns a phase named name routing its input to one of cases.
+// After the phase's pre-hooks run, keyFn derives the key of the case to run
+// from the value, and defaultCase runs when there is no case for the key. The
+// post-hooks run on the output of the selected case, which is recorded as the
+// Case of the phase's result.
+func SwitchPhase(name string, keyFn func(value interface{}) (string, error),
+	cases map[string]func(interface{}) (interface{}, error), defaultCase func(interface{}) (interface{}, error)) *Phase {
+	p := &Phase{Name: name}
+	p.executeContext = func(ctx context.Context, value interface{}) (interface{}, error) {
+		key, err := keyFn(value)
+		if err != nil {
+			return nil, err
+		}
+		execute, ok := cases[key]
+		if !ok {
+			if defaultCase == nil {
+				return nil, fmt.Errorf("%w %q, available cases are %v", ErrUnknownCase, key, caseKeys(cases))
+			}
+			execute, key = defaultCase, DefaultCase
+		}
+
+		state := runStateFrom(ctx)
+		state.trace.printf(name, "case %q selected", key)
+		state.decided(name, key)
+		phaseResultFrom(ctx).Case = key
+		return execute(value)
+	}
+	return p
+}
+
+// caseKeys returns the sorted keys of cases.
+func caseKeys(cases map[string]func(interface{}) (interface{}, error)) []string {
+	keys := make([]string, 0, len(cases))
+	for key := range cases {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,71 @@
+package phaser
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parserSwitch returns a switch phase parsing file names by extension.
+func parserSwitch(defaultCase func(interface{}) (interface{}, error)) *Phase {
+	return SwitchPhase("parse", func(value interface{}) (string, error) {
+		return path.Ext(value.(string)), nil
+	}, map[string]func(interface{}) (interface{}, error){
+		".json": func(value interface{}) (interface{}, error) {
+			return "json:" + value.(string), nil
+		},
+		".csv": func(value interface{}) (interface{}, error) {
+			return "csv:" + value.(string), nil
+		},
+	}, defaultCase)
+}
+
+func TestSwitchPhaseCases(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		cas   string
+	}{
+		{"A.JSON", "json:a.json!", ".json"},
+		{"B.CSV", "csv:b.csv!", ".csv"},
+		{"C.TXT", "raw:c.txt!", DefaultCase},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := parserSwitch(func(value interface{}) (interface{}, error) {
+				return "raw:" + value.(string), nil
+			})
+			// The pre-hook runs before the key is derived
+			p.appendPreHook(func(value interface{}) (interface{}, error) {
+				return strings.ToLower(value.(string)), nil
+			})
+			p.appendPostHook(func(value interface{}) (interface{}, error) {
+				return value.(string) + "!", nil
+			})
+
+			var report RunReport
+			o := &recordingObserver{}
+			m := NewPhaseManager(WithObserver(o))
+			require.NoError(t, m.AddPhase(p))
+
+			value, err := m.Run(tt.input, WithReport(&report))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, value)
+
+			result, ok := report.Result("parse")
+			require.True(t, ok)
+			assert.Equal(t, tt.cas, result.Case)
+			assert.Equal(t, tt.cas, o.results[0].Case)
+		})
+	}
+}
+
+func TestSwitchPhaseUnknownCase(t *testing.T) {
+	_, err := parserSwitch(nil).run("c.txt")
+	assert.ErrorIs(t, err, ErrUnknownCase)
+	assert.EqualError(t, err, `unknown case ".txt", available cases are [.csv .json]`)
+}
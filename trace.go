@@ -0,0 +1,204 @@
+package phaser
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultTraceValueLen is the default maximum length of the values printed by
+// debug traces.
+const defaultTraceValueLen = 80
+
+// TraceOption configures a debug trace.
+type TraceOption func(t *debugTrace)
+
+// WithTraceValueLen truncates the values printed by the trace to n bytes. A
+// non-positive n disables truncation.
+func WithTraceValueLen(n int) TraceOption {
+	return func(t *debugTrace) {
+		t.maxValueLen = n
+	}
+}
+
+// WithTraceValues sets whether the trace prints values. Values are printed by
+// default, so it should be disabled when values may contain secrets.
+func WithTraceValues(print bool) TraceOption {
+	return func(t *debugTrace) {
+		t.values = print
+	}
+}
+
+// WithTracePhases limits the trace to the phases named names. Run level
+// events are always traced.
+func WithTracePhases(names ...string) TraceOption {
+	return func(t *debugTrace) {
+		t.phases = make(map[string]bool, len(names))
+		for _, name := range names {
+			t.phases[name] = true
+		}
+	}
+}
+
+// WithTraceFormatter sets the function formatting the values printed by the
+// trace, which defaults to the %#v verb.
+func WithTraceFormatter(format func(value interface{}) string) TraceOption {
+	return func(t *debugTrace) {
+		t.format = format
+	}
+}
+
+// WithDebugTrace writes a human readable trace of every run to w, detailing
+// each phase and hook as it runs. Every line is prefixed with the ID of its
+// run so that the lines of concurrent runs can be told apart.
+func WithDebugTrace(w io.Writer, opts ...TraceOption) ManagerOption {
+	t := newDebugTrace(w, opts)
+	return func(m *DefaultPhaseManager) {
+		m.trace = t
+	}
+}
+
+// WithPhaseDebugTrace is like WithDebugTrace for a single phase. It only
+// applies to runs without a trace of their own, such as runs of a manager
+// without WithDebugTrace.
+func WithPhaseDebugTrace(w io.Writer, opts ...TraceOption) PhaseOption {
+	t := newDebugTrace(w, opts)
+	return func(p *Phase) {
+		p.trace = t
+	}
+}
+
+// debugTrace writes the debug trace of the runs of a manager or phase.
+type debugTrace struct {
+	// mu serializes the writes to w
+	mu sync.Mutex
+	w  io.Writer
+	// runs counts the traced runs, and is used to assign their IDs
+	runs        uint64
+	maxValueLen int
+	values      bool
+	// phases contains the names of the traced phases. Every phase is traced
+	// when nil
+	phases map[string]bool
+	format func(value interface{}) string
+	// now overrides the clock of the traced runs when set
+	now func() time.Time
+}
+
+// newDebugTrace returns a trace writing to w configured with opts.
+func newDebugTrace(w io.Writer, opts []TraceOption) *debugTrace {
+	t := &debugTrace{
+		w:           w,
+		maxValueLen: defaultTraceValueLen,
+		values:      true,
+		format: func(value interface{}) string {
+			return fmt.Sprintf("%#v", value)
+		},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// start returns the trace of a new run timed by clock, unless the trace has
+// a clock of its own.
+func (t *debugTrace) start(clock Clock) *runTrace {
+	id := atomic.AddUint64(&t.runs, 1)
+	now := t.now
+	if now == nil {
+		now = clock.Now
+	}
+	return &runTrace{debugTrace: t, id: fmt.Sprintf("run-%d", id), now: now}
+}
+
+// runTrace is the debug trace of a single run. A nil *runTrace traces
+// nothing.
+type runTrace struct {
+	*debugTrace
+	id string
+	// now returns the current time, shadowing the clock of the debugTrace
+	now func() time.Time
+}
+
+// traces reports whether events of the phase named phase are traced.
+func (r *runTrace) traces(phase string) bool {
+	return r != nil && (r.phases == nil || r.phases[phase])
+}
+
+// printf writes a trace line for the phase named phase. Run level lines have
+// an empty phase.
+func (r *runTrace) printf(phase string, format string, args ...interface{}) {
+	if r == nil || phase != "" && !r.traces(phase) {
+		return
+	}
+	r.printfAt(r.now(), phase, format, args...)
+}
+
+// printfAt is like printf for a line written at time at.
+func (r *runTrace) printfAt(at time.Time, phase string, format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	if phase != "" {
+		line = "phase " + phase + ": " + line
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, "[%s] %s %s\n", r.id, at.Format("15:04:05.000"), line)
+}
+
+// value formats value for the trace.
+func (r *runTrace) value(value interface{}) string {
+	if !r.values {
+		return ""
+	}
+	s := r.format(value)
+	if r.maxValueLen > 0 && len(s) > r.maxValueLen {
+		s = s[:r.maxValueLen] + "..."
+	}
+	return " value=" + s
+}
+
+// started writes the line opening a step of the phase named phase processing
+// value, returning the time the step started.
+func (r *runTrace) started(phase, step string, value interface{}) time.Time {
+	now := r.now()
+	r.printfAt(now, phase, "%s started%s", step, r.value(value))
+	return now
+}
+
+// finished writes the line closing a step of the phase named phase which
+// started at started, returning output or err.
+func (r *runTrace) finished(phase, step string, started time.Time, output interface{}, err error) {
+	now := r.now()
+	if err != nil {
+		r.printfAt(now, phase, "%s failed in %v: %v", step, now.Sub(started), err)
+		return
+	}
+	r.printfAt(now, phase, "%s finished in %v%s", step, now.Sub(started), r.value(output))
+}
+
+// hookLabel returns the label of the hook at index i of hooks, such as
+// "pre-hook 1 (auth)".
+func (p *Phase) hookLabel(hooks *[]PhaseHook, i int) string {
+	label := fmt.Sprintf("%s %d", p.hookStage(hooks), i)
+	if name := p.hookName(hooks, i); name != "" {
+		label += fmt.Sprintf(" (%s)", name)
+	}
+	return label
+}
+
+// traceHook runs hook, the hook at index i of stage, on value and traces it.
+func (p *Phase) traceHook(r *runTrace, stage Stage, i int, hook PhaseHook, value interface{}) (interface{}, error) {
+	hooks := &p.preHooks
+	if stage == StagePostHook {
+		hooks = &p.postHooks
+	}
+	step := p.hookLabel(hooks, i)
+	started := r.started(p.Name, step, value)
+	output, err := hook(value)
+	r.finished(p.Name, step, started, output, err)
+	return output, err
+}
@@ -0,0 +1,110 @@
+package phaser
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// fakeClock returns a trace option replacing the trace's clock with one that
+// starts at noon and advances by a millisecond on every reading.
+func fakeClock() TraceOption {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	return func(t *debugTrace) {
+		t.now = func() time.Time {
+			now = now.Add(time.Millisecond)
+			return now
+		}
+	}
+}
+
+// assertGolden compares got with the golden file named name, rewriting it
+// instead when the -update flag is set.
+func assertGolden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, got, 0644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestDebugTraceGolden(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewPhaseManager(WithDebugTrace(&buf, fakeClock(), WithTraceFormatter(func(value interface{}) string {
+		return fmt.Sprint(value)
+	})))
+
+	one := NewPhase("one", addOne)
+	one.AppendNamedPreHook("double", func(value interface{}) (interface{}, error) {
+		return value.(int) * 2, nil
+	})
+	two := NewPhase("two", addOne)
+	two.Disabled = true
+	require.NoError(t, m.AddPhases(
+		one,
+		two,
+		NewPhase("three", failWith(assert.AnError), WithNonCritical()),
+		NewPhase("four", addOne),
+	))
+
+	value, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, value)
+	assertGolden(t, "debug_trace.golden", buf.Bytes())
+}
+
+func TestDebugTraceOptions(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewPhaseManager(WithDebugTrace(&buf, fakeClock(), WithTracePhases("two"), WithTraceValueLen(4)))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", func(value interface{}) (interface{}, error) {
+			return "a long value", nil
+		}),
+	))
+
+	_, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, `[run-1] 12:00:00.001 run started value=1
+[run-1] 12:00:00.002 phase two: phase started value=2
+[run-1] 12:00:00.003 phase two: execute started value=2
+[run-1] 12:00:00.004 phase two: execute finished in 1ms value="a l...
+[run-1] 12:00:00.005 phase two: phase finished in 3ms value="a l...
+[run-1] 12:00:00.006 run finished in 5ms value="a l...
+`, buf.String())
+
+	// Values are hidden, and run IDs increase
+	buf.Reset()
+	m = NewPhaseManager(WithDebugTrace(&buf, fakeClock(), WithTraceValues(false)))
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+	for i := 0; i < 2; i++ {
+		_, err = m.Run(1)
+		require.NoError(t, err)
+	}
+	assert.NotContains(t, buf.String(), "value=")
+	assert.Contains(t, buf.String(), "[run-2] ")
+}
+
+func TestPhaseDebugTrace(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewPhase("one", addOne, WithPhaseDebugTrace(&buf, fakeClock()))
+
+	_, err := p.run(1)
+	require.NoError(t, err)
+	assert.Equal(t, `[run-1] 12:00:00.001 phase one: phase started value=1
+[run-1] 12:00:00.002 phase one: execute started value=1
+[run-1] 12:00:00.003 phase one: execute finished in 1ms value=2
+[run-1] 12:00:00.004 phase one: phase finished in 3ms value=2
+`, buf.String())
+}
@@ -0,0 +1,45 @@
+package phaser
+
+// Tracer starts a span for each phase ran, so that runs can be traced by
+// custom tracing backends.
+type Tracer interface {
+	// StartPhase starts the span of a run of the phase named name
+	StartPhase(name string) PhaseSpan
+}
+
+// PhaseSpan is the span of a run of a phase.
+type PhaseSpan interface {
+	// End ends the span with the error returned by the phase, if any
+	End(err error)
+}
+
+// NoopTracer is a Tracer whose spans do nothing. It is used by managers
+// without a tracer.
+type NoopTracer struct{}
+
+// StartPhase returns a span doing nothing.
+func (NoopTracer) StartPhase(name string) PhaseSpan {
+	return noopSpan{}
+}
+
+// noopSpan is the span of NoopTracer.
+type noopSpan struct{}
+
+func (noopSpan) End(err error) {}
+
+// WithTracer traces the phases of every run using tracer, starting a span
+// before the pre-hooks of each phase and ending it after its post-hooks.
+// Skipped phases are not traced.
+func WithTracer(tracer Tracer) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.tracer = tracer
+	}
+}
+
+// startSpan starts the span of the phase named phase using the run's tracer.
+func (s *runState) startSpan(phase string) PhaseSpan {
+	if s.tracer == nil {
+		return NoopTracer{}.StartPhase(phase)
+	}
+	return s.tracer.StartPhase(phase)
+}
@@ -0,0 +1,70 @@
+package phaser
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTracer records the spans it starts and ends.
+type fakeTracer struct {
+	mu      sync.Mutex
+	started []string
+	ended   map[string]error
+}
+
+func (t *fakeTracer) StartPhase(name string) PhaseSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = append(t.started, name)
+	return fakeSpan{tracer: t, name: name}
+}
+
+// fakeSpan is a span of a fakeTracer.
+type fakeSpan struct {
+	tracer *fakeTracer
+	name   string
+}
+
+func (s fakeSpan) End(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	if s.tracer.ended == nil {
+		s.tracer.ended = make(map[string]error)
+	}
+	s.tracer.ended[s.name] = err
+}
+
+func TestTracerSpans(t *testing.T) {
+	errTest := errors.New("post-hook failed")
+	tracer := &fakeTracer{}
+	m := NewPhaseManager(WithTracer(tracer))
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("skipped", addOne),
+		NewPhase("flaky", failWith(assert.AnError), WithNonCritical()),
+		NewPhase("two", addOne),
+	))
+	require.NoError(t, m.AddPostHookToPhase("two", failWith(errTest)))
+	m.phases[1].Disabled = true
+
+	_, err := m.Run(0)
+	assert.ErrorIs(t, err, errTest)
+	assert.Equal(t, []string{"one", "flaky", "two"}, tracer.started)
+	require.Len(t, tracer.ended, 3)
+	assert.NoError(t, tracer.ended["one"])
+	assert.ErrorIs(t, tracer.ended["flaky"], assert.AnError)
+	assert.ErrorIs(t, tracer.ended["two"], errTest)
+}
+
+func TestNoopTracer(t *testing.T) {
+	m := NewPhaseManager(WithTracer(NoopTracer{}))
+	require.NoError(t, m.AddPhase(NewPhase("one", addOne)))
+
+	value, err := m.Run(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
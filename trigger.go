@@ -0,0 +1,215 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTriggerClosed is returned by the runs of a Trigger fired after it was
+// closed, and by its pending runs cancelled by Close.
+var ErrTriggerClosed = errors.New("trigger closed")
+
+// TriggerOption configures a Trigger.
+type TriggerOption func(t *Trigger)
+
+// WithDebounce sets the debounce window of the trigger. The first fire of a
+// key opens a window of d, and the fires of the key within it are coalesced
+// into a single run of the latest value once it closes. The window is not
+// extended by later fires, so that a steady stream of fires still runs
+// every d. Non-positive windows, the default, run every fire at once.
+func WithDebounce(d time.Duration) TriggerOption {
+	return func(t *Trigger) {
+		t.debounce = d
+	}
+}
+
+// WithCoalesceKey sets the function returning the key of the fired values.
+// Only fires with the same key are coalesced. Every fire has the same key by
+// default.
+func WithCoalesceKey(key func(value interface{}) string) TriggerOption {
+	return func(t *Trigger) {
+		t.key = key
+	}
+}
+
+// WithTriggerCallback sets a function called with the key and handle of each
+// run of the trigger once it ends, including the pending runs cancelled by
+// Close.
+func WithTriggerCallback(callback func(key string, h *RunHandle)) TriggerOption {
+	return func(t *Trigger) {
+		t.callback = callback
+	}
+}
+
+// WithTriggerClock sets the clock timing the debounce windows, which defaults
+// to the system time.
+func WithTriggerClock(clock Clock) TriggerOption {
+	return func(t *Trigger) {
+		t.clock = clock
+	}
+}
+
+// WithRunOnClose makes Close start the pending runs instead of cancelling
+// them.
+func WithRunOnClose() TriggerOption {
+	return func(t *Trigger) {
+		t.runOnClose = true
+	}
+}
+
+// Trigger runs the pipeline of a manager on fired values, coalescing the
+// fires repeated within a debounce window, such as the bursts of events of a
+// filesystem watcher.
+type Trigger struct {
+	manager    *DefaultPhaseManager
+	debounce   time.Duration
+	key        func(value interface{}) string
+	callback   func(key string, h *RunHandle)
+	clock      Clock
+	runOnClose bool
+	// stop is closed by Close, ending the debounce windows
+	stop chan struct{}
+	// wg counts the runs in flight
+	wg sync.WaitGroup
+
+	mu sync.Mutex
+	// pending contains the runs waiting for their debounce window to close,
+	// by key
+	pending map[string]*pendingRun
+	closed  bool
+}
+
+// pendingRun is a run of a Trigger waiting for its debounce window to close.
+type pendingRun struct {
+	key string
+	// value is the latest value fired with the run's key
+	value  interface{}
+	handle *RunHandle
+}
+
+// NewTrigger returns a Trigger running the pipeline of m, configured with
+// opts.
+func NewTrigger(m *DefaultPhaseManager, opts ...TriggerOption) *Trigger {
+	t := &Trigger{
+		manager: m,
+		clock:   realClock{},
+		stop:    make(chan struct{}),
+		pending: make(map[string]*pendingRun),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Fire requests a run on value, returning the handle of the run. Fires
+// coalesced into the same run get the same handle, whose output is the one
+// of the latest value. A fire arriving as the window of its key closes
+// either makes it in time, or opens the next window.
+func (t *Trigger) Fire(value interface{}) *RunHandle {
+	key := ""
+	if t.key != nil {
+		key = t.key(value)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		h := &RunHandle{done: make(chan struct{})}
+		h.finish(value, ErrTriggerClosed)
+		return h
+	}
+	if p, ok := t.pending[key]; ok {
+		p.value = value
+		return p.handle
+	}
+
+	p := &pendingRun{key: key, value: value, handle: &RunHandle{done: make(chan struct{})}}
+	if t.debounce <= 0 {
+		t.start(p)
+		return p.handle
+	}
+	t.pending[key] = p
+	go t.wait(p)
+	return p.handle
+}
+
+// Flush starts the pending runs without waiting for their windows to close.
+func (t *Trigger) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, p := range t.pending {
+		delete(t.pending, key)
+		t.start(p)
+	}
+}
+
+// Close stops the trigger. Its pending runs are cancelled, failing with
+// ErrTriggerClosed, unless it uses WithRunOnClose. Close waits for the runs
+// in flight to end, and fires afterwards fail with ErrTriggerClosed.
+func (t *Trigger) Close() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		t.wg.Wait()
+		return
+	}
+	t.closed = true
+	close(t.stop)
+	var cancelled []*pendingRun
+	for key, p := range t.pending {
+		delete(t.pending, key)
+		if t.runOnClose {
+			t.start(p)
+		} else {
+			cancelled = append(cancelled, p)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, p := range cancelled {
+		p.handle.finish(p.value, ErrTriggerClosed)
+		if t.callback != nil {
+			t.callback(p.key, p.handle)
+		}
+	}
+	t.wg.Wait()
+}
+
+// wait starts p once its debounce window closes, unless it was started or
+// cancelled first.
+func (t *Trigger) wait(p *pendingRun) {
+	select {
+	case <-t.clock.After(t.debounce):
+	case <-t.stop:
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending[p.key] != p {
+		return
+	}
+	delete(t.pending, p.key)
+	t.start(p)
+}
+
+// start starts running p. t.mu must be held.
+func (t *Trigger) start(p *pendingRun) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		h := p.handle
+		if ctx, ok := h.start(context.Background()); !ok {
+			h.finish(p.value, &CancelError{Cause: ErrUserCancelled, Err: context.Canceled})
+		} else {
+			value, err := t.manager.RunContext(ctx, p.value, WithReport(&h.report))
+			h.cancel(nil)
+			h.finish(value, err)
+		}
+		if t.callback != nil {
+			t.callback(p.key, h)
+		}
+	}()
+}
@@ -0,0 +1,172 @@
+package phaser
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// windowClock is a Clock whose waits end when the test closes them.
+type windowClock struct {
+	testClock
+	mu      sync.Mutex
+	waiting []chan time.Time
+}
+
+func (c *windowClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiting = append(c.waiting, ch)
+	return ch
+}
+
+// elapse ends the oldest wait, once it started.
+func (c *windowClock) elapse(t *testing.T) {
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.waiting) > 0
+	}, time.Second, time.Millisecond)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waiting[0] <- time.Time{}
+	c.waiting = c.waiting[1:]
+}
+
+// recordManager returns a manager recording the values it runs on.
+func recordManager(t *testing.T) (*DefaultPhaseManager, func() []interface{}) {
+	var mu sync.Mutex
+	var values []interface{}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("record", func(value interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		values = append(values, value)
+		return value, nil
+	})))
+	return m, func() []interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]interface{}(nil), values...)
+	}
+}
+
+func TestTriggerCoalesces(t *testing.T) {
+	m, values := recordManager(t)
+	clock := &windowClock{}
+	trigger := NewTrigger(m, WithDebounce(time.Second), WithTriggerClock(clock), WithCoalesceKey(func(value interface{}) string {
+		return value.(string)[:1]
+	}))
+	defer trigger.Close()
+
+	a := trigger.Fire("a1")
+	assert.Same(t, a, trigger.Fire("a2"))
+	b := trigger.Fire("b1")
+	assert.NotSame(t, a, b)
+	assert.Same(t, a, trigger.Fire("a3"))
+
+	clock.elapse(t)
+	clock.elapse(t)
+	output, err := a.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, "a3", output)
+	output, err = b.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, "b1", output)
+	assert.ElementsMatch(t, []interface{}{"a3", "b1"}, values())
+
+	// Fires after the window closed open the next one
+	next := trigger.Fire("a4")
+	assert.NotSame(t, a, next)
+	clock.elapse(t)
+	output, _ = next.Wait()
+	assert.Equal(t, "a4", output)
+}
+
+func TestTriggerWithoutDebounce(t *testing.T) {
+	m, values := recordManager(t)
+	trigger := NewTrigger(m)
+	first, second := trigger.Fire(1), trigger.Fire(2)
+	assert.NotSame(t, first, second)
+	trigger.Close()
+	assert.ElementsMatch(t, []interface{}{1, 2}, values())
+}
+
+func TestTriggerCallback(t *testing.T) {
+	m, _ := recordManager(t)
+	var mu sync.Mutex
+	keys := map[string]*RunHandle{}
+	trigger := NewTrigger(m, WithDebounce(time.Hour), WithCoalesceKey(func(value interface{}) string {
+		return value.(string)
+	}), WithTriggerCallback(func(key string, h *RunHandle) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys[key] = h
+	}))
+	a, b := trigger.Fire("a"), trigger.Fire("b")
+	trigger.Flush()
+	trigger.Close()
+	assert.Equal(t, map[string]*RunHandle{"a": a, "b": b}, keys)
+	assert.Equal(t, "a", a.Report().Phases[0].Output)
+}
+
+func TestTriggerClose(t *testing.T) {
+	t.Run("cancels pending runs", func(t *testing.T) {
+		m, values := recordManager(t)
+		trigger := NewTrigger(m, WithDebounce(time.Hour))
+		h := trigger.Fire(1)
+		trigger.Close()
+		_, err := h.Wait()
+		assert.ErrorIs(t, err, ErrTriggerClosed)
+		assert.Empty(t, values())
+
+		_, err = trigger.Fire(2).Wait()
+		assert.ErrorIs(t, err, ErrTriggerClosed)
+	})
+
+	t.Run("runs pending runs", func(t *testing.T) {
+		m, values := recordManager(t)
+		trigger := NewTrigger(m, WithDebounce(time.Hour), WithRunOnClose())
+		h := trigger.Fire(1)
+		trigger.Close()
+		output, err := h.Wait()
+		require.NoError(t, err)
+		assert.Equal(t, 1, output)
+		assert.Equal(t, []interface{}{1}, values())
+	})
+
+	t.Run("window closing after close", func(t *testing.T) {
+		m, values := recordManager(t)
+		clock := &windowClock{}
+		trigger := NewTrigger(m, WithDebounce(time.Second), WithTriggerClock(clock))
+		h := trigger.Fire(1)
+		trigger.Close()
+		clock.elapse(t)
+		_, err := h.Wait()
+		assert.ErrorIs(t, err, ErrTriggerClosed)
+		assert.Empty(t, values())
+	})
+}
+
+func TestTriggerCancel(t *testing.T) {
+	m, values := recordManager(t)
+	trigger := NewTrigger(m, WithDebounce(time.Hour))
+	h := trigger.Fire(1)
+	h.Cancel()
+	trigger.Close()
+	_, err := h.Wait()
+	assert.ErrorIs(t, err, ErrTriggerClosed)
+
+	trigger = NewTrigger(m, WithDebounce(time.Hour))
+	h = trigger.Fire(2)
+	h.Cancel()
+	trigger.Flush()
+	_, err = h.Wait()
+	assert.ErrorIs(t, err, ErrUserCancelled)
+	trigger.Close()
+	assert.Empty(t, values())
+}
@@ -0,0 +1,174 @@
+package phaser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrTypeMismatch is returned when an untyped value passed to a typed phase is
+// not of the phase's input type.
+var ErrTypeMismatch = errors.New("type mismatch")
+
+// TypedPhase is a phase whose input and output types are checked by the
+// compiler. Typed phases can be composed using Chain2, Chain3 and Chain4, and
+// added to a manager using AsPhase.
+type TypedPhase[In, Out any] struct {
+	// Name contains the name of the phase
+	Name string
+	// execute performs the phase's action
+	execute func(value In) (Out, error)
+	// preHooks contains the hooks ran before execute
+	preHooks []func(value In) (In, error)
+	// postHooks contains the hooks ran after execute
+	postHooks []func(value Out) (Out, error)
+}
+
+// NewTypedPhase returns a typed phase named name that performs execute.
+func NewTypedPhase[In, Out any](name string, execute func(value In) (Out, error)) *TypedPhase[In, Out] {
+	return &TypedPhase[In, Out]{Name: name, execute: execute}
+}
+
+// AppendPreHook appends hook to the phase's pre-hooks.
+func (p *TypedPhase[In, Out]) AppendPreHook(hook func(value In) (In, error)) {
+	p.preHooks = append(p.preHooks, hook)
+}
+
+// AppendPostHook appends hook to the phase's post-hooks.
+func (p *TypedPhase[In, Out]) AppendPostHook(hook func(value Out) (Out, error)) {
+	p.postHooks = append(p.postHooks, hook)
+}
+
+// Run runs the phase's pre-hooks, execute function and post-hooks on value.
+func (p *TypedPhase[In, Out]) Run(value In) (Out, error) {
+	var output Out
+	var err error
+
+	for _, hook := range p.preHooks {
+		if value, err = hook(value); err != nil {
+			return output, err
+		}
+	}
+	if output, err = p.execute(value); err != nil {
+		return output, err
+	}
+	for _, hook := range p.postHooks {
+		if output, err = hook(output); err != nil {
+			return output, err
+		}
+	}
+
+	return output, nil
+}
+
+// AsPhase returns an untyped phase running p, so that it can be added to a
+// manager, declaring In and Out as its InputType and OutputType. The phase
+// fails with ErrTypeMismatch when its input is not an In.
+func (p *TypedPhase[In, Out]) AsPhase() *Phase {
+	phase := NewPhase(p.Name, func(value interface{}) (interface{}, error) {
+		input, ok := value.(In)
+		if !ok {
+			var want In
+			return nil, fmt.Errorf("%w: phase %s expects %T, got %T", ErrTypeMismatch, p.Name, want, value)
+		}
+		return p.Run(input)
+	})
+	phase.InputType = reflect.TypeOf((*In)(nil)).Elem()
+	phase.OutputType = reflect.TypeOf((*Out)(nil)).Elem()
+	return phase
+}
+
+// Chain2 returns a typed phase running p1 and passing its output to p2. The
+// compiler rejects phases whose types do not match. Errors are wrapped in a
+// *PhaseError naming the member that failed, and the hooks of the returned
+// phase wrap the whole chain.
+func Chain2[A, B, C any](p1 *TypedPhase[A, B], p2 *TypedPhase[B, C]) *TypedPhase[A, C] {
+	return NewTypedPhase(p1.Name+"->"+p2.Name, func(a A) (C, error) {
+		var c C
+		b, err := runLink(p1, a)
+		if err != nil {
+			return c, err
+		}
+		return runLink(p2, b)
+	})
+}
+
+// Chain3 is like Chain2 for three phases.
+func Chain3[A, B, C, D any](p1 *TypedPhase[A, B], p2 *TypedPhase[B, C], p3 *TypedPhase[C, D]) *TypedPhase[A, D] {
+	return NewTypedPhase(p1.Name+"->"+p2.Name+"->"+p3.Name, func(a A) (D, error) {
+		var d D
+		b, err := runLink(p1, a)
+		if err != nil {
+			return d, err
+		}
+		c, err := runLink(p2, b)
+		if err != nil {
+			return d, err
+		}
+		return runLink(p3, c)
+	})
+}
+
+// Chain4 is like Chain2 for four phases.
+func Chain4[A, B, C, D, E any](p1 *TypedPhase[A, B], p2 *TypedPhase[B, C], p3 *TypedPhase[C, D], p4 *TypedPhase[D, E]) *TypedPhase[A, E] {
+	head := Chain3(p1, p2, p3)
+	return NewTypedPhase(head.Name+"->"+p4.Name, func(a A) (E, error) {
+		var e E
+		d, err := runLink(head, a)
+		if err != nil {
+			return e, err
+		}
+		return runLink(p4, d)
+	})
+}
+
+// runLink runs p, a member of a chain, on value. Errors are wrapped in a
+// *PhaseError naming p, unless p is a chain which already identified the
+// member that failed.
+func runLink[In, Out any](p *TypedPhase[In, Out], value In) (Out, error) {
+	output, err := p.Run(value)
+	if err != nil {
+		var phaseErr *PhaseError
+		if !errors.As(err, &phaseErr) {
+			err = &PhaseError{Phase: p.Name, Err: err}
+		}
+	}
+	return output, err
+}
+
+// TypedHookOption configures a hook returned by TypedHook.
+type TypedHookOption func(c *typedHookConfig)
+
+// typedHookConfig contains the configuration of a typed hook.
+type typedHookConfig struct {
+	// strict makes mismatched values fail the hook
+	strict bool
+}
+
+// RejectMismatchedTypes makes typed hooks fail with ErrTypeMismatch when the
+// value is not of their type, instead of passing it through.
+func RejectMismatchedTypes() TypedHookOption {
+	return func(c *typedHookConfig) {
+		c.strict = true
+	}
+}
+
+// TypedHook returns a hook calling fn when the value is a T. Values of other
+// types are passed through unchanged, unless RejectMismatchedTypes is used.
+func TypedHook[T any](fn func(value T) (T, error), opts ...TypedHookOption) PhaseHook {
+	var c typedHookConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return func(value interface{}) (interface{}, error) {
+		typed, ok := value.(T)
+		if !ok {
+			if c.strict {
+				return nil, fmt.Errorf("%w: hook expects %T, got %T", ErrTypeMismatch, typed, value)
+			}
+			return value, nil
+		}
+		return fn(typed)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build phaser_mismatch
+// +build phaser_mismatch
+
+package phaser
+
+// This file documents that chains of mismatched typed phases are rejected by
+// the compiler. It is excluded from normal builds; building it with
+//
+//	go vet -tags phaser_mismatch .
+//
+// fails with an error similar to:
+//
+//	in call to Chain2, type *TypedPhase[int, int] of double() does not
+//	match inferred type *TypedPhase[string, C] for *TypedPhase[B, C]
+
+var _ = Chain2(format(), double())
@@ -0,0 +1,128 @@
+package phaser
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parse, double and format are typed phases for the chain tests.
+func parse() *TypedPhase[string, int] {
+	return NewTypedPhase("parse", strconv.Atoi)
+}
+
+func double() *TypedPhase[int, int] {
+	return NewTypedPhase("double", func(value int) (int, error) {
+		return value * 2, nil
+	})
+}
+
+func format() *TypedPhase[int, string] {
+	return NewTypedPhase("format", func(value int) (string, error) {
+		return "#" + strconv.Itoa(value), nil
+	})
+}
+
+func TestChainRunsInOrder(t *testing.T) {
+	value, err := Chain2(parse(), double()).Run("21")
+	require.NoError(t, err)
+	assert.Equal(t, 42, value)
+
+	chain := Chain3(parse(), double(), format())
+	assert.Equal(t, "parse->double->format", chain.Name)
+	output, err := chain.Run("21")
+	require.NoError(t, err)
+	assert.Equal(t, "#42", output)
+
+	output, err = Chain4(parse(), double(), double(), format()).Run("21")
+	require.NoError(t, err)
+	assert.Equal(t, "#84", output)
+}
+
+func TestChainWrapsMemberErrors(t *testing.T) {
+	_, err := Chain4(double(), format(), parse(), double()).Run(1)
+
+	var phaseErr *PhaseError
+	require.True(t, errors.As(err, &phaseErr))
+	assert.Equal(t, "parse", phaseErr.Phase)
+	assert.ErrorIs(t, err, strconv.ErrSyntax)
+}
+
+func TestChainHooksWrapTheChain(t *testing.T) {
+	var calls []string
+	p1 := double()
+	p1.AppendPreHook(func(value int) (int, error) {
+		calls = append(calls, "member")
+		return value, nil
+	})
+
+	chain := Chain2(p1, format())
+	chain.AppendPreHook(func(value int) (int, error) {
+		calls = append(calls, "pre")
+		return value + 1, nil
+	})
+	chain.AppendPostHook(func(value string) (string, error) {
+		calls = append(calls, "post")
+		return value + "!", nil
+	})
+
+	value, err := chain.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, "#4!", value)
+	assert.Equal(t, []string{"pre", "member", "post"}, calls)
+}
+
+func TestTypedPhaseAsPhase(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		Chain2(double(), format()).AsPhase(),
+	))
+
+	value, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, "#4", value)
+
+	_, err = double().AsPhase().run("1")
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+	assert.EqualError(t, err, "type mismatch: phase double expects int, got string")
+}
+
+func TestTypedHook(t *testing.T) {
+	calls := 0
+	hook := TypedHook(func(value int) (int, error) {
+		calls++
+		return value * 2, nil
+	})
+
+	value, err := hook(21)
+	require.NoError(t, err)
+	assert.Equal(t, 42, value)
+
+	// Other types are passed through
+	value, err = hook("21")
+	require.NoError(t, err)
+	assert.Equal(t, "21", value)
+	value, err = hook(nil)
+	require.NoError(t, err)
+	assert.Nil(t, value)
+	assert.Equal(t, 1, calls)
+}
+
+func TestTypedHookRejectMismatchedTypes(t *testing.T) {
+	p := NewPhase("one", addOne)
+	p.appendPreHook(TypedHook(func(value int) (int, error) {
+		return value * 2, nil
+	}, RejectMismatchedTypes()))
+
+	value, err := p.run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+
+	_, err = p.run("1")
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+	assert.EqualError(t, err, "type mismatch: hook expects int, got string")
+}
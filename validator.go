@@ -0,0 +1,125 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidInput wraps the errors returned by the input validator
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrInvalidOutput wraps the errors returned by the output validator
+	ErrInvalidOutput = errors.New("invalid output")
+)
+
+// Validator checks a value, returning an error if it is not valid.
+type Validator func(value interface{}) error
+
+// WithInputValidator makes runs check their initial value using validator
+// before any phase runs. Invalid values abort the run with an error wrapping
+// ErrInvalidInput. Runs resumed from a checkpoint do not check the
+// checkpointed value.
+func WithInputValidator(validator Validator) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.inputValidator = validator
+	}
+}
+
+// WithOutputValidator makes successful runs check their final value using
+// validator. Invalid values fail the run with an error wrapping
+// ErrInvalidOutput.
+func WithOutputValidator(validator Validator) ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.outputValidator = validator
+	}
+}
+
+// WithPanicOnUnimplemented makes runs panic on phases without an execute
+// function, such as zero-value phases, instead of failing them with
+// ErrPhaseNotImplemented, to fail fast during development.
+func WithPanicOnUnimplemented() ManagerOption {
+	return func(m *DefaultPhaseManager) {
+		m.panicUnimplemented = true
+	}
+}
+
+// ValidateOption configures the checks of Validate.
+type ValidateOption func(c *validateConfig)
+
+// validateConfig is the configuration of a Validate call.
+type validateConfig struct {
+	strict bool
+}
+
+// Strict makes Validate also report the settings recommended for robust
+// pipelines: it returns an error wrapping ErrNoNilValuePolicy for each phase
+// without a NilValuePolicy.
+func Strict() ValidateOption {
+	return func(c *validateConfig) {
+		c.strict = true
+	}
+}
+
+// Validate checks the phases of the manager, and of its branches, before
+// running them. It returns an error wrapping ErrPhaseNotImplemented for each
+// phase without an execute function, and ErrNoConverter for each pair of
+// consecutive phases whose declared types cannot be converted, which runs
+// would otherwise fail on.
+func (m *DefaultPhaseManager) Validate(opts ...ValidateOption) error {
+	c := &validateConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	var errs []error
+	for _, p := range m.phases {
+		if p.execute == nil && p.executeContext == nil {
+			errs = append(errs, p.notImplemented())
+		}
+		if c.strict && p.nilPolicy == nilPolicyUnset {
+			errs = append(errs, fmt.Errorf("%w: phase %s, set one using WithNilValuePolicy", ErrNoNilValuePolicy, displayName(p.Name)))
+		}
+		for _, key := range branchKeys(p.branches) {
+			if err := p.branches[key].Validate(opts...); err != nil {
+				errs = append(errs, fmt.Errorf("branch %s of phase %s: %w", key, p.Name, err))
+			}
+		}
+	}
+	errs = append(errs, m.checkConverters()...)
+	return errors.Join(errs...)
+}
+
+// notImplemented returns the error of the phase running without an execute
+// function.
+func (p *Phase) notImplemented() error {
+	return fmt.Errorf("%w: %s", ErrPhaseNotImplemented, displayName(p.Name))
+}
+
+// displayName returns name, or "<unnamed>" for unnamed phases.
+func displayName(name string) string {
+	if name == "" {
+		return "<unnamed>"
+	}
+	return name
+}
+
+// runValidated runs the phases starting at index start, checking the run's
+// input and output using the manager's validators.
+func (m *DefaultPhaseManager) runValidated(ctx context.Context, start int, value interface{}) (interface{}, error) {
+	if start == 0 && m.inputValidator != nil {
+		if err := m.inputValidator(value); err != nil {
+			return value, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		}
+	}
+
+	value, err := m.runFrom(ctx, start, value)
+	if errors.Is(err, ErrStopPipeline) {
+		err = nil
+	}
+	if err == nil && m.outputValidator != nil {
+		if err := m.outputValidator(value); err != nil {
+			return value, fmt.Errorf("%w: %w", ErrInvalidOutput, err)
+		}
+	}
+	return value, err
+}
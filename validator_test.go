@@ -0,0 +1,85 @@
+package phaser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNegative = errors.New("negative value")
+
+// nonNegative is a validator rejecting negative ints.
+func nonNegative(value interface{}) error {
+	if value.(int) < 0 {
+		return errNegative
+	}
+	return nil
+}
+
+func TestInputValidatorRejectsInput(t *testing.T) {
+	calls := 0
+	m := NewPhaseManager(WithInputValidator(nonNegative))
+	require.NoError(t, m.AddPhase(countingPhase("one", &calls, nil)))
+
+	_, err := m.Run(-1)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.ErrorIs(t, err, errNegative)
+	assert.EqualError(t, err, "invalid input: negative value")
+	assert.Zero(t, calls)
+
+	value, err := m.Run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+func TestOutputValidatorRejectsOutput(t *testing.T) {
+	m := NewPhaseManager(WithOutputValidator(nonNegative))
+	require.NoError(t, m.AddPhase(NewPhase("negate", func(value interface{}) (interface{}, error) {
+		return -value.(int), nil
+	})))
+
+	value, err := m.Run(1)
+	assert.ErrorIs(t, err, ErrInvalidOutput)
+	assert.ErrorIs(t, err, errNegative)
+	assert.Equal(t, -1, value)
+
+	value, err = m.Run(-1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
+
+func TestUnimplementedPhase(t *testing.T) {
+	o := &recordingObserver{}
+	m := NewPhaseManager(WithObserver(o))
+	p := &Phase{Name: "plugin"}
+	var handled error
+	p.AppendErrorHandler(func(ec ErrorContext, err error) (bool, interface{}, error) {
+		handled = err
+		return false, nil, nil
+	})
+	require.NoError(t, m.AddPhase(p))
+
+	_, err := m.Run(1)
+	assert.ErrorIs(t, err, ErrPhaseNotImplemented)
+	assert.EqualError(t, err, "phase plugin: phase not implemented: plugin")
+	assert.ErrorIs(t, handled, ErrPhaseNotImplemented)
+	assert.Equal(t, map[string]PhaseStatus{"plugin": StatusFailed}, o.statuses())
+}
+
+func TestValidate(t *testing.T) {
+	branch := NewPhaseManager()
+	require.NoError(t, branch.AddPhase(&Phase{Name: "inner"}))
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(NewPhase("ok", addOne), &Phase{Name: "plugin"}))
+	require.NoError(t, m.AddBranch("route", func(value interface{}) (string, error) {
+		return "a", nil
+	}, map[string]*DefaultPhaseManager{"a": branch}))
+
+	err := m.Validate()
+	assert.ErrorIs(t, err, ErrPhaseNotImplemented)
+	assert.EqualError(t, err, "phase not implemented: plugin\nbranch a of phase route: phase not implemented: inner")
+
+	require.NoError(t, NewPhaseManager().Validate())
+}
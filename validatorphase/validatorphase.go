@@ -0,0 +1,55 @@
+// Package validatorphase adapts github.com/go-playground/validator to
+// validate the values of pipelines using struct tags.
+package validatorphase
+
+import (
+	"errors"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validator is a phaser.ValueValidator checking struct values, and pointers
+// to them, against their `validate` struct tags.
+type Validator struct {
+	validate *validator.Validate
+}
+
+var _ phaser.ValueValidator = (*Validator)(nil)
+
+// New returns a Validator using validate, such as to use its custom
+// validations, or a new validator.Validate when nil.
+func New(validate *validator.Validate) *Validator {
+	if validate == nil {
+		validate = validator.New()
+	}
+	return &Validator{validate: validate}
+}
+
+// Validate checks value against its struct tags, returning a
+// *phaser.ViolationsError listing a violation per invalid field. Their Field
+// is the namespace of the field, such as User.Address.City, and their Rule is
+// the tag of the failed validation.
+func (v *Validator) Validate(value interface{}) error {
+	err := v.validate.Struct(value)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	violations := make([]phaser.Violation, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		violations[i] = phaser.Violation{
+			Field:   fieldErr.Namespace(),
+			Rule:    fieldErr.Tag(),
+			Message: fieldErr.Error(),
+		}
+	}
+	return &phaser.ViolationsError{Violations: violations}
+}
+
+// NewPhase returns a phaser.ValidationPhase named name validating struct tags
+// using a new validator.Validate.
+func NewPhase(name string, opts ...phaser.PhaseOption) *phaser.Phase {
+	return phaser.ValidationPhase(name, New(nil), opts...)
+}
@@ -0,0 +1,52 @@
+package validatorphase
+
+import (
+	"testing"
+
+	phaser "github.com/AlejoAsd/go-phase-manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type address struct {
+	City string `validate:"required"`
+}
+
+type user struct {
+	Email   string `validate:"required,email"`
+	Age     int    `validate:"gte=18"`
+	Address address
+}
+
+func TestValidationPhase(t *testing.T) {
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("validate")))
+
+	valid := &user{Email: "ana@example.com", Age: 30, Address: address{City: "Lima"}}
+	value, err := m.Run(valid)
+	require.NoError(t, err)
+	assert.Same(t, valid, value)
+
+	_, err = m.Run(user{Email: "ana", Age: 12})
+	assert.ErrorIs(t, err, phaser.ErrInvalidInput)
+	var violations *phaser.ViolationsError
+	require.ErrorAs(t, err, &violations)
+	require.Len(t, violations.Violations, 3)
+	assert.Equal(t, "user.Email", violations.Violations[0].Field)
+	assert.Equal(t, "email", violations.Violations[0].Rule)
+	assert.Equal(t, "user.Age", violations.Violations[1].Field)
+	assert.Equal(t, "gte", violations.Violations[1].Rule)
+	assert.Equal(t, "user.Address.City", violations.Violations[2].Field)
+	assert.Equal(t, "required", violations.Violations[2].Rule)
+}
+
+func TestValidationPhaseRejectsNonStructs(t *testing.T) {
+	m := phaser.NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("validate")))
+
+	_, err := m.Run(42)
+	var violations *phaser.ViolationsError
+	require.ErrorAs(t, err, &violations)
+	require.Len(t, violations.Violations, 1)
+	assert.Empty(t, violations.Violations[0].Field)
+}
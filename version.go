@@ -0,0 +1,134 @@
+package phaser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrIncompatibleState matches, through errors.Is, any IncompatibleStateError.
+var ErrIncompatibleState = errors.New("incompatible state")
+
+// PhaseVersion identifies a phase and the version it had when state was
+// persisted.
+type PhaseVersion struct {
+	Name    string
+	Version string
+}
+
+// IncompatibleStateError is returned when resuming from state persisted by a
+// pipeline whose phases differ from the current ones.
+type IncompatibleStateError struct {
+	// Added contains the phases that are not present in the persisted state
+	Added []string
+	// Removed contains the phases of the persisted state that no longer exist
+	Removed []string
+	// VersionChanged contains the phases whose version differs from the one
+	// in the persisted state
+	VersionChanged []string
+	// Reordered is set when both pipelines contain the same phases in a
+	// different order
+	Reordered bool
+}
+
+func (e *IncompatibleStateError) Error() string {
+	var details []string
+	if len(e.Added) > 0 {
+		details = append(details, fmt.Sprintf("added %v", e.Added))
+	}
+	if len(e.Removed) > 0 {
+		details = append(details, fmt.Sprintf("removed %v", e.Removed))
+	}
+	if len(e.VersionChanged) > 0 {
+		details = append(details, fmt.Sprintf("version changed %v", e.VersionChanged))
+	}
+	if e.Reordered {
+		details = append(details, "reordered")
+	}
+	return fmt.Sprintf("%v: %s", ErrIncompatibleState, strings.Join(details, "; "))
+}
+
+func (e *IncompatibleStateError) Is(target error) bool {
+	return target == ErrIncompatibleState
+}
+
+// Fingerprint returns a hash of the ordered phase names and versions. Two
+// managers share a fingerprint only if their phases and versions match.
+func (m *DefaultPhaseManager) Fingerprint() string {
+	return fingerprint(m.phaseVersions())
+}
+
+// phaseVersions returns the name and version of every phase in order.
+func (m *DefaultPhaseManager) phaseVersions() []PhaseVersion {
+	versions := make([]PhaseVersion, len(m.phases))
+	for i, p := range m.phases {
+		versions[i] = PhaseVersion{Name: p.Name, Version: p.Version}
+	}
+	return versions
+}
+
+// fingerprint hashes a list of phase versions.
+func fingerprint(versions []PhaseVersion) string {
+	h := sha256.New()
+	for _, v := range versions {
+		fmt.Fprintf(h, "%q %q\n", v.Name, v.Version)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkCompatible checks whether the state persisted in record can be
+// resumed after the phase at index position. When allowAdded is set, phases
+// added after position are tolerated.
+func (m *DefaultPhaseManager) checkCompatible(record *CheckpointRecord, position int, allowAdded bool) error {
+	versions := record.Phases
+	if record.Fingerprint != fingerprint(versions) {
+		return fmt.Errorf("checkpoint fingerprint does not match its phases: %w", ErrIncompatibleState)
+	}
+	current := m.phaseVersions()
+	if record.Fingerprint == fingerprint(current) {
+		return nil
+	}
+
+	persisted := make(map[string]string, len(versions))
+	for _, v := range versions {
+		persisted[v.Name] = v.Version
+	}
+	present := make(map[string]bool, len(current))
+	diff := &IncompatibleStateError{}
+	addedBeforePosition := false
+	var common []string
+
+	for i, v := range current {
+		present[v.Name] = true
+		version, ok := persisted[v.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, v.Name)
+			addedBeforePosition = addedBeforePosition || i <= position
+		case version != v.Version:
+			diff.VersionChanged = append(diff.VersionChanged, v.Name)
+			common = append(common, v.Name)
+		default:
+			common = append(common, v.Name)
+		}
+	}
+	i := 0
+	for _, v := range versions {
+		if !present[v.Name] {
+			diff.Removed = append(diff.Removed, v.Name)
+			continue
+		}
+		if common[i] != v.Name {
+			diff.Reordered = true
+		}
+		i++
+	}
+
+	if allowAdded && !addedBeforePosition && len(diff.Removed) == 0 &&
+		len(diff.VersionChanged) == 0 && !diff.Reordered {
+		return nil
+	}
+	return diff
+}
@@ -0,0 +1,154 @@
+package phaser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCheckpointer is an in-memory Checkpointer.
+type memoryCheckpointer map[string]interface{}
+
+func (c memoryCheckpointer) Save(phaseName string, value interface{}) error {
+	c[phaseName] = value
+	return nil
+}
+
+func (c memoryCheckpointer) Load(phaseName string) (interface{}, bool, error) {
+	value, ok := c[phaseName]
+	return value, ok, nil
+}
+
+// versionedManager returns a manager with a phase adding one to its input for
+// each of the given phase versions.
+func versionedManager(t *testing.T, c Checkpointer, versions ...PhaseVersion) *DefaultPhaseManager {
+	m := NewPhaseManager(WithCheckpointer(c))
+	for _, v := range versions {
+		require.NoError(t, m.AddPhase(NewPhase(v.Name, addOne, WithVersion(v.Version))))
+	}
+	return m
+}
+
+// oldCheckpoint returns a checkpointer holding a record for the phase named
+// phaseName as saved by a pipeline with the given phase versions.
+func oldCheckpoint(phaseName string, value interface{}, versions ...PhaseVersion) memoryCheckpointer {
+	return memoryCheckpointer{
+		phaseName: &CheckpointRecord{
+			Fingerprint: fingerprint(versions),
+			Phases:      versions,
+			Value:       value,
+		},
+	}
+}
+
+func TestFingerprintDependsOnNamesVersionsAndOrder(t *testing.T) {
+	a := versionedManager(t, nil, PhaseVersion{"a", "1"}, PhaseVersion{"b", "1"})
+	same := versionedManager(t, nil, PhaseVersion{"a", "1"}, PhaseVersion{"b", "1"})
+	version := versionedManager(t, nil, PhaseVersion{"a", "1"}, PhaseVersion{"b", "2"})
+	order := versionedManager(t, nil, PhaseVersion{"b", "1"}, PhaseVersion{"a", "1"})
+
+	assert.Equal(t, a.Fingerprint(), same.Fingerprint())
+	assert.NotEqual(t, a.Fingerprint(), version.Fingerprint())
+	assert.NotEqual(t, a.Fingerprint(), order.Fingerprint())
+}
+
+func TestResumeRunCompatibleState(t *testing.T) {
+	old := []PhaseVersion{{"a", "1"}, {"b", "1"}}
+	m := versionedManager(t, oldCheckpoint("a", 10, old...), old...)
+
+	value, err := m.ResumeRun(0)
+	require.NoError(t, err)
+	assert.Equal(t, 11, value)
+}
+
+func TestResumeRunIncompatibleState(t *testing.T) {
+	old := []PhaseVersion{{"a", "1"}, {"b", "1"}, {"c", "1"}}
+
+	tests := []struct {
+		name    string
+		current []PhaseVersion
+		want    IncompatibleStateError
+	}{
+		{
+			name:    "added",
+			current: []PhaseVersion{{"a", "1"}, {"x", "1"}, {"b", "1"}, {"c", "1"}},
+			want:    IncompatibleStateError{Added: []string{"x"}},
+		},
+		{
+			name:    "removed",
+			current: []PhaseVersion{{"a", "1"}, {"c", "1"}},
+			want:    IncompatibleStateError{Removed: []string{"b"}},
+		},
+		{
+			name:    "version changed",
+			current: []PhaseVersion{{"a", "1"}, {"b", "2"}, {"c", "1"}},
+			want:    IncompatibleStateError{VersionChanged: []string{"b"}},
+		},
+		{
+			name:    "renamed",
+			current: []PhaseVersion{{"a", "1"}, {"b2", "1"}, {"c", "1"}},
+			want:    IncompatibleStateError{Added: []string{"b2"}, Removed: []string{"b"}},
+		},
+		{
+			name:    "reordered",
+			current: []PhaseVersion{{"a", "1"}, {"c", "1"}, {"b", "1"}},
+			want:    IncompatibleStateError{Reordered: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := versionedManager(t, oldCheckpoint("a", 10, old...), tt.current...)
+
+			_, err := m.ResumeRun(0)
+			require.ErrorIs(t, err, ErrIncompatibleState)
+			var stateErr *IncompatibleStateError
+			require.True(t, errors.As(err, &stateErr))
+			assert.Equal(t, tt.want, *stateErr)
+		})
+	}
+}
+
+func TestResumeRunAllowAddedPhases(t *testing.T) {
+	old := []PhaseVersion{{"a", "1"}, {"b", "1"}}
+
+	// Phases added after the checkpoint position are tolerated
+	m := versionedManager(t, oldCheckpoint("a", 10, old...),
+		PhaseVersion{"a", "1"}, PhaseVersion{"x", "1"}, PhaseVersion{"b", "1"}, PhaseVersion{"y", "1"})
+	value, err := m.ResumeRun(0, AllowAddedPhases())
+	require.NoError(t, err)
+	assert.Equal(t, 13, value)
+
+	// Phases added before the checkpoint position are not
+	c := oldCheckpoint("a", 10, old...)
+	c["x"] = c["a"]
+	m = versionedManager(t, c, PhaseVersion{"a", "1"}, PhaseVersion{"x", "1"}, PhaseVersion{"b", "1"})
+	_, err = m.ResumeRun(0, AllowAddedPhases())
+	assert.ErrorIs(t, err, ErrIncompatibleState)
+
+	// Other differences are never tolerated
+	m = versionedManager(t, oldCheckpoint("a", 10, old...), PhaseVersion{"a", "1"}, PhaseVersion{"b", "2"})
+	_, err = m.ResumeRun(0, AllowAddedPhases())
+	assert.ErrorIs(t, err, ErrIncompatibleState)
+}
+
+func TestResumeRunMismatchedFingerprint(t *testing.T) {
+	versions := []PhaseVersion{{"a", "1"}, {"b", "1"}}
+	c := oldCheckpoint("a", 10, versions...)
+	c["a"].(*CheckpointRecord).Fingerprint = fingerprint([]PhaseVersion{{"a", "2"}, {"b", "1"}})
+	m := versionedManager(t, c, versions...)
+
+	_, err := m.ResumeRun(0)
+	assert.ErrorIs(t, err, ErrIncompatibleState)
+	var stateErr *IncompatibleStateError
+	assert.False(t, errors.As(err, &stateErr))
+}
+
+func TestResumeRunUnversionedCheckpoint(t *testing.T) {
+	m := versionedManager(t, memoryCheckpointer{"a": 10}, PhaseVersion{"a", "1"})
+
+	_, err := m.ResumeRun(0)
+	assert.ErrorIs(t, err, ErrIncompatibleState)
+}
@@ -0,0 +1,72 @@
+package phaser
+
+import (
+	"context"
+	"fmt"
+)
+
+// RunFrom starts a new run after the phase named phaseName, as if it had
+// returned value. The phase and every phase before it are reported as
+// skipped, and value is used as the input of the next phase and of the
+// phases depending on phaseName. Unlike ResumeRun, it does not use
+// checkpoints, so the value may come from any previous run, such as one
+// extracted from its report using ValueAfter.
+//
+// The outputs of the skipped phases other than value are not available, so
+// runs of pipelines with phases depending on them, or on named outputs of
+// phaseName, fail with ErrMissingOutput before running any phase.
+func (m *DefaultPhaseManager) RunFrom(phaseName string, value interface{}, opts ...RunOption) (interface{}, error) {
+	index := m.phaseIndex(phaseName)
+	if index < 0 {
+		return value, fmt.Errorf("%w: %s", ErrPhaseNotFound, phaseName)
+	}
+	for _, p := range m.phases[index+1:] {
+		for _, dep := range p.inputs {
+			if dep.Phase == phaseName && dep.Output == "" {
+				continue
+			}
+			if m.phaseIndex(dep.Phase) <= index {
+				return value, fmt.Errorf("%w: phase %s depends on %s, which is skipped when running from %s",
+					ErrMissingOutput, p.Name, dep.key(), phaseName)
+			}
+		}
+	}
+
+	c := newRunConfig(opts)
+	c.warmStart = phaseName
+	return m.run(context.Background(), c, index+1, value)
+}
+
+// ValueAfter returns the value produced by the phase named phase in the run
+// described by report, which is only available when the phase succeeded or
+// rejected its input.
+func ValueAfter(report *RunReport, phase string) (interface{}, bool) {
+	result, ok := report.Result(phase)
+	if !ok || (result.Status != StatusSucceeded && result.Status != StatusRejected) {
+		return nil, false
+	}
+	return result.Output, true
+}
+
+// skipWarmStarted records the phases before start as skipped by RunFrom, and
+// makes value the output of the phase named phase.
+func (m *DefaultPhaseManager) skipWarmStarted(state *runState, phase string, start int, value interface{}) {
+	for _, p := range m.phases[:start] {
+		state.trace.printf(p.Name, "skipped, running from %s", phase)
+		state.record(PhaseResult{Phase: p.Name, Status: StatusSkipped})
+	}
+	if state.outputs != nil {
+		state.outputs[phase] = value
+	}
+}
+
+// phaseIndex returns the index of the phase named name, or -1 if there is
+// none.
+func (m *DefaultPhaseManager) phaseIndex(name string) int {
+	for i, p := range m.phases {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
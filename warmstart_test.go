@@ -0,0 +1,104 @@
+package phaser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunFromLinear(t *testing.T) {
+	calls := 0
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		countingPhase("one", &calls, nil),
+		NewPhase("transform", func(value interface{}) (interface{}, error) {
+			return value.(int) * 10, nil
+		}),
+		NewPhase("load", addOne),
+	))
+
+	var report RunReport
+	value, err := m.Run(1, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 21, value)
+	captured, ok := ValueAfter(&report, "transform")
+	require.True(t, ok)
+	assert.Equal(t, 20, captured)
+
+	calls = 0
+	value, err = m.RunFrom("transform", captured, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 21, value)
+	assert.Zero(t, calls)
+	require.Len(t, report.Phases, 3)
+	for i, status := range []PhaseStatus{StatusSkipped, StatusSkipped, StatusSucceeded} {
+		assert.Equal(t, status, report.Phases[i].Status)
+	}
+
+	_, err = m.RunFrom("missing", captured)
+	assert.ErrorIs(t, err, ErrPhaseNotFound)
+}
+
+func TestRunFromDependencies(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("extract", addOne),
+		NewPhase("transform", func(value interface{}) (interface{}, error) {
+			return value.(int) * 10, nil
+		}),
+		NewPhase("log", addOne),
+	))
+	require.NoError(t, m.AddPhaseWithDeps(NewPhase("load", addOne), DependsOnPhase("transform")))
+
+	value, err := m.RunFrom("transform", 20)
+	require.NoError(t, err)
+	assert.Equal(t, 21, value)
+}
+
+func TestRunFromSkippedDependency(t *testing.T) {
+	tests := []struct {
+		name string
+		dep  Dependency
+	}{
+		{name: "earlier phase", dep: DependsOnPhase("extract")},
+		{name: "named output", dep: DependsOnOutput("transform", "rows")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			m := NewPhaseManager()
+			require.NoError(t, m.AddPhases(
+				NewPhase("extract", addOne),
+				NewPhase("transform", addOne),
+				countingPhase("log", &calls, nil),
+			))
+			require.NoError(t, m.AddPhaseWithDeps(NewPhase("load", addOne), tt.dep))
+
+			_, err := m.RunFrom("transform", 1)
+			assert.ErrorIs(t, err, ErrMissingOutput)
+			assert.ErrorContains(t, err, "load depends on "+tt.dep.key())
+			assert.Zero(t, calls)
+		})
+	}
+}
+
+func TestValueAfterFailedPhase(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhases(
+		NewPhase("one", addOne),
+		NewPhase("two", failWith(assert.AnError)),
+	))
+
+	var report RunReport
+	_, err := m.Run(0, WithReport(&report))
+	require.Error(t, err)
+	_, ok := ValueAfter(&report, "two")
+	assert.False(t, ok)
+	_, ok = ValueAfter(&report, "missing")
+	assert.False(t, ok)
+	value, ok := ValueAfter(&report, "one")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+}
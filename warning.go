@@ -0,0 +1,150 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Warning is a non-fatal issue reported by a phase through Warn, or returned
+// by its hooks and execute function through AsWarnings.
+type Warning struct {
+	// Phase is the name of the phase that reported the warning
+	Phase string
+	// Message describes the warning
+	Message string
+	// Err is the error of warnings returned through AsWarnings, whose
+	// message is Message
+	Err error
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("phase %s: %s", w.Phase, w.Message)
+}
+
+// warningCollector accumulates the warnings of a RunWithWarnings call. A nil
+// *warningCollector drops every warning.
+type warningCollector struct {
+	mu       sync.Mutex
+	warnings []Warning
+	// closed is set once the run ends, so that warnings reported by
+	// executions abandoned after a timeout are dropped
+	closed bool
+}
+
+// add adds warning to the collected warnings.
+func (c *warningCollector) add(warning Warning) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.warnings = append(c.warnings, warning)
+	}
+}
+
+// close stops collecting warnings, returning the collected ones.
+func (c *warningCollector) close() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.warnings
+}
+
+// Warn reports a non-fatal warning for the phase running with ctx. It is meant
+// to be called from context hooks and execute functions using the context
+// they receive, and the warning is returned by the RunWithWarnings call
+// running the phase. Warnings reported in other runs are dropped.
+func Warn(ctx context.Context, message string) {
+	runStateFrom(ctx).warnings.add(Warning{Phase: phaseResultFrom(ctx).Phase, Message: message})
+}
+
+// WarningsError is returned by AsWarnings.
+type WarningsError struct {
+	// Errs contains the warnings
+	Errs []error
+}
+
+func (e *WarningsError) Error() string {
+	return "warnings: " + errors.Join(e.Errs...).Error()
+}
+
+func (e *WarningsError) Unwrap() []error {
+	return e.Errs
+}
+
+// AsWarning returns an error that hooks and execute functions return along
+// with their output to report err as a warning. It is AsWarnings with a
+// single error.
+func AsWarning(err error) error {
+	return AsWarnings(err)
+}
+
+// AsWarnings returns an error that hooks and execute functions return along
+// with their output to report errs as warnings, such as for best-effort work
+// that skipped some records. The hook or execute function succeeds, and its
+// output flows on, while the warnings are added to the phase's result, in
+// the run's report and for observers, and are returned by RunWithWarnings.
+// It returns nil when every error of errs is nil.
+//
+// Only the errors returned as is are treated as warnings: errors wrapping or
+// joining a *WarningsError fail the phase as usual.
+func AsWarnings(errs ...error) error {
+	var warnings []error
+	for _, err := range errs {
+		if err != nil {
+			warnings = append(warnings, err)
+		}
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+	return &WarningsError{Errs: warnings}
+}
+
+// takeWarnings records the warnings of err, returned by a hook or execute
+// function of the phase running with ctx, when it is a *WarningsError, and
+// returns nil. Other errors are returned as is.
+func (p *Phase) takeWarnings(ctx context.Context, err error) error {
+	warnings, ok := err.(*WarningsError)
+	if !ok {
+		return err
+	}
+	result, collector := phaseResultFrom(ctx), runStateFrom(ctx).warnings
+	for _, warning := range warnings.Errs {
+		result.Warnings = append(result.Warnings, warning)
+		collector.add(Warning{Phase: p.Name, Message: warning.Error(), Err: warning})
+	}
+	return nil
+}
+
+// JoinWarnings returns an error joining warnings, or nil when there are none,
+// such as to let command line tools exit with a distinct status when runs
+// succeed with warnings. The error of each warning reads as the warning, and
+// wraps its Err.
+func JoinWarnings(warnings []Warning) error {
+	errs := make([]error, len(warnings))
+	for i, warning := range warnings {
+		if warning.Err != nil {
+			errs[i] = fmt.Errorf("phase %s: %w", warning.Phase, warning.Err)
+		} else {
+			errs[i] = errors.New(warning.String())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RunWithWarnings is like Run, but also returns the warnings reported through
+// Warn and AsWarnings during the run, in the order they were reported. Warnings are returned
+// even when the run fails.
+func (m *DefaultPhaseManager) RunWithWarnings(value interface{}, opts ...RunOption) (interface{}, []Warning, error) {
+	c := &warningCollector{}
+	opts = append(opts, func(rc *runConfig) {
+		rc.warnings = c
+	})
+
+	value, err := m.Run(value, opts...)
+	return value, c.close(), err
+}
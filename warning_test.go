@@ -0,0 +1,169 @@
+package phaser
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithWarnings(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhaseContext("parse", func(ctx context.Context, value interface{}) (interface{}, error) {
+		Warn(ctx, "field truncated")
+		return value.(int) + 1, nil
+	})))
+	store := NewPhase("store", addOne)
+	store.AppendPostHookContext(func(ctx context.Context, value interface{}) (interface{}, error) {
+		Warn(ctx, "slow write")
+		return value, nil
+	})
+	require.NoError(t, m.AddPhase(store))
+
+	value, warnings, err := m.RunWithWarnings(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, []Warning{
+		{Phase: "parse", Message: "field truncated"},
+		{Phase: "store", Message: "slow write"},
+	}, warnings)
+	assert.Equal(t, "phase parse: field truncated", warnings[0].String())
+
+	// Warnings are not shared between runs
+	_, warnings, err = m.RunWithWarnings(0)
+	require.NoError(t, err)
+	assert.Len(t, warnings, 2)
+}
+
+func TestRunWithWarningsOnError(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhaseContext("parse", func(ctx context.Context, value interface{}) (interface{}, error) {
+		Warn(ctx, "field truncated")
+		return value, nil
+	})))
+	require.NoError(t, m.AddPhase(NewPhase("store", failWith(assert.AnError))))
+
+	_, warnings, err := m.RunWithWarnings(0)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, []Warning{{Phase: "parse", Message: "field truncated"}}, warnings)
+}
+
+func TestRunWithWarningsConcurrentRuns(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhaseContext("parse", func(ctx context.Context, value interface{}) (interface{}, error) {
+		for i := 0; i < value.(int); i++ {
+			Warn(ctx, "warning")
+			time.Sleep(time.Millisecond)
+		}
+		return value, nil
+	})))
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, warnings, err := m.RunWithWarnings(i)
+			assert.NoError(t, err)
+			assert.Len(t, warnings, i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRunWithWarningsDropsLateWarnings(t *testing.T) {
+	release, done := make(chan struct{}), make(chan struct{})
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhaseContext("slow", func(ctx context.Context, value interface{}) (interface{}, error) {
+		defer close(done)
+		<-release
+		Warn(ctx, "late")
+		return value, nil
+	}, WithTimeout(time.Millisecond))))
+
+	_, warnings, err := m.RunWithWarnings(0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	close(release)
+	<-done
+	assert.Empty(t, warnings)
+}
+
+func TestWarnOutsideRunWithWarnings(t *testing.T) {
+	p := NewPhaseContext("parse", func(ctx context.Context, value interface{}) (interface{}, error) {
+		Warn(ctx, "field truncated")
+		return value, nil
+	})
+
+	value, err := p.run(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
+
+func TestReturnedWarnings(t *testing.T) {
+	errSkipped := errors.New("record skipped")
+	errDefaulted := errors.New("field defaulted")
+	errSlow := errors.New("slow write")
+	o := &recordingObserver{}
+	m := NewPhaseManager(WithObserver(o))
+	require.NoError(t, m.AddPhase(NewPhase("parse", func(value interface{}) (interface{}, error) {
+		return value.(int) + 1, AsWarnings(errSkipped, nil, errDefaulted)
+	})))
+	store := NewPhase("store", addOne)
+	store.appendPostHook(func(value interface{}) (interface{}, error) {
+		return value, AsWarning(errSlow)
+	})
+	require.NoError(t, m.AddPhase(store))
+
+	var report RunReport
+	value, warnings, err := m.RunWithWarnings(0, WithReport(&report))
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, []Warning{
+		{Phase: "parse", Message: "record skipped", Err: errSkipped},
+		{Phase: "parse", Message: "field defaulted", Err: errDefaulted},
+		{Phase: "store", Message: "slow write", Err: errSlow},
+	}, warnings)
+	assert.Equal(t, []error{errSkipped, errDefaulted}, report.Phases[0].Warnings)
+	assert.Equal(t, []error{errSlow}, report.Phases[1].Warnings)
+	assert.Equal(t, report.Phases, o.results)
+	assert.Equal(t, StatusSucceeded, report.Phases[0].Status)
+
+	joined := JoinWarnings(warnings)
+	assert.ErrorIs(t, joined, errDefaulted)
+	assert.ErrorIs(t, joined, errSlow)
+	assert.EqualError(t, joined, "phase parse: record skipped\nphase parse: field defaulted\nphase store: slow write")
+	assert.NoError(t, JoinWarnings(nil))
+}
+
+func TestReturnedWarningsWithinParallelHooks(t *testing.T) {
+	p := NewPhase("check", addOne)
+	p.ParallelHooks = true
+	for _, err := range []error{errors.New("first"), errors.New("second")} {
+		err := err
+		p.appendPreHook(func(value interface{}) (interface{}, error) {
+			return value, AsWarning(err)
+		})
+	}
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(p))
+
+	value, warnings, err := m.RunWithWarnings(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, "first", warnings[0].Message)
+	assert.Equal(t, "second", warnings[1].Message)
+}
+
+func TestWrappedWarningsFail(t *testing.T) {
+	m := NewPhaseManager()
+	require.NoError(t, m.AddPhase(NewPhase("parse", failWith(errors.Join(assert.AnError, AsWarning(errors.New("skipped")))))))
+
+	_, warnings, err := m.RunWithWarnings(0)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Empty(t, warnings)
+	assert.Nil(t, AsWarnings(nil))
+}